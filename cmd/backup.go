@@ -1,28 +1,79 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 
 	"github.com/zookeeper-backup/pkg/engine"
 )
 
+// clusterSpecificBackupFlags cannot be combined with --all-clusters
+var clusterSpecificBackupFlags = []string{"zk-data-dir", "zk-log-dir", "zk-host", "admin-url", "backup-id"}
+
 // NewBackupCmd creates the backup command
 func NewBackupCmd() *cobra.Command {
-	var config engine.BackupConfig
+	var (
+		config      engine.BackupConfig
+		allClusters bool
+		maxParallel int
+	)
 
 	cmd := &cobra.Command{
 		Use:   "backup",
 		Short: "Backup ZooKeeper data",
 		Long: `Create a full backup of ZooKeeper data including snapshots and transaction logs.
 
+With --compression, files keep their names and are compressed with gzip or zstd;
+restore, verify and info decompress them transparently.
+
+The current ZXID and version are read with the srvr four-letter word. When
+four-letter words are disabled, --stats-backend admin reads them from the
+AdminServer HTTP API instead; the default auto tries srvr first and falls back
+to the AdminServer.
+
+With --mode stream, a fresh snapshot is pulled over the AdminServer snapshot
+command (ZooKeeper 3.9+) instead of reading --zk-data-dir and --zk-log-dir, so
+clusters whose disks cannot be mounted can be backed up. The backup holds that
+single snapshot and restores to its ZXID.
+
+With --mode logical, the znode tree is walked over a client connection to
+--zk-host and the data, stat and ACL of every znode is written to
+logical/znodes.jsonl, so clusters without any filesystem access can be backed
+up. --path limits the walk to subtrees and can be repeated; /zookeeper is only
+included when given explicitly. The server ZXID before and after the walk is
+recorded as the consistency window.
+
+With --cluster, the ZooKeeper settings come from that profile of the clusters
+section of the config file and the backup is written to <output-dir>/<cluster>.
+--all-clusters backs up every profile, --max-parallel at a time, and prints a
+summary; it fails if any cluster failed.
+
 Example:
   zkbackup backup \
     --zk-data-dir /zookeeper/data/version-2 \
     --zk-log-dir /zookeeper/datalog/version-2 \
     --output-dir /backup/zookeeper \
-    --zk-host localhost:2181`,
+    --zk-host localhost:2181
+
+  zkbackup backup --mode stream \
+    --admin-url http://zk-0:8080 \
+    --admin-auth "digest root:secret" \
+    --output-dir /backup/zookeeper
+
+  zkbackup backup --mode logical \
+    --zk-host zk-0:2181 \
+    --path /app --path /config \
+    --output-dir /backup/zookeeper
+
+  zkbackup backup --cluster orders
+  zkbackup backup --all-clusters --max-parallel 8`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			config.Verbose = verbose
+			if allClusters {
+				return backupAllClusters(cmd, config, maxParallel)
+			}
+			settings.ApplyBackup(&config)
 
 			backupEngine := engine.NewBackupEngine(&config)
 			return backupEngine.Run()
@@ -30,18 +81,68 @@ Example:
 	}
 
 	// Flags
-	cmd.Flags().StringVar(&config.ZkDataDir, "zk-data-dir", "", "ZooKeeper dataDir path (required)")
-	cmd.Flags().StringVar(&config.ZkLogDir, "zk-log-dir", "", "ZooKeeper dataLogDir path (required)")
-	cmd.Flags().StringVar(&config.OutputDir, "output-dir", "", "Backup output directory (required)")
+	cmd.Flags().StringVar(&config.Mode, "mode", "physical", "Backup mode: physical|stream|logical")
+	cmd.Flags().StringVar(&config.ZkDataDir, "zk-data-dir", "", "ZooKeeper dataDir path (required in physical mode)")
+	cmd.Flags().StringVar(&config.ZkLogDir, "zk-log-dir", "", "ZooKeeper dataLogDir path (required in physical mode)")
+	cmd.Flags().StringVar(&config.OutputDir, "output-dir", "/backup/zookeeper", "Backup output directory")
 	cmd.Flags().StringVar(&config.ZkHost, "zk-host", "localhost:2181", "ZooKeeper host address")
+	cmd.Flags().StringSliceVar(&config.Paths, "path", nil, "Znode subtree to back up in logical mode, repeatable (default: /)")
+	cmd.Flags().StringVar(&config.StatsBackend, "stats-backend", "auto", "How server state is read: auto|4lw|admin")
+	cmd.Flags().StringVar(&config.AdminURL, "admin-url", "", "ZooKeeper AdminServer URL (default: http://<zk-host>:8080)")
+	cmd.Flags().StringVar(&config.AdminAuth, "admin-auth", "", "Authorization header for AdminServer commands, e.g. \"digest root:secret\"")
 	cmd.Flags().StringVar(&config.BackupID, "backup-id", "", "Backup ID (optional, auto-generated if not set)")
 	cmd.Flags().BoolVar(&config.Verify, "verify", true, "Verify backup after completion")
 	cmd.Flags().StringVar(&config.Compression, "compression", "none", "Compression: none|gzip|zstd")
+	cmd.Flags().IntVar(&config.CompressionLevel, "compression-level", 0, "Compression level: gzip 1-9, zstd 1-22 (0: codec default)")
+	cmd.Flags().BoolVar(&allClusters, "all-clusters", false, "Back up every cluster profile of the config file")
+	cmd.Flags().IntVar(&maxParallel, "max-parallel", 4, "Cluster backups running at the same time with --all-clusters")
 
-	// Required flags
-	cmd.MarkFlagRequired("zk-data-dir")
-	cmd.MarkFlagRequired("zk-log-dir")
-	cmd.MarkFlagRequired("output-dir")
+	// Flags overriding zkbackup.yaml, the ZooKeeper directories are checked by mode in BackupConfig.Validate
+	bindConfigFlag(cmd, "zk-data-dir", "zookeeper.data_dir")
+	bindConfigFlag(cmd, "zk-log-dir", "zookeeper.log_dir")
+	bindConfigFlag(cmd, "zk-host", "zookeeper.host")
+	bindConfigFlag(cmd, "stats-backend", "zookeeper.stats_backend")
+	bindConfigFlag(cmd, "admin-url", "zookeeper.admin_url")
+	bindConfigFlag(cmd, "output-dir", "backup.base_dir")
+	bindConfigFlag(cmd, "verify", "backup.auto_verify")
+	bindConfigFlag(cmd, "compression", "backup.compression")
+	bindConfigFlag(cmd, "compression-level", "backup.compression_level")
 
 	return cmd
 }
+
+// backupAllClusters backs up every cluster profile, each with the flags of the
+// command applied on top of its settings
+func backupAllClusters(cmd *cobra.Command, base engine.BackupConfig, maxParallel int) error {
+	if clusterName != "" {
+		return fmt.Errorf("--cluster and --all-clusters are mutually exclusive")
+	}
+	for _, flag := range clusterSpecificBackupFlags {
+		if cmd.Flags().Changed(flag) {
+			return fmt.Errorf("--%s cannot be used with --all-clusters, set it in the cluster profiles", flag)
+		}
+	}
+
+	names := settings.ClusterNames()
+	if len(names) == 0 {
+		return fmt.Errorf("no clusters configured, add profiles to the clusters section of the config file")
+	}
+
+	backups := make([]*engine.BackupConfig, 0, len(names))
+	for _, name := range names {
+		clusterSettings, err := loadSettings(cmd, name)
+		if err != nil {
+			return err
+		}
+		config := base
+		clusterSettings.ApplyBackup(&config)
+		backups = append(backups, &config)
+	}
+
+	clusterEngine := engine.NewClusterBackupEngine(&engine.ClusterBackupConfig{
+		Backups:     backups,
+		MaxParallel: maxParallel,
+		Verbose:     base.Verbose,
+	})
+	return clusterEngine.Run()
+}
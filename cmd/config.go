@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/zookeeper-backup/pkg/config"
+)
+
+// configKeyAnnotation marks a flag as the command line override of a config key
+const configKeyAnnotation = "zkbackup_config_key"
+
+// settings is the merged configuration of the running command, loaded before it runs
+var settings = config.Default()
+
+// NewConfigCmd creates the config command
+func NewConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "Print the effective configuration",
+		Long: `Print the effective configuration after merging, from lowest to highest
+precedence, the built-in defaults, /etc/zkbackup/config.yaml, ~/.zkbackup.yaml,
+./zkbackup.yaml and ZKBACKUP_* environment variables (e.g. ZKBACKUP_ZOOKEEPER_HOST
+for zookeeper.host). With --config, only that file is read. With --cluster, the
+cluster profile is applied on top of the environment and backup.base_dir points
+to the namespace of the cluster. Command line flags of the other commands take
+precedence over everything shown here.
+
+Example:
+  zkbackup config show
+  zkbackup config show --cluster orders
+  ZKBACKUP_BACKUP_COMPRESSION=zstd zkbackup config show`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			content, err := settings.YAML()
+			if err != nil {
+				return err
+			}
+
+			if len(settings.Files) == 0 {
+				fmt.Println("# No config file found, showing defaults and environment")
+			} else {
+				fmt.Printf("# Config files: %s\n", strings.Join(settings.Files, ", "))
+			}
+			if settings.Cluster != "" {
+				fmt.Printf("# Cluster: %s\n", settings.Cluster)
+			}
+			fmt.Print(content)
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+// bindConfigFlag makes a flag override a config key when it is set on the command line
+func bindConfigFlag(cmd *cobra.Command, flag, key string) {
+	_ = cmd.Flags().SetAnnotation(flag, configKeyAnnotation, []string{key})
+}
+
+// loadSettings merges the config files, environment and the bound flags of cmd,
+// applying the profile of cluster when it is not empty
+func loadSettings(cmd *cobra.Command, cluster string) (*config.Config, error) {
+	flags := make(map[string]*pflag.Flag)
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if keys := flag.Annotations[configKeyAnnotation]; len(keys) > 0 {
+			flags[keys[0]] = flag
+		}
+	})
+
+	return config.Load(config.LoadOptions{File: cfgFile, Flags: flags, Cluster: cluster})
+}
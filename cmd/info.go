@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/spf13/cobra"
+
+	"github.com/zookeeper-backup/pkg/metadata"
 )
 
 // NewInfoCmd creates the info command
@@ -18,17 +21,40 @@ func NewInfoCmd() *cobra.Command {
 		Short: "Show backup details",
 		Long: `Show detailed information about a specific backup.
 
+When the config file has cluster profiles, the backup is looked up in every
+cluster namespace as list shows them; --cluster is only needed when the same
+backup ID exists in several clusters.
+
 Example:
   zkbackup info backup-20250115-103000 --backup-base-dir /backup/zookeeper`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			backupID := args[0]
+			backupBaseDir = settings.Backup.BaseDir
+
+			entry, err := findBackup(cmd, backupBaseDir, args[0])
+			if err != nil {
+				return err
+			}
+			if entry.Info == nil {
+				return fmt.Errorf("backup %s is incomplete: %s", entry.BackupID, entry.Error)
+			}
 
-			fmt.Printf("Backup ID: %s\n", backupID)
-			fmt.Printf("Base Dir: %s\n\n", backupBaseDir)
+			switch format {
+			case "text":
+				fmt.Print(entry.Info.GenerateTextReport())
+				fmt.Printf("\nLocation: %s\n\n", entry.Path)
+				fmt.Print(entry.Info.GenerateFileReport())
+				fmt.Printf("\nOverall Status: %s\n", formatStatus(entry.Status))
+			case "json":
+				data, err := json.MarshalIndent(entry.Info, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+			default:
+				return fmt.Errorf("unsupported format: %s", format)
+			}
 
-			// TODO: Implement info logic
-			fmt.Println("Backup not found")
 			return nil
 		},
 	}
@@ -36,5 +62,21 @@ Example:
 	cmd.Flags().StringVar(&backupBaseDir, "backup-base-dir", "/backup/zookeeper", "Backup base directory")
 	cmd.Flags().StringVar(&format, "format", "text", "Output format: text|json")
 
+	bindConfigFlag(cmd, "backup-base-dir", "backup.base_dir")
+
 	return cmd
 }
+
+// findBackup locates a backup by ID. With cluster profiles and no --cluster, it
+// searches the same namespaces list shows and fails if the ID is ambiguous.
+func findBackup(cmd *cobra.Command, baseDir, backupID string) (*metadata.CatalogEntry, error) {
+	if settings.Cluster != "" || len(settings.ClusterNames()) == 0 {
+		return metadata.FindBackup(baseDir, backupID)
+	}
+
+	catalog, err := scanCatalog(cmd, baseDir)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.SelectBackup(catalog, backupID)
+}
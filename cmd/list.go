@@ -1,9 +1,18 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/zookeeper-backup/pkg/metadata"
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
 )
 
 // NewListCmd creates the list command
@@ -20,13 +29,40 @@ func NewListCmd() *cobra.Command {
 		Short: "List all backups",
 		Long: `List all backups in the backup base directory.
 
+When the config file has cluster profiles, the backups of every cluster are
+listed with their cluster name; --cluster lists only the namespace of one
+cluster.
+
 Example:
-  zkbackup list --backup-base-dir /backup/zookeeper`,
+  zkbackup list --backup-base-dir /backup/zookeeper
+  zkbackup list --cluster orders`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			fmt.Printf("Listing backups in: %s\n\n", backupBaseDir)
+			backupBaseDir = settings.Backup.BaseDir
+
+			catalog, err := scanCatalog(cmd, backupBaseDir)
+			if err != nil {
+				return err
+			}
+			if err = metadata.SortCatalog(catalog, sortBy); err != nil {
+				return err
+			}
+
+			total := len(catalog)
+			if limit > 0 && len(catalog) > limit {
+				catalog = catalog[:limit]
+			}
+
+			switch format {
+			case "table":
+				printCatalogTable(backupBaseDir, catalog, total)
+			case "json":
+				return printCatalogJSON(catalog)
+			case "simple":
+				printCatalogSimple(catalog)
+			default:
+				return fmt.Errorf("unsupported format: %s", format)
+			}
 
-			// TODO: Implement list logic
-			fmt.Println("No backups found")
 			return nil
 		},
 	}
@@ -36,5 +72,153 @@ Example:
 	cmd.Flags().StringVar(&sortBy, "sort-by", "time", "Sort by: time|size|zxid")
 	cmd.Flags().IntVar(&limit, "limit", 20, "Limit number of results")
 
+	bindConfigFlag(cmd, "backup-base-dir", "backup.base_dir")
+
 	return cmd
 }
+
+// scanCatalog loads the backups under baseDir. With cluster profiles, it also
+// loads the namespace of every cluster, or only the selected one with --cluster.
+func scanCatalog(cmd *cobra.Command, baseDir string) ([]*metadata.CatalogEntry, error) {
+	if settings.Cluster != "" {
+		return metadata.ScanClusterCatalog(map[string]string{settings.Cluster: baseDir})
+	}
+	names := settings.ClusterNames()
+	if len(names) == 0 {
+		return metadata.ScanCatalog(baseDir)
+	}
+
+	namespaces := make(map[string]string, len(names))
+	isNamespace := make(map[string]bool, len(names))
+	for _, name := range names {
+		clusterSettings, err := loadSettings(cmd, name)
+		if err != nil {
+			return nil, err
+		}
+		namespaces[name] = clusterSettings.Backup.BaseDir
+		isNamespace[filepath.Clean(clusterSettings.Backup.BaseDir)] = true
+	}
+
+	catalog, err := metadata.ScanClusterCatalog(namespaces)
+	if err != nil {
+		return nil, err
+	}
+	if !zkfile.DirExists(baseDir) {
+		return catalog, nil
+	}
+
+	// Backups taken without a cluster live directly under the base directory
+	// next to the cluster namespaces
+	entries, err := metadata.ScanCatalog(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !isNamespace[filepath.Clean(entry.Path)] {
+			catalog = append(catalog, entry)
+		}
+	}
+
+	return catalog, nil
+}
+
+// printCatalogTable prints backups as an aligned table
+func printCatalogTable(baseDir string, catalog []*metadata.CatalogEntry, total int) {
+	fmt.Printf("Backup base dir: %s\n", baseDir)
+	fmt.Printf("Total backups: %d\n\n", total)
+
+	if len(catalog) == 0 {
+		fmt.Println("No backups found")
+		return
+	}
+
+	// The cluster column is only shown when some backup belongs to a cluster
+	showCluster := false
+	for _, entry := range catalog {
+		showCluster = showCluster || entry.Cluster != ""
+	}
+	cluster := func(entry *metadata.CatalogEntry) string {
+		if !showCluster {
+			return ""
+		}
+		if entry.Cluster == "" {
+			return "-\t"
+		}
+		return entry.Cluster + "\t"
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if showCluster {
+		fmt.Fprint(w, "CLUSTER\t")
+	}
+	fmt.Fprintln(w, "BACKUP ID\tTIMESTAMP\tZXID\tSNAPSHOTS\tTXNLOGS\tSIZE\tSTATUS")
+	for _, entry := range catalog {
+		if entry.Info == nil {
+			fmt.Fprintf(w, "%s%s\t%s\t-\t-\t-\t%s\t%s\n", cluster(entry),
+				entry.BackupID, formatCatalogTime(entry), utils.FormatBytes(entry.TotalSize), formatStatus(entry.Status))
+			continue
+		}
+		fmt.Fprintf(w, "%s%s\t%s\t0x%s\t%d\t%d\t%s\t%s\n", cluster(entry),
+			entry.BackupID, formatCatalogTime(entry), entry.BackupZxid.Hex,
+			entry.SnapshotCount, entry.TxnLogCount, utils.FormatBytes(entry.TotalSize), formatStatus(entry.Status))
+	}
+	_ = w.Flush()
+
+	if len(catalog) < total {
+		fmt.Printf("\nShowing %d of %d backups (use --limit to show more)\n", len(catalog), total)
+	}
+	fmt.Println("\nUse 'zkbackup info <backup-id>' to see details")
+}
+
+// printCatalogJSON prints backups as a JSON array
+func printCatalogJSON(catalog []*metadata.CatalogEntry) error {
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// printCatalogSimple prints one space-separated backup per line for scripting.
+// The cluster comes last, "-" for backups taken without a cluster.
+func printCatalogSimple(catalog []*metadata.CatalogEntry) {
+	for _, entry := range catalog {
+		timestamp := "-"
+		if !entry.Timestamp.IsZero() {
+			timestamp = entry.Timestamp.Format(time.RFC3339)
+		}
+		zxid := "-"
+		if entry.Info != nil {
+			zxid = "0x" + entry.BackupZxid.Hex
+		}
+		cluster := "-"
+		if entry.Cluster != "" {
+			cluster = entry.Cluster
+		}
+		fmt.Printf("%s %s %s %d %d %d %s %s\n", entry.BackupID, timestamp, zxid,
+			entry.SnapshotCount, entry.TxnLogCount, entry.TotalSize, entry.Status, cluster)
+	}
+}
+
+// formatCatalogTime formats the backup timestamp for display
+func formatCatalogTime(entry *metadata.CatalogEntry) string {
+	if entry.Timestamp.IsZero() {
+		return "-"
+	}
+	return entry.Timestamp.Format("2006-01-02 15:04:05")
+}
+
+// formatStatus decorates a backup status for display
+func formatStatus(status string) string {
+	switch status {
+	case metadata.BackupStatusValid:
+		return "✅ valid"
+	case metadata.BackupStatusPartial:
+		return "⚠️ partial"
+	case metadata.BackupStatusCorrupted:
+		return "❌ corrupted"
+	default:
+		return "❓ " + status
+	}
+}
@@ -1,50 +1,60 @@
 package cmd
 
 import (
-	"fmt"
-
 	"github.com/spf13/cobra"
+
+	"github.com/zookeeper-backup/pkg/engine"
 )
 
 // NewPruneCmd creates the prune command
 func NewPruneCmd() *cobra.Command {
-	var (
-		backupBaseDir string
-		keepDays      int
-		keepCount     int
-		keepMinCount  int
-		dryRun        bool
-		force         bool
-	)
+	var config engine.PruneConfig
 
 	cmd := &cobra.Command{
 		Use:   "prune",
 		Short: "Clean up old backups",
 		Long: `Remove old backups based on retention policy.
 
+The newest --keep-min-count usable backups are always kept. Older backups
+are deleted when they exceed --keep-days or fall outside --keep-count.
+Corrupted and incomplete backups are never deleted.
+
+Tiered retention keeps the newest backup of each of the last N hours, days,
+weeks and months, even when it is older than --keep-days. With --keep-days 0
+and no --keep-count, backups outside every tier are deleted.
+
 Example:
-  zkbackup prune --keep-days 7 --keep-min-count 3`,
+  zkbackup prune --keep-days 7 --keep-min-count 3
+  zkbackup prune --keep-days 0 --keep-daily 7 --keep-weekly 4 --keep-monthly 12`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			fmt.Printf("Pruning backups in: %s\n", backupBaseDir)
-			fmt.Printf("Keep days: %d\n", keepDays)
-			fmt.Printf("Keep min count: %d\n\n", keepMinCount)
-
-			if dryRun {
-				fmt.Println("🔍 Dry-run mode - no actual changes will be made")
-			}
+			config.Verbose = verbose
+			settings.ApplyPrune(&config)
 
-			// TODO: Implement prune logic
-			fmt.Println("No backups to prune")
-			return nil
+			pruneEngine := engine.NewPruneEngine(&config)
+			return pruneEngine.Run()
 		},
 	}
 
-	cmd.Flags().StringVar(&backupBaseDir, "backup-base-dir", "/backup/zookeeper", "Backup base directory")
-	cmd.Flags().IntVar(&keepDays, "keep-days", 7, "Keep backups for this many days")
-	cmd.Flags().IntVar(&keepCount, "keep-count", 0, "Keep this many recent backups (0=unlimited)")
-	cmd.Flags().IntVar(&keepMinCount, "keep-min-count", 3, "Minimum number of backups to keep")
-	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Simulate prune without deleting")
-	cmd.Flags().BoolVar(&force, "force", false, "Force prune without confirmation")
+	cmd.Flags().StringVar(&config.BackupBaseDir, "backup-base-dir", "/backup/zookeeper", "Backup base directory")
+	cmd.Flags().IntVar(&config.KeepDays, "keep-days", 7, "Keep backups for this many days")
+	cmd.Flags().IntVar(&config.KeepCount, "keep-count", 0, "Keep this many recent backups (0=unlimited)")
+	cmd.Flags().IntVar(&config.KeepMinCount, "keep-min-count", 3, "Minimum number of backups to keep")
+	cmd.Flags().IntVar(&config.KeepHourly, "keep-hourly", 0, "Keep the newest backup of each of the last N hours")
+	cmd.Flags().IntVar(&config.KeepDaily, "keep-daily", 0, "Keep the newest backup of each of the last N days")
+	cmd.Flags().IntVar(&config.KeepWeekly, "keep-weekly", 0, "Keep the newest backup of each of the last N weeks")
+	cmd.Flags().IntVar(&config.KeepMonthly, "keep-monthly", 0, "Keep the newest backup of each of the last N months")
+	cmd.Flags().BoolVar(&config.DryRun, "dry-run", false, "Simulate prune without deleting")
+	cmd.Flags().BoolVar(&config.Force, "force", false, "Force prune without confirmation")
+
+	// Flags overriding zkbackup.yaml
+	bindConfigFlag(cmd, "backup-base-dir", "backup.base_dir")
+	bindConfigFlag(cmd, "keep-days", "prune.keep_days")
+	bindConfigFlag(cmd, "keep-count", "prune.keep_count")
+	bindConfigFlag(cmd, "keep-min-count", "prune.keep_min_count")
+	bindConfigFlag(cmd, "keep-hourly", "prune.keep_hourly")
+	bindConfigFlag(cmd, "keep-daily", "prune.keep_daily")
+	bindConfigFlag(cmd, "keep-weekly", "prune.keep_weekly")
+	bindConfigFlag(cmd, "keep-monthly", "prune.keep_monthly")
 
 	return cmd
 }
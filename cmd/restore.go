@@ -15,13 +15,37 @@ func NewRestoreCmd() *cobra.Command {
 		Short: "Restore ZooKeeper data from backup",
 		Long: `Restore ZooKeeper data from a backup directory.
 
+With --truncate-to-zxid, only the newest snapshot at or below the target ZXID
+and the txnlogs needed to replay up to the target are restored; the last txnlog
+is cut at the target ZXID. With --restore-to-time, the target ZXID is the last
+transaction committed at or before the given RFC3339 time.
+
+Existing snapshot, txnlog and epoch files are moved into a safety directory
+first. If the restore fails they are put back automatically; after a successful
+restore, "zkbackup rollback" puts them back on demand. The server must be
+stopped: restore refuses to start while ruok is answered on --zk-host, and stops
+without touching anything if new files show up after the existing ones were
+moved aside.
+
+With --target live, only the subtree at --path is restored, into a running
+ensemble at --zk-host: the subtree is rebuilt from the backup at
+--truncate-to-zxid or --restore-to-time (default: its last transaction), diffed
+against the live subtree, and only the znodes that differ are created, updated
+or deleted. The plan is printed and confirmed before anything is written.
+Ephemeral znodes of live sessions are never deleted.
+
 Example:
   zkbackup restore \
     --backup-dir /backup/zookeeper/backup-20250115-103000 \
     --zk-data-dir /zookeeper/data/version-2 \
-    --zk-log-dir /zookeeper/datalog/version-2`,
+    --zk-log-dir /zookeeper/datalog/version-2
+  zkbackup restore \
+    --backup-dir /backup/zookeeper/backup-20250115-103000 \
+    --target live --path /services/foo --zk-host zk-0:2181 \
+    --restore-to-time 2025-01-15T10:25:00Z`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			config.Verbose = verbose
+			settings.ApplyRestore(&config)
 
 			restoreEngine := engine.NewRestoreEngine(&config)
 			return restoreEngine.Run()
@@ -30,17 +54,27 @@ Example:
 
 	// Flags
 	cmd.Flags().StringVar(&config.BackupDir, "backup-dir", "", "Backup directory path (required)")
-	cmd.Flags().StringVar(&config.ZkDataDir, "zk-data-dir", "", "ZooKeeper dataDir path (required)")
-	cmd.Flags().StringVar(&config.ZkLogDir, "zk-log-dir", "", "ZooKeeper dataLogDir path (required)")
+	cmd.Flags().StringVar(&config.Target, "target", engine.RestoreTargetData, "Restore target: data|live")
+	cmd.Flags().StringVar(&config.ZkDataDir, "zk-data-dir", "", "ZooKeeper dataDir path (required with target data)")
+	cmd.Flags().StringVar(&config.ZkLogDir, "zk-log-dir", "", "ZooKeeper dataLogDir path (required with target data)")
+	cmd.Flags().StringVar(&config.Path, "path", "", "Znode path of the subtree to restore (required with target live)")
+	cmd.Flags().StringVar(&config.ZkHost, "zk-host", "localhost:2181", "ZooKeeper host address, must not answer with target data")
+	cmd.Flags().StringArrayVar(&config.Auth, "auth", nil, "Session authentication scheme:auth, repeatable (target live)")
 	cmd.Flags().BoolVar(&config.Force, "force", false, "Force restore without confirmation")
 	cmd.Flags().BoolVar(&config.DryRun, "dry-run", false, "Simulate restore without making changes")
 	cmd.Flags().BoolVar(&config.SkipVerify, "skip-verify", false, "Skip backup verification before restore")
-	cmd.Flags().StringVar(&config.TruncateToZxid, "truncate-to-zxid", "", "Restore to specific ZXID (optional)")
+	cmd.Flags().StringVar(&config.TruncateToZxid, "truncate-to-zxid", "", "Restore to specific ZXID, e.g. 0x100000005 (optional)")
+	cmd.Flags().StringVar(&config.SafetyDir, "safety-dir", "", "Directory to move existing data to (default: zkbackup-safety-<timestamp> next to zk-data-dir)")
+	cmd.Flags().StringVar(&config.RestoreToTime, "restore-to-time", "", "Restore to the last transaction at or before an RFC3339 time (optional)")
 
 	// Required flags
 	cmd.MarkFlagRequired("backup-dir")
-	cmd.MarkFlagRequired("zk-data-dir")
-	cmd.MarkFlagRequired("zk-log-dir")
+
+	// Flags overriding zkbackup.yaml
+	bindConfigFlag(cmd, "zk-data-dir", "zookeeper.data_dir")
+	bindConfigFlag(cmd, "zk-log-dir", "zookeeper.log_dir")
+	bindConfigFlag(cmd, "zk-host", "zookeeper.host")
+	bindConfigFlag(cmd, "safety-dir", "restore.safety_dir")
 
 	return cmd
 }
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/zookeeper-backup/pkg/engine"
+)
+
+// NewRollbackCmd creates the rollback command
+func NewRollbackCmd() *cobra.Command {
+	var config engine.RollbackConfig
+
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Undo a restore using its safety copy",
+		Long: `Undo a restore by putting back the data it moved aside.
+
+Before overwriting anything, restore moves the existing snapshot, txnlog and
+epoch files into a safety directory (zkbackup-safety-<timestamp> next to the
+data dir by default). Rollback removes the restored files and moves the saved
+files back. Without --safety-dir, the newest safety copy next to --zk-data-dir
+is used. Stop ZooKeeper before rolling back.
+
+Example:
+  zkbackup rollback --zk-data-dir /zookeeper/data/version-2`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config.Verbose = verbose
+			settings.ApplyRollback(&config)
+
+			rollbackEngine := engine.NewRollbackEngine(&config)
+			return rollbackEngine.Run()
+		},
+	}
+
+	cmd.Flags().StringVar(&config.SafetyDir, "safety-dir", "", "Safety directory created by restore")
+	cmd.Flags().StringVar(&config.ZkDataDir, "zk-data-dir", "", "ZooKeeper dataDir path, used to find the newest safety directory")
+	cmd.Flags().BoolVar(&config.Force, "force", false, "Force rollback without confirmation")
+	cmd.Flags().BoolVar(&config.DryRun, "dry-run", false, "Show the safety copy without making changes")
+
+	bindConfigFlag(cmd, "zk-data-dir", "zookeeper.data_dir")
+
+	return cmd
+}
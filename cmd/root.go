@@ -1,20 +1,32 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 
 	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
 )
 
 var (
-	cfgFile string
-	verbose bool
+	cfgFile     string
+	clusterName string
+	errorFormat string
+	verbose     bool
 )
 
+// failureExitCodes are the exit codes of failed commands whose error has no more specific code
+var failureExitCodes = map[string]int{
+	"backup":   zkfile.ExitBackupFailed,
+	"restore":  zkfile.ExitRestoreFailed,
+	"load":     zkfile.ExitRestoreFailed,
+	"rollback": zkfile.ExitRestoreFailed,
+	"verify":   zkfile.ExitValidationFailed,
+}
+
 // NewRootCmd creates the root command
 func NewRootCmd(version, commit, date string) *cobra.Command {
 	rootCmd := &cobra.Command{
@@ -28,62 +40,79 @@ It provides:
 - TxnLog verification and repair
 - Easy integration with existing systems`,
 		Version: fmt.Sprintf("%s (commit: %s, built: %s)", version, commit, date),
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
-			// Initialize logger
-			level := "info"
-			if verbose {
-				level = "debug"
+		// Execute prints errors in the selected --error-format
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Arguments are valid, failures from here on are not usage errors
+			cmd.SilenceUsage = true
+
+			if errorFormat != "text" && errorFormat != "json" {
+				return zkfile.NewConfigurationError("unsupported error format").WithContext("format", errorFormat)
 			}
-			utils.InitLogger(level, "text")
+
+			// Merge flags, environment and config files, then initialize the logger
+			loaded, err := loadSettings(cmd, clusterName)
+			if err != nil {
+				return err
+			}
+			settings = loaded
+
+			return settings.ApplyGlobals(verbose)
 		},
 	}
 
 	// Global flags
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./zkbackup.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: ./zkbackup.yaml, ~/.zkbackup.yaml, /etc/zkbackup/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&clusterName, "cluster", "", "cluster profile from the clusters section of the config file")
+	rootCmd.PersistentFlags().StringVar(&errorFormat, "error-format", "text", "how errors are printed to stderr: text|json")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 
-	// Bind flags to viper
-	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
-
 	// Add subcommands
 	rootCmd.AddCommand(NewBackupCmd())
 	rootCmd.AddCommand(NewRestoreCmd())
 	rootCmd.AddCommand(NewVerifyCmd())
+	rootCmd.AddCommand(NewExportCmd())
+	rootCmd.AddCommand(NewLoadCmd())
 	rootCmd.AddCommand(NewListCmd())
 	rootCmd.AddCommand(NewInfoCmd())
 	rootCmd.AddCommand(NewPruneCmd())
+	rootCmd.AddCommand(NewRollbackCmd())
+	rootCmd.AddCommand(NewConfigCmd())
 
 	return rootCmd
 }
 
-// Execute runs the root command
-func Execute(version, commit, date string) error {
+// Execute runs the root command and returns the process exit code
+func Execute(version, commit, date string) int {
 	defer utils.Sync()
 
 	rootCmd := NewRootCmd(version, commit, date)
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		return err
+	cmd, err := rootCmd.ExecuteC()
+	if err == nil {
+		return zkfile.ExitOK
 	}
 
-	return nil
-}
-
-func initConfig() {
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
-	} else {
-		viper.AddConfigPath(".")
-		viper.AddConfigPath("$HOME")
-		viper.AddConfigPath("/etc/zkbackup")
-		viper.SetConfigName("github.com/zookeeper-backup")
-		viper.SetConfigType("yaml")
+	// Commands that did not get past argument parsing failed with a usage error
+	failure, ok := failureExitCodes[cmd.Name()]
+	if !ok || !cmd.SilenceUsage {
+		failure = zkfile.ExitFailure
 	}
+	code := zkfile.ExitCode(err, failure)
 
-	viper.AutomaticEnv()
+	printError(err, code)
+	return code
+}
 
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+// printError prints a failed command's error to stderr in the --error-format
+func printError(err error, code int) {
+	if errorFormat == "json" {
+		data, jsonErr := json.Marshal(zkfile.NewErrorReport(err, code))
+		if jsonErr == nil {
+			fmt.Fprintln(os.Stderr, string(data))
+			return
+		}
 	}
+
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 }
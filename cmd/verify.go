@@ -1,38 +1,39 @@
 package cmd
 
 import (
-	"fmt"
-
 	"github.com/spf13/cobra"
+
+	"github.com/zookeeper-backup/pkg/engine"
 )
 
 // NewVerifyCmd creates the verify command
 func NewVerifyCmd() *cobra.Command {
-	var (
-		backupDir    string
-		fix          bool
-		outputFormat string
-	)
+	var config engine.VerifyConfig
 
 	cmd := &cobra.Command{
 		Use:   "verify",
 		Short: "Verify backup integrity",
 		Long: `Verify the integrity of a backup directory.
 
+Every snapshot and txnlog is validated, snapshot checksums are compared with
+backup_info.json and every file listed in the metadata must exist. The results
+are stored back into backup_info.json. With --fix, truncated txnlogs are
+repaired by cutting them at the last valid transaction.
+
 Example:
   zkbackup verify --backup-dir /backup/zookeeper/backup-20250115-103000`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			fmt.Printf("Verifying backup: %s\n\n", backupDir)
+			config.Verbose = verbose
+			settings.ApplyVerify(&config)
 
-			// TODO: Implement full verify logic
-			fmt.Println("✅ Verification completed")
-			return nil
+			verifyEngine := engine.NewVerifyEngine(&config)
+			return verifyEngine.Run()
 		},
 	}
 
-	cmd.Flags().StringVar(&backupDir, "backup-dir", "", "Backup directory path (required)")
-	cmd.Flags().BoolVar(&fix, "fix", false, "Automatically fix corrupted files")
-	cmd.Flags().StringVar(&outputFormat, "output-format", "text", "Output format: text|json")
+	cmd.Flags().StringVar(&config.BackupDir, "backup-dir", "", "Backup directory path (required)")
+	cmd.Flags().BoolVar(&config.Fix, "fix", false, "Automatically fix corrupted files")
+	cmd.Flags().StringVar(&config.OutputFormat, "output-format", "text", "Output format: text|json")
 
 	cmd.MarkFlagRequired("backup-dir")
 
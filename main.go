@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/zookeeper-backup/cmd"
@@ -14,8 +13,5 @@ var (
 )
 
 func main() {
-	if err := cmd.Execute(version, commit, date); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
+	os.Exit(cmd.Execute(version, commit, date))
 }
@@ -0,0 +1,530 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/zookeeper-backup/pkg/engine"
+	"github.com/zookeeper-backup/pkg/jute"
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// EnvPrefix prefixes environment variables, e.g. ZKBACKUP_ZOOKEEPER_HOST for zookeeper.host
+const EnvPrefix = "ZKBACKUP"
+
+// clusterSections are the sections a cluster profile may override
+var clusterSections = []string{"zookeeper", "backup", "restore", "prune"}
+
+// clusterNamePattern keeps cluster names usable as directory names and config keys
+var clusterNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Config is the content of zkbackup.yaml
+type Config struct {
+	ZooKeeper ZooKeeperSection `mapstructure:"zookeeper" yaml:"zookeeper"`
+	Backup    BackupSection    `mapstructure:"backup" yaml:"backup"`
+	Restore   RestoreSection   `mapstructure:"restore" yaml:"restore"`
+	Prune     PruneSection     `mapstructure:"prune" yaml:"prune"`
+	Logging   LoggingSection   `mapstructure:"logging" yaml:"logging"`
+	Advanced  AdvancedSection  `mapstructure:"advanced" yaml:"advanced"`
+
+	// Clusters holds named cluster profiles. A profile overrides keys of the
+	// zookeeper, backup, restore and prune sections, e.g.
+	// clusters.orders.zookeeper.host.
+	Clusters map[string]map[string]interface{} `mapstructure:"clusters" yaml:"clusters,omitempty"`
+
+	// Cluster is the profile applied by Load, empty when none was selected
+	Cluster string `mapstructure:"-" yaml:"-"`
+
+	// Files lists the config files that were merged, lowest precedence first
+	Files []string `mapstructure:"-" yaml:"-"`
+}
+
+// ZooKeeperSection configures how the ZooKeeper server is reached
+type ZooKeeperSection struct {
+	DataDir      string `mapstructure:"data_dir" yaml:"data_dir"`
+	LogDir       string `mapstructure:"log_dir" yaml:"log_dir"`
+	Host         string `mapstructure:"host" yaml:"host"`
+	Timeout      int    `mapstructure:"timeout" yaml:"timeout"` // seconds
+	StatsBackend string `mapstructure:"stats_backend" yaml:"stats_backend"`
+	AdminURL     string `mapstructure:"admin_url" yaml:"admin_url"`
+}
+
+// BackupSection configures backup
+type BackupSection struct {
+	BaseDir          string `mapstructure:"base_dir" yaml:"base_dir"`
+	Compression      string `mapstructure:"compression" yaml:"compression"`
+	CompressionLevel int    `mapstructure:"compression_level" yaml:"compression_level"`
+	AutoVerify       bool   `mapstructure:"auto_verify" yaml:"auto_verify"`
+	AutoRepair       bool   `mapstructure:"auto_repair" yaml:"auto_repair"`
+	ConcurrentCopies int    `mapstructure:"concurrent_copies" yaml:"concurrent_copies"` // reserved, files are copied one at a time
+}
+
+// RestoreSection configures restore
+type RestoreSection struct {
+	RequireConfirmation bool   `mapstructure:"require_confirmation" yaml:"require_confirmation"`
+	VerifyBeforeRestore bool   `mapstructure:"verify_before_restore" yaml:"verify_before_restore"`
+	BackupBeforeRestore bool   `mapstructure:"backup_before_restore" yaml:"backup_before_restore"`
+	SafetyDir           string `mapstructure:"safety_dir" yaml:"safety_dir"`
+}
+
+// PruneSection configures the retention policy
+type PruneSection struct {
+	KeepDays            int  `mapstructure:"keep_days" yaml:"keep_days"`
+	KeepCount           int  `mapstructure:"keep_count" yaml:"keep_count"`
+	KeepMinCount        int  `mapstructure:"keep_min_count" yaml:"keep_min_count"`
+	KeepHourly          int  `mapstructure:"keep_hourly" yaml:"keep_hourly"`
+	KeepDaily           int  `mapstructure:"keep_daily" yaml:"keep_daily"`
+	KeepWeekly          int  `mapstructure:"keep_weekly" yaml:"keep_weekly"`
+	KeepMonthly         int  `mapstructure:"keep_monthly" yaml:"keep_monthly"`
+	RequireConfirmation bool `mapstructure:"require_confirmation" yaml:"require_confirmation"`
+}
+
+// LoggingSection configures the logger
+type LoggingSection struct {
+	Level  string `mapstructure:"level" yaml:"level"`
+	Format string `mapstructure:"format" yaml:"format"`
+	Output string `mapstructure:"output" yaml:"output"` // stdout, stderr or file
+	File   string `mapstructure:"file" yaml:"file"`
+}
+
+// AdvancedSection holds tuning knobs
+type AdvancedSection struct {
+	MaxTxnRecordSize  int `mapstructure:"max_txn_record_size" yaml:"max_txn_record_size"`
+	JuteMaxBuffer     int `mapstructure:"jute_max_buffer" yaml:"jute_max_buffer"`       // jute.maxbuffer of the servers
+	CopyBufferSize    int `mapstructure:"copy_buffer_size" yaml:"copy_buffer_size"`     // reserved, copies use the OS fast path
+	ValidationTimeout int `mapstructure:"validation_timeout" yaml:"validation_timeout"` // seconds, 0 disables
+}
+
+// LoadOptions selects the sources merged by Load
+type LoadOptions struct {
+	// File is an explicit config file; it replaces the search paths and must exist
+	File string
+
+	// SearchPaths are optional config files, lowest precedence first.
+	// Nil selects DefaultSearchPaths.
+	SearchPaths []string
+
+	// Flags maps config keys such as "zookeeper.host" to the flags overriding them.
+	// Only flags set on the command line take precedence over the other sources.
+	Flags map[string]*pflag.Flag
+
+	// Cluster selects a profile of the clusters section. Its keys take precedence
+	// over the environment and the top level sections, and backup.base_dir gets
+	// the cluster name appended so every cluster has its own namespace.
+	Cluster string
+}
+
+// Default returns the built-in configuration, matching the command line defaults
+func Default() *Config {
+	return &Config{
+		ZooKeeper: ZooKeeperSection{
+			Host:         "localhost:2181",
+			Timeout:      5,
+			StatsBackend: utils.StatsBackendAuto,
+		},
+		Backup: BackupSection{
+			BaseDir:          "/backup/zookeeper",
+			Compression:      zkfile.CompressionNone,
+			AutoVerify:       true,
+			AutoRepair:       true,
+			ConcurrentCopies: 1,
+		},
+		Restore: RestoreSection{
+			RequireConfirmation: true,
+			VerifyBeforeRestore: true,
+			BackupBeforeRestore: true,
+		},
+		Prune: PruneSection{
+			KeepDays:            7,
+			KeepMinCount:        3,
+			RequireConfirmation: true,
+		},
+		Logging: LoggingSection{
+			Level:  "info",
+			Format: "text",
+			Output: "stderr",
+		},
+		Advanced: AdvancedSection{
+			MaxTxnRecordSize:  zkfile.DefaultMaxRecordSize,
+			JuteMaxBuffer:     jute.DefaultMaxBuffer,
+			CopyBufferSize:    1024 * 1024,
+			ValidationTimeout: 0,
+		},
+	}
+}
+
+// DefaultSearchPaths returns /etc/zkbackup/config.yaml, ~/.zkbackup.yaml and
+// ./zkbackup.yaml, lowest precedence first
+func DefaultSearchPaths() []string {
+	paths := []string{"/etc/zkbackup/config.yaml"}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".zkbackup.yaml"))
+	}
+	return append(paths, "zkbackup.yaml")
+}
+
+// Load merges, from highest to lowest precedence, the flags set on the command
+// line, the selected cluster profile, ZKBACKUP_* environment variables, the
+// config files and the defaults. Unknown keys in a config file are rejected.
+func Load(opts LoadOptions) (*Config, error) {
+	v := viper.New()
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	// Registering every key as a default lets environment variables override keys
+	// that are in no config file
+	defaults, err := flatten(Default())
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+
+	paths := opts.SearchPaths
+	if opts.File != "" {
+		paths = []string{opts.File}
+	} else if paths == nil {
+		paths = DefaultSearchPaths()
+	}
+
+	var files []string
+	for _, path := range paths {
+		if opts.File == "" && !zkfile.FileExists(path) {
+			continue
+		}
+		settings, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err = v.MergeConfigMap(settings); err != nil {
+			return nil, zkfile.NewConfigurationError("failed to merge config file").WithError(err).WithContext("path", path)
+		}
+		files = append(files, path)
+	}
+
+	for key, flag := range opts.Flags {
+		if err = v.BindPFlag(key, flag); err != nil {
+			return nil, zkfile.NewConfigurationError("failed to bind flag").WithError(err).WithContext("key", key)
+		}
+	}
+
+	if opts.Cluster != "" {
+		if err = applyCluster(v, opts.Cluster, defaults, opts.Flags); err != nil {
+			return nil, err
+		}
+	}
+
+	config := &Config{}
+	if err = v.UnmarshalExact(config); err != nil {
+		return nil, zkfile.NewConfigurationError("invalid configuration").WithError(err)
+	}
+	config.Files = files
+	if opts.Cluster != "" {
+		config.Cluster = opts.Cluster
+		config.Backup.BaseDir = filepath.Join(config.Backup.BaseDir, opts.Cluster)
+	}
+
+	if err = config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Validate checks the merged configuration
+func (c *Config) Validate() error {
+	if c.ZooKeeper.Timeout <= 0 {
+		return zkfile.NewConfigurationError("zookeeper.timeout must be positive").WithContext("timeout", c.ZooKeeper.Timeout)
+	}
+	if err := utils.ValidateStatsBackend(c.ZooKeeper.StatsBackend); err != nil {
+		return err
+	}
+	if err := zkfile.ValidateCompression(c.Backup.Compression, c.Backup.CompressionLevel); err != nil {
+		return err
+	}
+	if !c.Restore.BackupBeforeRestore {
+		return zkfile.NewConfigurationError("restore.backup_before_restore cannot be disabled, " +
+			"restore always moves existing data into a safety directory")
+	}
+
+	prune := c.Prune
+	if prune.KeepDays < 0 || prune.KeepCount < 0 || prune.KeepMinCount < 0 ||
+		prune.KeepHourly < 0 || prune.KeepDaily < 0 || prune.KeepWeekly < 0 || prune.KeepMonthly < 0 {
+		return zkfile.NewConfigurationError("prune settings must not be negative")
+	}
+
+	switch c.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return zkfile.NewConfigurationError("unsupported logging.level").WithContext("level", c.Logging.Level)
+	}
+	switch c.Logging.Format {
+	case "text", "json":
+	default:
+		return zkfile.NewConfigurationError("unsupported logging.format").WithContext("format", c.Logging.Format)
+	}
+	switch c.Logging.Output {
+	case "stdout", "stderr":
+	case "file":
+		if c.Logging.File == "" {
+			return zkfile.NewConfigurationError("logging.file is required when logging.output is file")
+		}
+	default:
+		return zkfile.NewConfigurationError("unsupported logging.output").WithContext("output", c.Logging.Output)
+	}
+
+	if c.Advanced.MaxTxnRecordSize <= 0 {
+		return zkfile.NewConfigurationError("advanced.max_txn_record_size must be positive")
+	}
+	if c.Advanced.JuteMaxBuffer <= 0 {
+		return zkfile.NewConfigurationError("advanced.jute_max_buffer must be positive")
+	}
+	if c.Advanced.ValidationTimeout < 0 {
+		return zkfile.NewConfigurationError("advanced.validation_timeout must not be negative")
+	}
+
+	return c.validateClusters()
+}
+
+// validateClusters checks the names and keys of the cluster profiles
+func (c *Config) validateClusters() error {
+	defaults, err := flatten(Default())
+	if err != nil {
+		return err
+	}
+
+	for name, profile := range c.Clusters {
+		if !clusterNamePattern.MatchString(name) {
+			return zkfile.NewConfigurationError("invalid cluster name, use lowercase letters, digits, '-' and '_'").
+				WithContext("cluster", name)
+		}
+		for section, values := range profile {
+			if !isClusterSection(section) {
+				return zkfile.NewConfigurationError("unsupported section in cluster profile").
+					WithContext("cluster", name).WithContext("section", section).
+					WithContext("supported", strings.Join(clusterSections, ", "))
+			}
+			keys, ok := values.(map[string]interface{})
+			if !ok {
+				return zkfile.NewConfigurationError("cluster profile section must be a map").
+					WithContext("cluster", name).WithContext("section", section)
+			}
+			for key := range keys {
+				if _, ok = defaults[section+"."+key]; !ok {
+					return zkfile.NewConfigurationError("invalid key in cluster profile").
+						WithContext("cluster", name).WithContext("key", section+"."+key)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ClusterNames returns the names of the cluster profiles in sorted order
+func (c *Config) ClusterNames() []string {
+	names := make([]string, 0, len(c.Clusters))
+	for name := range c.Clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyBackup fills a backup configuration
+func (c *Config) ApplyBackup(b *engine.BackupConfig) {
+	b.Cluster = c.Cluster
+	b.ZkDataDir = c.ZooKeeper.DataDir
+	b.ZkLogDir = c.ZooKeeper.LogDir
+	b.ZkHost = c.ZooKeeper.Host
+	b.ZkTimeout = c.zkTimeout()
+	b.StatsBackend = c.ZooKeeper.StatsBackend
+	b.AdminURL = c.ZooKeeper.AdminURL
+	b.OutputDir = c.Backup.BaseDir
+	b.Compression = c.Backup.Compression
+	b.CompressionLevel = c.Backup.CompressionLevel
+	b.Verify = c.Backup.AutoVerify
+	b.SkipRepair = !c.Backup.AutoRepair
+}
+
+// ApplyRestore fills a restore configuration. Disabled confirmation or
+// verification in the file has the same effect as --force or --skip-verify.
+func (c *Config) ApplyRestore(r *engine.RestoreConfig) {
+	r.ZkDataDir = c.ZooKeeper.DataDir
+	r.ZkLogDir = c.ZooKeeper.LogDir
+	r.ZkHost = c.ZooKeeper.Host
+	r.ZkTimeout = c.zkTimeout()
+	r.SafetyDir = c.Restore.SafetyDir
+	r.Force = r.Force || !c.Restore.RequireConfirmation
+	r.SkipVerify = r.SkipVerify || !c.Restore.VerifyBeforeRestore
+}
+
+// ApplyLoad fills a load configuration. A --zk-timeout flag wins over the
+// timeout of the file, which is in seconds.
+func (c *Config) ApplyLoad(l *engine.LoadConfig) {
+	l.ZkHost = c.ZooKeeper.Host
+	if l.ZkTimeout <= 0 {
+		l.ZkTimeout = c.zkTimeout()
+	}
+	l.BackupBaseDir = c.Backup.BaseDir
+}
+
+// ApplyRollback fills a rollback configuration
+func (c *Config) ApplyRollback(r *engine.RollbackConfig) {
+	r.ZkDataDir = c.ZooKeeper.DataDir
+}
+
+// ApplyVerify fills a verify configuration
+func (c *Config) ApplyVerify(v *engine.VerifyConfig) {
+	v.Timeout = time.Duration(c.Advanced.ValidationTimeout) * time.Second
+}
+
+// ApplyPrune fills a prune configuration
+func (c *Config) ApplyPrune(p *engine.PruneConfig) {
+	p.BackupBaseDir = c.Backup.BaseDir
+	p.KeepDays = c.Prune.KeepDays
+	p.KeepCount = c.Prune.KeepCount
+	p.KeepMinCount = c.Prune.KeepMinCount
+	p.KeepHourly = c.Prune.KeepHourly
+	p.KeepDaily = c.Prune.KeepDaily
+	p.KeepWeekly = c.Prune.KeepWeekly
+	p.KeepMonthly = c.Prune.KeepMonthly
+	p.Force = p.Force || !c.Prune.RequireConfirmation
+}
+
+// ApplyGlobals applies the settings shared by every command: the logger and the
+// txnlog record size limit
+func (c *Config) ApplyGlobals(verbose bool) error {
+	level := c.Logging.Level
+	if verbose {
+		level = "debug"
+	}
+	output := c.Logging.Output
+	if output == "file" {
+		output = c.Logging.File
+	}
+	if err := utils.InitLoggerWithOutput(level, c.Logging.Format, output); err != nil {
+		return zkfile.NewConfigurationError("failed to initialize logger").WithError(err)
+	}
+
+	zkfile.MaxRecordSize = int32(c.Advanced.MaxTxnRecordSize)
+	jute.MaxBuffer = c.Advanced.JuteMaxBuffer
+	return nil
+}
+
+// YAML returns the configuration as zkbackup.yaml content
+func (c *Config) YAML() (string, error) {
+	var sb strings.Builder
+	encoder := yaml.NewEncoder(&sb)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return "", fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// zkTimeout returns the ZooKeeper timeout as a duration
+func (c *Config) zkTimeout() time.Duration {
+	return time.Duration(c.ZooKeeper.Timeout) * time.Second
+}
+
+// applyCluster overlays a cluster profile on the merged settings. Keys whose
+// flag was set on the command line keep the flag value.
+func applyCluster(v *viper.Viper, name string, defaults map[string]interface{}, flags map[string]*pflag.Flag) error {
+	clusters, _ := v.Get("clusters").(map[string]interface{})
+	profile, ok := clusters[name].(map[string]interface{})
+	if !ok {
+		names := make([]string, 0, len(clusters))
+		for cluster := range clusters {
+			names = append(names, cluster)
+		}
+		sort.Strings(names)
+		return zkfile.NewConfigurationError("unknown cluster").
+			WithContext("cluster", name).WithContext("available", strings.Join(names, ", "))
+	}
+
+	for section, values := range profile {
+		keys, ok := values.(map[string]interface{})
+		if !ok || !isClusterSection(section) {
+			// Invalid profiles are reported by validateClusters
+			continue
+		}
+		for key, value := range keys {
+			key = section + "." + key
+			if _, known := defaults[key]; !known {
+				continue
+			}
+			if flag := flags[key]; flag != nil && flag.Changed {
+				continue
+			}
+			v.Set(key, value)
+		}
+	}
+
+	return nil
+}
+
+// isClusterSection reports whether a cluster profile may override a section
+func isClusterSection(section string) bool {
+	for _, s := range clusterSections {
+		if s == section {
+			return true
+		}
+	}
+	return false
+}
+
+// readFile reads a config file, rejecting keys that are not part of Config
+func readFile(path string) (map[string]interface{}, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, zkfile.NewConfigurationError("failed to read config file").WithError(err).WithContext("path", path)
+	}
+
+	if err := v.UnmarshalExact(&Config{}); err != nil {
+		return nil, zkfile.NewConfigurationError("invalid config file").WithError(err).WithContext("path", path)
+	}
+
+	return v.AllSettings(), nil
+}
+
+// flatten returns the configuration as dotted keys such as "zookeeper.host"
+func flatten(c *Config) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	var sections map[string]interface{}
+	if err = yaml.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	keys := make(map[string]interface{})
+	for section, values := range sections {
+		values, ok := values.(map[string]interface{})
+		if !ok || section == "clusters" {
+			continue
+		}
+		for key, value := range values {
+			keys[section+"."+key] = value
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,301 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/zookeeper-backup/pkg/engine"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+func writeConfigFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	config, err := Load(LoadOptions{SearchPaths: []string{filepath.Join(t.TempDir(), "missing.yaml")}})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(config.Files) != 0 {
+		t.Errorf("Files = %v, want none", config.Files)
+	}
+	want := Default()
+	want.Files = config.Files
+	if got, _ := config.YAML(); got != mustYAML(t, want) {
+		t.Errorf("Load() without files =\n%s\nwant defaults", got)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	dir := t.TempDir()
+	system := writeConfigFile(t, dir, "system.yaml", `
+zookeeper:
+  host: system:2181
+  data_dir: /system/data
+  log_dir: /system/log
+backup:
+  base_dir: /system/backup
+prune:
+  keep_days: 30
+`)
+	user := writeConfigFile(t, dir, "user.yaml", `
+zookeeper:
+  host: user:2181
+  data_dir: /user/data
+backup:
+  compression: gzip
+`)
+	local := writeConfigFile(t, dir, "local.yaml", `
+zookeeper:
+  host: local:2181
+`)
+
+	t.Setenv("ZKBACKUP_BACKUP_COMPRESSION", "zstd")
+	t.Setenv("ZKBACKUP_PRUNE_KEEP_DAYS", "14")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("zk-host", "localhost:2181", "")
+	flags.Int("keep-days", 7, "")
+	if err := flags.Parse([]string{"--keep-days", "3"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	config, err := Load(LoadOptions{
+		SearchPaths: []string{system, user, local},
+		Flags: map[string]*pflag.Flag{
+			"zookeeper.host":  flags.Lookup("zk-host"),
+			"prune.keep_days": flags.Lookup("keep-days"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"local file over user file, unset flag ignored", config.ZooKeeper.Host, "local:2181"},
+		{"user file over system file", config.ZooKeeper.DataDir, "/user/data"},
+		{"system file over defaults", config.ZooKeeper.LogDir, "/system/log"},
+		{"system file only", config.Backup.BaseDir, "/system/backup"},
+		{"env over files", config.Backup.Compression, "zstd"},
+		{"flag over env", config.Prune.KeepDays, 3},
+		{"defaults", config.Prune.KeepMinCount, 3},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if len(config.Files) != 3 {
+		t.Errorf("Files = %v, want all three files", config.Files)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		errMsg  string
+	}{
+		{"unknown key", "zookeeper:\n  hots: zk:2181\n", "invalid keys: hots"},
+		{"unknown section", "zookeper:\n  host: zk:2181\n", "invalid keys: zookeper"},
+		{"invalid yaml", "zookeeper: [\n", "failed to read config file"},
+		{"invalid compression", "backup:\n  compression: lz4\n", "unsupported compression"},
+		{"invalid stats backend", "zookeeper:\n  stats_backend: jmx\n", "unsupported stats backend"},
+		{"backup before restore disabled", "restore:\n  backup_before_restore: false\n", "cannot be disabled"},
+		{"log file missing", "logging:\n  output: file\n", "logging.file is required"},
+		{"negative prune", "prune:\n  keep_daily: -1\n", "must not be negative"},
+		{"invalid jute max buffer", "advanced:\n  jute_max_buffer: 0\n", "jute_max_buffer must be positive"},
+		{"invalid cluster name", "clusters:\n  orders/eu:\n    zookeeper:\n      host: zk:2181\n", "invalid cluster name"},
+		{"unsupported cluster section", "clusters:\n  orders:\n    logging:\n      level: debug\n", "unsupported section"},
+		{"unknown cluster key", "clusters:\n  orders:\n    zookeeper:\n      hots: zk:2181\n", "invalid key in cluster profile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigFile(t, dir, strings.ReplaceAll(tt.name, " ", "_")+".yaml", tt.content)
+
+			_, err := Load(LoadOptions{File: path})
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Load() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+
+	t.Run("explicit file missing", func(t *testing.T) {
+		_, err := Load(LoadOptions{File: filepath.Join(dir, "missing.yaml")})
+		if err == nil {
+			t.Error("Load() should fail when --config does not exist")
+		}
+	})
+}
+
+func TestLoad_Cluster(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, "zkbackup.yaml", `
+zookeeper:
+  host: default:2181
+  data_dir: /default/data
+backup:
+  base_dir: /backups
+  compression: gzip
+prune:
+  keep_days: 7
+clusters:
+  orders:
+    zookeeper:
+      host: orders-0:2181,orders-1:2181
+      data_dir: /orders/data
+      log_dir: /orders/log
+    prune:
+      keep_days: 30
+  users:
+    zookeeper:
+      host: users-0:2181
+    backup:
+      base_dir: /mnt/users
+`)
+
+	t.Setenv("ZKBACKUP_ZOOKEEPER_HOST", "env:2181")
+	t.Setenv("ZKBACKUP_BACKUP_COMPRESSION", "zstd")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("zk-log-dir", "", "")
+	if err := flags.Parse([]string{"--zk-log-dir", "/flag/log"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	orders, err := Load(LoadOptions{
+		File:    path,
+		Cluster: "orders",
+		Flags:   map[string]*pflag.Flag{"zookeeper.log_dir": flags.Lookup("zk-log-dir")},
+	})
+	if err != nil {
+		t.Fatalf("Load(orders) error = %v", err)
+	}
+	users, err := Load(LoadOptions{File: path, Cluster: "users"})
+	if err != nil {
+		t.Fatalf("Load(users) error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"profile over env", orders.ZooKeeper.Host, "orders-0:2181,orders-1:2181"},
+		{"profile over file", orders.ZooKeeper.DataDir, "/orders/data"},
+		{"flag over profile", orders.ZooKeeper.LogDir, "/flag/log"},
+		{"profile retention", orders.Prune.KeepDays, 30},
+		{"env without profile key", orders.Backup.Compression, "zstd"},
+		{"namespace under base dir", orders.Backup.BaseDir, "/backups/orders"},
+		{"namespace under profile base dir", users.Backup.BaseDir, "/mnt/users/users"},
+		{"file without profile key", users.ZooKeeper.DataDir, "/default/data"},
+		{"cluster name", orders.Cluster, "orders"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if names := orders.ClusterNames(); strings.Join(names, ",") != "orders,users" {
+		t.Errorf("ClusterNames() = %v, want [orders users]", names)
+	}
+
+	t.Run("without cluster", func(t *testing.T) {
+		config, err := Load(LoadOptions{File: path})
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if config.ZooKeeper.Host != "env:2181" || config.Backup.BaseDir != "/backups" || config.Cluster != "" {
+			t.Errorf("Host = %q, BaseDir = %q, Cluster = %q", config.ZooKeeper.Host, config.Backup.BaseDir, config.Cluster)
+		}
+	})
+
+	t.Run("unknown cluster", func(t *testing.T) {
+		_, err := Load(LoadOptions{File: path, Cluster: "payments"})
+		if err == nil || !strings.Contains(err.Error(), "unknown cluster") || !strings.Contains(err.Error(), "orders, users") {
+			t.Errorf("Load() error = %v, want unknown cluster listing the profiles", err)
+		}
+	})
+}
+
+func TestConfig_Apply(t *testing.T) {
+	config := Default()
+	config.ZooKeeper.DataDir = "/zk/data"
+	config.ZooKeeper.LogDir = "/zk/log"
+	config.ZooKeeper.Timeout = 10
+	config.Backup.BaseDir = "/backups"
+	config.Backup.Compression = zkfile.CompressionZstd
+	config.Backup.AutoRepair = false
+	config.Restore.RequireConfirmation = false
+	config.Prune.KeepDaily = 7
+	config.Prune.RequireConfirmation = false
+	config.Advanced.ValidationTimeout = 60
+
+	backup := &engine.BackupConfig{}
+	config.ApplyBackup(backup)
+	if backup.ZkDataDir != "/zk/data" || backup.ZkLogDir != "/zk/log" || backup.OutputDir != "/backups" ||
+		backup.ZkTimeout != 10*time.Second || backup.Compression != zkfile.CompressionZstd ||
+		!backup.Verify || !backup.SkipRepair {
+		t.Errorf("ApplyBackup() = %+v", backup)
+	}
+
+	restore := &engine.RestoreConfig{}
+	config.ApplyRestore(restore)
+	if restore.ZkDataDir != "/zk/data" || restore.ZkTimeout != 10*time.Second || !restore.Force || restore.SkipVerify {
+		t.Errorf("ApplyRestore() = %+v", restore)
+	}
+
+	load := &engine.LoadConfig{}
+	config.ApplyLoad(load)
+	if load.ZkHost != config.ZooKeeper.Host || load.ZkTimeout != 10*time.Second || load.BackupBaseDir != "/backups" {
+		t.Errorf("ApplyLoad() = %+v", load)
+	}
+	load = &engine.LoadConfig{ZkTimeout: time.Second}
+	config.ApplyLoad(load)
+	if load.ZkTimeout != time.Second {
+		t.Errorf("ApplyLoad() ZkTimeout = %v, want the --zk-timeout flag kept", load.ZkTimeout)
+	}
+
+	verify := &engine.VerifyConfig{}
+	config.ApplyVerify(verify)
+	if verify.Timeout != time.Minute {
+		t.Errorf("ApplyVerify() Timeout = %v, want 1m", verify.Timeout)
+	}
+
+	prune := &engine.PruneConfig{}
+	config.ApplyPrune(prune)
+	if prune.BackupBaseDir != "/backups" || prune.KeepDays != 7 || prune.KeepDaily != 7 || !prune.Force {
+		t.Errorf("ApplyPrune() = %+v", prune)
+	}
+}
+
+func mustYAML(t *testing.T, config *Config) string {
+	t.Helper()
+
+	content, err := config.YAML()
+	if err != nil {
+		t.Fatalf("YAML() error = %v", err)
+	}
+	return content
+}
@@ -21,9 +21,14 @@ type BackupEngine struct {
 
 // NewBackupEngine creates a new backup engine
 func NewBackupEngine(config *BackupConfig) *BackupEngine {
+	logger := utils.GetLogger()
+	if config.Cluster != "" {
+		logger = logger.With(zap.String("cluster", config.Cluster))
+	}
+
 	return &BackupEngine{
 		config: config,
-		logger: utils.GetLogger(),
+		logger: logger,
 	}
 }
 
@@ -33,14 +38,16 @@ func (e *BackupEngine) Run() error {
 
 	// 1. Validate configuration
 	if err := e.config.Validate(); err != nil {
-		return fmt.Errorf("invalid configuration: %w", err)
+		return zkfile.NewConfigurationError("invalid configuration").WithError(err)
 	}
 
 	e.logger.Info("Starting backup",
 		zap.String("backup_id", e.config.BackupID),
+		zap.String("mode", e.config.Mode),
 		zap.String("log_dir", e.config.ZkLogDir),
 		zap.String("data_dir", e.config.ZkDataDir),
-		zap.String("output_dir", e.config.OutputDir))
+		zap.String("output_dir", e.config.OutputDir),
+		zap.String("compression", e.config.Compression))
 
 	// 2. Pre-check
 	if err := e.preCheck(); err != nil {
@@ -62,21 +69,39 @@ func (e *BackupEngine) Run() error {
 
 	// 5. Initialize backup info
 	backupInfo := metadata.NewBackupInfo(e.config.BackupID, currentZxid)
+	backupInfo.Mode = e.config.Mode
+	backupInfo.Cluster = e.config.Cluster
 	backupInfo.ZooKeeper.Version = zkVersion
 	backupInfo.ZooKeeper.Host = e.config.ZkHost
 	backupInfo.ZooKeeper.LogDir = e.config.ZkLogDir
 	backupInfo.ZooKeeper.DataDir = e.config.ZkDataDir
 
-	// 6. Backup snapshot files
-	e.logger.Info("Backing up snapshot files")
-	if err = e.backupSnapshots(backupDir, backupInfo); err != nil {
-		return fmt.Errorf("failed to backup snapshots: %w", err)
-	}
+	switch e.config.Mode {
+	case BackupModeLogical:
+		// 6-7. Walk the znode tree over the client connection, there are no files to copy
+		e.logger.Info("Walking znodes", zap.Strings("paths", e.config.Paths))
+		if err = e.backupLogical(backupDir, backupInfo); err != nil {
+			return fmt.Errorf("failed to back up znodes: %w", err)
+		}
+	case BackupModeStream:
+		// 6-7. Stream a fresh snapshot from the AdminServer, there are no txnlogs to copy
+		backupInfo.ZooKeeper.AdminURL = e.config.AdminURL
+		e.logger.Info("Streaming snapshot from admin server", zap.String("admin_url", e.config.AdminURL))
+		if err = e.streamSnapshot(backupDir, backupInfo); err != nil {
+			return fmt.Errorf("failed to stream snapshot: %w", err)
+		}
+	default:
+		// 6. Backup snapshot files
+		e.logger.Info("Backing up snapshot files")
+		if err = e.backupSnapshots(backupDir, backupInfo); err != nil {
+			return fmt.Errorf("failed to backup snapshots: %w", err)
+		}
 
-	// 7. Backup txnlog files
-	e.logger.Info("Backing up txnlog files")
-	if err = e.backupTxnLogs(backupDir, backupInfo); err != nil {
-		return fmt.Errorf("failed to backup txnlogs: %w", err)
+		// 7. Backup txnlog files
+		e.logger.Info("Backing up txnlog files")
+		if err = e.backupTxnLogs(backupDir, backupInfo); err != nil {
+			return fmt.Errorf("failed to backup txnlogs: %w", err)
+		}
 	}
 
 	// 8. Verify backup if enabled
@@ -92,7 +117,11 @@ func (e *BackupEngine) Run() error {
 	if err != nil {
 		e.logger.Warn("Failed to calculate backup size", zap.Error(err))
 	}
-	backupInfo.UpdateStatistics(totalSize, 0, time.Since(startTime))
+	if savings := backupInfo.CompressionSavings(); savings > 0 {
+		backupInfo.UpdateStatistics(totalSize+savings, totalSize, time.Since(startTime))
+	} else {
+		backupInfo.UpdateStatistics(totalSize, 0, time.Since(startTime))
+	}
 
 	// 10. Save metadata
 	if err := e.saveMetadata(backupDir, backupInfo); err != nil {
@@ -109,17 +138,22 @@ func (e *BackupEngine) Run() error {
 func (e *BackupEngine) preCheck() error {
 	// Check if output directory is writable
 	if err := zkfile.EnsureDir(e.config.OutputDir); err != nil {
-		return fmt.Errorf("output directory is not writable: %w", err)
+		return zkfile.NewIOError("output directory is not writable").WithError(err).WithContext("dir", e.config.OutputDir)
+	}
+
+	// Streamed and logical backups do not read the ZooKeeper directories
+	if e.config.Mode == BackupModeStream || e.config.Mode == BackupModeLogical {
+		return nil
 	}
 
 	// Check if log directory exists
 	if !zkfile.DirExists(e.config.ZkLogDir) {
-		return fmt.Errorf("log directory does not exist: %s", e.config.ZkLogDir)
+		return zkfile.NewIOError("log directory does not exist").WithContext("dir", e.config.ZkLogDir)
 	}
 
 	// Check if data directory exists
 	if !zkfile.DirExists(e.config.ZkDataDir) {
-		return fmt.Errorf("data directory does not exist: %s", e.config.ZkDataDir)
+		return zkfile.NewIOError("data directory does not exist").WithContext("dir", e.config.ZkDataDir)
 	}
 
 	return nil
@@ -133,6 +167,9 @@ func (e *BackupEngine) createBackupDirs(backupDir string) error {
 		filepath.Join(backupDir, "txnlogs"),
 		filepath.Join(backupDir, "snapshots"),
 	}
+	if e.config.Mode == BackupModeLogical {
+		dirs = append(dirs, filepath.Join(backupDir, filepath.Dir(LogicalArchiveFile)))
+	}
 
 	for _, dir := range dirs {
 		if err := zkfile.EnsureDir(dir); err != nil {
@@ -145,23 +182,27 @@ func (e *BackupEngine) createBackupDirs(backupDir string) error {
 
 // getCurrentZxid gets current ZXID from ZooKeeper
 func (e *BackupEngine) getCurrentZxid() (zkfile.ZXID, string, error) {
-	client, err := utils.NewZKClient(e.config.ZkHost, 5*time.Second)
+	client, err := utils.NewZKClient(e.config.ZkHost, e.config.ZkTimeout)
 	if err != nil {
 		return 0, "", err
 	}
 	defer client.Close()
 
-	version, err := client.GetVersion()
-	if err != nil {
-		version = "unknown"
+	if err = client.SetStatsBackend(e.config.StatsBackend, e.config.AdminURL); err != nil {
+		return 0, "", err
 	}
 
-	zxid, err := client.GetCurrentZXID()
+	stats, err := client.GetServerStats()
 	if err != nil {
 		return 0, "", err
 	}
 
-	return zxid, version, nil
+	version := stats.Version
+	if version == "" {
+		version = "unknown"
+	}
+
+	return stats.Zxid, version, nil
 }
 
 // backupTxnLogs backs up all txnlog files
@@ -174,8 +215,9 @@ func (e *BackupEngine) backupTxnLogs(backupDir string, backupInfo *metadata.Back
 	txnlogDir := filepath.Join(backupDir, "txnlogs")
 
 	for _, txnlog := range txnlogs {
-		e.logger.Debug("Copying txnlog", zap.String("file", txnlog))
-		err = zkfile.CopyFile(txnlog, filepath.Join(txnlogDir, filepath.Base(txnlog)))
+		e.logger.Debug("Copying txnlog", zap.String("file", txnlog), zap.String("compression", e.config.Compression))
+		storedSize, err := zkfile.CompressFile(txnlog, filepath.Join(txnlogDir, filepath.Base(txnlog)),
+			e.config.Compression, e.config.CompressionLevel)
 		if err != nil {
 			return err
 		}
@@ -186,6 +228,10 @@ func (e *BackupEngine) backupTxnLogs(backupDir string, backupInfo *metadata.Back
 			e.logger.Warn("Failed to get txnlog info", zap.Error(err))
 			continue
 		}
+		if e.config.Compression != zkfile.CompressionNone {
+			info.Compression = e.config.Compression
+			info.CompressedSize = storedSize
+		}
 
 		backupInfo.AddTxnLog(info)
 	}
@@ -205,9 +251,10 @@ func (e *BackupEngine) backupSnapshots(backupDir string, backupInfo *metadata.Ba
 	snapshotDir := filepath.Join(backupDir, "snapshots")
 
 	for _, snapshot := range snapshots {
-		e.logger.Debug("Copying snapshot", zap.String("file", snapshot))
+		e.logger.Debug("Copying snapshot", zap.String("file", snapshot), zap.String("compression", e.config.Compression))
 
-		err = zkfile.CopyFile(snapshot, filepath.Join(snapshotDir, filepath.Base(snapshot)))
+		storedSize, err := zkfile.CompressFile(snapshot, filepath.Join(snapshotDir, filepath.Base(snapshot)),
+			e.config.Compression, e.config.CompressionLevel)
 		if err != nil {
 			return err
 		}
@@ -218,6 +265,13 @@ func (e *BackupEngine) backupSnapshots(backupDir string, backupInfo *metadata.Ba
 			e.logger.Warn("Failed to get snapshot info", zap.Error(err))
 			continue
 		}
+		if info.Status != "valid" {
+			e.logger.Warn("Snapshot is incomplete or corrupted", zap.String("file", snapshot), zap.String("note", info.Note))
+		}
+		if e.config.Compression != zkfile.CompressionNone {
+			info.Compression = e.config.Compression
+			info.CompressedSize = storedSize
+		}
 
 		backupInfo.AddSnapshot(info)
 	}
@@ -227,6 +281,58 @@ func (e *BackupEngine) backupSnapshots(backupDir string, backupInfo *metadata.Ba
 	return nil
 }
 
+// streamSnapshot pulls a fresh snapshot over the AdminServer snapshot command and
+// stores it as snapshot.<last zxid>, so restore and verify treat it like a copied one
+func (e *BackupEngine) streamSnapshot(backupDir string, backupInfo *metadata.BackupInfo) error {
+	snapshotDir := filepath.Join(backupDir, "snapshots")
+	partial := filepath.Join(snapshotDir, "streaming.partial")
+
+	f, err := os.Create(partial)
+	if err != nil {
+		return zkfile.NewIOError("failed to create snapshot file").WithError(err).WithContext("path", partial)
+	}
+	defer func() { _ = os.Remove(partial) }()
+
+	client := utils.NewAdminClient(e.config.AdminURL, e.config.ZkTimeout)
+	client.SetAuth(e.config.AdminAuth)
+	zxid, n, err := client.StreamSnapshot(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	e.logger.Info("Snapshot streamed", zap.String("zxid", zxid.String()), zap.Int64("size", n))
+
+	// A snapshot the server already compressed is kept as it is
+	codec, err := zkfile.DetectCompression(partial)
+	if err != nil {
+		return err
+	}
+
+	snapshot := filepath.Join(snapshotDir, zkfile.FormatZxidFileName(zkfile.FileTypeSnapshot, zxid))
+	if codec != zkfile.CompressionNone || e.config.Compression == zkfile.CompressionNone {
+		err = zkfile.MoveFile(partial, snapshot)
+	} else {
+		_, err = zkfile.CompressFile(partial, snapshot, e.config.Compression, e.config.CompressionLevel)
+	}
+	if err != nil {
+		return err
+	}
+
+	info, err := zkfile.GetSnapshotInfo(snapshot)
+	if err != nil {
+		return err
+	}
+	backupInfo.AddSnapshot(info)
+	backupInfo.SetBackupZxid(zxid)
+
+	return nil
+}
+
 // verifyBackup verifies the backup
 func (e *BackupEngine) verifyBackup(backupDir string, backupInfo *metadata.BackupInfo) error {
 	txnlogDir := filepath.Join(backupDir, "txnlogs")
@@ -251,7 +357,7 @@ func (e *BackupEngine) verifyBackup(backupDir string, backupInfo *metadata.Backu
 				zap.String("corruption_type", result.CorruptionType))
 
 			// Try to repair
-			if zkfile.DetermineFileType(path) == zkfile.FileTypeTxnLog {
+			if !e.config.SkipRepair && zkfile.DetermineFileType(path) == zkfile.FileTypeTxnLog {
 				e.logger.Info("Attempting to repair", zap.String("file", path))
 				repairedPath := path + ".repaired"
 				if _, err := zkfile.RepairTxnLog(path, repairedPath); err == nil {
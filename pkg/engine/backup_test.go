@@ -1,9 +1,16 @@
 package engine
 
 import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/zookeeper-backup/pkg/metadata"
 	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
 )
 
 func TestNewBackupEngine(t *testing.T) {
@@ -112,3 +119,152 @@ func TestFormatBytes(t *testing.T) {
 		})
 	}
 }
+
+func TestBackupEngine_Compression(t *testing.T) {
+	for _, codec := range []string{zkfile.CompressionGzip, zkfile.CompressionZstd} {
+		t.Run(codec, func(t *testing.T) {
+			root := t.TempDir()
+			zkDir := filepath.Join(root, "zk")
+			os.MkdirAll(zkDir, 0755)
+			snapshotContent := writeTestSnapshot(t, filepath.Join(zkDir, "snapshot.100000002"), 32*1024)
+			writeTestTxnLog(t, filepath.Join(zkDir, "log.100000001"), 0x100000001, 0x100000002, 0x100000003)
+
+			backupDir := filepath.Join(root, "backup")
+			engine := NewBackupEngine(&BackupConfig{
+				ZkDataDir:        zkDir,
+				ZkLogDir:         zkDir,
+				OutputDir:        root,
+				BackupID:         "backup",
+				Compression:      codec,
+				CompressionLevel: 3,
+			})
+			if err := engine.createBackupDirs(backupDir); err != nil {
+				t.Fatalf("createBackupDirs() error = %v", err)
+			}
+
+			info := metadata.NewBackupInfo("backup", zkfile.ZXID(0x100000003))
+			if err := engine.backupSnapshots(backupDir, info); err != nil {
+				t.Fatalf("backupSnapshots() error = %v", err)
+			}
+			if err := engine.backupTxnLogs(backupDir, info); err != nil {
+				t.Fatalf("backupTxnLogs() error = %v", err)
+			}
+			if err := info.SaveToFile(metadata.InfoPath(backupDir)); err != nil {
+				t.Fatalf("SaveToFile() error = %v", err)
+			}
+
+			snapshot := info.Files.Snapshots[0]
+			if snapshot.Compression != codec || snapshot.Size != int64(len(snapshotContent)) ||
+				snapshot.CompressedSize <= 0 || snapshot.CompressedSize >= snapshot.Size {
+				t.Errorf("Snapshot info = %+v", snapshot)
+			}
+			if got, _ := zkfile.DetectCompression(filepath.Join(backupDir, "snapshots", "snapshot.100000002")); got != codec {
+				t.Errorf("Stored snapshot compression = %v, want %v", got, codec)
+			}
+			if txnlog := info.Files.TxnLogs[0]; txnlog.Compression != codec || txnlog.TransactionCount != 3 {
+				t.Errorf("TxnLog info = %+v", txnlog)
+			}
+
+			// Verify checks compressed files against the recorded checksums
+			report, err := NewVerifyEngine(&VerifyConfig{BackupDir: backupDir}).Verify()
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if report.Status != metadata.BackupStatusValid {
+				t.Errorf("Verify() status = %v, want valid", report.Status)
+			}
+
+			// Restore decompresses into the ZooKeeper directories
+			dataDir := filepath.Join(root, "restore", "data")
+			logDir := filepath.Join(root, "restore", "log")
+			restore := NewRestoreEngine(&RestoreConfig{BackupDir: backupDir, ZkDataDir: dataDir, ZkLogDir: logDir, Force: true})
+			if err = restore.Run(); err != nil {
+				t.Fatalf("Restore Run() error = %v", err)
+			}
+			got, _ := os.ReadFile(filepath.Join(dataDir, "snapshot.100000002"))
+			if !bytes.Equal(got, snapshotContent) {
+				t.Error("Restored snapshot does not match original")
+			}
+			result, err := zkfile.ValidateTxnLog(filepath.Join(logDir, "log.100000001"))
+			if err != nil || !result.IsValid || result.ValidTransactionCount != 3 {
+				t.Errorf("Restored txnlog = %+v, err = %v", result, err)
+			}
+			if got, _ := zkfile.DetectCompression(filepath.Join(logDir, "log.100000001")); got != zkfile.CompressionNone {
+				t.Errorf("Restored txnlog compression = %v, want none", got)
+			}
+		})
+	}
+}
+
+func TestBackupEngine_Stream(t *testing.T) {
+	snapshotContent := writeTestSnapshot(t, filepath.Join(t.TempDir(), "snapshot.500000003"), 32*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/commands/snapshot":
+			w.Header().Set(utils.SnapshotLastZxidHeader, "0x500000003")
+			w.Write(snapshotContent)
+		case "/commands/srvr":
+			w.Write([]byte(`{"version":"3.9.1","server_stats":{"server_state":"leader","last_processed_zxid":21474836484},"error":null}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	for _, codec := range []string{zkfile.CompressionNone, zkfile.CompressionZstd} {
+		t.Run(codec, func(t *testing.T) {
+			root := t.TempDir()
+			engine := NewBackupEngine(&BackupConfig{
+				Mode:         BackupModeStream,
+				ZkHost:       "127.0.0.1:1",
+				StatsBackend: utils.StatsBackendAdmin,
+				AdminURL:     server.URL,
+				OutputDir:    root,
+				BackupID:     "backup",
+				Verify:       true,
+				Compression:  codec,
+			})
+			if err := engine.Run(); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			backupDir := filepath.Join(root, "backup")
+			info, err := metadata.LoadBackupInfo(metadata.InfoPath(backupDir))
+			if err != nil {
+				t.Fatalf("LoadBackupInfo() error = %v", err)
+			}
+			if info.Mode != BackupModeStream || info.ZooKeeper.AdminURL != server.URL {
+				t.Errorf("Mode = %q, AdminURL = %q", info.Mode, info.ZooKeeper.AdminURL)
+			}
+			if info.BackupZxid.Hex != "500000003" {
+				t.Errorf("BackupZxid = %v, want the snapshot zxid 500000003", info.BackupZxid.Hex)
+			}
+			if len(info.Files.Snapshots) != 1 || info.Files.Snapshots[0].Name != "snapshot.500000003" ||
+				info.Files.Snapshots[0].Size != int64(len(snapshotContent)) {
+				t.Fatalf("Snapshots = %+v", info.Files.Snapshots)
+			}
+			if names := listDirNames(t, filepath.Join(backupDir, "snapshots")); len(names) != 1 {
+				t.Errorf("snapshots dir = %v, want only the streamed snapshot", names)
+			}
+
+			report, err := NewVerifyEngine(&VerifyConfig{BackupDir: backupDir}).Verify()
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if report.Status != metadata.BackupStatusValid {
+				t.Errorf("Verify() status = %v, want valid", report.Status)
+			}
+
+			dataDir := filepath.Join(root, "restore", "data")
+			logDir := filepath.Join(root, "restore", "log")
+			restore := NewRestoreEngine(&RestoreConfig{BackupDir: backupDir, ZkDataDir: dataDir, ZkLogDir: logDir, Force: true})
+			if err = restore.Run(); err != nil {
+				t.Fatalf("Restore Run() error = %v", err)
+			}
+			got, _ := os.ReadFile(filepath.Join(dataDir, "snapshot.500000003"))
+			if !bytes.Equal(got, snapshotContent) {
+				t.Error("Restored snapshot does not match the streamed one")
+			}
+		})
+	}
+}
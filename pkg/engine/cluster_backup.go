@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// ClusterBackupEngine backs up several clusters in parallel
+type ClusterBackupEngine struct {
+	config *ClusterBackupConfig
+	logger *zap.Logger
+}
+
+// ClusterBackupResult is the outcome of the backup of a single cluster
+type ClusterBackupResult struct {
+	Cluster   string
+	BackupID  string
+	BackupDir string
+	Duration  time.Duration
+	Err       error
+}
+
+// NewClusterBackupEngine creates a new cluster backup engine
+func NewClusterBackupEngine(config *ClusterBackupConfig) *ClusterBackupEngine {
+	return &ClusterBackupEngine{
+		config: config,
+		logger: utils.GetLogger(),
+	}
+}
+
+// Run backs up every cluster, prints a summary and fails if any backup failed
+func (e *ClusterBackupEngine) Run() error {
+	results, err := e.Backup()
+	if err != nil {
+		return err
+	}
+
+	e.printSummary(results)
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 && failed < len(results) {
+		return zkfile.NewPartialError("some cluster backups failed").
+			WithContext("failed", failed).WithContext("total", len(results))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d cluster backups failed", failed, len(results))
+	}
+
+	return nil
+}
+
+// Backup runs the backup of every cluster, at most MaxParallel at a time.
+// A failed cluster does not stop the others; results keep the order of the
+// configured backups.
+func (e *ClusterBackupEngine) Backup() ([]*ClusterBackupResult, error) {
+	// 1. Validate configuration
+	if err := e.config.Validate(); err != nil {
+		return nil, zkfile.NewConfigurationError("invalid configuration").WithError(err)
+	}
+
+	e.logger.Info("Starting cluster backups",
+		zap.Int("clusters", len(e.config.Backups)), zap.Int("max_parallel", e.config.MaxParallel))
+
+	// 2. Run the backups with a bounded number of workers
+	results := make([]*ClusterBackupResult, len(e.config.Backups))
+	slots := make(chan struct{}, e.config.MaxParallel)
+	var wg sync.WaitGroup
+	for i, config := range e.config.Backups {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, config *BackupConfig) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = e.backupCluster(config)
+		}(i, config)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// backupCluster runs the backup of a single cluster
+func (e *ClusterBackupEngine) backupCluster(config *BackupConfig) *ClusterBackupResult {
+	startTime := time.Now()
+
+	err := NewBackupEngine(config).Run()
+	result := &ClusterBackupResult{
+		Cluster:   config.Cluster,
+		BackupID:  config.BackupID,
+		BackupDir: config.OutputDir,
+		Duration:  time.Since(startTime),
+		Err:       err,
+	}
+	if err != nil {
+		e.logger.Error("Cluster backup failed", zap.String("cluster", config.Cluster), zap.Error(err))
+	}
+
+	return result
+}
+
+// printSummary prints one line per cluster
+func (e *ClusterBackupEngine) printSummary(results []*ClusterBackupResult) {
+	succeeded := 0
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tSTATUS\tBACKUP ID\tDURATION\tERROR")
+	for _, result := range results {
+		status, detail := "✅ ok", ""
+		if result.Err != nil {
+			status, detail = "❌ failed", result.Err.Error()
+		} else {
+			succeeded++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", result.Cluster, status, result.BackupID,
+			result.Duration.Round(time.Millisecond), detail)
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\nClusters: %d, succeeded: %d, failed: %d\n", len(results), succeeded, len(results)-succeeded)
+}
@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zookeeper-backup/pkg/metadata"
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// newClusterTestBackup returns the backup configuration of a cluster whose
+// ZooKeeper directory holds one snapshot and one txnlog
+func newClusterTestBackup(t *testing.T, root, cluster string) *BackupConfig {
+	t.Helper()
+
+	zkDir := filepath.Join(root, "zk", cluster)
+	if err := os.MkdirAll(zkDir, 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	os.WriteFile(filepath.Join(zkDir, "snapshot.100000002"), append([]byte("ZKSN"), make([]byte, 1024)...), 0644)
+	writeTestTxnLog(t, filepath.Join(zkDir, "log.100000001"), 0x100000001, 0x100000002, 0x100000003)
+
+	return &BackupConfig{
+		Cluster:      cluster,
+		ZkDataDir:    zkDir,
+		ZkLogDir:     zkDir,
+		ZkHost:       "127.0.0.1:1",
+		StatsBackend: utils.StatsBackendFourLetter,
+		OutputDir:    filepath.Join(root, "backup", cluster),
+		BackupID:     "backup",
+		Compression:  zkfile.CompressionNone,
+	}
+}
+
+func TestClusterBackupEngine_Backup(t *testing.T) {
+	root := t.TempDir()
+
+	orders := newClusterTestBackup(t, root, "orders")
+	users := newClusterTestBackup(t, root, "users")
+	broken := newClusterTestBackup(t, root, "broken")
+	broken.ZkDataDir = filepath.Join(root, "missing")
+
+	engine := NewClusterBackupEngine(&ClusterBackupConfig{
+		Backups:     []*BackupConfig{orders, broken, users},
+		MaxParallel: 2,
+	})
+	results, err := engine.Backup()
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("Backup() returned %d results, want 3", len(results))
+	}
+	for i, cluster := range []string{"orders", "broken", "users"} {
+		if results[i].Cluster != cluster {
+			t.Errorf("results[%d].Cluster = %q, want %q", i, results[i].Cluster, cluster)
+		}
+	}
+	if results[1].Err == nil {
+		t.Error("Backup of a cluster with a missing data dir should fail")
+	}
+
+	for _, result := range []*ClusterBackupResult{results[0], results[2]} {
+		if result.Err != nil {
+			t.Errorf("%s: Err = %v", result.Cluster, result.Err)
+			continue
+		}
+		info, err := metadata.LoadBackupInfo(metadata.InfoPath(filepath.Join(root, "backup", result.Cluster, "backup")))
+		if err != nil {
+			t.Fatalf("%s: LoadBackupInfo() error = %v", result.Cluster, err)
+		}
+		if info.Cluster != result.Cluster || len(info.Files.Snapshots) != 1 || len(info.Files.TxnLogs) != 1 {
+			t.Errorf("%s: backup info = %+v", result.Cluster, info)
+		}
+	}
+
+	t.Run("run reports failures", func(t *testing.T) {
+		broken.BackupID = "backup-2"
+		err := NewClusterBackupEngine(&ClusterBackupConfig{Backups: []*BackupConfig{broken}}).Run()
+		if err == nil || !strings.Contains(err.Error(), "1 of 1 cluster backups failed") {
+			t.Errorf("Run() error = %v, want failure summary", err)
+		}
+		if code := zkfile.ExitCode(err, zkfile.ExitBackupFailed); code != zkfile.ExitBackupFailed {
+			t.Errorf("ExitCode() = %d, want %d", code, zkfile.ExitBackupFailed)
+		}
+	})
+
+	t.Run("run reports partial success", func(t *testing.T) {
+		orders.BackupID = "backup-3"
+		broken.BackupID = "backup-3"
+		err := NewClusterBackupEngine(&ClusterBackupConfig{Backups: []*BackupConfig{orders, broken}}).Run()
+		if code := zkfile.ExitCode(err, zkfile.ExitBackupFailed); code != zkfile.ExitPartialSuccess {
+			t.Errorf("Run() error = %v, exit code %d, want partial success", err, code)
+		}
+	})
+}
+
+func TestClusterBackupConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *ClusterBackupConfig
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name:   "valid config",
+			config: &ClusterBackupConfig{Backups: []*BackupConfig{{Cluster: "a"}, {Cluster: "b"}}},
+		},
+		{
+			name:    "no clusters",
+			config:  &ClusterBackupConfig{},
+			wantErr: true,
+			errMsg:  "no clusters",
+		},
+		{
+			name:    "duplicate cluster",
+			config:  &ClusterBackupConfig{Backups: []*BackupConfig{{Cluster: "a"}, {Cluster: "a"}}},
+			wantErr: true,
+			errMsg:  "duplicate cluster",
+		},
+		{
+			name:    "negative parallelism",
+			config:  &ClusterBackupConfig{Backups: []*BackupConfig{{Cluster: "a"}}, MaxParallel: -1},
+			wantErr: true,
+			errMsg:  "max-parallel",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Validate() error = %v, want error containing %v", err, tt.errMsg)
+			}
+			if !tt.wantErr && tt.config.MaxParallel != 4 {
+				t.Errorf("MaxParallel = %d, want default 4", tt.config.MaxParallel)
+			}
+		})
+	}
+}
@@ -2,19 +2,43 @@ package engine
 
 import (
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+const (
+	// BackupModePhysical copies snapshot and txnlog files from the ZooKeeper directories
+	BackupModePhysical = "physical"
+
+	// BackupModeStream pulls a fresh snapshot over the AdminServer snapshot command (ZooKeeper 3.9+)
+	BackupModeStream = "stream"
+
+	// BackupModeLogical walks the znode tree over a client connection and stores a znode archive
+	BackupModeLogical = "logical"
 )
 
 // BackupConfig backup configuration
 type BackupConfig struct {
-	ZkDataDir   string
-	ZkLogDir    string
-	OutputDir   string
-	ZkHost      string
-	BackupID    string
-	Verify      bool
-	Compression string
-	Verbose     bool
+	Mode             string // physical, stream or logical
+	Cluster          string // cluster profile name, recorded in the metadata
+	ZkDataDir        string
+	ZkLogDir         string
+	OutputDir        string
+	ZkHost           string
+	Paths            []string      // subtrees walked in logical mode, defaults to "/"
+	ZkTimeout        time.Duration // timeout of ZooKeeper and AdminServer requests, defaults to 5s
+	StatsBackend     string        // how server state is read: auto, 4lw or admin
+	AdminURL         string        // AdminServer URL, defaults to port 8080 on the ZooKeeper host
+	AdminAuth        string        // Authorization header for AdminServer commands, e.g. "digest root:secret"
+	BackupID         string
+	Verify           bool
+	SkipRepair       bool // do not repair corrupted txnlogs found by Verify
+	Compression      string
+	CompressionLevel int // 0 selects the codec default
+	Verbose          bool
 }
 
 // Validate validates the backup configuration
@@ -25,27 +49,108 @@ func (c *BackupConfig) Validate() error {
 	if c.BackupID == "" {
 		c.BackupID = generateBackupID()
 	}
-	if c.ZkLogDir == "" {
-		return fmt.Errorf("zk-log-dir is required")
+	if c.ZkTimeout <= 0 {
+		c.ZkTimeout = 5 * time.Second
+	}
+	if c.StatsBackend == "" {
+		c.StatsBackend = utils.StatsBackendAuto
 	}
-	if c.ZkDataDir == "" {
-		return fmt.Errorf("zk-data-dir is required")
+	if err := utils.ValidateStatsBackend(c.StatsBackend); err != nil {
+		return fmt.Errorf("invalid stats backend: %w", err)
+	}
+	switch c.Mode {
+	case "", BackupModePhysical:
+		c.Mode = BackupModePhysical
+		if c.ZkLogDir == "" {
+			return fmt.Errorf("zk-log-dir is required")
+		}
+		if c.ZkDataDir == "" {
+			return fmt.Errorf("zk-data-dir is required")
+		}
+	case BackupModeStream:
+		// The snapshot comes over HTTP, the ZooKeeper directories are not read
+		if c.AdminURL == "" {
+			c.AdminURL = utils.DefaultAdminURL(c.ZkHost)
+		}
+	case BackupModeLogical:
+		// The znodes are read over the client connection, the ZooKeeper directories are not read
+		if len(c.Paths) == 0 {
+			c.Paths = []string{"/"}
+		}
+		for _, path := range c.Paths {
+			if err := validateZnodePath(path); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported backup mode: %s", c.Mode)
 	}
 	if c.OutputDir == "" {
 		return fmt.Errorf("output-dir is required")
 	}
+	if c.Compression == "" {
+		c.Compression = zkfile.CompressionNone
+	}
+	if err := zkfile.ValidateCompression(c.Compression, c.CompressionLevel); err != nil {
+		return fmt.Errorf("invalid compression: %w", err)
+	}
+	return nil
+}
+
+// ClusterBackupConfig configures the backup of several clusters
+type ClusterBackupConfig struct {
+	Backups     []*BackupConfig // one per cluster, BackupConfig.Cluster names it
+	MaxParallel int             // backups running at the same time, defaults to 4
+	Verbose     bool
+}
+
+// Validate validates the cluster backup configuration
+func (c *ClusterBackupConfig) Validate() error {
+	if len(c.Backups) == 0 {
+		return fmt.Errorf("no clusters to back up")
+	}
+	if c.MaxParallel == 0 {
+		c.MaxParallel = 4
+	}
+	if c.MaxParallel < 0 {
+		return fmt.Errorf("max-parallel must be positive")
+	}
+
+	seen := make(map[string]bool, len(c.Backups))
+	for _, backup := range c.Backups {
+		if backup.Cluster == "" {
+			return fmt.Errorf("cluster name is required")
+		}
+		if seen[backup.Cluster] {
+			return fmt.Errorf("duplicate cluster: %s", backup.Cluster)
+		}
+		seen[backup.Cluster] = true
+	}
 	return nil
 }
 
+// Restore targets
+const (
+	RestoreTargetData = "data"
+	RestoreTargetLive = "live"
+)
+
 // RestoreConfig restore configuration
 type RestoreConfig struct {
 	BackupDir      string
+	Target         string // data directories, or the subtree at Path in a live ensemble
+	Path           string // live target only
 	ZkDataDir      string
 	ZkLogDir       string
+	ZkHost         string // ensemble of the live target, client port checked to be down for the data target
+	ZkTimeout      time.Duration
+	Auth           []string // live target only, scheme:auth added to the session
 	Force          bool
 	DryRun         bool
 	SkipVerify     bool
 	TruncateToZxid string
+	RestoreToTime  string // RFC3339, resolved to the last ZXID committed at or before it
+	SafetyDir      string // where existing data is moved before restore, defaults next to ZkDataDir
 	Verbose        bool
 }
 
@@ -54,11 +159,68 @@ func (c *RestoreConfig) Validate() error {
 	if c.BackupDir == "" {
 		return fmt.Errorf("backup-dir is required")
 	}
-	if c.ZkLogDir == "" {
-		return fmt.Errorf("zk-log-dir is required")
+	if c.Target == "" {
+		c.Target = RestoreTargetData
+	}
+	switch c.Target {
+	case RestoreTargetData:
+		if c.Path != "" {
+			return fmt.Errorf("path requires target live")
+		}
+		if c.ZkLogDir == "" {
+			return fmt.Errorf("zk-log-dir is required")
+		}
+		if c.ZkDataDir == "" {
+			return fmt.Errorf("zk-data-dir is required")
+		}
+	case RestoreTargetLive:
+		if c.Path == "" {
+			return fmt.Errorf("path is required with target live")
+		}
+		if err := validateZnodePath(c.Path); err != nil {
+			return err
+		}
+		if err := validateAuth(c.Auth); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported restore target: %s", c.Target)
+	}
+	if c.ZkHost == "" {
+		c.ZkHost = "localhost:2181"
+	}
+	if c.ZkTimeout <= 0 {
+		c.ZkTimeout = 5 * time.Second
 	}
-	if c.ZkDataDir == "" {
-		return fmt.Errorf("zk-data-dir is required")
+	if c.TruncateToZxid != "" {
+		if _, err := zkfile.ParseZXID(c.TruncateToZxid); err != nil {
+			return fmt.Errorf("invalid truncate-to-zxid: %w", err)
+		}
+	}
+	if c.RestoreToTime != "" {
+		if c.TruncateToZxid != "" {
+			return fmt.Errorf("truncate-to-zxid and restore-to-time are mutually exclusive")
+		}
+		if _, err := time.Parse(time.RFC3339, c.RestoreToTime); err != nil {
+			return fmt.Errorf("invalid restore-to-time (expected RFC3339): %w", err)
+		}
+	}
+	return nil
+}
+
+// RollbackConfig rollback configuration
+type RollbackConfig struct {
+	SafetyDir string // safety directory to restore, defaults to the newest one next to ZkDataDir
+	ZkDataDir string
+	Force     bool
+	DryRun    bool
+	Verbose   bool
+}
+
+// Validate validates the rollback configuration
+func (c *RollbackConfig) Validate() error {
+	if c.SafetyDir == "" && c.ZkDataDir == "" {
+		return fmt.Errorf("safety-dir or zk-data-dir is required")
 	}
 	return nil
 }
@@ -68,6 +230,7 @@ type VerifyConfig struct {
 	BackupDir    string
 	Fix          bool
 	OutputFormat string
+	Timeout      time.Duration // aborts the verification when exceeded, 0 disables
 	Verbose      bool
 }
 
@@ -82,7 +245,240 @@ func (c *VerifyConfig) Validate() error {
 	return nil
 }
 
+// Export formats
+const (
+	ExportFormatJSON  = "json"
+	ExportFormatYAML  = "yaml"
+	ExportFormatJSONL = "jsonl" // one node per line
+)
+
+// Encodings of znode data in exports
+const (
+	DataEncodingUTF8   = "utf8"
+	DataEncodingBase64 = "base64"
+)
+
+// ExportConfig export configuration
+type ExportConfig struct {
+	BackupDir    string
+	Path         string // root of the exported subtree, defaults to "/"
+	Zxid         string // export the state at this ZXID
+	Time         string // RFC3339, export the state at the last ZXID committed at or before it
+	Format       string // json, yaml or jsonl
+	DataEncoding string // utf8 or base64; data that is not valid UTF-8 is always base64
+	IncludeStats bool
+	IncludeACL   bool
+	Output       string // output file, stdout when empty
+	Verbose      bool
+}
+
+// Validate validates the export configuration
+func (c *ExportConfig) Validate() error {
+	if c.BackupDir == "" {
+		return fmt.Errorf("backup-dir is required")
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	if err := validateZnodePath(c.Path); err != nil {
+		return err
+	}
+	if c.Zxid != "" {
+		if _, err := zkfile.ParseZXID(c.Zxid); err != nil {
+			return fmt.Errorf("invalid zxid: %w", err)
+		}
+	}
+	if c.Time != "" {
+		if c.Zxid != "" {
+			return fmt.Errorf("zxid and time are mutually exclusive")
+		}
+		if _, err := time.Parse(time.RFC3339, c.Time); err != nil {
+			return fmt.Errorf("invalid time (expected RFC3339): %w", err)
+		}
+	}
+	if c.Format == "" {
+		c.Format = ExportFormatJSON
+	}
+	switch c.Format {
+	case ExportFormatJSON, ExportFormatYAML, ExportFormatJSONL:
+	default:
+		return fmt.Errorf("unsupported format: %s", c.Format)
+	}
+	if c.DataEncoding == "" {
+		c.DataEncoding = DataEncodingUTF8
+	}
+	if c.DataEncoding != DataEncodingUTF8 && c.DataEncoding != DataEncodingBase64 {
+		return fmt.Errorf("unsupported data encoding: %s", c.DataEncoding)
+	}
+	return nil
+}
+
+// Conflict policies of a load, for znodes that exist with different content
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictFail      = "fail"
+)
+
+// ACL handling of a load
+const (
+	ACLModePreserve = "preserve" // the ACLs of the source, which must have them for created znodes
+	ACLModeRewrite  = "rewrite"  // RewriteACL on every loaded znode
+)
+
+// LoadConfig load configuration
+type LoadConfig struct {
+	BackupDir     string        // logical backup, or physical backup replayed to Zxid or Time
+	BackupBaseDir string        // where a BackupDir that is a backup ID is looked up
+	File          string        // export file: json, yaml or jsonl
+	Path          string        // subtree to load, defaults to "/"
+	Zxid          string        // physical backups only
+	Time          string        // RFC3339, physical backups only
+	ZkHost        string        // target ensemble
+	ZkTimeout     time.Duration // defaults to 5s
+	Auth          []string      // scheme:auth added to the session, e.g. digest:user:password
+	OnConflict    string        // skip, overwrite or fail
+	ACLMode       string        // preserve or rewrite
+	RewriteACL    string        // ACL set with ACLModeRewrite, defaults to world:anyone:cdrwa
+	BatchSize     int           // operations per multi, defaults to 100
+	Delete        bool          // delete znodes of the subtree that the source lacks
+	DryRun        bool
+	Verbose       bool
+}
+
+// Validate validates the load configuration
+func (c *LoadConfig) Validate() error {
+	if c.BackupDir == "" && c.File == "" {
+		return fmt.Errorf("backup-dir or file is required")
+	}
+	if c.BackupDir != "" && c.File != "" {
+		return fmt.Errorf("backup-dir and file are mutually exclusive")
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	if err := validateZnodePath(c.Path); err != nil {
+		return err
+	}
+	if (c.Zxid != "" || c.Time != "") && c.BackupDir == "" {
+		return fmt.Errorf("zxid and time require backup-dir")
+	}
+	if c.Zxid != "" {
+		if _, err := zkfile.ParseZXID(c.Zxid); err != nil {
+			return fmt.Errorf("invalid zxid: %w", err)
+		}
+	}
+	if c.Time != "" {
+		if c.Zxid != "" {
+			return fmt.Errorf("zxid and time are mutually exclusive")
+		}
+		if _, err := time.Parse(time.RFC3339, c.Time); err != nil {
+			return fmt.Errorf("invalid time (expected RFC3339): %w", err)
+		}
+	}
+	if c.ZkHost == "" {
+		c.ZkHost = "localhost:2181"
+	}
+	if c.ZkTimeout <= 0 {
+		c.ZkTimeout = 5 * time.Second
+	}
+	if err := validateAuth(c.Auth); err != nil {
+		return err
+	}
+	if c.OnConflict == "" {
+		c.OnConflict = ConflictSkip
+	}
+	switch c.OnConflict {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+	default:
+		return fmt.Errorf("unsupported conflict policy: %s", c.OnConflict)
+	}
+	if c.ACLMode == "" {
+		c.ACLMode = ACLModePreserve
+	}
+	switch c.ACLMode {
+	case ACLModePreserve:
+		if c.RewriteACL != "" {
+			return fmt.Errorf("rewrite-acl requires acl mode rewrite")
+		}
+	case ACLModeRewrite:
+		if c.RewriteACL == "" {
+			c.RewriteACL = "world:anyone:cdrwa"
+		}
+		if _, err := ParseACL(c.RewriteACL); err != nil {
+			return fmt.Errorf("invalid rewrite-acl: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported acl mode: %s", c.ACLMode)
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = 100
+	}
+	if c.BatchSize < 0 {
+		return fmt.Errorf("batch-size must be positive")
+	}
+	return nil
+}
+
+// PruneConfig prune configuration
+type PruneConfig struct {
+	BackupBaseDir string
+	KeepDays      int
+	KeepCount     int
+	KeepMinCount  int
+	KeepHourly    int
+	KeepDaily     int
+	KeepWeekly    int
+	KeepMonthly   int
+	DryRun        bool
+	Force         bool
+	Verbose       bool
+}
+
+// Validate validates the prune configuration
+func (c *PruneConfig) Validate() error {
+	if c.BackupBaseDir == "" {
+		return fmt.Errorf("backup-base-dir is required")
+	}
+	if c.KeepDays < 0 {
+		return fmt.Errorf("keep-days must not be negative")
+	}
+	if c.KeepCount < 0 {
+		return fmt.Errorf("keep-count must not be negative")
+	}
+	if c.KeepMinCount < 0 {
+		return fmt.Errorf("keep-min-count must not be negative")
+	}
+	if c.KeepHourly < 0 || c.KeepDaily < 0 || c.KeepWeekly < 0 || c.KeepMonthly < 0 {
+		return fmt.Errorf("keep-hourly, keep-daily, keep-weekly and keep-monthly must not be negative")
+	}
+	return nil
+}
+
+// HasTieredRetention reports whether any grandfather-father-son tier is configured
+func (c *PruneConfig) HasTieredRetention() bool {
+	return c.KeepHourly > 0 || c.KeepDaily > 0 || c.KeepWeekly > 0 || c.KeepMonthly > 0
+}
+
 // generateBackupID generates a backup ID with timestamp
 func generateBackupID() string {
 	return fmt.Sprintf("backup-%s", time.Now().Format("20060102-150405"))
 }
+
+// validateZnodePath checks that path is an absolute znode path
+func validateZnodePath(path string) error {
+	if !strings.HasPrefix(path, "/") || (path != "/" && strings.HasSuffix(path, "/")) || strings.Contains(path, "//") {
+		return fmt.Errorf("invalid path: %s", path)
+	}
+	return nil
+}
+
+// validateAuth checks that every session authentication is scheme:auth
+func validateAuth(auths []string) error {
+	for _, auth := range auths {
+		if i := strings.Index(auth, ":"); i <= 0 || i == len(auth)-1 {
+			return fmt.Errorf("invalid auth (expected scheme:auth): %s", auth)
+		}
+	}
+	return nil
+}
@@ -66,6 +66,100 @@ func TestBackupConfig_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "admin stats backend",
+			config: &BackupConfig{
+				ZkDataDir:    "/data",
+				ZkLogDir:     "/logs",
+				OutputDir:    "/backup",
+				StatsBackend: "admin",
+				AdminURL:     "http://zk-0:8080",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unsupported stats backend",
+			config: &BackupConfig{
+				ZkDataDir:    "/data",
+				ZkLogDir:     "/logs",
+				OutputDir:    "/backup",
+				StatsBackend: "jmx",
+			},
+			wantErr: true,
+			errMsg:  "invalid stats backend",
+		},
+		{
+			name: "stream mode without zookeeper dirs",
+			config: &BackupConfig{
+				Mode:      BackupModeStream,
+				OutputDir: "/backup",
+			},
+			wantErr: false,
+		},
+		{
+			name: "logical mode without zookeeper dirs",
+			config: &BackupConfig{
+				Mode:      BackupModeLogical,
+				Paths:     []string{"/app", "/config"},
+				OutputDir: "/backup",
+			},
+			wantErr: false,
+		},
+		{
+			name: "logical mode with relative path",
+			config: &BackupConfig{
+				Mode:      BackupModeLogical,
+				Paths:     []string{"app"},
+				OutputDir: "/backup",
+			},
+			wantErr: true,
+			errMsg:  "invalid path",
+		},
+		{
+			name: "unsupported mode",
+			config: &BackupConfig{
+				Mode:      "rsync",
+				ZkDataDir: "/data",
+				ZkLogDir:  "/logs",
+				OutputDir: "/backup",
+			},
+			wantErr: true,
+			errMsg:  "unsupported backup mode",
+		},
+		{
+			name: "gzip with level",
+			config: &BackupConfig{
+				ZkDataDir:        "/data",
+				ZkLogDir:         "/logs",
+				OutputDir:        "/backup",
+				Compression:      "gzip",
+				CompressionLevel: 9,
+			},
+			wantErr: false,
+		},
+		{
+			name: "unsupported compression",
+			config: &BackupConfig{
+				ZkDataDir:   "/data",
+				ZkLogDir:    "/logs",
+				OutputDir:   "/backup",
+				Compression: "lz4",
+			},
+			wantErr: true,
+			errMsg:  "invalid compression",
+		},
+		{
+			name: "compression level out of range",
+			config: &BackupConfig{
+				ZkDataDir:        "/data",
+				ZkLogDir:         "/logs",
+				OutputDir:        "/backup",
+				Compression:      "zstd",
+				CompressionLevel: 23,
+			},
+			wantErr: true,
+			errMsg:  "invalid compression",
+		},
 	}
 
 	for _, tt := range tests {
@@ -137,6 +231,122 @@ func TestRestoreConfig_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "zk-log-dir is required",
 		},
+		{
+			name: "valid truncate zxid",
+			config: &RestoreConfig{
+				BackupDir:      "/backup/backup-123",
+				ZkDataDir:      "/data",
+				ZkLogDir:       "/logs",
+				TruncateToZxid: "0x500000001",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid truncate zxid",
+			config: &RestoreConfig{
+				BackupDir:      "/backup/backup-123",
+				ZkDataDir:      "/data",
+				ZkLogDir:       "/logs",
+				TruncateToZxid: "not-a-zxid",
+			},
+			wantErr: true,
+			errMsg:  "invalid truncate-to-zxid",
+		},
+		{
+			name: "valid restore time",
+			config: &RestoreConfig{
+				BackupDir:     "/backup/backup-123",
+				ZkDataDir:     "/data",
+				ZkLogDir:      "/logs",
+				RestoreToTime: "2025-01-15T10:30:00+08:00",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid restore time",
+			config: &RestoreConfig{
+				BackupDir:     "/backup/backup-123",
+				ZkDataDir:     "/data",
+				ZkLogDir:      "/logs",
+				RestoreToTime: "2025-01-15 10:30",
+			},
+			wantErr: true,
+			errMsg:  "invalid restore-to-time",
+		},
+		{
+			name: "restore time with truncate zxid",
+			config: &RestoreConfig{
+				BackupDir:      "/backup/backup-123",
+				ZkDataDir:      "/data",
+				ZkLogDir:       "/logs",
+				TruncateToZxid: "0x500000001",
+				RestoreToTime:  "2025-01-15T10:30:00Z",
+			},
+			wantErr: true,
+			errMsg:  "mutually exclusive",
+		},
+		{
+			name: "valid live target",
+			config: &RestoreConfig{
+				BackupDir:     "/backup/backup-123",
+				Target:        RestoreTargetLive,
+				Path:          "/services/foo",
+				ZkHost:        "zk-0:2181",
+				Auth:          []string{"digest:admin:secret"},
+				RestoreToTime: "2025-01-15T10:30:00Z",
+			},
+			wantErr: false,
+		},
+		{
+			name: "live target without path",
+			config: &RestoreConfig{
+				BackupDir: "/backup/backup-123",
+				Target:    RestoreTargetLive,
+			},
+			wantErr: true,
+			errMsg:  "path is required with target live",
+		},
+		{
+			name: "live target with invalid path",
+			config: &RestoreConfig{
+				BackupDir: "/backup/backup-123",
+				Target:    RestoreTargetLive,
+				Path:      "services/foo",
+			},
+			wantErr: true,
+			errMsg:  "invalid path",
+		},
+		{
+			name: "live target with invalid auth",
+			config: &RestoreConfig{
+				BackupDir: "/backup/backup-123",
+				Target:    RestoreTargetLive,
+				Path:      "/services/foo",
+				Auth:      []string{"digest:"},
+			},
+			wantErr: true,
+			errMsg:  "invalid auth",
+		},
+		{
+			name: "path with data target",
+			config: &RestoreConfig{
+				BackupDir: "/backup/backup-123",
+				ZkDataDir: "/data",
+				ZkLogDir:  "/logs",
+				Path:      "/services/foo",
+			},
+			wantErr: true,
+			errMsg:  "path requires target live",
+		},
+		{
+			name: "unsupported target",
+			config: &RestoreConfig{
+				BackupDir: "/backup/backup-123",
+				Target:    "cluster",
+			},
+			wantErr: true,
+			errMsg:  "unsupported restore target",
+		},
 	}
 
 	for _, tt := range tests {
@@ -198,3 +408,219 @@ func TestVerifyConfig_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestPruneConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *PruneConfig
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid config",
+			config: &PruneConfig{
+				BackupBaseDir: "/backup",
+				KeepDays:      7,
+				KeepMinCount:  3,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "missing base dir",
+			config:  &PruneConfig{KeepDays: 7},
+			wantErr: true,
+			errMsg:  "backup-base-dir is required",
+		},
+		{
+			name: "negative keep days",
+			config: &PruneConfig{
+				BackupBaseDir: "/backup",
+				KeepDays:      -1,
+			},
+			wantErr: true,
+			errMsg:  "keep-days must not be negative",
+		},
+		{
+			name: "negative keep count",
+			config: &PruneConfig{
+				BackupBaseDir: "/backup",
+				KeepCount:     -1,
+			},
+			wantErr: true,
+			errMsg:  "keep-count must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PruneConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && err != nil && !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("PruneConfig.Validate() error = %v, want error containing %v", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestExportConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *ExportConfig
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name:   "valid config",
+			config: &ExportConfig{BackupDir: "/backup/backup-123", Path: "/app", Zxid: "0x100000001", Format: ExportFormatJSONL},
+		},
+		{
+			name:    "missing backup dir",
+			config:  &ExportConfig{},
+			wantErr: true,
+			errMsg:  "backup-dir is required",
+		},
+		{
+			name:    "relative path",
+			config:  &ExportConfig{BackupDir: "/backup/backup-123", Path: "app"},
+			wantErr: true,
+			errMsg:  "invalid path",
+		},
+		{
+			name:    "trailing slash",
+			config:  &ExportConfig{BackupDir: "/backup/backup-123", Path: "/app/"},
+			wantErr: true,
+			errMsg:  "invalid path",
+		},
+		{
+			name:    "zxid and time",
+			config:  &ExportConfig{BackupDir: "/backup/backup-123", Zxid: "0x100000001", Time: "2025-01-15T10:00:00Z"},
+			wantErr: true,
+			errMsg:  "mutually exclusive",
+		},
+		{
+			name:    "invalid format",
+			config:  &ExportConfig{BackupDir: "/backup/backup-123", Format: "xml"},
+			wantErr: true,
+			errMsg:  "unsupported format",
+		},
+		{
+			name:    "invalid data encoding",
+			config:  &ExportConfig{BackupDir: "/backup/backup-123", DataEncoding: "hex"},
+			wantErr: true,
+			errMsg:  "unsupported data encoding",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ExportConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ExportConfig.Validate() error = %v, want error containing %v", err, tt.errMsg)
+			}
+		})
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		config := &ExportConfig{BackupDir: "/backup/backup-123"}
+		if err := config.Validate(); err != nil {
+			t.Fatalf("ExportConfig.Validate() error = %v", err)
+		}
+		if config.Path != "/" || config.Format != ExportFormatJSON || config.DataEncoding != DataEncodingUTF8 {
+			t.Errorf("defaults = %+v", config)
+		}
+	})
+}
+
+func TestLoadConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *LoadConfig
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid config",
+			config: &LoadConfig{BackupDir: "/backup/backup-123", Path: "/app", Zxid: "0x100000001", Auth: []string{"digest:admin:secret"},
+				OnConflict: ConflictOverwrite, ACLMode: ACLModeRewrite, RewriteACL: "digest:admin:hash:cdrwa,world:anyone:r"},
+		},
+		{
+			name:    "missing source",
+			config:  &LoadConfig{},
+			wantErr: true,
+			errMsg:  "backup-dir or file is required",
+		},
+		{
+			name:    "backup dir and file",
+			config:  &LoadConfig{BackupDir: "/backup/backup-123", File: "/tmp/export.json"},
+			wantErr: true,
+			errMsg:  "mutually exclusive",
+		},
+		{
+			name:    "zxid with file",
+			config:  &LoadConfig{File: "/tmp/export.json", Zxid: "0x100000001"},
+			wantErr: true,
+			errMsg:  "require backup-dir",
+		},
+		{
+			name:    "invalid auth",
+			config:  &LoadConfig{File: "/tmp/export.json", Auth: []string{"digest"}},
+			wantErr: true,
+			errMsg:  "invalid auth",
+		},
+		{
+			name:    "invalid conflict policy",
+			config:  &LoadConfig{File: "/tmp/export.json", OnConflict: "merge"},
+			wantErr: true,
+			errMsg:  "unsupported conflict policy",
+		},
+		{
+			name:    "rewrite acl without rewrite mode",
+			config:  &LoadConfig{File: "/tmp/export.json", RewriteACL: "world:anyone:r"},
+			wantErr: true,
+			errMsg:  "requires acl mode rewrite",
+		},
+		{
+			name:    "invalid rewrite acl",
+			config:  &LoadConfig{File: "/tmp/export.json", ACLMode: ACLModeRewrite, RewriteACL: "world:anyone:rx"},
+			wantErr: true,
+			errMsg:  "invalid rewrite-acl",
+		},
+		{
+			name:    "negative batch size",
+			config:  &LoadConfig{File: "/tmp/export.json", BatchSize: -1},
+			wantErr: true,
+			errMsg:  "batch-size must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("LoadConfig.Validate() error = %v, want error containing %v", err, tt.errMsg)
+			}
+		})
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		config := &LoadConfig{File: "/tmp/export.json", ACLMode: ACLModeRewrite}
+		if err := config.Validate(); err != nil {
+			t.Fatalf("LoadConfig.Validate() error = %v", err)
+		}
+		if config.Path != "/" || config.ZkHost != "localhost:2181" || config.OnConflict != ConflictSkip ||
+			config.RewriteACL != "world:anyone:cdrwa" || config.BatchSize != 100 {
+			t.Errorf("defaults = %+v", config)
+		}
+	})
+}
@@ -0,0 +1,253 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/zookeeper-backup/pkg/replay"
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// ExportEngine export engine
+type ExportEngine struct {
+	config *ExportConfig
+	logger *zap.Logger
+}
+
+// ExportDocument is a JSON or YAML export: the subtree at Path as of Zxid
+type ExportDocument struct {
+	Zxid      string      `json:"zxid" yaml:"zxid"`
+	Path      string      `json:"path" yaml:"path"`
+	NodeCount int         `json:"node_count" yaml:"node_count"`
+	Root      *ExportNode `json:"root" yaml:"root"`
+}
+
+// ExportNode is an exported znode. JSON-lines exports hold one node per line,
+// without children.
+type ExportNode struct {
+	Path     string        `json:"path" yaml:"path"`
+	Data     *string       `json:"data" yaml:"data"`                             // null for a node without data
+	Encoding string        `json:"encoding,omitempty" yaml:"encoding,omitempty"` // "base64", empty for utf8
+	Stat     *ExportStat   `json:"stat,omitempty" yaml:"stat,omitempty"`
+	ACL      []zkfile.ACL  `json:"acl,omitempty" yaml:"acl,omitempty"`
+	Children []*ExportNode `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// ExportStat is the stat of an exported znode, in the form zkCli shows it
+type ExportStat struct {
+	Czxid          string `json:"czxid" yaml:"czxid"`
+	Mzxid          string `json:"mzxid" yaml:"mzxid"`
+	Pzxid          string `json:"pzxid" yaml:"pzxid"`
+	Ctime          string `json:"ctime" yaml:"ctime"`
+	Mtime          string `json:"mtime" yaml:"mtime"`
+	Version        int32  `json:"version" yaml:"version"`
+	Cversion       int32  `json:"cversion" yaml:"cversion"`
+	Aversion       int32  `json:"aversion" yaml:"aversion"`
+	EphemeralOwner string `json:"ephemeral_owner" yaml:"ephemeral_owner"`
+	DataLength     int    `json:"data_length" yaml:"data_length"`
+	NumChildren    int    `json:"num_children" yaml:"num_children"`
+}
+
+// DecodeData returns the node data, nil for a node without data
+func (n *ExportNode) DecodeData() ([]byte, error) {
+	if n.Data == nil {
+		return nil, nil
+	}
+	switch n.Encoding {
+	case "", DataEncodingUTF8:
+		return []byte(*n.Data), nil
+	case DataEncodingBase64:
+		return base64.StdEncoding.DecodeString(*n.Data)
+	default:
+		return nil, fmt.Errorf("unsupported data encoding: %s", n.Encoding)
+	}
+}
+
+// NewExportEngine creates a new export engine
+func NewExportEngine(config *ExportConfig) *ExportEngine {
+	return &ExportEngine{
+		config: config,
+		logger: utils.GetLogger(),
+	}
+}
+
+// Run exports the znode tree
+func (e *ExportEngine) Run() error {
+	if err := e.config.Validate(); err != nil {
+		return zkfile.NewConfigurationError("invalid configuration").WithError(err)
+	}
+
+	target, err := resolveBackupTarget(e.config.BackupDir, e.config.Zxid, e.config.Time, e.logger)
+	if err != nil {
+		return err
+	}
+
+	e.logger.Info("Starting export", zap.String("backup_dir", e.config.BackupDir),
+		zap.String("path", e.config.Path), zap.String("format", e.config.Format))
+
+	tree, err := replay.LoadBackup(e.config.BackupDir, target)
+	if err != nil {
+		return err
+	}
+	if _, ok := tree.Get(e.config.Path); !ok {
+		return zkfile.NewUserError("path does not exist at the export zxid").
+			WithContext("path", e.config.Path).WithContext("zxid", tree.Zxid().String())
+	}
+
+	out := io.Writer(os.Stdout)
+	if e.config.Output != "" {
+		f, err := os.Create(e.config.Output)
+		if err != nil {
+			return zkfile.NewIOError("failed to create output file").WithError(err).WithContext("path", e.config.Output)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w := bufio.NewWriter(out)
+	count, err := e.write(w, tree)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		return zkfile.NewIOError("failed to write export").WithError(err).WithContext("output", e.config.Output)
+	}
+
+	e.logger.Info("Export completed", zap.String("zxid", tree.Zxid().String()), zap.Int("nodes", count))
+	return nil
+}
+
+// resolveBackupTarget returns the ZXID to materialize a backup at: zxid, the
+// last transaction committed at or before ts, or replay.Latest when both are empty
+func resolveBackupTarget(backupDir, zxid, ts string, logger *zap.Logger) (zkfile.ZXID, error) {
+	switch {
+	case zxid != "":
+		return zkfile.ParseZXID(zxid)
+	case ts != "":
+		target, _ := time.Parse(time.RFC3339, ts)
+		txnlogs, err := zkfile.ListTxnLogFiles(filepath.Join(backupDir, "txnlogs"))
+		if err != nil {
+			return 0, err
+		}
+		lastTxn, err := zkfile.FindLastTxnAtTime(txnlogs, target)
+		if err != nil {
+			return 0, err
+		}
+		if lastTxn == nil {
+			return 0, zkfile.NewUserError("no transaction committed at or before target time").WithContext("time", ts)
+		}
+		logger.Info("Resolved target time", zap.String("time", ts), zap.String("zxid", lastTxn.Zxid.String()),
+			zap.Time("last_transaction", lastTxn.Time()))
+		return lastTxn.Zxid, nil
+	default:
+		return replay.Latest, nil
+	}
+}
+
+// write writes the subtree in the configured format and returns the number of nodes
+func (e *ExportEngine) write(w io.Writer, tree *replay.DataTree) (int, error) {
+	count := 0
+
+	if e.config.Format == ExportFormatJSONL {
+		enc := json.NewEncoder(w)
+		err := tree.Walk(e.config.Path, func(node *replay.Node) error {
+			count++
+			return enc.Encode(e.exportNode(node))
+		})
+		return count, err
+	}
+
+	// Nested documents are built in memory, parents before their children
+	nodes := make(map[string]*ExportNode)
+	var root *ExportNode
+	err := tree.Walk(e.config.Path, func(node *replay.Node) error {
+		count++
+		exported := e.exportNode(node)
+		nodes[node.Path] = exported
+		if root == nil {
+			root = exported
+			return nil
+		}
+		parent := nodes[path.Dir(node.Path)]
+		parent.Children = append(parent.Children, exported)
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	doc := &ExportDocument{Zxid: tree.Zxid().String(), Path: e.config.Path, NodeCount: count, Root: root}
+	if e.config.Format == ExportFormatYAML {
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err = enc.Encode(doc); err != nil {
+			return count, err
+		}
+		return count, enc.Close()
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return count, enc.Encode(doc)
+}
+
+// exportNode converts a node without its children
+func (e *ExportEngine) exportNode(node *replay.Node) *ExportNode {
+	exported := &ExportNode{Path: node.Path}
+	exported.Data, exported.Encoding = exportData(node.Data, e.config.DataEncoding)
+	if e.config.IncludeStats {
+		exported.Stat = exportStat(node.Stat, len(node.Data), node.NumChildren())
+	}
+	if e.config.IncludeACL {
+		exported.ACL = node.ACL
+	}
+	return exported
+}
+
+// exportData encodes node data and returns it with its encoding, base64 when
+// requested or when the data is not valid UTF-8
+func exportData(data []byte, encoding string) (*string, string) {
+	if data == nil {
+		return nil, ""
+	}
+	if encoding == DataEncodingBase64 || !utf8.Valid(data) {
+		encoded := base64.StdEncoding.EncodeToString(data)
+		return &encoded, DataEncodingBase64
+	}
+	encoded := string(data)
+	return &encoded, ""
+}
+
+// exportStat converts a node stat
+func exportStat(s zkfile.StatPersisted, dataLength, numChildren int) *ExportStat {
+	return &ExportStat{
+		Czxid:          s.Czxid.String(),
+		Mzxid:          s.Mzxid.String(),
+		Pzxid:          s.Pzxid.String(),
+		Ctime:          formatZkTime(s.Ctime),
+		Mtime:          formatZkTime(s.Mtime),
+		Version:        s.Version,
+		Cversion:       s.Cversion,
+		Aversion:       s.Aversion,
+		EphemeralOwner: fmt.Sprintf("0x%x", uint64(s.EphemeralOwner)),
+		DataLength:     dataLength,
+		NumChildren:    numChildren,
+	}
+}
+
+// formatZkTime formats a ZooKeeper time (milliseconds since the Unix epoch)
+func formatZkTime(ms int64) string {
+	return time.UnixMilli(ms).UTC().Format("2006-01-02T15:04:05.000Z07:00")
+}
@@ -0,0 +1,225 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"hash/adler32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/zookeeper-backup/pkg/jute"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// exportTestTime is the commit time of the first transaction of the export backup
+var exportTestTime = time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+
+// createExportTestBackup creates a backup whose snapshot.0 holds /app and
+// /app/bin (binary data) and whose txnlog creates /app/new at 0x100000001 and
+// sets /app at 0x100000002, one minute apart
+func createExportTestBackup(t *testing.T) string {
+	t.Helper()
+
+	backupDir := t.TempDir()
+	os.MkdirAll(filepath.Join(backupDir, "snapshots"), 0755)
+	os.MkdirAll(filepath.Join(backupDir, "txnlogs"), 0755)
+
+	header := &zkfile.SnapshotHeader{Magic: zkfile.SnapshotMagic, Version: zkfile.SnapshotVersion}
+	acls := map[int64][]zkfile.ACL{1: {{Perms: 1, Scheme: "digest", ID: "user:hash"}}}
+	snapshot, err := zkfile.CreateSnapshot(filepath.Join(backupDir, "snapshots", "snapshot.0"), header, nil, acls)
+	if err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+	for _, node := range []*zkfile.SnapshotNode{
+		{Path: "/", ACLRef: zkfile.OpenACLUnsafeRef, Stat: zkfile.StatPersisted{Cversion: 1}},
+		{Path: "/app", Data: []byte("cfg"), ACLRef: 1, Stat: zkfile.StatPersisted{Cversion: 1}},
+		{Path: "/app/bin", Data: []byte{0xff, 0xfe}, ACLRef: zkfile.OpenACLUnsafeRef},
+		{Path: "/zookeeper", ACLRef: zkfile.OpenACLUnsafeRef},
+	} {
+		if err = snapshot.WriteNode(node); err != nil {
+			t.Fatalf("WriteNode() error = %v", err)
+		}
+	}
+	if err = snapshot.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	txnlog, err := zkfile.CreateTxnLog(filepath.Join(backupDir, "txnlogs", "log.100000001"),
+		&zkfile.TxnLogHeader{Magic: zkfile.MagicNumber, Version: zkfile.LogVersion})
+	if err != nil {
+		t.Fatalf("CreateTxnLog() error = %v", err)
+	}
+	defer txnlog.Close()
+	for i, body := range []struct {
+		txnType int32
+		record  jute.Record
+	}{
+		{zkfile.TxnTypeCreate, &zkfile.CreateTxn{Path: "/app/new", Data: []byte("n"),
+			ACL: []zkfile.ACL{{Perms: 31, Scheme: "world", ID: "anyone"}}, ParentCVersion: 2}},
+		{zkfile.TxnTypeSetData, &zkfile.SetDataTxn{Path: "/app", Data: []byte("cfg2"), Version: 1}},
+	} {
+		zxid := zkfile.ZXID(0x100000001 + i)
+		ts := exportTestTime.Add(time.Duration(i) * time.Minute).UnixMilli()
+
+		var buf bytes.Buffer
+		out := jute.NewOutputArchive(&buf)
+		out.WriteLong(1)
+		out.WriteInt(1)
+		out.WriteLong(int64(zxid))
+		out.WriteLong(ts)
+		out.WriteInt(body.txnType)
+		if err = out.WriteRecord(body.record); err != nil {
+			t.Fatalf("WriteRecord() error = %v", err)
+		}
+
+		txn := &zkfile.Transaction{Checksum: int64(adler32.Checksum(buf.Bytes())), Length: int32(buf.Len()), Data: buf.Bytes()}
+		if err = txnlog.WriteTransaction(txn); err != nil {
+			t.Fatalf("WriteTransaction() error = %v", err)
+		}
+	}
+
+	return backupDir
+}
+
+// runTestExport runs an export to a file and returns the output
+func runTestExport(t *testing.T, config *ExportConfig) []byte {
+	t.Helper()
+
+	config.Output = filepath.Join(t.TempDir(), "export")
+	if err := NewExportEngine(config).Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	content, err := os.ReadFile(config.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestExportEngine_Formats(t *testing.T) {
+	backupDir := createExportTestBackup(t)
+
+	t.Run("json", func(t *testing.T) {
+		var doc ExportDocument
+		content := runTestExport(t, &ExportConfig{BackupDir: backupDir, Path: "/app"})
+		if err := json.Unmarshal(content, &doc); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+
+		if doc.Zxid != "0x100000002" || doc.NodeCount != 3 || doc.Root.Path != "/app" {
+			t.Fatalf("document = %+v", doc)
+		}
+		if *doc.Root.Data != "cfg2" || doc.Root.Stat != nil || doc.Root.ACL != nil {
+			t.Errorf("/app = %+v", doc.Root)
+		}
+		if len(doc.Root.Children) != 2 || doc.Root.Children[0].Path != "/app/bin" || doc.Root.Children[1].Path != "/app/new" {
+			t.Fatalf("/app children = %+v", doc.Root.Children)
+		}
+		bin := doc.Root.Children[0]
+		if data, err := bin.DecodeData(); err != nil || bin.Encoding != DataEncodingBase64 || !bytes.Equal(data, []byte{0xff, 0xfe}) {
+			t.Errorf("/app/bin data = %v (%s), error = %v, want base64 of invalid UTF-8", data, bin.Encoding, err)
+		}
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		var doc ExportDocument
+		content := runTestExport(t, &ExportConfig{BackupDir: backupDir, Format: ExportFormatYAML, DataEncoding: DataEncodingBase64})
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			t.Fatalf("yaml.Unmarshal() error = %v", err)
+		}
+
+		if doc.Path != "/" || doc.NodeCount != 5 || len(doc.Root.Children) != 2 {
+			t.Fatalf("document = %+v", doc)
+		}
+		app := doc.Root.Children[0]
+		if data, _ := app.DecodeData(); *app.Data != "Y2ZnMg==" || string(data) != "cfg2" {
+			t.Errorf("/app data = %q, want base64 of cfg2", *app.Data)
+		}
+		if doc.Root.Data != nil {
+			t.Errorf("root data = %q, want null", *doc.Root.Data)
+		}
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		content := runTestExport(t, &ExportConfig{BackupDir: backupDir, Path: "/app", Format: ExportFormatJSONL,
+			IncludeStats: true, IncludeACL: true})
+
+		var nodes []*ExportNode
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			var node ExportNode
+			if err := json.Unmarshal(scanner.Bytes(), &node); err != nil {
+				t.Fatalf("json.Unmarshal(%s) error = %v", scanner.Text(), err)
+			}
+			nodes = append(nodes, &node)
+		}
+
+		if len(nodes) != 3 || nodes[0].Path != "/app" || nodes[2].Path != "/app/new" {
+			t.Fatalf("nodes = %+v", nodes)
+		}
+		app := nodes[0]
+		want := ExportStat{Czxid: "0x0", Mzxid: "0x100000002", Pzxid: "0x100000001",
+			Ctime: "1970-01-01T00:00:00.000Z", Mtime: "2025-01-15T10:01:00.000Z",
+			Version: 1, Cversion: 2, EphemeralOwner: "0x0", DataLength: 4, NumChildren: 2}
+		if app.Stat == nil || *app.Stat != want {
+			t.Errorf("/app stat = %+v, want %+v", app.Stat, want)
+		}
+		if len(app.ACL) != 1 || app.ACL[0].Scheme != "digest" || app.Children != nil {
+			t.Errorf("/app = %+v", app)
+		}
+	})
+}
+
+func TestExportEngine_Target(t *testing.T) {
+	backupDir := createExportTestBackup(t)
+
+	tests := []struct {
+		name     string
+		config   ExportConfig
+		wantZxid string
+		wantData string
+	}{
+		{name: "zxid", config: ExportConfig{Zxid: "0x100000001"}, wantZxid: "0x100000001", wantData: "cfg"},
+		{name: "time", config: ExportConfig{Time: exportTestTime.Add(30 * time.Second).Format(time.RFC3339)},
+			wantZxid: "0x100000001", wantData: "cfg"},
+		{name: "latest", wantZxid: "0x100000002", wantData: "cfg2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.BackupDir = backupDir
+			config.Path = "/app"
+
+			var doc ExportDocument
+			if err := json.Unmarshal(runTestExport(t, &config), &doc); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if doc.Zxid != tt.wantZxid || *doc.Root.Data != tt.wantData {
+				t.Errorf("zxid = %s, /app data = %q, want %s, %q", doc.Zxid, *doc.Root.Data, tt.wantZxid, tt.wantData)
+			}
+		})
+	}
+
+	t.Run("node created after the target", func(t *testing.T) {
+		config := &ExportConfig{BackupDir: backupDir, Path: "/app/new", Zxid: "0x0", Output: filepath.Join(t.TempDir(), "export")}
+		err := NewExportEngine(config).Run()
+		if err == nil || !strings.Contains(err.Error(), "path does not exist") {
+			t.Errorf("Run() error = %v, want path does not exist", err)
+		}
+	})
+
+	t.Run("time before the first transaction", func(t *testing.T) {
+		config := &ExportConfig{BackupDir: backupDir, Time: exportTestTime.Add(-time.Hour).Format(time.RFC3339)}
+		err := NewExportEngine(config).Run()
+		if err == nil || !strings.Contains(err.Error(), "no transaction committed at or before target time") {
+			t.Errorf("Run() error = %v, want no transaction committed", err)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/zookeeper-backup/pkg/metadata"
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// restoreLive connects to the ensemble and restores the subtree at Path
+func (e *RestoreEngine) restoreLive(info *metadata.BackupInfo) error {
+	client, err := utils.NewZKClient(e.config.ZkHost, e.config.ZkTimeout)
+	if err != nil {
+		return zkfile.NewZooKeeperError("failed to connect to zookeeper").WithError(err).WithContext("host", e.config.ZkHost)
+	}
+	defer client.Close()
+
+	for _, auth := range e.config.Auth {
+		scheme, credentials, _ := strings.Cut(auth, ":")
+		if err = client.AddAuth(scheme, credentials); err != nil {
+			return zkfile.NewZooKeeperError("failed to authenticate").WithError(err).WithContext("scheme", scheme)
+		}
+	}
+
+	return e.restoreSubtree(client, info)
+}
+
+// restoreSubtree rebuilds the subtree at Path from the backup at the target
+// ZXID or time, diffs it against the live subtree and, once confirmed, applies
+// only the creates, setData, setACL and deletes that make them equal. The
+// servers keep running and znodes outside the subtree are never touched.
+func (e *RestoreEngine) restoreSubtree(client ZnodeWriter, info *metadata.BackupInfo) error {
+	loader := NewLoadEngine(&LoadConfig{
+		BackupDir:  e.config.BackupDir,
+		Path:       e.config.Path,
+		Zxid:       e.config.TruncateToZxid,
+		Time:       e.config.RestoreToTime,
+		ZkHost:     e.config.ZkHost,
+		ZkTimeout:  e.config.ZkTimeout,
+		OnConflict: ConflictOverwrite,
+		ACLMode:    ACLModePreserve,
+		Delete:     true,
+		DryRun:     e.config.DryRun,
+		Verbose:    e.config.Verbose,
+	})
+	if err := loader.config.Validate(); err != nil {
+		return zkfile.NewConfigurationError("invalid configuration").WithError(err)
+	}
+
+	plan, err := loader.buildPlan(client)
+	if err != nil {
+		return fmt.Errorf("failed to plan restore: %w", err)
+	}
+
+	fmt.Printf("Restore plan for %s on %s, from %s:\n", e.config.Path, e.config.ZkHost, plan.Source)
+	loader.printChanges(plan)
+	loader.printSummary(plan)
+
+	if !plan.Modifies() {
+		fmt.Printf("%s already matches the backup, nothing to restore\n", e.config.Path)
+		return nil
+	}
+	if e.config.DryRun {
+		return nil
+	}
+	if !e.config.Force && !e.confirmLiveRestore(info, plan) {
+		return zkfile.NewCancelledError("restore cancelled by user")
+	}
+
+	if err = loader.applyPlan(client, plan); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", e.config.Path, err)
+	}
+
+	e.logger.Info("Live restore completed", zap.String("path", e.config.Path), zap.String("zk_host", e.config.ZkHost))
+	fmt.Printf("Restored %s on %s: %d created, %d updated, %d deleted\n", e.config.Path, e.config.ZkHost,
+		plan.Count(LoadActionCreate), plan.Count(LoadActionUpdate), plan.Count(LoadActionDelete))
+	return nil
+}
+
+// confirmLiveRestore asks user for confirmation
+func (e *RestoreEngine) confirmLiveRestore(info *metadata.BackupInfo, plan *LoadPlan) bool {
+	fmt.Printf("You are about to restore znodes of a live ZooKeeper ensemble:\n")
+	fmt.Printf("  Backup ID: %s\n", info.BackupID)
+	fmt.Printf("  Backup State: %s\n", plan.Source)
+	fmt.Printf("  Path: %s\n", e.config.Path)
+	fmt.Printf("  ZooKeeper Host: %s\n", e.config.ZkHost)
+	fmt.Printf("  Changes: %d creates, %d updates, %d deletes\n",
+		plan.Count(LoadActionCreate), plan.Count(LoadActionUpdate), plan.Count(LoadActionDelete))
+	fmt.Printf("This will overwrite and delete live znodes! Type 'yes' to continue: \n")
+
+	var response string
+	_, _ = fmt.Scanln(&response)
+
+	return response == "yes"
+}
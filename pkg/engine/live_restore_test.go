@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/zookeeper-backup/pkg/metadata"
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// runTestLiveRestore restores the subtree at path of the export test backup into the writer
+func runTestLiveRestore(t *testing.T, writer *fakeZnodeWriter, config *RestoreConfig) error {
+	t.Helper()
+
+	config.BackupDir = createExportTestBackup(t)
+	config.Target = RestoreTargetLive
+	config.Force = true
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	return NewRestoreEngine(config).restoreSubtree(writer, metadata.NewBackupInfo("backup-live", 0x100000002))
+}
+
+// znodePaths returns the sorted paths of the writer
+func znodePaths(writer *fakeZnodeWriter) []string {
+	var paths []string
+	for p := range writer.znodes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestRestoreEngine_LiveSubtree(t *testing.T) {
+	readOnly := []zkfile.ACL{{Perms: 1, Scheme: "digest", ID: "user:hash"}}
+
+	t.Run("deleted subtree", func(t *testing.T) {
+		writer := newFakeZnodeWriter(&utils.Znode{Path: "/other", Data: []byte("keep")})
+		if err := runTestLiveRestore(t, writer, &RestoreConfig{Path: "/app", TruncateToZxid: "0x100000001"}); err != nil {
+			t.Fatalf("restoreSubtree() error = %v", err)
+		}
+
+		want := []string{"/", "/app", "/app/bin", "/app/new", "/other", "/zookeeper", "/zookeeper/quota"}
+		if got := znodePaths(writer); !reflect.DeepEqual(got, want) {
+			t.Fatalf("znodes = %v, want %v", got, want)
+		}
+		if app := writer.znodes["/app"]; string(app.Data) != "cfg" || !sameACL(app.ACL, readOnly) {
+			t.Errorf("/app = %+v, want cfg at 0x100000001 with its ACL", app)
+		}
+	})
+
+	t.Run("diff against the live subtree", func(t *testing.T) {
+		writer := newFakeZnodeWriter(
+			&utils.Znode{Path: "/app", Data: []byte("changed")},
+			&utils.Znode{Path: "/app/bin", Data: []byte{0xff, 0xfe}},
+			&utils.Znode{Path: "/app/extra"},
+			&utils.Znode{Path: "/app/extra/x"},
+			&utils.Znode{Path: "/app/session"},
+			&utils.Znode{Path: "/app/session/lock", Stat: zkfile.StatPersisted{EphemeralOwner: 0x1234}},
+		)
+		if err := runTestLiveRestore(t, writer, &RestoreConfig{Path: "/app"}); err != nil {
+			t.Fatalf("restoreSubtree() error = %v", err)
+		}
+
+		want := []string{"/", "/app", "/app/bin", "/app/new", "/app/session", "/app/session/lock", "/zookeeper", "/zookeeper/quota"}
+		if got := znodePaths(writer); !reflect.DeepEqual(got, want) {
+			t.Fatalf("znodes = %v, want %v (extra subtree deleted, live session kept)", got, want)
+		}
+		if app := writer.znodes["/app"]; string(app.Data) != "cfg2" || !sameACL(app.ACL, readOnly) {
+			t.Errorf("/app = %+v, want cfg2 with its ACL", app)
+		}
+
+		var ops []string
+		for _, batch := range writer.batches {
+			for _, op := range batch {
+				ops = append(ops, op.Path)
+			}
+		}
+		// /app/bin is unchanged, /app/extra/x goes before its parent
+		if want := []string{"/app", "/app/new", "/app/extra/x", "/app/extra"}; !reflect.DeepEqual(ops, want) {
+			t.Errorf("operations = %v, want %v", ops, want)
+		}
+		if !reflect.DeepEqual(writer.setACLs, []string{"/app"}) {
+			t.Errorf("SetACL paths = %v, want [/app]", writer.setACLs)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		writer := newFakeZnodeWriter(&utils.Znode{Path: "/app", Data: []byte("changed")})
+		if err := runTestLiveRestore(t, writer, &RestoreConfig{Path: "/app", DryRun: true}); err != nil {
+			t.Fatalf("restoreSubtree() error = %v", err)
+		}
+		if len(writer.batches) != 0 || len(writer.setACLs) != 0 || string(writer.znodes["/app"].Data) != "changed" {
+			t.Errorf("dry run applied %v, SetACL %v", writer.batches, writer.setACLs)
+		}
+	})
+
+	t.Run("subtree matching the backup", func(t *testing.T) {
+		writer := newFakeZnodeWriter(&utils.Znode{Path: "/app", Data: []byte("cfg2")})
+		if err := runTestLiveRestore(t, writer, &RestoreConfig{Path: "/app/bin"}); err != nil {
+			t.Fatalf("restoreSubtree() error = %v", err)
+		}
+		if len(writer.batches) != 1 {
+			t.Fatalf("batches = %v, want /app/bin created", writer.batches)
+		}
+
+		if err := runTestLiveRestore(t, writer, &RestoreConfig{Path: "/app/bin"}); err != nil {
+			t.Fatalf("restoreSubtree() error = %v", err)
+		}
+		if len(writer.batches) != 1 || len(writer.setACLs) != 0 {
+			t.Errorf("second restore applied %v, SetACL %v", writer.batches[1:], writer.setACLs)
+		}
+	})
+}
@@ -0,0 +1,763 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/zookeeper-backup/pkg/metadata"
+	"github.com/zookeeper-backup/pkg/replay"
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// Actions of a load plan
+const (
+	LoadActionCreate    = "create"
+	LoadActionUpdate    = "update"
+	LoadActionConflict  = "conflict" // exists with different content, left as it is
+	LoadActionUnchanged = "unchanged"
+	LoadActionDelete    = "delete" // missing from the source, with LoadConfig.Delete
+)
+
+// maxMultiBytes bounds the data of a multi, well below the 1MB jute.maxbuffer
+// default of the servers
+const maxMultiBytes = 512 * 1024
+
+// ZnodeWriter creates and updates znodes in a live ensemble, implemented by utils.ZKClient
+type ZnodeWriter interface {
+	GetZnode(path string) (*utils.Znode, error)
+	Multi(ops ...utils.ZnodeOp) error
+	SetACL(path string, acl []zkfile.ACL) error
+}
+
+// LoadEngine load engine
+type LoadEngine struct {
+	config *LoadConfig
+	logger *zap.Logger
+}
+
+// LoadChange is the planned change of a znode
+type LoadChange struct {
+	Path        string
+	Action      string
+	Data        []byte
+	ACL         []zkfile.ACL // ACL the znode ends with
+	DataChanged bool         // update and conflict: the data differs
+	ACLChanged  bool         // update and conflict: the ACL differs
+	Parent      bool         // missing parent of a loaded znode, created empty
+}
+
+// LoadPlan is the difference between the source znodes and the target ensemble
+type LoadPlan struct {
+	Source         string
+	Changes        []*LoadChange // parents before their children, deletes last and children first
+	Ephemerals     int           // ephemeral znodes of the source, which are not loaded
+	LiveEphemerals int           // ephemeral znodes of live sessions missing from the source, which are not deleted
+}
+
+// Modifies reports whether applying the plan changes any znode
+func (p *LoadPlan) Modifies() bool {
+	return p.Count(LoadActionCreate)+p.Count(LoadActionUpdate)+p.Count(LoadActionDelete) > 0
+}
+
+// Count returns the number of changes with an action
+func (p *LoadPlan) Count(action string) int {
+	count := 0
+	for _, change := range p.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// loadNode is a znode of the load source
+type loadNode struct {
+	Path      string
+	Data      []byte
+	ACL       []zkfile.ACL // nil when the source has none
+	Ephemeral bool
+}
+
+// NewLoadEngine creates a new load engine
+func NewLoadEngine(config *LoadConfig) *LoadEngine {
+	return &LoadEngine{
+		config: config,
+		logger: utils.GetLogger(),
+	}
+}
+
+// Run loads the znodes into the ensemble, or prints the plan of a dry run
+func (e *LoadEngine) Run() error {
+	if err := e.config.Validate(); err != nil {
+		return zkfile.NewConfigurationError("invalid configuration").WithError(err)
+	}
+
+	client, err := utils.NewZKClient(e.config.ZkHost, e.config.ZkTimeout)
+	if err != nil {
+		return zkfile.NewZooKeeperError("failed to connect to zookeeper").WithError(err).WithContext("host", e.config.ZkHost)
+	}
+	defer client.Close()
+
+	for _, auth := range e.config.Auth {
+		scheme, credentials, _ := strings.Cut(auth, ":")
+		if err = client.AddAuth(scheme, credentials); err != nil {
+			return zkfile.NewZooKeeperError("failed to authenticate").WithError(err).WithContext("scheme", scheme)
+		}
+	}
+
+	plan, err := e.Load(client)
+	if err != nil {
+		return err
+	}
+
+	e.printPlan(plan)
+	return nil
+}
+
+// Load plans the load against the ensemble and, unless it is a dry run,
+// applies the plan. The configuration must be validated.
+func (e *LoadEngine) Load(client ZnodeWriter) (*LoadPlan, error) {
+	if err := e.resolveBackupDir(); err != nil {
+		return nil, err
+	}
+	e.logger.Info("Starting load", zap.String("backup_dir", e.config.BackupDir), zap.String("file", e.config.File),
+		zap.String("path", e.config.Path), zap.String("zk_host", e.config.ZkHost), zap.Bool("dry_run", e.config.DryRun))
+
+	plan, err := e.buildPlan(client)
+	if err != nil {
+		return nil, err
+	}
+	if e.config.DryRun {
+		return plan, nil
+	}
+
+	if err = e.applyPlan(client, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// resolveBackupDir looks up a BackupDir that is not a directory as a backup ID
+// under BackupBaseDir, like zkbackup info does
+func (e *LoadEngine) resolveBackupDir() error {
+	if e.config.BackupDir == "" || e.config.BackupBaseDir == "" || zkfile.DirExists(e.config.BackupDir) ||
+		strings.ContainsRune(e.config.BackupDir, filepath.Separator) {
+		return nil
+	}
+
+	entry, err := metadata.FindBackup(e.config.BackupBaseDir, e.config.BackupDir)
+	if err != nil {
+		return err
+	}
+	e.config.BackupDir = entry.Path
+	return nil
+}
+
+// buildPlan reads the source and compares it with the ensemble
+func (e *LoadEngine) buildPlan(client ZnodeWriter) (*LoadPlan, error) {
+	nodes, source, err := e.readSource()
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := e.plan(client, nodes, source)
+	if err != nil {
+		return nil, err
+	}
+	if e.config.Delete {
+		if err = e.planDeletes(client, nodes, plan); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// applyPlan applies a plan, unless it has conflicts that fail the load
+func (e *LoadEngine) applyPlan(client ZnodeWriter, plan *LoadPlan) error {
+	if conflicts := plan.Count(LoadActionConflict); conflicts > 0 && e.config.OnConflict == ConflictFail {
+		first := ""
+		for _, change := range plan.Changes {
+			if change.Action == LoadActionConflict {
+				first = change.Path
+				break
+			}
+		}
+		return zkfile.NewUserError("znodes exist with different content").
+			WithContext("conflicts", conflicts).WithContext("first_conflict", first)
+	}
+
+	if err := e.apply(client, plan); err != nil {
+		return err
+	}
+
+	e.logger.Info("Load completed", zap.Int("created", plan.Count(LoadActionCreate)),
+		zap.Int("updated", plan.Count(LoadActionUpdate)), zap.Int("deleted", plan.Count(LoadActionDelete)),
+		zap.Int("conflicts", plan.Count(LoadActionConflict)))
+	return nil
+}
+
+// readSource returns the znodes of the subtree to load, sorted by path so
+// parents come before their children, and a description of the source
+func (e *LoadEngine) readSource() ([]*loadNode, string, error) {
+	var (
+		nodes  []*loadNode
+		source string
+		err    error
+	)
+
+	switch {
+	case e.config.File != "":
+		source = e.config.File
+		nodes, err = readExportFile(e.config.File)
+	default:
+		nodes, source, err = e.readBackup()
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	subtree := make([]*loadNode, 0, len(nodes))
+	for _, node := range nodes {
+		if inZnodeSubtree(node.Path, e.config.Path) {
+			subtree = append(subtree, node)
+		}
+	}
+	if len(subtree) == 0 {
+		return nil, "", zkfile.NewUserError("path does not exist in the load source").
+			WithContext("path", e.config.Path).WithContext("source", source)
+	}
+	sort.Slice(subtree, func(i, j int) bool { return subtree[i].Path < subtree[j].Path })
+
+	return subtree, source, nil
+}
+
+// readBackup reads the znodes of a logical backup, or of a physical backup at
+// the configured target
+func (e *LoadEngine) readBackup() ([]*loadNode, string, error) {
+	// Physical backups are replayed from their files, with or without metadata
+	infoPath := metadata.InfoPath(e.config.BackupDir)
+	info, err := metadata.LoadBackupInfo(infoPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, "", zkfile.NewValidationError("failed to load backup info").WithError(err).WithContext("path", infoPath)
+	}
+
+	if info != nil && info.Mode == BackupModeLogical && info.Logical != nil {
+		if e.config.Zxid != "" || e.config.Time != "" {
+			return nil, "", zkfile.NewUserError("a logical backup can only be loaded at its end zxid").
+				WithContext("end_zxid", "0x"+info.Logical.EndZxid.Hex)
+		}
+		nodes, err := readExportFile(filepath.Join(e.config.BackupDir, info.Logical.File))
+		return nodes, fmt.Sprintf("%s (logical, 0x%s)", e.config.BackupDir, info.Logical.EndZxid.Hex), err
+	}
+
+	target, err := resolveBackupTarget(e.config.BackupDir, e.config.Zxid, e.config.Time, e.logger)
+	if err != nil {
+		return nil, "", err
+	}
+	tree, err := replay.LoadBackup(e.config.BackupDir, target)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, ok := tree.Get(e.config.Path); !ok {
+		return nil, "", zkfile.NewUserError("path does not exist at the load zxid").
+			WithContext("path", e.config.Path).WithContext("zxid", tree.Zxid().String())
+	}
+
+	var nodes []*loadNode
+	err = tree.Walk(e.config.Path, func(node *replay.Node) error {
+		nodes = append(nodes, &loadNode{Path: node.Path, Data: node.Data, ACL: node.ACL,
+			Ephemeral: zkfile.IsSessionOwner(node.Stat.EphemeralOwner)})
+		return nil
+	})
+	return nodes, fmt.Sprintf("%s (%s)", e.config.BackupDir, tree.Zxid()), err
+}
+
+// plan compares the source znodes with the ensemble. The root and /zookeeper
+// are managed by the servers and never loaded.
+func (e *LoadEngine) plan(client ZnodeWriter, nodes []*loadNode, source string) (*LoadPlan, error) {
+	plan := &LoadPlan{Source: source}
+	created := make(map[string]bool) // paths the plan creates
+	exists := make(map[string]bool)  // paths known to exist in the ensemble
+
+	for _, node := range nodes {
+		if node.Path == "/" || inZnodeSubtree(node.Path, zookeeperSystemPath) {
+			continue
+		}
+		if node.Ephemeral {
+			plan.Ephemerals++
+			continue
+		}
+
+		// Missing parents are created empty
+		for _, parent := range znodeAncestors(node.Path) {
+			if created[parent] || exists[parent] {
+				continue
+			}
+			if _, err := e.getZnode(client, parent); errors.Is(err, utils.ErrNoNode) {
+				plan.Changes = append(plan.Changes, &LoadChange{Path: parent, Action: LoadActionCreate,
+					ACL: e.loadACL(nil), Parent: true})
+				created[parent] = true
+			} else if err != nil {
+				return nil, err
+			} else {
+				exists[parent] = true
+			}
+		}
+
+		change := &LoadChange{Path: node.Path, Data: node.Data, ACL: e.loadACL(node.ACL)}
+		plan.Changes = append(plan.Changes, change)
+
+		// The children of a created znode cannot exist
+		if created[path.Dir(node.Path)] {
+			change.Action = LoadActionCreate
+			created[node.Path] = true
+			if err := e.checkCreateACL(node); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		existing, err := e.getZnode(client, node.Path)
+		if errors.Is(err, utils.ErrNoNode) {
+			change.Action = LoadActionCreate
+			created[node.Path] = true
+			if err = e.checkCreateACL(node); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		exists[node.Path] = true
+
+		change.DataChanged = !bytes.Equal(existing.Data, node.Data)
+		// Without ACLs in the source the existing ones are kept
+		change.ACLChanged = (node.ACL != nil || e.config.ACLMode == ACLModeRewrite) && !sameACL(existing.ACL, change.ACL)
+		switch {
+		case !change.DataChanged && !change.ACLChanged:
+			change.Action = LoadActionUnchanged
+		case e.config.OnConflict == ConflictOverwrite:
+			change.Action = LoadActionUpdate
+		default:
+			change.Action = LoadActionConflict
+		}
+	}
+
+	return plan, nil
+}
+
+// planDeletes adds the znodes of the ensemble subtree that the source lacks,
+// children before their parents. Ephemeral znodes of live sessions are kept,
+// and so are their parents.
+func (e *LoadEngine) planDeletes(client ZnodeWriter, nodes []*loadNode, plan *LoadPlan) error {
+	loaded := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		if !node.Ephemeral {
+			loaded[node.Path] = true
+		}
+	}
+
+	root, err := e.getZnode(client, e.config.Path)
+	if errors.Is(err, utils.ErrNoNode) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var extra []string
+	kept := make(map[string]bool)
+	queue := []*utils.Znode{root}
+	for len(queue) > 0 {
+		znode := queue[0]
+		queue = queue[1:]
+
+		if !loaded[znode.Path] && znode.Path != "/" {
+			if zkfile.IsSessionOwner(znode.Stat.EphemeralOwner) {
+				plan.LiveEphemerals++
+				for _, parent := range znodeAncestors(znode.Path) {
+					kept[parent] = true
+				}
+			} else {
+				extra = append(extra, znode.Path)
+			}
+		}
+
+		for _, name := range znode.Children {
+			path := childZnodePath(znode.Path, name)
+			if path == zookeeperSystemPath {
+				continue
+			}
+			child, err := e.getZnode(client, path)
+			if errors.Is(err, utils.ErrNoNode) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			queue = append(queue, child)
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(extra)))
+	for _, path := range extra {
+		if !kept[path] {
+			plan.Changes = append(plan.Changes, &LoadChange{Path: path, Action: LoadActionDelete})
+		}
+	}
+	return nil
+}
+
+// getZnode reads a znode of the ensemble
+func (e *LoadEngine) getZnode(client ZnodeWriter, path string) (*utils.Znode, error) {
+	znode, err := client.GetZnode(path)
+	if err != nil && !errors.Is(err, utils.ErrNoNode) {
+		return nil, zkfile.NewZooKeeperError("failed to read znode").WithError(err).WithContext("path", path)
+	}
+	return znode, err
+}
+
+// checkCreateACL refuses to create a znode the source has no ACL for, unless
+// the ACLs are rewritten: opening it to everyone could expose a protected znode
+func (e *LoadEngine) checkCreateACL(node *loadNode) error {
+	if len(node.ACL) == 0 && e.config.ACLMode == ACLModePreserve {
+		return zkfile.NewUserError("source has no ACL for znode, use --acl rewrite to choose one").
+			WithContext("path", node.Path)
+	}
+	return nil
+}
+
+// loadACL returns the ACL a loaded znode ends with. Missing parents, which
+// have no source, are created open.
+func (e *LoadEngine) loadACL(acl []zkfile.ACL) []zkfile.ACL {
+	if e.config.ACLMode == ACLModeRewrite {
+		rewritten, _ := ParseACL(e.config.RewriteACL)
+		return rewritten
+	}
+	if len(acl) == 0 {
+		return openACL()
+	}
+	return acl
+}
+
+// apply creates and updates the znodes in batches of multi operations, parents
+// before their children. Created znodes with children are created open and get
+// their ACL once their subtree is loaded, children first, so a restrictive ACL
+// cannot keep the load from creating the children.
+func (e *LoadEngine) apply(client ZnodeWriter, plan *LoadPlan) error {
+	hasChildren := make(map[string]bool)
+	for _, change := range plan.Changes {
+		if change.Action == LoadActionCreate {
+			hasChildren[path.Dir(change.Path)] = true
+		}
+	}
+
+	var (
+		batch     []utils.ZnodeOp
+		batchSize int
+		applied   int
+		setACLs   []*LoadChange
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := client.Multi(batch...); err != nil {
+			return zkfile.NewZooKeeperError("failed to apply znode batch").WithError(err).
+				WithContext("applied_operations", applied).WithContext("batch_operations", len(batch))
+		}
+		applied += len(batch)
+		e.logger.Debug("Applied znode batch", zap.Int("operations", len(batch)), zap.Int("applied", applied))
+		batch, batchSize = batch[:0], 0
+		return nil
+	}
+
+	for _, change := range plan.Changes {
+		var op utils.ZnodeOp
+		switch {
+		case change.Action == LoadActionCreate:
+			op = utils.ZnodeOp{Type: utils.ZnodeOpCreate, Path: change.Path, Data: change.Data, ACL: change.ACL}
+			if hasChildren[change.Path] && !sameACL(change.ACL, openACL()) {
+				op.ACL = openACL()
+				setACLs = append(setACLs, change)
+			}
+		case change.Action == LoadActionUpdate:
+			if change.ACLChanged {
+				setACLs = append(setACLs, change)
+			}
+			if !change.DataChanged {
+				continue
+			}
+			op = utils.ZnodeOp{Type: utils.ZnodeOpSetData, Path: change.Path, Data: change.Data}
+		case change.Action == LoadActionDelete:
+			op = utils.ZnodeOp{Type: utils.ZnodeOpDelete, Path: change.Path}
+		default:
+			continue
+		}
+
+		size := len(op.Path) + len(op.Data)
+		if len(batch) >= e.config.BatchSize || (len(batch) > 0 && batchSize+size > maxMultiBytes) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, op)
+		batchSize += size
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	for i := len(setACLs) - 1; i >= 0; i-- {
+		if err := client.SetACL(setACLs[i].Path, setACLs[i].ACL); err != nil {
+			return zkfile.NewZooKeeperError("failed to set ACL").WithError(err).WithContext("path", setACLs[i].Path)
+		}
+	}
+
+	return nil
+}
+
+// printPlan prints the changes of a dry run, or the summary of a load
+func (e *LoadEngine) printPlan(plan *LoadPlan) {
+	if e.config.DryRun {
+		fmt.Printf("Would load %s into %s:\n", plan.Source, e.config.ZkHost)
+		e.printChanges(plan)
+	} else {
+		fmt.Printf("Loaded %s into %s:\n", plan.Source, e.config.ZkHost)
+	}
+	e.printSummary(plan)
+}
+
+// printChanges prints one line per znode the plan changes or leaves in conflict
+func (e *LoadEngine) printChanges(plan *LoadPlan) {
+	for _, change := range plan.Changes {
+		switch change.Action {
+		case LoadActionCreate:
+			if change.Parent {
+				fmt.Printf("  + %s (parent)\n", change.Path)
+			} else {
+				fmt.Printf("  + %s\n", change.Path)
+			}
+		case LoadActionUpdate:
+			fmt.Printf("  ~ %s (%s)\n", change.Path, describeLoadChange(change))
+		case LoadActionDelete:
+			fmt.Printf("  - %s\n", change.Path)
+		case LoadActionConflict:
+			fmt.Printf("  ! %s (exists with different %s, %s)\n", change.Path, describeLoadChange(change), e.config.OnConflict)
+		}
+	}
+}
+
+// printSummary prints the number of changes by action
+func (e *LoadEngine) printSummary(plan *LoadPlan) {
+	fmt.Printf("- %d znodes created\n", plan.Count(LoadActionCreate))
+	fmt.Printf("- %d znodes updated\n", plan.Count(LoadActionUpdate))
+	if e.config.Delete {
+		fmt.Printf("- %d znodes deleted\n", plan.Count(LoadActionDelete))
+	}
+	fmt.Printf("- %d conflicts (%s)\n", plan.Count(LoadActionConflict), e.config.OnConflict)
+	fmt.Printf("- %d znodes unchanged\n", plan.Count(LoadActionUnchanged))
+	if plan.Ephemerals > 0 {
+		fmt.Printf("- %d ephemeral znodes not loaded\n", plan.Ephemerals)
+	}
+	if plan.LiveEphemerals > 0 {
+		fmt.Printf("- %d ephemeral znodes of live sessions kept\n", plan.LiveEphemerals)
+	}
+}
+
+// describeLoadChange names what differs in an existing znode
+func describeLoadChange(change *LoadChange) string {
+	var parts []string
+	if change.DataChanged {
+		parts = append(parts, "data")
+	}
+	if change.ACLChanged {
+		parts = append(parts, "acl")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// readExportFile reads the znodes of an export or logical archive: a JSON or
+// YAML document, or JSON lines. Compressed files are read transparently.
+func readExportFile(path string) ([]*loadNode, error) {
+	f, err := zkfile.OpenDecompressed(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var exported []*ExportNode
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		var doc ExportDocument
+		if err = yaml.NewDecoder(f).Decode(&doc); err == nil && doc.Root == nil {
+			err = fmt.Errorf("document has no root node")
+		}
+		if err == nil {
+			exported = flattenExportNode(doc.Root, exported)
+		}
+	} else {
+		exported, err = decodeExportJSON(f)
+	}
+	if err != nil {
+		return nil, zkfile.NewValidationError("invalid export file").WithError(err).WithContext("path", path)
+	}
+
+	nodes := make([]*loadNode, 0, len(exported))
+	for _, n := range exported {
+		node, err := newLoadNode(n)
+		if err != nil {
+			return nil, zkfile.NewValidationError("invalid export file").WithError(err).
+				WithContext("path", path).WithContext("node", n.Path)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// decodeExportJSON decodes a JSON document or JSON lines
+func decodeExportJSON(r io.Reader) ([]*ExportNode, error) {
+	var exported []*ExportNode
+	decoder := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err == io.EOF {
+			return exported, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		var doc ExportDocument
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+		if doc.Root != nil {
+			exported = flattenExportNode(doc.Root, exported)
+			continue
+		}
+
+		var node ExportNode
+		if err := json.Unmarshal(raw, &node); err != nil {
+			return nil, err
+		}
+		exported = append(exported, &node)
+	}
+}
+
+// flattenExportNode appends a node and its descendants
+func flattenExportNode(node *ExportNode, nodes []*ExportNode) []*ExportNode {
+	nodes = append(nodes, node)
+	for _, child := range node.Children {
+		nodes = flattenExportNode(child, nodes)
+	}
+	return nodes
+}
+
+// newLoadNode converts an exported node
+func newLoadNode(n *ExportNode) (*loadNode, error) {
+	if err := validateZnodePath(n.Path); err != nil {
+		return nil, err
+	}
+	data, err := n.DecodeData()
+	if err != nil {
+		return nil, err
+	}
+
+	node := &loadNode{Path: n.Path, Data: data, ACL: n.ACL}
+	if n.Stat != nil && n.Stat.EphemeralOwner != "" {
+		owner, err := strconv.ParseUint(strings.TrimPrefix(n.Stat.EphemeralOwner, "0x"), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ephemeral owner: %s", n.Stat.EphemeralOwner)
+		}
+		node.Ephemeral = zkfile.IsSessionOwner(int64(owner))
+	}
+	return node, nil
+}
+
+// ParseACL parses a comma separated list of scheme:id:perms ACLs, perms being
+// letters of cdrwa, e.g. "world:anyone:r,digest:admin:Xh8z...=:cdrwa"
+func ParseACL(spec string) ([]zkfile.ACL, error) {
+	var acl []zkfile.ACL
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		first, last := strings.Index(entry, ":"), strings.LastIndex(entry, ":")
+		if first <= 0 || first == last {
+			return nil, fmt.Errorf("invalid ACL %q, expected scheme:id:perms", entry)
+		}
+
+		var perms int32
+		for _, p := range entry[last+1:] {
+			switch p {
+			case 'r':
+				perms |= 1
+			case 'w':
+				perms |= 2
+			case 'c':
+				perms |= 4
+			case 'd':
+				perms |= 8
+			case 'a':
+				perms |= 16
+			default:
+				return nil, fmt.Errorf("invalid permission %q in ACL %q", p, entry)
+			}
+		}
+		if perms == 0 {
+			return nil, fmt.Errorf("no permissions in ACL %q", entry)
+		}
+
+		acl = append(acl, zkfile.ACL{Perms: perms, Scheme: entry[:first], ID: entry[first+1 : last]})
+	}
+	return acl, nil
+}
+
+// openACL returns world:anyone with all permissions
+func openACL() []zkfile.ACL {
+	return []zkfile.ACL{{Perms: 31, Scheme: "world", ID: "anyone"}}
+}
+
+// sameACL reports whether two ACLs hold the same entries
+func sameACL(a, b []zkfile.ACL) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sorted := func(acl []zkfile.ACL) []zkfile.ACL {
+		s := append([]zkfile.ACL(nil), acl...)
+		sort.Slice(s, func(i, j int) bool {
+			if s[i].Scheme != s[j].Scheme {
+				return s[i].Scheme < s[j].Scheme
+			}
+			return s[i].ID < s[j].ID
+		})
+		return s
+	}
+	return reflect.DeepEqual(sorted(a), sorted(b))
+}
+
+// inZnodeSubtree reports whether p is root or one of its descendants
+func inZnodeSubtree(p, root string) bool {
+	return root == "/" || p == root || strings.HasPrefix(p, root+"/")
+}
+
+// znodeAncestors returns the ancestors of a path below the root, outermost first
+func znodeAncestors(p string) []string {
+	var ancestors []string
+	for parent := path.Dir(p); parent != "/"; parent = path.Dir(parent) {
+		ancestors = append([]string{parent}, ancestors...)
+	}
+	return ancestors
+}
@@ -0,0 +1,467 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// fakeZnodeWriter is an unauthenticated session on an in-memory ensemble. A
+// multi is atomic and creating a znode needs the create permission of
+// world:anyone on its parent. failOn makes the operations on a path fail.
+type fakeZnodeWriter struct {
+	*fakeZnodeReader
+	batches [][]utils.ZnodeOp
+	setACLs []string
+	failOn  string
+}
+
+// newFakeZnodeWriter creates a writer holding the root, /zookeeper and the given znodes
+func newFakeZnodeWriter(znodes ...*utils.Znode) *fakeZnodeWriter {
+	return &fakeZnodeWriter{fakeZnodeReader: newFakeZnodeReader(znodes...)}
+}
+
+func (w *fakeZnodeWriter) Multi(ops ...utils.ZnodeOp) error {
+	saved := make(map[string]*utils.Znode, len(w.znodes))
+	for p, znode := range w.znodes {
+		copied := *znode
+		copied.Children = append([]string(nil), znode.Children...)
+		saved[p] = &copied
+	}
+
+	for _, op := range ops {
+		if err := w.apply(op); err != nil {
+			w.znodes = saved
+			return fmt.Errorf("failed to apply %s: %w", op.Path, err)
+		}
+	}
+	w.batches = append(w.batches, append([]utils.ZnodeOp(nil), ops...))
+	return nil
+}
+
+// apply applies an operation of a multi
+func (w *fakeZnodeWriter) apply(op utils.ZnodeOp) error {
+	if op.Path == w.failOn {
+		return errors.New("injected failure")
+	}
+	switch op.Type {
+	case utils.ZnodeOpCreate:
+		if _, ok := w.znodes[op.Path]; ok {
+			return utils.ErrNodeExists
+		}
+		parent, ok := w.znodes[path.Dir(op.Path)]
+		if !ok {
+			return utils.ErrNoNode
+		}
+		if !canCreate(parent.ACL) {
+			return errors.New("not authenticated")
+		}
+		w.add(&utils.Znode{Path: op.Path, Data: op.Data, ACL: op.ACL})
+	case utils.ZnodeOpSetData:
+		znode, ok := w.znodes[op.Path]
+		if !ok {
+			return utils.ErrNoNode
+		}
+		znode.Data = op.Data
+	case utils.ZnodeOpDelete:
+		znode, ok := w.znodes[op.Path]
+		if !ok {
+			return utils.ErrNoNode
+		}
+		if len(znode.Children) > 0 {
+			return errors.New("node has children")
+		}
+		delete(w.znodes, op.Path)
+		parent := w.znodes[path.Dir(op.Path)]
+		for i, name := range parent.Children {
+			if name == path.Base(op.Path) {
+				parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (w *fakeZnodeWriter) SetACL(path string, acl []zkfile.ACL) error {
+	znode, ok := w.znodes[path]
+	if !ok {
+		return utils.ErrNoNode
+	}
+	znode.ACL = acl
+	w.setACLs = append(w.setACLs, path)
+	return nil
+}
+
+// canCreate reports whether world:anyone may create children
+func canCreate(acl []zkfile.ACL) bool {
+	for _, a := range acl {
+		if a.Scheme == "world" && a.ID == "anyone" && a.Perms&4 != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// writeTestExportFile writes lines to an export file and returns its path
+func writeTestExportFile(t *testing.T, name string, lines ...string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// runTestLoad validates the configuration and loads into the writer
+func runTestLoad(t *testing.T, writer *fakeZnodeWriter, config *LoadConfig) (*LoadPlan, error) {
+	t.Helper()
+
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	return NewLoadEngine(config).Load(writer)
+}
+
+// planActions returns path:action of the changes of a plan
+func planActions(plan *LoadPlan) []string {
+	var actions []string
+	for _, change := range plan.Changes {
+		actions = append(actions, change.Path+":"+change.Action)
+	}
+	return actions
+}
+
+func TestLoadEngine_Load(t *testing.T) {
+	readOnly := []zkfile.ACL{{Perms: 1, Scheme: "digest", ID: "user:hash"}}
+	file := writeTestExportFile(t, "znodes.jsonl",
+		`{"path":"/app/a","data":"a","acl":[{"perms":31,"scheme":"world","id":"anyone"}]}`,
+		`{"path":"/app/a/b","data":"Yg==","encoding":"base64","acl":[{"perms":31,"scheme":"world","id":"anyone"}]}`,
+		`{"path":"/app/lock","stat":{"ephemeral_owner":"0x1234"}}`,
+		`{"path":"/svc","acl":[{"perms":1,"scheme":"digest","id":"user:hash"}]}`,
+		`{"path":"/svc/x","data":"x","acl":[{"perms":31,"scheme":"world","id":"anyone"}]}`,
+	)
+
+	t.Run("parents first in batches", func(t *testing.T) {
+		writer := newFakeZnodeWriter()
+		plan, err := runTestLoad(t, writer, &LoadConfig{File: file, BatchSize: 2})
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+
+		want := []string{"/app:create", "/app/a:create", "/app/a/b:create", "/svc:create", "/svc/x:create"}
+		if got := planActions(plan); !reflect.DeepEqual(got, want) {
+			t.Fatalf("plan = %v, want %v", got, want)
+		}
+		if !plan.Changes[0].Parent || plan.Changes[1].Parent || plan.Ephemerals != 1 {
+			t.Errorf("plan = %+v, want /app a parent and one ephemeral", plan)
+		}
+		if len(writer.batches) != 3 || len(writer.batches[0]) != 2 || len(writer.batches[2]) != 1 {
+			t.Errorf("batches = %v, want 2, 2 and 1 operations", writer.batches)
+		}
+
+		if b := writer.znodes["/app/a/b"]; b == nil || string(b.Data) != "b" {
+			t.Errorf("/app/a/b = %+v", b)
+		}
+		if _, ok := writer.znodes["/app/lock"]; ok {
+			t.Error("ephemeral /app/lock loaded")
+		}
+		// /svc is read-only for world, so its ACL is set once /svc/x exists
+		if svc := writer.znodes["/svc"]; !sameACL(svc.ACL, readOnly) || writer.znodes["/svc/x"] == nil {
+			t.Errorf("/svc = %+v, /svc/x = %+v", svc, writer.znodes["/svc/x"])
+		}
+		if !reflect.DeepEqual(writer.setACLs, []string{"/svc"}) {
+			t.Errorf("SetACL paths = %v, want [/svc]", writer.setACLs)
+		}
+	})
+
+	t.Run("subtree", func(t *testing.T) {
+		writer := newFakeZnodeWriter()
+		plan, err := runTestLoad(t, writer, &LoadConfig{File: file, Path: "/svc"})
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if got := planActions(plan); !reflect.DeepEqual(got, []string{"/svc:create", "/svc/x:create"}) {
+			t.Errorf("plan = %v", got)
+		}
+
+		_, err = runTestLoad(t, writer, &LoadConfig{File: file, Path: "/missing"})
+		if err == nil || !strings.Contains(err.Error(), "path does not exist") {
+			t.Errorf("Load() error = %v, want path does not exist", err)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		writer := newFakeZnodeWriter(&utils.Znode{Path: "/svc", ACL: readOnly}, &utils.Znode{Path: "/svc/x", Data: []byte("old")})
+		plan, err := runTestLoad(t, writer, &LoadConfig{File: file, DryRun: true})
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+
+		want := []string{"/app:create", "/app/a:create", "/app/a/b:create", "/svc:unchanged", "/svc/x:conflict"}
+		if got := planActions(plan); !reflect.DeepEqual(got, want) {
+			t.Errorf("plan = %v, want %v", got, want)
+		}
+		if x := plan.Changes[4]; !x.DataChanged || x.ACLChanged {
+			t.Errorf("/svc/x change = %+v, want data changed only", x)
+		}
+		if len(writer.batches) != 0 || writer.znodes["/app"] != nil {
+			t.Errorf("dry run applied %v", writer.batches)
+		}
+	})
+
+	t.Run("multi failure", func(t *testing.T) {
+		writer := newFakeZnodeWriter()
+		writer.failOn = "/svc/x"
+		_, err := runTestLoad(t, writer, &LoadConfig{File: file})
+		if err == nil || !strings.Contains(err.Error(), "failed to apply znode batch") {
+			t.Fatalf("Load() error = %v, want batch failure", err)
+		}
+		if _, ok := writer.znodes["/app"]; ok {
+			t.Error("failed batch partially applied")
+		}
+	})
+}
+
+func TestLoadEngine_Conflicts(t *testing.T) {
+	file := writeTestExportFile(t, "znodes.jsonl",
+		`{"path":"/app","data":"new"}`,
+		`{"path":"/app/same","data":"same","acl":[{"perms":31,"scheme":"world","id":"anyone"}]}`,
+		`{"path":"/app/y","data":"y","acl":[{"perms":31,"scheme":"world","id":"anyone"}]}`,
+	)
+	newWriter := func() *fakeZnodeWriter {
+		return newFakeZnodeWriter(&utils.Znode{Path: "/app", Data: []byte("old")},
+			&utils.Znode{Path: "/app/same", Data: []byte("same")})
+	}
+
+	tests := []struct {
+		onConflict string
+		wantErr    string
+		wantData   string
+		wantAction string
+		wantY      bool
+	}{
+		{onConflict: ConflictSkip, wantData: "old", wantAction: LoadActionConflict, wantY: true},
+		{onConflict: ConflictOverwrite, wantData: "new", wantAction: LoadActionUpdate, wantY: true},
+		{onConflict: ConflictFail, wantErr: "znodes exist with different content", wantData: "old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.onConflict, func(t *testing.T) {
+			writer := newWriter()
+			plan, err := runTestLoad(t, writer, &LoadConfig{File: file, OnConflict: tt.onConflict})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %s", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			} else {
+				want := []string{"/app:" + tt.wantAction, "/app/same:unchanged", "/app/y:create"}
+				if got := planActions(plan); !reflect.DeepEqual(got, want) {
+					t.Errorf("plan = %v, want %v", got, want)
+				}
+			}
+
+			if got := string(writer.znodes["/app"].Data); got != tt.wantData {
+				t.Errorf("/app data = %q, want %q", got, tt.wantData)
+			}
+			if _, ok := writer.znodes["/app/y"]; ok != tt.wantY {
+				t.Errorf("/app/y created = %v, want %v", ok, tt.wantY)
+			}
+		})
+	}
+}
+
+func TestLoadEngine_MissingACL(t *testing.T) {
+	file := writeTestExportFile(t, "znodes.jsonl",
+		`{"path":"/app","data":"cfg"}`,
+		`{"path":"/app/x","data":"x","acl":[{"perms":31,"scheme":"world","id":"anyone"}]}`,
+	)
+
+	t.Run("existing znode keeps its ACL", func(t *testing.T) {
+		readCreate := []zkfile.ACL{{Perms: 5, Scheme: "world", ID: "anyone"}}
+		writer := newFakeZnodeWriter(&utils.Znode{Path: "/app", Data: []byte("old"), ACL: readCreate})
+		if _, err := runTestLoad(t, writer, &LoadConfig{File: file, OnConflict: ConflictOverwrite}); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if app := writer.znodes["/app"]; string(app.Data) != "cfg" || !sameACL(app.ACL, readCreate) {
+			t.Errorf("/app = %+v, want cfg with its ACL", app)
+		}
+	})
+
+	t.Run("created znode is refused", func(t *testing.T) {
+		writer := newFakeZnodeWriter()
+		_, err := runTestLoad(t, writer, &LoadConfig{File: file})
+		if err == nil || !strings.Contains(err.Error(), "source has no ACL") || !strings.Contains(err.Error(), "path=/app") {
+			t.Fatalf("Load() error = %v, want /app refused", err)
+		}
+		if len(writer.batches) != 0 {
+			t.Errorf("refused load applied %v", writer.batches)
+		}
+	})
+}
+
+func TestLoadEngine_RewriteACL(t *testing.T) {
+	admin := []zkfile.ACL{{Perms: 31, Scheme: "digest", ID: "admin:hash"}}
+	file := writeTestExportFile(t, "znodes.jsonl",
+		`{"path":"/app","data":"cfg","acl":[{"perms":31,"scheme":"world","id":"anyone"}]}`,
+		`{"path":"/app/new/x","data":"x"}`,
+	)
+	writer := newFakeZnodeWriter(&utils.Znode{Path: "/app", Data: []byte("cfg")})
+
+	plan, err := runTestLoad(t, writer, &LoadConfig{File: file, OnConflict: ConflictOverwrite,
+		ACLMode: ACLModeRewrite, RewriteACL: "digest:admin:hash:cdrwa"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := []string{"/app:update", "/app/new:create", "/app/new/x:create"}
+	if got := planActions(plan); !reflect.DeepEqual(got, want) {
+		t.Fatalf("plan = %v, want %v", got, want)
+	}
+	if app := plan.Changes[0]; app.DataChanged || !app.ACLChanged {
+		t.Errorf("/app change = %+v, want ACL changed only", app)
+	}
+	for _, p := range []string{"/app", "/app/new", "/app/new/x"} {
+		if !sameACL(writer.znodes[p].ACL, admin) {
+			t.Errorf("%s ACL = %v, want %v", p, writer.znodes[p].ACL, admin)
+		}
+	}
+	// Children get their ACL before the parents that would lock them out
+	if !reflect.DeepEqual(writer.setACLs, []string{"/app/new", "/app"}) {
+		t.Errorf("SetACL paths = %v, want [/app/new /app]", writer.setACLs)
+	}
+}
+
+func TestLoadEngine_Sources(t *testing.T) {
+	t.Run("logical backup", func(t *testing.T) {
+		reader := newFakeZnodeReader(
+			&utils.Znode{Path: "/app", Data: []byte("cfg")},
+			&utils.Znode{Path: "/app/bin", Data: []byte{0xff}, ACL: []zkfile.ACL{{Perms: 1, Scheme: "digest", ID: "user:hash"}}},
+			&utils.Znode{Path: "/app/lock", Stat: zkfile.StatPersisted{EphemeralOwner: 0x1234}},
+		)
+		reader.zxids = []zkfile.ZXID{0x100000005, 0x100000007}
+		backupDir, _, err := runTestLogicalBackup(t, reader, &BackupConfig{Compression: zkfile.CompressionGzip})
+		if err != nil {
+			t.Fatalf("backupZnodes() error = %v", err)
+		}
+
+		writer := newFakeZnodeWriter()
+		plan, err := runTestLoad(t, writer, &LoadConfig{BackupDir: backupDir})
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if got := planActions(plan); !reflect.DeepEqual(got, []string{"/app:create", "/app/bin:create"}) || plan.Ephemerals != 1 {
+			t.Errorf("plan = %v, %d ephemerals", got, plan.Ephemerals)
+		}
+		if bin := writer.znodes["/app/bin"]; string(bin.Data) != "\xff" || bin.ACL[0].Scheme != "digest" {
+			t.Errorf("/app/bin = %+v", bin)
+		}
+
+		_, err = runTestLoad(t, writer, &LoadConfig{BackupDir: backupDir, Zxid: "0x100000005"})
+		if err == nil || !strings.Contains(err.Error(), "only be loaded at its end zxid") {
+			t.Errorf("Load() error = %v, want end zxid only", err)
+		}
+	})
+
+	t.Run("physical backup", func(t *testing.T) {
+		backupDir := createExportTestBackup(t)
+
+		writer := newFakeZnodeWriter()
+		if _, err := runTestLoad(t, writer, &LoadConfig{BackupDir: backupDir, Zxid: "0x100000001"}); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		app := writer.znodes["/app"]
+		if string(app.Data) != "cfg" || app.ACL[0].Scheme != "digest" || writer.znodes["/app/new"] == nil {
+			t.Errorf("/app = %+v, /app/new = %+v", app, writer.znodes["/app/new"])
+		}
+
+		writer = newFakeZnodeWriter()
+		if _, err := runTestLoad(t, writer, &LoadConfig{BackupDir: backupDir}); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if string(writer.znodes["/app"].Data) != "cfg2" {
+			t.Errorf("/app data = %q, want the latest cfg2", writer.znodes["/app"].Data)
+		}
+
+		// A backup ID is looked up under the base dir
+		writer = newFakeZnodeWriter()
+		config := &LoadConfig{BackupDir: filepath.Base(backupDir), BackupBaseDir: filepath.Dir(backupDir)}
+		if _, err := runTestLoad(t, writer, config); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if config.BackupDir != backupDir || writer.znodes["/app"] == nil {
+			t.Errorf("BackupDir = %q, want %q loaded", config.BackupDir, backupDir)
+		}
+	})
+
+	t.Run("export documents", func(t *testing.T) {
+		backupDir := createExportTestBackup(t)
+		for _, format := range []string{ExportFormatJSON, ExportFormatYAML} {
+			config := &ExportConfig{BackupDir: backupDir, Format: format, DataEncoding: DataEncodingBase64, IncludeACL: true}
+			file := filepath.Join(t.TempDir(), "export."+format)
+			if err := os.WriteFile(file, runTestExport(t, config), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			writer := newFakeZnodeWriter()
+			plan, err := runTestLoad(t, writer, &LoadConfig{File: file})
+			if err != nil {
+				t.Fatalf("%s: Load() error = %v", format, err)
+			}
+			want := []string{"/app:create", "/app/bin:create", "/app/new:create"}
+			if got := planActions(plan); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: plan = %v, want %v", format, got, want)
+			}
+			if bin := writer.znodes["/app/bin"]; string(bin.Data) != "\xff\xfe" {
+				t.Errorf("%s: /app/bin data = %v", format, bin.Data)
+			}
+		}
+	})
+
+	t.Run("invalid export file", func(t *testing.T) {
+		file := writeTestExportFile(t, "znodes.jsonl", `{"path":"app"}`)
+		_, err := runTestLoad(t, newFakeZnodeWriter(), &LoadConfig{File: file})
+		if err == nil || !strings.Contains(err.Error(), "invalid export file") {
+			t.Errorf("Load() error = %v, want invalid export file", err)
+		}
+	})
+}
+
+func TestParseACL(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []zkfile.ACL
+		wantErr bool
+	}{
+		{spec: "world:anyone:cdrwa", want: []zkfile.ACL{{Perms: 31, Scheme: "world", ID: "anyone"}}},
+		{spec: "digest:admin:Xh8z+=:rw, ip:10.0.0.1:r", want: []zkfile.ACL{
+			{Perms: 3, Scheme: "digest", ID: "admin:Xh8z+="}, {Perms: 1, Scheme: "ip", ID: "10.0.0.1"}}},
+		{spec: "auth::cd", want: []zkfile.ACL{{Perms: 12, Scheme: "auth", ID: ""}}},
+		{spec: "world:anyone", wantErr: true},
+		{spec: "world:anyone:", wantErr: true},
+		{spec: "world:anyone:rx", wantErr: true},
+		{spec: ":anyone:r", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseACL(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseACL(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseACL(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/zookeeper-backup/pkg/metadata"
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// LogicalArchiveFile is the znode archive of a logical backup, relative to the
+// backup directory. It holds one ExportNode per line, with stat and ACL,
+// parents before their children.
+const LogicalArchiveFile = "logical/znodes.jsonl"
+
+// logicalFileType is the file type of the logical archive in verify reports
+const logicalFileType = "logical"
+
+// zookeeperSystemPath holds quotas and the dynamic configuration; it is only
+// backed up when it is one of the walked paths
+const zookeeperSystemPath = "/zookeeper"
+
+// ZnodeReader reads znodes from a live ensemble, implemented by utils.ZKClient
+type ZnodeReader interface {
+	GetZnode(path string) (*utils.Znode, error)
+	GetCurrentZXID() (zkfile.ZXID, error)
+}
+
+// backupLogical connects to ZooKeeper and walks the configured subtrees
+func (e *BackupEngine) backupLogical(backupDir string, backupInfo *metadata.BackupInfo) error {
+	client, err := utils.NewZKClient(e.config.ZkHost, e.config.ZkTimeout)
+	if err != nil {
+		return zkfile.NewZooKeeperError("failed to connect to zookeeper").WithError(err).WithContext("host", e.config.ZkHost)
+	}
+	defer client.Close()
+
+	if err = client.SetStatsBackend(e.config.StatsBackend, e.config.AdminURL); err != nil {
+		return err
+	}
+
+	return e.backupZnodes(client, backupDir, backupInfo)
+}
+
+// backupZnodes writes the znodes of the configured subtrees to the logical
+// archive. The tree is read while clients keep writing, so the server ZXID is
+// recorded before and after the walk: every znode is at least as recent as the
+// first one, and the backup ZXID is the second.
+func (e *BackupEngine) backupZnodes(client ZnodeReader, backupDir string, backupInfo *metadata.BackupInfo) error {
+	startZxid, err := client.GetCurrentZXID()
+	if err != nil {
+		e.logger.Warn("Failed to get ZXID before walking znodes, the window starts at 0", zap.Error(err))
+		startZxid = 0
+	}
+
+	archive := filepath.Join(backupDir, LogicalArchiveFile)
+	partial := archive + ".partial"
+	f, err := os.Create(partial)
+	if err != nil {
+		return zkfile.NewIOError("failed to create logical archive").WithError(err).WithContext("path", partial)
+	}
+	defer func() { _ = os.Remove(partial) }()
+
+	roots := logicalRoots(e.config.Paths)
+	walker := &znodeWalker{client: client, logger: e.logger, highest: startZxid}
+	w := bufio.NewWriter(f)
+	walker.encoder = json.NewEncoder(w)
+	for _, root := range roots {
+		if err = walker.walkRoot(root); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	endZxid, err := client.GetCurrentZXID()
+	if err != nil {
+		e.logger.Warn("Failed to get ZXID after walking znodes, using the highest ZXID seen", zap.Error(err))
+	}
+	endZxid = max(endZxid, walker.highest)
+
+	size, err := zkfile.GetFileInfo(partial)
+	if err != nil {
+		return err
+	}
+	storedSize, err := zkfile.CompressFile(partial, archive, e.config.Compression, e.config.CompressionLevel)
+	if err != nil {
+		return err
+	}
+	checksum, err := zkfile.CalculateFileChecksum(archive)
+	if err != nil {
+		return err
+	}
+
+	backupInfo.Logical = &metadata.LogicalInfo{
+		File:      LogicalArchiveFile,
+		Paths:     roots,
+		StartZxid: metadata.NewZxidInfo(startZxid),
+		EndZxid:   metadata.NewZxidInfo(endZxid),
+		NodeCount: walker.count,
+		Size:      size.Size,
+		Checksum:  checksum,
+	}
+	if e.config.Compression != zkfile.CompressionNone {
+		backupInfo.Logical.Compression = e.config.Compression
+		backupInfo.Logical.CompressedSize = storedSize
+	}
+	backupInfo.SetBackupZxid(endZxid)
+
+	e.logger.Info("Znode walk completed", zap.Int("nodes", walker.count),
+		zap.String("start_zxid", startZxid.String()), zap.String("end_zxid", endZxid.String()))
+
+	return nil
+}
+
+// znodeWalker writes the znodes of subtrees, parents before their children
+type znodeWalker struct {
+	client  ZnodeReader
+	logger  *zap.Logger
+	encoder *json.Encoder
+	count   int
+	highest zkfile.ZXID // highest ZXID in the stats read
+}
+
+// walkRoot writes the subtree at root, which must exist
+func (w *znodeWalker) walkRoot(root string) error {
+	znode, err := w.client.GetZnode(root)
+	if errors.Is(err, utils.ErrNoNode) {
+		return zkfile.NewUserError("path does not exist").WithContext("path", root)
+	}
+	if err != nil {
+		return zkfile.NewZooKeeperError("failed to read znode").WithError(err).WithContext("path", root)
+	}
+	return w.walk(znode)
+}
+
+// walk writes a znode and its descendants. Children deleted while the tree is
+// walked are skipped.
+func (w *znodeWalker) walk(znode *utils.Znode) error {
+	node := &ExportNode{
+		Path: znode.Path,
+		Stat: exportStat(znode.Stat, len(znode.Data), len(znode.Children)),
+		ACL:  znode.ACL,
+	}
+	node.Data, node.Encoding = exportData(znode.Data, DataEncodingUTF8)
+	if err := w.encoder.Encode(node); err != nil {
+		return zkfile.NewIOError("failed to write logical archive").WithError(err)
+	}
+	w.count++
+	w.highest = max(w.highest, znode.Stat.Czxid, znode.Stat.Mzxid, znode.Stat.Pzxid)
+
+	for _, name := range znode.Children {
+		path := childZnodePath(znode.Path, name)
+		if path == zookeeperSystemPath {
+			continue
+		}
+
+		child, err := w.client.GetZnode(path)
+		if errors.Is(err, utils.ErrNoNode) {
+			w.logger.Debug("Znode deleted during walk", zap.String("path", path))
+			continue
+		}
+		if err != nil {
+			return zkfile.NewZooKeeperError("failed to read znode").WithError(err).WithContext("path", path)
+		}
+		if err = w.walk(child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// logicalRoots returns the sorted paths to walk, without paths inside another
+// one. /zookeeper is not walked from "/", so it stays a root when given.
+func logicalRoots(paths []string) []string {
+	sorted := append([]string(nil), paths...)
+	sort.Strings(sorted)
+
+	roots := make([]string, 0, len(sorted))
+	for _, path := range sorted {
+		covered := false
+		for _, root := range roots {
+			if root == "/" && (path == zookeeperSystemPath || strings.HasPrefix(path, zookeeperSystemPath+"/")) {
+				continue
+			}
+			if path == root || root == "/" || strings.HasPrefix(path, root+"/") {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			roots = append(roots, path)
+		}
+	}
+	return roots
+}
+
+// childZnodePath joins a parent path and a child name
+func childZnodePath(parent, name string) string {
+	if parent == "/" {
+		return "/" + name
+	}
+	return parent + "/" + name
+}
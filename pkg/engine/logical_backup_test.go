@@ -0,0 +1,286 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/zookeeper-backup/pkg/metadata"
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// fakeZnodeReader serves znodes from memory. zxids are returned by
+// GetCurrentZXID in turn; onGet runs before a znode is read.
+type fakeZnodeReader struct {
+	znodes map[string]*utils.Znode
+	zxids  []zkfile.ZXID
+	onGet  func(path string)
+}
+
+// newFakeZnodeReader creates a reader holding the root, /zookeeper and the
+// given znodes, linked to their parents
+func newFakeZnodeReader(znodes ...*utils.Znode) *fakeZnodeReader {
+	r := &fakeZnodeReader{znodes: make(map[string]*utils.Znode)}
+	for _, znode := range append([]*utils.Znode{{Path: "/"}, {Path: "/zookeeper"}, {Path: "/zookeeper/quota"}}, znodes...) {
+		r.add(znode)
+	}
+	return r
+}
+
+// add adds a znode and links it to its parent
+func (r *fakeZnodeReader) add(znode *utils.Znode) {
+	if znode.ACL == nil {
+		znode.ACL = []zkfile.ACL{{Perms: 31, Scheme: "world", ID: "anyone"}}
+	}
+	r.znodes[znode.Path] = znode
+	if znode.Path == "/" {
+		return
+	}
+	i := strings.LastIndex(znode.Path, "/")
+	parent := r.znodes[znode.Path[:max(i, 1)]]
+	parent.Children = append(parent.Children, znode.Path[i+1:])
+	sort.Strings(parent.Children)
+}
+
+func (r *fakeZnodeReader) GetZnode(path string) (*utils.Znode, error) {
+	if r.onGet != nil {
+		r.onGet(path)
+	}
+	znode, ok := r.znodes[path]
+	if !ok {
+		return nil, utils.ErrNoNode
+	}
+	copied := *znode
+	copied.Children = append([]string(nil), znode.Children...)
+	return &copied, nil
+}
+
+func (r *fakeZnodeReader) GetCurrentZXID() (zkfile.ZXID, error) {
+	if len(r.zxids) == 0 {
+		return 0, errors.New("stats unavailable")
+	}
+	zxid := r.zxids[0]
+	r.zxids = r.zxids[1:]
+	return zxid, nil
+}
+
+// readLogicalArchive returns the nodes of a logical archive
+func readLogicalArchive(t *testing.T, path string) []*ExportNode {
+	t.Helper()
+
+	f, err := zkfile.OpenDecompressed(path)
+	if err != nil {
+		t.Fatalf("OpenDecompressed() error = %v", err)
+	}
+	defer f.Close()
+
+	var nodes []*ExportNode
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var node ExportNode
+		if err = json.Unmarshal(scanner.Bytes(), &node); err != nil {
+			t.Fatalf("json.Unmarshal(%s) error = %v", scanner.Text(), err)
+		}
+		nodes = append(nodes, &node)
+	}
+	return nodes
+}
+
+// runTestLogicalBackup backs up the reader into a new backup directory and
+// returns the directory and its metadata
+func runTestLogicalBackup(t *testing.T, reader *fakeZnodeReader, config *BackupConfig) (string, *metadata.BackupInfo, error) {
+	t.Helper()
+
+	config.Mode = BackupModeLogical
+	config.OutputDir = t.TempDir()
+	config.BackupID = "backup-logical"
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	engine := NewBackupEngine(config)
+	backupDir := filepath.Join(config.OutputDir, config.BackupID)
+	if err := engine.createBackupDirs(backupDir); err != nil {
+		t.Fatalf("createBackupDirs() error = %v", err)
+	}
+	info := metadata.NewBackupInfo(config.BackupID, 0)
+	info.Mode = config.Mode
+	if err := engine.backupZnodes(reader, backupDir, info); err != nil {
+		return backupDir, info, err
+	}
+	if err := engine.saveMetadata(backupDir, info); err != nil {
+		t.Fatalf("saveMetadata() error = %v", err)
+	}
+	return backupDir, info, nil
+}
+
+func TestBackupEngine_Logical(t *testing.T) {
+	newReader := func() *fakeZnodeReader {
+		r := newFakeZnodeReader(
+			&utils.Znode{Path: "/app", Data: []byte("cfg"), Stat: zkfile.StatPersisted{Czxid: 0x100000001, Mzxid: 0x100000004, Version: 2}},
+			&utils.Znode{Path: "/app/bin", Data: []byte{0xff}, Stat: zkfile.StatPersisted{Czxid: 0x100000002, Mzxid: 0x100000002},
+				ACL: []zkfile.ACL{{Perms: 1, Scheme: "digest", ID: "user:hash"}}},
+			&utils.Znode{Path: "/app/lock", Stat: zkfile.StatPersisted{Czxid: 0x100000003, EphemeralOwner: 0x1234}},
+			&utils.Znode{Path: "/config"},
+			&utils.Znode{Path: "/other"},
+		)
+		r.zxids = []zkfile.ZXID{0x100000005, 0x100000007}
+		return r
+	}
+
+	t.Run("whole tree", func(t *testing.T) {
+		backupDir, info, err := runTestLogicalBackup(t, newReader(), &BackupConfig{})
+		if err != nil {
+			t.Fatalf("backupZnodes() error = %v", err)
+		}
+
+		nodes := readLogicalArchive(t, filepath.Join(backupDir, LogicalArchiveFile))
+		var paths []string
+		for _, node := range nodes {
+			paths = append(paths, node.Path)
+		}
+		want := []string{"/", "/app", "/app/bin", "/app/lock", "/config", "/other"}
+		if !reflect.DeepEqual(paths, want) {
+			t.Fatalf("archive paths = %v, want %v (without /zookeeper)", paths, want)
+		}
+
+		app, bin := nodes[1], nodes[2]
+		if *app.Data != "cfg" || app.Stat.Mzxid != "0x100000004" || app.Stat.Version != 2 || app.Stat.NumChildren != 2 {
+			t.Errorf("/app = %+v, stat %+v", app, app.Stat)
+		}
+		if data, _ := bin.DecodeData(); bin.Encoding != DataEncodingBase64 || string(data) != "\xff" || bin.ACL[0].Scheme != "digest" {
+			t.Errorf("/app/bin = %+v", bin)
+		}
+		if nodes[3].Stat.EphemeralOwner != "0x1234" || nodes[3].Data != nil {
+			t.Errorf("/app/lock = %+v, stat %+v", nodes[3], nodes[3].Stat)
+		}
+
+		l := info.Logical
+		if l == nil || l.NodeCount != 6 || l.StartZxid.Hex != "100000005" || l.EndZxid.Hex != "100000007" ||
+			!reflect.DeepEqual(l.Paths, []string{"/"}) || info.BackupZxid.Hex != "100000007" {
+			t.Fatalf("Logical = %+v, BackupZxid = %v", l, info.BackupZxid)
+		}
+		if names := listDirNames(t, filepath.Join(backupDir, "logical")); !reflect.DeepEqual(names, []string{"znodes.jsonl"}) {
+			t.Errorf("logical dir = %v, want only the archive", names)
+		}
+	})
+
+	t.Run("path filters", func(t *testing.T) {
+		config := &BackupConfig{Paths: []string{"/config", "/app/bin", "/app", "/zookeeper"}}
+		backupDir, info, err := runTestLogicalBackup(t, newReader(), config)
+		if err != nil {
+			t.Fatalf("backupZnodes() error = %v", err)
+		}
+
+		var paths []string
+		for _, node := range readLogicalArchive(t, filepath.Join(backupDir, LogicalArchiveFile)) {
+			paths = append(paths, node.Path)
+		}
+		want := []string{"/app", "/app/bin", "/app/lock", "/config", "/zookeeper", "/zookeeper/quota"}
+		if !reflect.DeepEqual(paths, want) {
+			t.Errorf("archive paths = %v, want %v", paths, want)
+		}
+		if !reflect.DeepEqual(info.Logical.Paths, []string{"/app", "/config", "/zookeeper"}) {
+			t.Errorf("Logical.Paths = %v", info.Logical.Paths)
+		}
+	})
+
+	t.Run("znodes changing during the walk", func(t *testing.T) {
+		reader := newReader()
+		reader.zxids = nil
+		reader.onGet = func(path string) {
+			// /app/lock goes away and /config is modified after the walk starts
+			if path == "/app" {
+				delete(reader.znodes, "/app/lock")
+				reader.znodes["/config"].Stat.Mzxid = 0x100000009
+			}
+		}
+
+		backupDir, info, err := runTestLogicalBackup(t, reader, &BackupConfig{})
+		if err != nil {
+			t.Fatalf("backupZnodes() error = %v", err)
+		}
+		if nodes := readLogicalArchive(t, filepath.Join(backupDir, LogicalArchiveFile)); len(nodes) != 5 {
+			t.Errorf("archive holds %d nodes, want 5 without the deleted /app/lock", len(nodes))
+		}
+		// Without server stats the window ends at the highest ZXID read
+		if info.Logical.StartZxid.Hex != "0" || info.Logical.EndZxid.Hex != "100000009" {
+			t.Errorf("window = %s ~ %s, want 0 ~ 100000009", info.Logical.StartZxid.Hex, info.Logical.EndZxid.Hex)
+		}
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		_, _, err := runTestLogicalBackup(t, newReader(), &BackupConfig{Paths: []string{"/missing"}})
+		if err == nil || !strings.Contains(err.Error(), "path does not exist") {
+			t.Errorf("backupZnodes() error = %v, want path does not exist", err)
+		}
+	})
+
+	t.Run("compressed archive verifies", func(t *testing.T) {
+		backupDir, info, err := runTestLogicalBackup(t, newReader(), &BackupConfig{Compression: zkfile.CompressionZstd})
+		if err != nil {
+			t.Fatalf("backupZnodes() error = %v", err)
+		}
+		if info.Logical.Compression != zkfile.CompressionZstd || info.Logical.CompressedSize <= 0 {
+			t.Errorf("Logical = %+v, want zstd", info.Logical)
+		}
+		if nodes := readLogicalArchive(t, filepath.Join(backupDir, LogicalArchiveFile)); len(nodes) != 6 {
+			t.Errorf("archive holds %d nodes, want 6", len(nodes))
+		}
+
+		report, err := NewVerifyEngine(&VerifyConfig{BackupDir: backupDir}).Verify()
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+		if report.Status != metadata.BackupStatusValid || len(report.Files) != 1 || report.Files[0].Type != logicalFileType {
+			t.Errorf("Verify() = %+v, files %+v", report, report.Files)
+		}
+
+		os.WriteFile(filepath.Join(backupDir, LogicalArchiveFile), []byte("{}\n"), 0644)
+		report, err = NewVerifyEngine(&VerifyConfig{BackupDir: backupDir}).Verify()
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+		if report.Status != metadata.BackupStatusCorrupted || !strings.Contains(report.Files[0].Detail, "checksum mismatch") {
+			t.Errorf("Verify() status = %v, files %+v, want a checksum mismatch", report.Status, report.Files)
+		}
+	})
+
+	t.Run("restore refuses logical backups", func(t *testing.T) {
+		backupDir, _, err := runTestLogicalBackup(t, newReader(), &BackupConfig{})
+		if err != nil {
+			t.Fatalf("backupZnodes() error = %v", err)
+		}
+		root := t.TempDir()
+		restore := NewRestoreEngine(&RestoreConfig{BackupDir: backupDir, ZkDataDir: filepath.Join(root, "data"),
+			ZkLogDir: filepath.Join(root, "log"), Force: true})
+		if err = restore.Run(); err == nil || !strings.Contains(err.Error(), "logical backups") {
+			t.Errorf("Run() error = %v, want logical backups refused", err)
+		}
+	})
+}
+
+func TestLogicalRoots(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  []string
+	}{
+		{paths: []string{"/"}, want: []string{"/"}},
+		{paths: []string{"/app/x", "/app", "/app-x", "/app"}, want: []string{"/app", "/app-x"}},
+		{paths: []string{"/app", "/"}, want: []string{"/"}},
+		{paths: []string{"/", "/zookeeper/quota", "/zookeeper"}, want: []string{"/", "/zookeeper"}},
+	}
+
+	for _, tt := range tests {
+		if got := logicalRoots(tt.paths); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("logicalRoots(%v) = %v, want %v", tt.paths, got, tt.want)
+		}
+	}
+}
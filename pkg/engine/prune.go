@@ -0,0 +1,310 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zookeeper-backup/pkg/metadata"
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// PruneEngine prune engine
+type PruneEngine struct {
+	config *PruneConfig
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+// PruneDecision is the retention decision for a single backup
+type PruneDecision struct {
+	Entry  *metadata.CatalogEntry
+	Delete bool
+	Reason string
+}
+
+// PrunePlan is the ordered list of retention decisions, newest backup first
+type PrunePlan struct {
+	Decisions []*PruneDecision
+}
+
+// NewPruneEngine creates a new prune engine
+func NewPruneEngine(config *PruneConfig) *PruneEngine {
+	return &PruneEngine{
+		config: config,
+		logger: utils.GetLogger(),
+		now:    time.Now,
+	}
+}
+
+// Run executes the prune operation
+func (e *PruneEngine) Run() error {
+	// 1. Validate configuration
+	if err := e.config.Validate(); err != nil {
+		return zkfile.NewConfigurationError("invalid configuration").WithError(err)
+	}
+
+	e.logger.Info("Starting prune",
+		zap.String("base_dir", e.config.BackupBaseDir),
+		zap.Int("keep_days", e.config.KeepDays),
+		zap.Int("keep_count", e.config.KeepCount),
+		zap.Int("keep_min_count", e.config.KeepMinCount),
+		zap.Int("keep_hourly", e.config.KeepHourly),
+		zap.Int("keep_daily", e.config.KeepDaily),
+		zap.Int("keep_weekly", e.config.KeepWeekly),
+		zap.Int("keep_monthly", e.config.KeepMonthly))
+
+	// 2. Build retention plan
+	plan, err := e.Plan()
+	if err != nil {
+		return fmt.Errorf("failed to build prune plan: %w", err)
+	}
+
+	e.printPlan(plan)
+
+	toDelete := plan.ToDelete()
+	if len(toDelete) == 0 {
+		fmt.Println("No backups to prune")
+		return nil
+	}
+
+	// 3. Dry-run: stop after showing the plan
+	if e.config.DryRun {
+		fmt.Println("🔍 Dry-run mode - no actual changes were made")
+		return nil
+	}
+
+	// 4. Confirm prune (if not forced)
+	if !e.config.Force {
+		if !e.confirmPrune(len(toDelete)) {
+			return zkfile.NewCancelledError("prune cancelled by user")
+		}
+	}
+
+	// 5. Delete backups
+	return e.deleteBackups(toDelete)
+}
+
+// Plan scans the backup base directory and decides which backups to keep
+func (e *PruneEngine) Plan() (*PrunePlan, error) {
+	catalog, err := metadata.ScanCatalog(e.config.BackupBaseDir)
+	if err != nil {
+		return nil, err
+	}
+	if err = metadata.SortCatalog(catalog, "time"); err != nil {
+		return nil, err
+	}
+
+	return buildPrunePlan(catalog, e.config, e.now()), nil
+}
+
+// buildPrunePlan applies the retention rules to a catalog sorted newest first.
+// Only usable backups count towards keep-count and keep-min-count, and only
+// usable backups are ever deleted, so the newest usable copies always survive.
+// Backups selected by an hourly/daily/weekly/monthly tier survive keep-days and
+// keep-count; when tiers are the only rule, unselected backups are deleted.
+func buildPrunePlan(catalog []*metadata.CatalogEntry, config *PruneConfig, now time.Time) *PrunePlan {
+	plan := &PrunePlan{Decisions: make([]*PruneDecision, 0, len(catalog))}
+
+	usable := make([]*metadata.CatalogEntry, 0, len(catalog))
+	for _, entry := range catalog {
+		if isUsableBackup(entry) {
+			usable = append(usable, entry)
+		}
+	}
+	tiers := selectTieredBackups(usable, config, now.Location())
+	tiersOnly := config.HasTieredRetention() && config.KeepDays == 0 && config.KeepCount == 0
+
+	rank := 0
+	for _, entry := range catalog {
+		decision := &PruneDecision{Entry: entry}
+		plan.Decisions = append(plan.Decisions, decision)
+
+		if !isUsableBackup(entry) {
+			decision.Reason = fmt.Sprintf("skipped: %s backups are never pruned", entry.Status)
+			continue
+		}
+
+		rank++
+		age := now.Sub(entry.Timestamp)
+
+		switch {
+		case rank <= config.KeepMinCount:
+			decision.Reason = fmt.Sprintf("protected: within newest %d (keep-min-count)", config.KeepMinCount)
+		case tiers[entry] != "":
+			decision.Reason = "protected: " + tiers[entry]
+		case config.KeepCount > 0 && rank > config.KeepCount:
+			decision.Delete = true
+			decision.Reason = fmt.Sprintf("exceeds keep-count %d", config.KeepCount)
+		case config.KeepDays > 0 && age > time.Duration(config.KeepDays)*24*time.Hour:
+			decision.Delete = true
+			decision.Reason = fmt.Sprintf("older than %d days", config.KeepDays)
+		case tiersOnly:
+			decision.Delete = true
+			decision.Reason = "not selected by any retention tier"
+		default:
+			decision.Reason = "within retention"
+		}
+	}
+
+	return plan
+}
+
+// retentionTier is one grandfather-father-son retention level
+type retentionTier struct {
+	name   string
+	count  int
+	period func(t time.Time) string
+}
+
+// selectTieredBackups keeps the newest backup of each of the last N distinct
+// hours, days, weeks and months that contain a backup.
+// The returned map holds the reason for every selected backup.
+func selectTieredBackups(usable []*metadata.CatalogEntry, config *PruneConfig, loc *time.Location) map[*metadata.CatalogEntry]string {
+	tiers := []retentionTier{
+		{name: "hourly", count: config.KeepHourly, period: func(t time.Time) string { return t.Format("2006-01-02 15h") }},
+		{name: "daily", count: config.KeepDaily, period: func(t time.Time) string { return t.Format("2006-01-02") }},
+		{name: "weekly", count: config.KeepWeekly, period: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{name: "monthly", count: config.KeepMonthly, period: func(t time.Time) string { return t.Format("2006-01") }},
+	}
+
+	selected := make(map[*metadata.CatalogEntry]string)
+	for _, tier := range tiers {
+		if tier.count <= 0 {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		for _, entry := range usable {
+			period := tier.period(entry.Timestamp.In(loc))
+			if seen[period] {
+				continue
+			}
+			if len(seen) >= tier.count {
+				break
+			}
+			seen[period] = true
+
+			reason := fmt.Sprintf("%s %s", tier.name, period)
+			if selected[entry] != "" {
+				reason = selected[entry] + ", " + reason
+			}
+			selected[entry] = reason
+		}
+	}
+
+	return selected
+}
+
+// isUsableBackup reports whether a backup can be used for restore
+func isUsableBackup(entry *metadata.CatalogEntry) bool {
+	return entry.Status == metadata.BackupStatusValid || entry.Status == metadata.BackupStatusPartial
+}
+
+// ToDelete returns the decisions that delete a backup
+func (p *PrunePlan) ToDelete() []*PruneDecision {
+	var decisions []*PruneDecision
+	for _, d := range p.Decisions {
+		if d.Delete {
+			decisions = append(decisions, d)
+		}
+	}
+	return decisions
+}
+
+// ReclaimableSize returns the total size of the backups to delete
+func (p *PrunePlan) ReclaimableSize() int64 {
+	var size int64
+	for _, d := range p.ToDelete() {
+		size += d.Entry.TotalSize
+	}
+	return size
+}
+
+// deleteBackups removes the selected backup directories
+func (e *PruneEngine) deleteBackups(decisions []*PruneDecision) error {
+	deleted := 0
+	var freed int64
+	var failed []string
+
+	for _, d := range decisions {
+		if err := zkfile.RemoveDir(d.Entry.Path); err != nil {
+			e.logger.Error("Failed to delete backup", zap.String("backup_id", d.Entry.BackupID), zap.Error(err))
+			fmt.Printf("  ❌ %s: %v\n", d.Entry.BackupID, err)
+			failed = append(failed, d.Entry.BackupID)
+			continue
+		}
+
+		deleted++
+		freed += d.Entry.TotalSize
+		e.logger.Debug("Deleted backup", zap.String("backup_id", d.Entry.BackupID))
+		fmt.Printf("  ✅ %s\n", d.Entry.BackupID)
+	}
+
+	fmt.Printf("\nDeleted: %d backups, freed %s\n", deleted, utils.FormatBytes(freed))
+
+	e.logger.Info("Prune completed", zap.Int("deleted", deleted), zap.Int("failed", len(failed)), zap.Int64("freed", freed))
+
+	if len(failed) > 0 && deleted > 0 {
+		return zkfile.NewPartialError("some backups could not be deleted").
+			WithContext("deleted", deleted).WithContext("failed", failed)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to delete %d backups: %v", len(failed), failed)
+	}
+
+	return nil
+}
+
+// printPlan prints the retention decision for every backup
+func (e *PruneEngine) printPlan(plan *PrunePlan) {
+	keepCount := "unlimited"
+	if e.config.KeepCount > 0 {
+		keepCount = fmt.Sprintf("%d", e.config.KeepCount)
+	}
+
+	fmt.Printf("Pruning backups in: %s\n", e.config.BackupBaseDir)
+	fmt.Printf("Policy: keep %d days, keep count %s, keep at least %d\n",
+		e.config.KeepDays, keepCount, e.config.KeepMinCount)
+	if e.config.HasTieredRetention() {
+		fmt.Printf("Tiers: %d hourly, %d daily, %d weekly, %d monthly\n",
+			e.config.KeepHourly, e.config.KeepDaily, e.config.KeepWeekly, e.config.KeepMonthly)
+	}
+	fmt.Println()
+
+	if len(plan.Decisions) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACTION\tBACKUP ID\tTIMESTAMP\tSIZE\tREASON")
+	for _, d := range plan.Decisions {
+		action := "keep"
+		if d.Delete {
+			action = "delete"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", action, d.Entry.BackupID,
+			d.Entry.Timestamp.Format("2006-01-02 15:04:05"), utils.FormatBytes(d.Entry.TotalSize), d.Reason)
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\nTotal: %d, to delete: %d, reclaimable: %s\n\n",
+		len(plan.Decisions), len(plan.ToDelete()), utils.FormatBytes(plan.ReclaimableSize()))
+}
+
+// confirmPrune asks user for confirmation
+func (e *PruneEngine) confirmPrune(count int) bool {
+	fmt.Printf("This will permanently delete %d backups! Type 'yes' to continue: \n", count)
+
+	var response string
+	_, _ = fmt.Scanln(&response)
+
+	return response == "yes"
+}
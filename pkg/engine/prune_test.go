@@ -0,0 +1,260 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zookeeper-backup/pkg/metadata"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+var pruneTestNow = time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+
+// newPruneTestCatalog builds a catalog with one backup per day, newest first
+func newPruneTestCatalog(days int, statuses map[int]string) []*metadata.CatalogEntry {
+	catalog := make([]*metadata.CatalogEntry, 0, days)
+	for i := 0; i < days; i++ {
+		status := metadata.BackupStatusValid
+		if s, ok := statuses[i]; ok {
+			status = s
+		}
+		catalog = append(catalog, &metadata.CatalogEntry{
+			BackupID:  fmt.Sprintf("backup-%d", i),
+			Timestamp: pruneTestNow.Add(-time.Duration(i) * 24 * time.Hour),
+			TotalSize: 100,
+			Status:    status,
+		})
+	}
+	return catalog
+}
+
+func TestBuildPrunePlan(t *testing.T) {
+	tests := []struct {
+		name       string
+		days       int
+		statuses   map[int]string
+		config     PruneConfig
+		wantDelete []string
+	}{
+		{
+			name:       "keep days",
+			days:       10,
+			config:     PruneConfig{KeepDays: 7, KeepMinCount: 3},
+			wantDelete: []string{"backup-8", "backup-9"},
+		},
+		{
+			name:       "keep count",
+			days:       6,
+			config:     PruneConfig{KeepCount: 4, KeepMinCount: 1},
+			wantDelete: []string{"backup-4", "backup-5"},
+		},
+		{
+			name:       "min count overrides keep days",
+			days:       5,
+			config:     PruneConfig{KeepDays: 1, KeepMinCount: 3},
+			wantDelete: []string{"backup-3", "backup-4"},
+		},
+		{
+			name:       "min count overrides keep count",
+			days:       5,
+			config:     PruneConfig{KeepCount: 1, KeepMinCount: 3},
+			wantDelete: []string{"backup-3", "backup-4"},
+		},
+		{
+			name: "unusable backups are skipped and do not count",
+			days: 6,
+			statuses: map[int]string{
+				0: metadata.BackupStatusIncomplete,
+				4: metadata.BackupStatusCorrupted,
+			},
+			config:     PruneConfig{KeepDays: 1, KeepMinCount: 2},
+			wantDelete: []string{"backup-3", "backup-5"},
+		},
+		{
+			name:       "nothing expired",
+			days:       3,
+			config:     PruneConfig{KeepDays: 7, KeepMinCount: 3},
+			wantDelete: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := buildPrunePlan(newPruneTestCatalog(tt.days, tt.statuses), &tt.config, pruneTestNow)
+
+			if len(plan.Decisions) != tt.days {
+				t.Fatalf("Decisions count = %d, want %d", len(plan.Decisions), tt.days)
+			}
+
+			var got []string
+			for _, d := range plan.ToDelete() {
+				got = append(got, d.Entry.BackupID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantDelete) {
+				t.Errorf("ToDelete() = %v, want %v", got, tt.wantDelete)
+			}
+
+			for _, d := range plan.Decisions {
+				if d.Reason == "" {
+					t.Errorf("Decision for %s has no reason", d.Entry.BackupID)
+				}
+			}
+		})
+	}
+}
+
+func TestPrunePlan_ReclaimableSize(t *testing.T) {
+	plan := buildPrunePlan(newPruneTestCatalog(5, nil), &PruneConfig{KeepCount: 2}, pruneTestNow)
+
+	if got := plan.ReclaimableSize(); got != 300 {
+		t.Errorf("ReclaimableSize() = %d, want 300", got)
+	}
+}
+
+func TestPruneEngine_Run(t *testing.T) {
+	baseDir := t.TempDir()
+
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("backup-%d", i)
+		info := metadata.NewBackupInfo(id, zkfile.ZXID(i))
+		info.BackupTimestamp = pruneTestNow.Add(-time.Duration(i) * 24 * time.Hour)
+		os.MkdirAll(filepath.Join(baseDir, id, "metadata"), 0755)
+		if err := info.SaveToFile(metadata.InfoPath(filepath.Join(baseDir, id))); err != nil {
+			t.Fatalf("SaveToFile() error = %v", err)
+		}
+	}
+
+	t.Run("dry run deletes nothing", func(t *testing.T) {
+		engine := NewPruneEngine(&PruneConfig{BackupBaseDir: baseDir, KeepCount: 2, DryRun: true})
+		engine.now = func() time.Time { return pruneTestNow }
+
+		if err := engine.Run(); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		for i := 0; i < 5; i++ {
+			if !zkfile.DirExists(filepath.Join(baseDir, fmt.Sprintf("backup-%d", i))) {
+				t.Errorf("backup-%d should still exist after dry run", i)
+			}
+		}
+	})
+
+	t.Run("force deletes expired backups", func(t *testing.T) {
+		engine := NewPruneEngine(&PruneConfig{BackupBaseDir: baseDir, KeepCount: 2, Force: true})
+		engine.now = func() time.Time { return pruneTestNow }
+
+		if err := engine.Run(); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		for i := 0; i < 5; i++ {
+			exists := zkfile.DirExists(filepath.Join(baseDir, fmt.Sprintf("backup-%d", i)))
+			if exists != (i < 2) {
+				t.Errorf("backup-%d exists = %v, want %v", i, exists, i < 2)
+			}
+		}
+	})
+}
+
+// newTieredTestCatalog builds a catalog with backups at the given offsets before pruneTestNow, newest first
+func newTieredTestCatalog(offsets ...time.Duration) []*metadata.CatalogEntry {
+	catalog := make([]*metadata.CatalogEntry, 0, len(offsets))
+	for _, offset := range offsets {
+		ts := pruneTestNow.Add(-offset)
+		catalog = append(catalog, &metadata.CatalogEntry{
+			BackupID:  ts.Format("backup-20060102-1504"),
+			Timestamp: ts,
+			TotalSize: 100,
+			Status:    metadata.BackupStatusValid,
+		})
+	}
+	return catalog
+}
+
+func TestBuildPrunePlan_TieredRetention(t *testing.T) {
+	const (
+		hour = time.Hour
+		day  = 24 * time.Hour
+	)
+
+	// pruneTestNow is Wednesday 2025-01-15 12:00 UTC
+	tests := []struct {
+		name     string
+		offsets  []time.Duration
+		config   PruneConfig
+		wantKeep []string
+	}{
+		{
+			name:     "hourly keeps newest backup per hour",
+			offsets:  []time.Duration{0, 30 * time.Minute, hour, 90 * time.Minute, 2 * hour, 3 * hour},
+			config:   PruneConfig{KeepHourly: 3},
+			wantKeep: []string{"backup-20250115-1200", "backup-20250115-1130", "backup-20250115-1030"},
+		},
+		{
+			name:     "daily keeps newest backup per day",
+			offsets:  []time.Duration{0, 6 * hour, day, day + 6*hour, 2 * day, 3 * day},
+			config:   PruneConfig{KeepDaily: 2},
+			wantKeep: []string{"backup-20250115-1200", "backup-20250114-1200"},
+		},
+		{
+			name:     "weekly uses iso weeks",
+			offsets:  []time.Duration{0, 2 * day, 3 * day, 9 * day, 10 * day, 17 * day},
+			config:   PruneConfig{KeepWeekly: 2},
+			wantKeep: []string{"backup-20250115-1200", "backup-20250112-1200"},
+		},
+		{
+			name:     "monthly keeps newest backup per month",
+			offsets:  []time.Duration{0, 10 * day, 20 * day, 40 * day, 50 * day, 80 * day},
+			config:   PruneConfig{KeepMonthly: 3},
+			wantKeep: []string{"backup-20250115-1200", "backup-20241226-1200", "backup-20241126-1200"},
+		},
+		{
+			name:     "combined tiers",
+			offsets:  []time.Duration{0, day, 2 * day, 9 * day, 40 * day},
+			config:   PruneConfig{KeepDaily: 2, KeepWeekly: 2, KeepMonthly: 2},
+			wantKeep: []string{"backup-20250115-1200", "backup-20250114-1200", "backup-20250106-1200", "backup-20241206-1200"},
+		},
+		{
+			name:     "tiers protect backups older than keep days",
+			offsets:  []time.Duration{0, day, 10 * day, 11 * day, 40 * day},
+			config:   PruneConfig{KeepDays: 7, KeepMonthly: 2},
+			wantKeep: []string{"backup-20250115-1200", "backup-20250114-1200", "backup-20241206-1200"},
+		},
+		{
+			name:     "min count still applies",
+			offsets:  []time.Duration{0, hour, 2 * hour, day},
+			config:   PruneConfig{KeepDaily: 1, KeepMinCount: 3},
+			wantKeep: []string{"backup-20250115-1200", "backup-20250115-1100", "backup-20250115-1000"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := buildPrunePlan(newTieredTestCatalog(tt.offsets...), &tt.config, pruneTestNow)
+
+			var got []string
+			for _, d := range plan.Decisions {
+				if !d.Delete {
+					got = append(got, d.Entry.BackupID)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantKeep) {
+				t.Errorf("kept = %v, want %v", got, tt.wantKeep)
+			}
+		})
+	}
+}
+
+func TestBuildPrunePlan_TieredReasons(t *testing.T) {
+	catalog := newTieredTestCatalog(0, 24*time.Hour)
+	plan := buildPrunePlan(catalog, &PruneConfig{KeepDaily: 1, KeepMonthly: 1}, pruneTestNow)
+
+	want := "protected: daily 2025-01-15, monthly 2025-01"
+	if plan.Decisions[0].Reason != want {
+		t.Errorf("Reason = %q, want %q", plan.Decisions[0].Reason, want)
+	}
+	if !plan.Decisions[1].Delete || plan.Decisions[1].Reason != "not selected by any retention tier" {
+		t.Errorf("Second backup decision = %+v, want deleted as unselected", plan.Decisions[1])
+	}
+}
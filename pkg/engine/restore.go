@@ -1,8 +1,10 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -15,6 +17,20 @@ import (
 type RestoreEngine struct {
 	config *RestoreConfig
 	logger *zap.Logger
+	safety *SafetyCopy
+}
+
+// restorePlan lists the backup files a restore copies into the ZooKeeper directories
+type restorePlan struct {
+	pointInTime bool
+	targetZxid  zkfile.ZXID
+	snapshots   []string
+	txnlogs     []string
+	truncateLog string   // last txnlog, cut at targetZxid
+	skipped     []string // backup files beyond targetZxid
+
+	restoreTime time.Time           // requested restore time, zero unless restoring to a time
+	lastTxn     *zkfile.Transaction // last transaction committed at or before restoreTime
 }
 
 // NewRestoreEngine creates a new restore engine
@@ -29,12 +45,14 @@ func NewRestoreEngine(config *RestoreConfig) *RestoreEngine {
 func (e *RestoreEngine) Run() error {
 	// 1. Validate configuration
 	if err := e.config.Validate(); err != nil {
-		return fmt.Errorf("invalid configuration: %w", err)
+		return zkfile.NewConfigurationError("invalid configuration").WithError(err)
 	}
 
 	e.logger.Info("Starting restore",
+		zap.String("target", e.config.Target),
 		zap.String("log_dir", e.config.ZkLogDir),
 		zap.String("data_dir", e.config.ZkDataDir),
+		zap.String("path", e.config.Path),
 		zap.String("backup_dir", e.config.BackupDir))
 
 	// 2. Load backup metadata
@@ -42,47 +60,64 @@ func (e *RestoreEngine) Run() error {
 	if err != nil {
 		return fmt.Errorf("failed to load backup info: %w", err)
 	}
+	if backupInfo.Mode == BackupModeLogical && e.config.Target != RestoreTargetLive {
+		return zkfile.NewUserError("logical backups hold znodes, not data files, and cannot be restored to data directories, use zkbackup load or --target live").
+			WithContext("backup_dir", e.config.BackupDir)
+	}
 
 	// 3. Verify backup if not skipped
-	if !e.config.SkipVerify {
+	if !e.config.SkipVerify && backupInfo.Mode != BackupModeLogical {
 		e.logger.Info("Verifying backup before restore")
 		if err = e.verifyBackup(); err != nil {
 			return fmt.Errorf("backup verification failed: %w", err)
 		}
 	}
 
-	// 4. Confirm restore (if not forced or dry-run)
+	// Live target: restore the subtree through the client API
+	if e.config.Target == RestoreTargetLive {
+		return e.restoreLive(backupInfo)
+	}
+
+	// 4. Plan which files to restore
+	plan, err := e.buildPlan(backupInfo)
+	if err != nil {
+		return fmt.Errorf("failed to plan restore: %w", err)
+	}
+	if !plan.restoreTime.IsZero() {
+		fmt.Printf("Resolved restore time %s to ZXID %s (last transaction at %s)\n",
+			plan.restoreTime.Format(time.RFC3339), plan.targetZxid, plan.lastTxn.Time().Format(time.RFC3339Nano))
+	}
+
+	// 5. Confirm restore (if not forced or dry-run)
 	if !e.config.Force && !e.config.DryRun {
-		if !e.confirmRestore(backupInfo) {
-			return fmt.Errorf("restore cancelled by user")
+		if !e.confirmRestore(backupInfo, plan) {
+			return zkfile.NewCancelledError("restore cancelled by user")
 		}
 	}
 
-	// 5. Dry-run: just show what would be restored
+	// 6. Dry-run: just show what would be restored
 	if e.config.DryRun {
-		return e.showDryRun(backupInfo)
+		return e.showDryRun(plan)
 	}
 
-	// 6. Backup existing data (safety measure)
-	if err = e.backupExistingData(); err != nil {
-		return fmt.Errorf("failed to backup existing data: %w", err)
+	// 7. Make sure the server is not writing to the directories
+	if err = e.ensureServerStopped(); err != nil {
+		return err
 	}
 
-	// 7. Restore snapshots
-	e.logger.Info("Restoring snapshot files")
-	if err = e.restoreSnapshots(); err != nil {
-		return fmt.Errorf("failed to restore snapshots: %w", err)
+	// 8. Backup existing data (safety measure)
+	if err = e.backupExistingData(); err != nil {
+		return fmt.Errorf("failed to backup existing data: %w", err)
 	}
 
-	// 8. Restore txnlogs
-	e.logger.Info("Restoring txnlog files")
-	if err = e.restoreTxnLogs(); err != nil {
-		return fmt.Errorf("failed to restore txnlogs: %w", err)
+	// 9. Restore snapshots and txnlogs
+	if err = e.restoreFiles(plan); err != nil {
+		return err
 	}
 
 	e.logger.Info("Restore completed successfully")
 
-	e.printNextSteps(backupInfo)
+	e.printNextSteps(backupInfo, plan)
 
 	return nil
 }
@@ -105,31 +140,247 @@ func (e *RestoreEngine) verifyBackup() error {
 
 	for path, result := range results {
 		if !result.IsValid {
-			return fmt.Errorf("file validation failed: %s (%s)", path, result.CorruptionType)
+			return zkfile.NewCorruptionError("file validation failed").
+				WithContext("file", path).WithContext("corruption", result.CorruptionType)
 		}
 	}
 
 	return nil
 }
 
-// backupExistingData backs up existing data before restore
+// backupExistingData moves the existing ZooKeeper files into the safety directory
 func (e *RestoreEngine) backupExistingData() error {
-	// Implementation: move existing files to backup location
-	e.logger.Info("Backing up existing data (safety measure)")
+	e.logger.Info("Backing up existing data (safety measure)", zap.String("safety_dir", e.safetyDir()))
+
+	safety, err := CreateSafetyCopy(e.config.ZkDataDir, e.config.ZkLogDir, e.safetyDir())
+	if err != nil {
+		return err
+	}
+	e.safety = safety
+
+	e.logger.Info("Existing data moved to safety directory",
+		zap.String("safety_dir", safety.Dir), zap.Int("files", safety.FileCount()))
+
 	return nil
 }
 
-// restoreTxnLogs restores txnlog files
-func (e *RestoreEngine) restoreTxnLogs() error {
-	txnlogDir := filepath.Join(e.config.BackupDir, "txnlogs")
-	txnlogs, err := zkfile.ListTxnLogFiles(txnlogDir)
+// ensureServerStopped refuses to restore while a server answers on the client
+// port: it would keep writing snapshots and txnlogs into the directories.
+func (e *RestoreEngine) ensureServerStopped() error {
+	_, err := utils.NewFourLetterClient(e.config.ZkHost, e.config.ZkTimeout).Ruok()
+	if err == nil || errors.Is(err, utils.ErrNotWhitelisted) {
+		return zkfile.NewUserError("zookeeper server is running, stop it before restoring").
+			WithContext("host", e.config.ZkHost)
+	}
+	return nil
+}
+
+// restoreFiles copies the planned files into the emptied ZooKeeper directories,
+// rolling back to the safety copy if it fails
+func (e *RestoreEngine) restoreFiles(plan *restorePlan) error {
+	// A server still running may have written new files after they were moved.
+	// They may hold transactions found nowhere else, so they are left where
+	// they are and nothing is rolled back over them.
+	if err := e.ensureEmptyTargets(); err != nil {
+		return fmt.Errorf("%w; nothing was restored, existing data kept in %s", err, e.safety.Dir)
+	}
+
+	e.logger.Info("Restoring snapshot files")
+	if err := e.restoreSnapshots(plan); err != nil {
+		return e.rollback(fmt.Errorf("failed to restore snapshots: %w", err))
+	}
+
+	e.logger.Info("Restoring txnlog files")
+	if err := e.restoreTxnLogs(plan); err != nil {
+		return e.rollback(fmt.Errorf("failed to restore txnlogs: %w", err))
+	}
+
+	return nil
+}
+
+// ensureEmptyTargets checks that no snapshot, txnlog or epoch file is left in
+// the ZooKeeper directories. ZooKeeper loads the newest snapshot and replays
+// every txnlog it finds, so a file newer than the restored ones would take it
+// past the restore target.
+func (e *RestoreEngine) ensureEmptyTargets() error {
+	for _, dir := range []string{e.config.ZkDataDir, e.config.ZkLogDir} {
+		names, err := listZooKeeperFiles(dir)
+		if err != nil {
+			return err
+		}
+		if len(names) > 0 {
+			return zkfile.NewUserError("zookeeper directory is not empty, is the server stopped?").
+				WithContext("dir", dir).WithContext("file", names[0])
+		}
+	}
+	return nil
+}
+
+// rollback restores the safety copy after a failed restore step
+func (e *RestoreEngine) rollback(cause error) error {
+	e.logger.Error("Restore failed, rolling back to existing data",
+		zap.String("safety_dir", e.safety.Dir), zap.Error(cause))
+
+	if err := e.safety.Rollback(); err != nil {
+		return fmt.Errorf("%w; rollback failed, existing data kept in %s: %v", cause, e.safety.Dir, err)
+	}
+
+	e.logger.Info("Rolled back to existing data")
+	return fmt.Errorf("%w (rolled back to existing data)", cause)
+}
+
+// safetyDir returns the configured safety directory or the default one
+func (e *RestoreEngine) safetyDir() string {
+	if e.config.SafetyDir == "" {
+		e.config.SafetyDir = DefaultSafetyDir(e.config.ZkDataDir, time.Now())
+	}
+	return e.config.SafetyDir
+}
+
+// buildPlan selects the backup files to restore.
+// A full restore copies every file. A point-in-time restore copies the newest
+// snapshot at or below the target ZXID and the txnlogs from that snapshot up
+// to the target, cutting the last txnlog at the target. A restore time is
+// resolved to the ZXID of the last transaction committed at or before it.
+func (e *RestoreEngine) buildPlan(info *metadata.BackupInfo) (*restorePlan, error) {
+	snapshots, err := zkfile.ListSnapshotFiles(filepath.Join(e.config.BackupDir, "snapshots"))
+	if err != nil {
+		return nil, err
+	}
+	txnlogs, err := zkfile.ListTxnLogFiles(filepath.Join(e.config.BackupDir, "txnlogs"))
+	if err != nil {
+		return nil, err
+	}
+
+	var target zkfile.ZXID
+	var restoreTime time.Time
+	var lastTxn *zkfile.Transaction
+	switch {
+	case e.config.TruncateToZxid != "":
+		if target, err = zkfile.ParseZXID(e.config.TruncateToZxid); err != nil {
+			return nil, err
+		}
+	case e.config.RestoreToTime != "":
+		if restoreTime, err = time.Parse(time.RFC3339, e.config.RestoreToTime); err != nil {
+			return nil, zkfile.NewUserError("invalid restore time").WithError(err)
+		}
+		if lastTxn, err = zkfile.FindLastTxnAtTime(txnlogs, restoreTime); err != nil {
+			return nil, err
+		}
+		if lastTxn == nil {
+			return nil, zkfile.NewUserError("no transaction committed at or before restore time").
+				WithContext("restore_time", e.config.RestoreToTime)
+		}
+		target = lastTxn.Zxid
+	default:
+		return &restorePlan{snapshots: snapshots, txnlogs: txnlogs}, nil
+	}
+
+	if !info.CoversZxid(target) {
+		coverage := info.Coverage()
+		return nil, zkfile.NewUserError("backup does not cover target zxid").
+			WithContext("target_zxid", target.String()).
+			WithContext("first_zxid", coverage.FirstZxid.String()).
+			WithContext("last_zxid", coverage.LastZxid.String()).
+			WithContext("gaps", len(coverage.Gaps))
+	}
+
+	plan, err := planPointInTime(snapshots, txnlogs, target)
 	if err != nil {
+		return nil, err
+	}
+	plan.restoreTime, plan.lastTxn = restoreTime, lastTxn
+
+	return plan, nil
+}
+
+// planPointInTime builds a restore plan for the state at target.
+// ZooKeeper replays txnlogs starting with the newest log whose first ZXID is
+// at or below the snapshot ZXID, so that log is kept as well.
+func planPointInTime(snapshots, txnlogs []string, target zkfile.ZXID) (*restorePlan, error) {
+	plan := &restorePlan{pointInTime: true, targetZxid: target}
+
+	snapshot := ""
+	var snapshotZxid zkfile.ZXID
+	for _, path := range snapshots {
+		zxid, err := zkfile.ParseZxidFromFileName(path)
+		if err != nil {
+			return nil, err
+		}
+		if zxid > target {
+			plan.skipped = append(plan.skipped, path)
+			continue
+		}
+		if snapshot != "" {
+			plan.skipped = append(plan.skipped, snapshot)
+		}
+		snapshot, snapshotZxid = path, zxid
+	}
+	if snapshot == "" {
+		return nil, zkfile.NewUserError("no snapshot at or below target zxid").WithContext("target_zxid", target.String())
+	}
+	plan.snapshots = []string{snapshot}
+
+	first := -1
+	for i, path := range txnlogs {
+		zxid, err := zkfile.ParseZxidFromFileName(path)
+		if err != nil {
+			return nil, err
+		}
+		if zxid <= snapshotZxid {
+			first = i
+		}
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	for i, path := range txnlogs {
+		zxid, _ := zkfile.ParseZxidFromFileName(path)
+		if i < first || zxid > target {
+			plan.skipped = append(plan.skipped, path)
+			continue
+		}
+		plan.txnlogs = append(plan.txnlogs, path)
+	}
+	if len(plan.txnlogs) > 0 {
+		plan.truncateLog = plan.txnlogs[len(plan.txnlogs)-1]
+	}
+
+	return plan, nil
+}
+
+// restoreTxnLogs restores txnlog files
+func (e *RestoreEngine) restoreTxnLogs(plan *restorePlan) error {
+	if err := zkfile.EnsureDir(e.config.ZkLogDir); err != nil {
 		return err
 	}
 
-	for _, txnlog := range txnlogs {
+	for _, txnlog := range plan.txnlogs {
 		dst := filepath.Join(e.config.ZkLogDir, filepath.Base(txnlog))
-		if err = zkfile.CopyFile(txnlog, dst); err != nil {
+
+		if txnlog == plan.truncateLog {
+			count, err := zkfile.CopyTxnLogUntilZxid(txnlog, dst, plan.targetZxid)
+			if err != nil {
+				return err
+			}
+			// The log may end before the target, ZooKeeper would then stop short of it
+			restored, err := zkfile.GetTxnLogInfo(dst)
+			if err != nil {
+				return err
+			}
+			if restored.EndZxid != plan.targetZxid {
+				return zkfile.NewCorruptionError("restored txnlog does not reach target zxid").
+					WithContext("file", filepath.Base(txnlog)).
+					WithContext("target_zxid", plan.targetZxid.String()).
+					WithContext("last_zxid", restored.EndZxid.String())
+			}
+			e.logger.Info("Restored truncated txnlog", zap.String("file", filepath.Base(txnlog)),
+				zap.Int("transactions", count), zap.String("target_zxid", plan.targetZxid.String()))
+			continue
+		}
+
+		if err := zkfile.DecompressFile(txnlog, dst); err != nil {
 			return err
 		}
 		e.logger.Debug("Restored txnlog", zap.String("file", filepath.Base(txnlog)))
@@ -139,16 +390,10 @@ func (e *RestoreEngine) restoreTxnLogs() error {
 }
 
 // restoreSnapshots restores snapshot files
-func (e *RestoreEngine) restoreSnapshots() error {
-	snapshotDir := filepath.Join(e.config.BackupDir, "snapshots")
-	snapshots, err := zkfile.ListSnapshotFiles(snapshotDir)
-	if err != nil {
-		return err
-	}
-
-	for _, snapshot := range snapshots {
+func (e *RestoreEngine) restoreSnapshots(plan *restorePlan) error {
+	for _, snapshot := range plan.snapshots {
 		dst := filepath.Join(e.config.ZkDataDir, filepath.Base(snapshot))
-		if err = zkfile.CopyFile(snapshot, dst); err != nil {
+		if err := zkfile.CopySnapshot(snapshot, dst); err != nil {
 			return err
 		}
 		e.logger.Debug("Restored snapshot", zap.String("file", filepath.Base(snapshot)))
@@ -158,34 +403,69 @@ func (e *RestoreEngine) restoreSnapshots() error {
 }
 
 // showDryRun shows what would be restored
-func (e *RestoreEngine) showDryRun(info *metadata.BackupInfo) error {
+func (e *RestoreEngine) showDryRun(plan *restorePlan) error {
 	fmt.Printf("Would restore:\n")
-	fmt.Printf("- %d txnlog files\n", len(info.Files.TxnLogs))
-	fmt.Printf("- %d snapshot files\n", len(info.Files.Snapshots))
+	if plan.pointInTime {
+		fmt.Printf("- point in time: %s\n", plan.targetZxid)
+	}
+	fmt.Printf("- %d snapshot files\n", len(plan.snapshots))
+	for _, snapshot := range plan.snapshots {
+		fmt.Printf("    %s\n", filepath.Base(snapshot))
+	}
+	fmt.Printf("- %d txnlog files\n", len(plan.txnlogs))
+	for _, txnlog := range plan.txnlogs {
+		if txnlog == plan.truncateLog {
+			fmt.Printf("    %s (truncated at %s)\n", filepath.Base(txnlog), plan.targetZxid)
+			continue
+		}
+		fmt.Printf("    %s\n", filepath.Base(txnlog))
+	}
+	if len(plan.skipped) > 0 {
+		fmt.Printf("- %d files skipped\n", len(plan.skipped))
+		for _, path := range plan.skipped {
+			fmt.Printf("    %s\n", filepath.Base(path))
+		}
+	}
+	fmt.Printf("- existing data would be moved to: %s\n", e.safetyDir())
 
 	return nil
 }
 
 // printNextSteps prints next steps after restore
-func (e *RestoreEngine) printNextSteps(info *metadata.BackupInfo) {
+func (e *RestoreEngine) printNextSteps(info *metadata.BackupInfo, plan *restorePlan) {
+	expected := "0x" + info.BackupZxid.Hex
+	if plan.pointInTime {
+		expected = plan.targetZxid.String()
+	}
+
 	fmt.Printf("Next steps:\n")
 	fmt.Printf("1. Start ZooKeeper:\n")
 	fmt.Printf("   zkServer.sh start\n")
 	fmt.Printf("2. Verify ZXID:\n")
 	fmt.Printf("   echo mntr | nc localhost 2181 | grep zk_zxid\n")
-	fmt.Printf("   Expected: 0x%s\n", info.BackupZxid.Hex)
+	fmt.Printf("   Expected: %s\n", expected)
 	fmt.Printf("3. Verify data integrity:\n")
 	fmt.Printf("   zkCli.sh -server localhost:2181\n")
 	fmt.Printf("   ls /\n")
+	fmt.Printf("\nPrevious data (%d files) saved in: %s\n", e.safety.FileCount(), e.safety.Dir)
+	fmt.Printf("To undo this restore, stop ZooKeeper and run:\n")
+	fmt.Printf("   zkbackup rollback --safety-dir %s\n", e.safety.Dir)
 }
 
 // confirmRestore asks user for confirmation
-func (e *RestoreEngine) confirmRestore(info *metadata.BackupInfo) bool {
+func (e *RestoreEngine) confirmRestore(info *metadata.BackupInfo, plan *restorePlan) bool {
 	fmt.Printf("You are about to restore ZooKeeper data:\n")
 	fmt.Printf("  Backup ID: %s\n", info.BackupID)
 	fmt.Printf("  Backup ZXID: 0x%s\n", info.BackupZxid.Hex)
+	if !plan.restoreTime.IsZero() {
+		fmt.Printf("  Restore To Time: %s\n", plan.restoreTime.Format(time.RFC3339))
+	}
+	if plan.pointInTime {
+		fmt.Printf("  Restore To ZXID: %s\n", plan.targetZxid)
+	}
 	fmt.Printf("  Target Log Dir: %s\n", e.config.ZkLogDir)
 	fmt.Printf("  Target Data Dir: %s\n", e.config.ZkDataDir)
+	fmt.Printf("  Safety Dir: %s\n", e.safetyDir())
 	fmt.Printf("  Backup Time: %s\n", info.BackupTimestamp.Format("2006-01-02 15:04:05"))
 	fmt.Printf("This will overwrite existing data! Type 'yes' to continue: \n")
 
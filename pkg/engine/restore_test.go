@@ -1,7 +1,17 @@
 package engine
 
 import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/zookeeper-backup/pkg/metadata"
+	"github.com/zookeeper-backup/pkg/zkfile"
 )
 
 func TestNewRestoreEngine(t *testing.T) {
@@ -44,3 +54,464 @@ func TestRestoreEngine_Structure(t *testing.T) {
 		}
 	})
 }
+
+// createRestoreTestBackup creates a backup with snapshots at 0x100000002 and
+// 0x100000006 and txnlogs holding 0x100000001-0x100000004 and 0x100000005-0x100000008
+func createRestoreTestBackup(t *testing.T) string {
+	t.Helper()
+
+	backupDir := t.TempDir()
+	for _, dir := range []string{"metadata", "snapshots", "txnlogs"} {
+		os.MkdirAll(filepath.Join(backupDir, dir), 0755)
+	}
+
+	info := metadata.NewBackupInfo("backup-restore", zkfile.ZXID(0x100000008))
+	for _, zxid := range []zkfile.ZXID{0x100000002, 0x100000006} {
+		path := filepath.Join(backupDir, "snapshots", zkfile.FormatZxidFileName(zkfile.FileTypeSnapshot, zxid))
+		writeTestSnapshot(t, path, 16)
+		snapshotInfo, err := zkfile.GetSnapshotInfo(path)
+		if err != nil {
+			t.Fatalf("GetSnapshotInfo() error = %v", err)
+		}
+		info.AddSnapshot(snapshotInfo)
+	}
+
+	for _, zxids := range [][]zkfile.ZXID{
+		{0x100000001, 0x100000002, 0x100000003, 0x100000004},
+		{0x100000005, 0x100000006, 0x100000007, 0x100000008},
+	} {
+		path := filepath.Join(backupDir, "txnlogs", zkfile.FormatZxidFileName(zkfile.FileTypeTxnLog, zxids[0]))
+		writeTestTxnLog(t, path, zxids...)
+		txnlogInfo, err := zkfile.GetTxnLogInfo(path)
+		if err != nil {
+			t.Fatalf("GetTxnLogInfo() error = %v", err)
+		}
+		info.AddTxnLog(txnlogInfo)
+	}
+
+	if err := info.SaveToFile(metadata.InfoPath(backupDir)); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	return backupDir
+}
+
+// listDirNames returns the sorted file names in dir
+func listDirNames(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestRestoreEngine_PointInTime(t *testing.T) {
+	tests := []struct {
+		name          string
+		target        string
+		wantSnapshots []string
+		wantTxnLogs   []string
+		wantLastZxid  zkfile.ZXID
+		wantErr       bool
+	}{
+		{
+			name:          "full restore",
+			target:        "",
+			wantSnapshots: []string{"snapshot.100000002", "snapshot.100000006"},
+			wantTxnLogs:   []string{"log.100000001", "log.100000005"},
+			wantLastZxid:  0x100000008,
+		},
+		{
+			name:          "target in second txnlog before newer snapshot",
+			target:        "0x100000005",
+			wantSnapshots: []string{"snapshot.100000002"},
+			wantTxnLogs:   []string{"log.100000001", "log.100000005"},
+			wantLastZxid:  0x100000005,
+		},
+		{
+			name:          "target after newest snapshot",
+			target:        "0x100000007",
+			wantSnapshots: []string{"snapshot.100000006"},
+			wantTxnLogs:   []string{"log.100000005"},
+			wantLastZxid:  0x100000007,
+		},
+		{
+			name:          "target inside first txnlog",
+			target:        "100000003",
+			wantSnapshots: []string{"snapshot.100000002"},
+			wantTxnLogs:   []string{"log.100000001"},
+			wantLastZxid:  0x100000003,
+		},
+		{
+			name:    "target before first snapshot",
+			target:  "0x100000001",
+			wantErr: true,
+		},
+		{
+			name:    "target beyond backup",
+			target:  "0x100000009",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupDir := createRestoreTestBackup(t)
+			dataDir := filepath.Join(t.TempDir(), "data")
+			logDir := filepath.Join(t.TempDir(), "log")
+
+			engine := NewRestoreEngine(&RestoreConfig{
+				BackupDir:      backupDir,
+				ZkDataDir:      dataDir,
+				ZkLogDir:       logDir,
+				Force:          true,
+				TruncateToZxid: tt.target,
+			})
+
+			err := engine.Run()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := listDirNames(t, dataDir); fmt.Sprint(got) != fmt.Sprint(tt.wantSnapshots) {
+				t.Errorf("Restored snapshots = %v, want %v", got, tt.wantSnapshots)
+			}
+			got := listDirNames(t, logDir)
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantTxnLogs) {
+				t.Fatalf("Restored txnlogs = %v, want %v", got, tt.wantTxnLogs)
+			}
+
+			result, err := zkfile.ValidateTxnLog(filepath.Join(logDir, got[len(got)-1]))
+			if err != nil {
+				t.Fatalf("ValidateTxnLog() error = %v", err)
+			}
+			if !result.IsValid || result.LastValidZxid != tt.wantLastZxid {
+				t.Errorf("Last txnlog valid = %v, last zxid = %v, want valid with %v",
+					result.IsValid, result.LastValidZxid, tt.wantLastZxid)
+			}
+		})
+	}
+}
+
+func TestRestoreEngine_PointInTimeShortLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		damage  func(t *testing.T, path string)
+		wantErr string
+	}{
+		{
+			name: "corrupted record before the target",
+			damage: func(t *testing.T, path string) {
+				// Cut the record of 0x100000008, verify would flag it as repairable
+				info, _ := os.Stat(path)
+				os.Truncate(path, info.Size()-3)
+			},
+			wantErr: "failed to read transaction",
+		},
+		{
+			name: "log ends before the target",
+			damage: func(t *testing.T, path string) {
+				writeTestTxnLog(t, path, 0x100000005, 0x100000006, 0x100000007)
+			},
+			wantErr: "does not reach target zxid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupDir := createRestoreTestBackup(t)
+			tt.damage(t, filepath.Join(backupDir, "txnlogs", "log.100000005"))
+
+			logDir := filepath.Join(t.TempDir(), "log")
+			engine := NewRestoreEngine(&RestoreConfig{
+				BackupDir:      backupDir,
+				ZkDataDir:      filepath.Join(t.TempDir(), "data"),
+				ZkLogDir:       logDir,
+				Force:          true,
+				SkipVerify:     true,
+				TruncateToZxid: "0x100000008",
+			})
+
+			err := engine.Run()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), "rolled back") {
+				t.Fatalf("Run() error = %v, want %q and rolled back", err, tt.wantErr)
+			}
+			if got := listDirNames(t, logDir); len(got) != 0 {
+				t.Errorf("Log dir after rollback = %v, want empty", got)
+			}
+		})
+	}
+}
+
+func TestRestoreEngine_BuildPlanRestoreToTime(t *testing.T) {
+	// writeTestTxnLog uses the ZXID as the millisecond timestamp
+	tests := []struct {
+		name          string
+		restoreTime   time.Time
+		wantZxid      zkfile.ZXID
+		wantSnapshots []string
+		wantErr       bool
+	}{
+		{
+			name:          "exact transaction time",
+			restoreTime:   time.UnixMilli(0x100000005),
+			wantZxid:      0x100000005,
+			wantSnapshots: []string{"snapshot.100000002"},
+		},
+		{
+			name:          "between transactions",
+			restoreTime:   time.UnixMilli(0x100000007).Add(500 * time.Microsecond),
+			wantZxid:      0x100000007,
+			wantSnapshots: []string{"snapshot.100000006"},
+		},
+		{
+			name:        "before first transaction",
+			restoreTime: time.UnixMilli(0x100000001).Add(-time.Second),
+			wantErr:     true,
+		},
+		{
+			name:        "before first snapshot",
+			restoreTime: time.UnixMilli(0x100000001).Add(500 * time.Microsecond),
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backupDir := createRestoreTestBackup(t)
+			info, err := metadata.LoadBackupInfo(metadata.InfoPath(backupDir))
+			if err != nil {
+				t.Fatalf("LoadBackupInfo() error = %v", err)
+			}
+
+			engine := NewRestoreEngine(&RestoreConfig{
+				BackupDir:     backupDir,
+				ZkDataDir:     t.TempDir(),
+				ZkLogDir:      t.TempDir(),
+				RestoreToTime: tt.restoreTime.UTC().Format(time.RFC3339Nano),
+			})
+
+			plan, err := engine.buildPlan(info)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildPlan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !plan.pointInTime || plan.targetZxid != tt.wantZxid {
+				t.Errorf("targetZxid = %v, want %v", plan.targetZxid, tt.wantZxid)
+			}
+			if plan.lastTxn == nil || plan.lastTxn.Zxid != tt.wantZxid {
+				t.Errorf("lastTxn = %v, want zxid %v", plan.lastTxn, tt.wantZxid)
+			}
+			var snapshots []string
+			for _, path := range plan.snapshots {
+				snapshots = append(snapshots, filepath.Base(path))
+			}
+			if fmt.Sprint(snapshots) != fmt.Sprint(tt.wantSnapshots) {
+				t.Errorf("snapshots = %v, want %v", snapshots, tt.wantSnapshots)
+			}
+		})
+	}
+}
+
+func TestRestoreEngine_EnsureEmptyTargets(t *testing.T) {
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "data")
+	logDir := filepath.Join(root, "log")
+	writeTestFiles(t, dataDir, "myid", "zookeeper_server.pid")
+
+	engine := NewRestoreEngine(&RestoreConfig{ZkDataDir: dataDir, ZkLogDir: logDir})
+	if err := engine.ensureEmptyTargets(); err != nil {
+		t.Fatalf("ensureEmptyTargets() error = %v, want other files and missing dirs accepted", err)
+	}
+
+	// A txnlog newer than the target would be replayed past it
+	writeTestFiles(t, logDir, "log.900000001")
+	err := engine.ensureEmptyTargets()
+	if err == nil || !strings.Contains(err.Error(), "log.900000001") {
+		t.Errorf("ensureEmptyTargets() error = %v, want the leftover txnlog reported", err)
+	}
+}
+
+// startRuokServer answers imok to ruok on a local port, like a running server
+func startRuokServer(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			command := make([]byte, 4)
+			if _, err = io.ReadFull(conn, command); err == nil && string(command) == "ruok" {
+				conn.Write([]byte("imok"))
+			}
+			conn.Close()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestRestoreEngine_ServerRunning(t *testing.T) {
+	backupDir := createRestoreTestBackup(t)
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "data")
+	logDir := filepath.Join(root, "log")
+	safetyDir := filepath.Join(root, "safety")
+	writeTestFiles(t, dataDir, "snapshot.900000001", "currentEpoch")
+
+	engine := NewRestoreEngine(&RestoreConfig{
+		BackupDir: backupDir,
+		ZkDataDir: dataDir,
+		ZkLogDir:  logDir,
+		ZkHost:    startRuokServer(t),
+		SafetyDir: safetyDir,
+		Force:     true,
+	})
+	err := engine.Run()
+	if err == nil || !strings.Contains(err.Error(), "server is running") {
+		t.Fatalf("Run() error = %v, want server is running", err)
+	}
+
+	if got := listDirNames(t, dataDir); fmt.Sprint(got) != "[currentEpoch snapshot.900000001]" {
+		t.Errorf("Data dir = %v, want nothing moved", got)
+	}
+	if zkfile.FileExists(safetyDir) {
+		t.Error("Safety dir should not be created")
+	}
+}
+
+func TestRestoreEngine_SafetyCopy(t *testing.T) {
+	t.Run("existing data is moved aside", func(t *testing.T) {
+		backupDir := createRestoreTestBackup(t)
+		root := t.TempDir()
+		dataDir := filepath.Join(root, "data")
+		logDir := filepath.Join(root, "log")
+		safetyDir := filepath.Join(root, "safety")
+		writeTestFiles(t, dataDir, "snapshot.900000001", "currentEpoch")
+		writeTestFiles(t, logDir, "log.900000001")
+
+		engine := NewRestoreEngine(&RestoreConfig{
+			BackupDir: backupDir,
+			ZkDataDir: dataDir,
+			ZkLogDir:  logDir,
+			SafetyDir: safetyDir,
+			Force:     true,
+		})
+		if err := engine.Run(); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+
+		if got := listDirNames(t, dataDir); fmt.Sprint(got) != "[snapshot.100000002 snapshot.100000006]" {
+			t.Errorf("Restored data dir = %v", got)
+		}
+		if got := listDirNames(t, filepath.Join(safetyDir, "data")); fmt.Sprint(got) != "[currentEpoch snapshot.900000001]" {
+			t.Errorf("Safety data dir = %v", got)
+		}
+		if got := listDirNames(t, filepath.Join(safetyDir, "log")); fmt.Sprint(got) != "[log.900000001]" {
+			t.Errorf("Safety log dir = %v", got)
+		}
+	})
+
+	t.Run("failed restore rolls back", func(t *testing.T) {
+		backupDir := createRestoreTestBackup(t)
+		// Break the txnlog cut at the target ZXID
+		os.WriteFile(filepath.Join(backupDir, "txnlogs", "log.100000005"), []byte("garbage"), 0644)
+
+		root := t.TempDir()
+		dataDir := filepath.Join(root, "data")
+		logDir := filepath.Join(root, "log")
+		safetyDir := filepath.Join(root, "safety")
+		writeTestFiles(t, dataDir, "snapshot.900000001", "currentEpoch")
+		writeTestFiles(t, logDir, "log.900000001")
+
+		engine := NewRestoreEngine(&RestoreConfig{
+			BackupDir:      backupDir,
+			ZkDataDir:      dataDir,
+			ZkLogDir:       logDir,
+			SafetyDir:      safetyDir,
+			Force:          true,
+			SkipVerify:     true,
+			TruncateToZxid: "0x100000007",
+		})
+		err := engine.Run()
+		if err == nil || !strings.Contains(err.Error(), "rolled back") {
+			t.Fatalf("Run() error = %v, want rolled back error", err)
+		}
+
+		if got := listDirNames(t, dataDir); fmt.Sprint(got) != "[currentEpoch snapshot.900000001]" {
+			t.Errorf("Data dir after rollback = %v", got)
+		}
+		if got := listDirNames(t, logDir); fmt.Sprint(got) != "[log.900000001]" {
+			t.Errorf("Log dir after rollback = %v", got)
+		}
+		if zkfile.FileExists(safetyDir) {
+			t.Error("Safety dir should be removed after rollback")
+		}
+	})
+
+	t.Run("files written after the move are kept", func(t *testing.T) {
+		backupDir := createRestoreTestBackup(t)
+		root := t.TempDir()
+		dataDir := filepath.Join(root, "data")
+		logDir := filepath.Join(root, "log")
+		safetyDir := filepath.Join(root, "safety")
+		writeTestFiles(t, dataDir, "snapshot.900000001", "currentEpoch")
+
+		engine := NewRestoreEngine(&RestoreConfig{
+			BackupDir: backupDir,
+			ZkDataDir: dataDir,
+			ZkLogDir:  logDir,
+			SafetyDir: safetyDir,
+			Force:     true,
+		})
+		info, err := engine.loadBackupInfo()
+		if err != nil {
+			t.Fatalf("loadBackupInfo() error = %v", err)
+		}
+		plan, err := engine.buildPlan(info)
+		if err != nil {
+			t.Fatalf("buildPlan() error = %v", err)
+		}
+		if err = engine.backupExistingData(); err != nil {
+			t.Fatalf("backupExistingData() error = %v", err)
+		}
+
+		// A server still running writes a new txnlog
+		writeTestFiles(t, logDir, "log.900000002")
+
+		err = engine.restoreFiles(plan)
+		if err == nil || !strings.Contains(err.Error(), "log.900000002") || !strings.Contains(err.Error(), safetyDir) {
+			t.Fatalf("restoreFiles() error = %v, want the new txnlog and the safety dir reported", err)
+		}
+
+		if got := listDirNames(t, logDir); fmt.Sprint(got) != "[log.900000002]" {
+			t.Errorf("Log dir = %v, want the new txnlog kept", got)
+		}
+		if got := listDirNames(t, dataDir); len(got) != 0 {
+			t.Errorf("Data dir = %v, want nothing restored or moved back", got)
+		}
+		if got := listDirNames(t, filepath.Join(safetyDir, "data")); fmt.Sprint(got) != "[currentEpoch snapshot.900000001]" {
+			t.Errorf("Safety data dir = %v, want the safety copy kept", got)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// RollbackEngine rollback engine
+type RollbackEngine struct {
+	config *RollbackConfig
+	logger *zap.Logger
+}
+
+// NewRollbackEngine creates a new rollback engine
+func NewRollbackEngine(config *RollbackConfig) *RollbackEngine {
+	return &RollbackEngine{
+		config: config,
+		logger: utils.GetLogger(),
+	}
+}
+
+// Run puts the data saved by a restore back into the ZooKeeper directories
+func (e *RollbackEngine) Run() error {
+	// 1. Validate configuration
+	if err := e.config.Validate(); err != nil {
+		return zkfile.NewConfigurationError("invalid configuration").WithError(err)
+	}
+
+	// 2. Load safety copy
+	safety, err := e.loadSafetyCopy()
+	if err != nil {
+		return fmt.Errorf("failed to load safety copy: %w", err)
+	}
+
+	e.logger.Info("Starting rollback",
+		zap.String("safety_dir", safety.Dir),
+		zap.String("data_dir", safety.ZkDataDir),
+		zap.String("log_dir", safety.ZkLogDir))
+
+	e.printSafetyCopy(safety)
+
+	// 3. Dry-run: stop after showing the safety copy
+	if e.config.DryRun {
+		fmt.Println("🔍 Dry-run mode - no actual changes were made")
+		return nil
+	}
+
+	// 4. Confirm rollback (if not forced)
+	if !e.config.Force {
+		if !e.confirmRollback() {
+			return zkfile.NewCancelledError("rollback cancelled by user")
+		}
+	}
+
+	// 5. Put the saved files back
+	if err = safety.Rollback(); err != nil {
+		return fmt.Errorf("failed to roll back: %w", err)
+	}
+
+	e.logger.Info("Rollback completed successfully", zap.Int("files", safety.FileCount()))
+	fmt.Printf("✅ Restored %d files from %s\n", safety.FileCount(), safety.Dir)
+
+	return nil
+}
+
+// loadSafetyCopy loads the configured safety copy or the newest one
+func (e *RollbackEngine) loadSafetyCopy() (*SafetyCopy, error) {
+	if e.config.SafetyDir != "" {
+		return LoadSafetyCopy(e.config.SafetyDir)
+	}
+	return FindLatestSafetyCopy(e.config.ZkDataDir)
+}
+
+// printSafetyCopy prints what the rollback puts back
+func (e *RollbackEngine) printSafetyCopy(safety *SafetyCopy) {
+	fmt.Printf("Safety copy: %s\n", safety.Dir)
+	fmt.Printf("  Created: %s\n", safety.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("  Data Dir: %s (%d files)\n", safety.ZkDataDir, len(safety.DataFiles))
+	for _, name := range safety.DataFiles {
+		fmt.Printf("    %s\n", name)
+	}
+	fmt.Printf("  Log Dir: %s (%d files)\n", safety.ZkLogDir, len(safety.LogFiles))
+	for _, name := range safety.LogFiles {
+		fmt.Printf("    %s\n", name)
+	}
+	fmt.Println()
+}
+
+// confirmRollback asks user for confirmation
+func (e *RollbackEngine) confirmRollback() bool {
+	fmt.Printf("This will replace the current ZooKeeper data with the safety copy! Type 'yes' to continue: \n")
+
+	var response string
+	_, _ = fmt.Scanln(&response)
+
+	return response == "yes"
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRollbackEngine_Run(t *testing.T) {
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "data")
+	logDir := filepath.Join(root, "log")
+	writeTestFiles(t, dataDir, "snapshot.100000002", "acceptedEpoch")
+	writeTestFiles(t, logDir, "log.100000001")
+
+	safety, err := CreateSafetyCopy(dataDir, logDir, DefaultSafetyDir(dataDir, pruneTestNow))
+	if err != nil {
+		t.Fatalf("CreateSafetyCopy() error = %v", err)
+	}
+	writeTestFiles(t, dataDir, "snapshot.200000001")
+
+	t.Run("dry run", func(t *testing.T) {
+		engine := NewRollbackEngine(&RollbackConfig{ZkDataDir: dataDir, DryRun: true})
+		if err := engine.Run(); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if names := listDirNames(t, dataDir); len(names) != 1 || names[0] != "snapshot.200000001" {
+			t.Errorf("Data dir after dry run = %v", names)
+		}
+	})
+
+	t.Run("rollback newest safety copy", func(t *testing.T) {
+		engine := NewRollbackEngine(&RollbackConfig{ZkDataDir: dataDir, Force: true})
+		if err := engine.Run(); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if names := listDirNames(t, dataDir); len(names) != 2 || names[0] != "acceptedEpoch" || names[1] != "snapshot.100000002" {
+			t.Errorf("Data dir after rollback = %v", names)
+		}
+		if names := listDirNames(t, logDir); len(names) != 1 || names[0] != "log.100000001" {
+			t.Errorf("Log dir after rollback = %v", names)
+		}
+	})
+
+	t.Run("missing safety dir", func(t *testing.T) {
+		engine := NewRollbackEngine(&RollbackConfig{SafetyDir: safety.Dir, Force: true})
+		if err := engine.Run(); err == nil {
+			t.Error("Run() should fail after the safety copy was used")
+		}
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		engine := NewRollbackEngine(&RollbackConfig{Force: true})
+		if err := engine.Run(); err == nil {
+			t.Error("Run() should fail without safety-dir and zk-data-dir")
+		}
+	})
+}
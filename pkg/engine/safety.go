@@ -0,0 +1,217 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+const (
+	// safetyDirPrefix is the name prefix of safety directories created by restore
+	safetyDirPrefix = "zkbackup-safety-"
+
+	// safetyInfoFile is the manifest stored in every safety directory
+	safetyInfoFile = "safety_info.json"
+)
+
+// SafetyCopy records the ZooKeeper files moved aside before a restore.
+// Data dir files are kept under <dir>/data and log dir files under <dir>/log.
+type SafetyCopy struct {
+	Dir       string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	ZkDataDir string    `json:"zk_data_dir"`
+	ZkLogDir  string    `json:"zk_log_dir"`
+	DataFiles []string  `json:"data_files"`
+	LogFiles  []string  `json:"log_files"`
+}
+
+// DefaultSafetyDir returns the safety directory next to the ZooKeeper data directory
+func DefaultSafetyDir(zkDataDir string, now time.Time) string {
+	return filepath.Join(filepath.Dir(filepath.Clean(zkDataDir)), safetyDirPrefix+now.Format("20060102-150405"))
+}
+
+// CreateSafetyCopy moves the existing snapshot, txnlog and epoch files of the
+// ZooKeeper directories into safetyDir. On failure the moved files are put back.
+func CreateSafetyCopy(zkDataDir, zkLogDir, safetyDir string) (*SafetyCopy, error) {
+	if zkfile.FileExists(safetyDir) {
+		return nil, zkfile.NewUserError("safety directory already exists").WithContext("dir", safetyDir)
+	}
+
+	s := &SafetyCopy{
+		Dir:       safetyDir,
+		CreatedAt: time.Now(),
+		ZkDataDir: zkDataDir,
+		ZkLogDir:  zkLogDir,
+		DataFiles: make([]string, 0),
+		LogFiles:  make([]string, 0),
+	}
+
+	if err := s.moveAside(zkDataDir, "data", &s.DataFiles); err != nil {
+		return nil, s.abort(err)
+	}
+	// When both directories are the same, every file was already moved with the data dir
+	if filepath.Clean(zkLogDir) != filepath.Clean(zkDataDir) {
+		if err := s.moveAside(zkLogDir, "log", &s.LogFiles); err != nil {
+			return nil, s.abort(err)
+		}
+	}
+
+	if err := s.save(); err != nil {
+		return nil, s.abort(err)
+	}
+
+	return s, nil
+}
+
+// LoadSafetyCopy loads the safety copy stored in dir
+func LoadSafetyCopy(dir string) (*SafetyCopy, error) {
+	path := filepath.Join(dir, safetyInfoFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, zkfile.NewIOError("failed to read safety info").WithError(err).WithContext("path", path)
+	}
+
+	s := &SafetyCopy{}
+	if err = json.Unmarshal(data, s); err != nil {
+		return nil, zkfile.NewCorruptionError("failed to parse safety info").WithError(err).WithContext("path", path)
+	}
+	s.Dir = dir
+
+	return s, nil
+}
+
+// FindLatestSafetyCopy returns the newest safety copy next to the ZooKeeper data directory
+func FindLatestSafetyCopy(zkDataDir string) (*SafetyCopy, error) {
+	parent := filepath.Dir(filepath.Clean(zkDataDir))
+	dirs, err := filepath.Glob(filepath.Join(parent, safetyDirPrefix+"*"))
+	if err != nil {
+		return nil, zkfile.NewIOError("failed to search safety directories").WithError(err).WithContext("dir", parent)
+	}
+
+	// Names end with a sortable timestamp, newest last
+	sort.Strings(dirs)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if zkfile.FileExists(filepath.Join(dirs[i], safetyInfoFile)) {
+			return LoadSafetyCopy(dirs[i])
+		}
+	}
+
+	return nil, zkfile.NewUserError("no safety copy found").WithContext("dir", parent)
+}
+
+// FileCount returns the number of saved files
+func (s *SafetyCopy) FileCount() int {
+	return len(s.DataFiles) + len(s.LogFiles)
+}
+
+// Rollback removes the files written by the restore and moves the saved files
+// back into the ZooKeeper directories. The safety directory is removed afterwards.
+func (s *SafetyCopy) Rollback() error {
+	for _, dir := range []string{s.ZkDataDir, s.ZkLogDir} {
+		names, err := listZooKeeperFiles(dir)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if err = zkfile.RemoveFile(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.moveBack(); err != nil {
+		return err
+	}
+
+	return zkfile.RemoveDir(s.Dir)
+}
+
+// moveAside moves the ZooKeeper files of dir into the sub directory of the safety copy
+func (s *SafetyCopy) moveAside(dir, sub string, moved *[]string) error {
+	names, err := listZooKeeperFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err = zkfile.MoveFile(filepath.Join(dir, name), filepath.Join(s.Dir, sub, name)); err != nil {
+			return err
+		}
+		*moved = append(*moved, name)
+	}
+
+	return zkfile.EnsureDir(filepath.Join(s.Dir, sub))
+}
+
+// moveBack moves the saved files back to where they came from
+func (s *SafetyCopy) moveBack() error {
+	for _, name := range s.DataFiles {
+		if err := zkfile.MoveFile(filepath.Join(s.Dir, "data", name), filepath.Join(s.ZkDataDir, name)); err != nil {
+			return err
+		}
+	}
+	for _, name := range s.LogFiles {
+		if err := zkfile.MoveFile(filepath.Join(s.Dir, "log", name), filepath.Join(s.ZkLogDir, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// abort puts back the files moved so far after a failed safety copy
+func (s *SafetyCopy) abort(cause error) error {
+	if err := s.moveBack(); err != nil {
+		return zkfile.NewIOError("failed to put back existing data").WithError(err).
+			WithContext("cause", cause.Error()).WithContext("safety_dir", s.Dir)
+	}
+	_ = zkfile.RemoveDir(s.Dir)
+
+	return cause
+}
+
+// save writes the safety manifest
+func (s *SafetyCopy) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return zkfile.NewIOError("failed to marshal safety info").WithError(err)
+	}
+
+	path := filepath.Join(s.Dir, safetyInfoFile)
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		return zkfile.NewIOError("failed to write safety info").WithError(err).WithContext("path", path)
+	}
+
+	return nil
+}
+
+// listZooKeeperFiles lists the snapshot, txnlog and epoch files in dir.
+// A missing directory has no files.
+func listZooKeeperFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, zkfile.NewIOError("failed to read directory").WithError(err).WithContext("dir", dir)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if strings.HasPrefix(name, "snapshot.") || strings.HasPrefix(name, "log.") ||
+			name == "acceptedEpoch" || name == "currentEpoch" {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
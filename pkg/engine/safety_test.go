@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestFiles creates files with their own name as content
+func writeTestFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+
+	os.MkdirAll(dir, 0755)
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+}
+
+func TestCreateSafetyCopy(t *testing.T) {
+	t.Run("separate data and log dirs", func(t *testing.T) {
+		root := t.TempDir()
+		dataDir := filepath.Join(root, "data")
+		logDir := filepath.Join(root, "log")
+		safetyDir := filepath.Join(root, "safety")
+		writeTestFiles(t, dataDir, "snapshot.100000002", "acceptedEpoch", "currentEpoch", "myid")
+		writeTestFiles(t, logDir, "log.100000001")
+
+		safety, err := CreateSafetyCopy(dataDir, logDir, safetyDir)
+		if err != nil {
+			t.Fatalf("CreateSafetyCopy() error = %v", err)
+		}
+
+		if safety.FileCount() != 4 {
+			t.Errorf("FileCount() = %d, want 4", safety.FileCount())
+		}
+		if names := listDirNames(t, dataDir); len(names) != 1 || names[0] != "myid" {
+			t.Errorf("Data dir after safety copy = %v, want [myid]", names)
+		}
+		if names := listDirNames(t, logDir); len(names) != 0 {
+			t.Errorf("Log dir after safety copy = %v, want empty", names)
+		}
+
+		loaded, err := LoadSafetyCopy(safetyDir)
+		if err != nil {
+			t.Fatalf("LoadSafetyCopy() error = %v", err)
+		}
+		if len(loaded.DataFiles) != 3 || len(loaded.LogFiles) != 1 || loaded.ZkDataDir != dataDir {
+			t.Errorf("LoadSafetyCopy() = %+v", loaded)
+		}
+	})
+
+	t.Run("same data and log dir", func(t *testing.T) {
+		root := t.TempDir()
+		dataDir := filepath.Join(root, "data")
+		writeTestFiles(t, dataDir, "snapshot.100000002", "log.100000001")
+
+		safety, err := CreateSafetyCopy(dataDir, dataDir, filepath.Join(root, "safety"))
+		if err != nil {
+			t.Fatalf("CreateSafetyCopy() error = %v", err)
+		}
+		if len(safety.DataFiles) != 2 || len(safety.LogFiles) != 0 {
+			t.Errorf("DataFiles = %v, LogFiles = %v", safety.DataFiles, safety.LogFiles)
+		}
+	})
+
+	t.Run("existing safety dir", func(t *testing.T) {
+		root := t.TempDir()
+		_, err := CreateSafetyCopy(filepath.Join(root, "data"), filepath.Join(root, "log"), root)
+		if err == nil {
+			t.Error("CreateSafetyCopy() should fail when the safety dir exists")
+		}
+	})
+}
+
+func TestSafetyCopy_Rollback(t *testing.T) {
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "data")
+	logDir := filepath.Join(root, "log")
+	safetyDir := filepath.Join(root, "safety")
+	writeTestFiles(t, dataDir, "snapshot.100000002", "currentEpoch")
+	writeTestFiles(t, logDir, "log.100000001")
+
+	safety, err := CreateSafetyCopy(dataDir, logDir, safetyDir)
+	if err != nil {
+		t.Fatalf("CreateSafetyCopy() error = %v", err)
+	}
+
+	// Files written by a restore
+	writeTestFiles(t, dataDir, "snapshot.200000001")
+	writeTestFiles(t, logDir, "log.200000001")
+
+	if err = safety.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+
+	if names := listDirNames(t, dataDir); len(names) != 2 || names[0] != "currentEpoch" || names[1] != "snapshot.100000002" {
+		t.Errorf("Data dir after rollback = %v", names)
+	}
+	if names := listDirNames(t, logDir); len(names) != 1 || names[0] != "log.100000001" {
+		t.Errorf("Log dir after rollback = %v", names)
+	}
+	if _, err = os.Stat(safetyDir); !os.IsNotExist(err) {
+		t.Error("Safety dir should be removed after rollback")
+	}
+}
+
+func TestFindLatestSafetyCopy(t *testing.T) {
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "data")
+	logDir := filepath.Join(root, "log")
+
+	if _, err := FindLatestSafetyCopy(dataDir); err == nil {
+		t.Error("FindLatestSafetyCopy() should fail without safety copies")
+	}
+
+	older := DefaultSafetyDir(dataDir, time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC))
+	newer := DefaultSafetyDir(dataDir, time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC))
+	for _, dir := range []string{newer, older} {
+		if _, err := CreateSafetyCopy(dataDir, logDir, dir); err != nil {
+			t.Fatalf("CreateSafetyCopy() error = %v", err)
+		}
+	}
+
+	safety, err := FindLatestSafetyCopy(dataDir)
+	if err != nil {
+		t.Fatalf("FindLatestSafetyCopy() error = %v", err)
+	}
+	if safety.Dir != newer {
+		t.Errorf("FindLatestSafetyCopy() = %s, want %s", safety.Dir, newer)
+	}
+}
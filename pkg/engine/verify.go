@@ -0,0 +1,419 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zookeeper-backup/pkg/metadata"
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// File check status values reported by verify
+const (
+	FileStatusValid         = "valid"
+	FileStatusCorrupted     = "corrupted"
+	FileStatusRepaired      = "repaired"
+	FileStatusUnrecoverable = "unrecoverable"
+)
+
+// VerifyEngine verify engine
+type VerifyEngine struct {
+	config *VerifyConfig
+	logger *zap.Logger
+}
+
+// FileCheck is the verification result of a single backup file
+type FileCheck struct {
+	Name             string      `json:"name"`
+	Type             string      `json:"type"`
+	Status           string      `json:"status"`
+	Size             int64       `json:"size"`
+	TransactionCount int         `json:"transaction_count,omitempty"`
+	LastValidZxid    zkfile.ZXID `json:"last_valid_zxid,omitempty"`
+	Detail           string      `json:"detail,omitempty"`
+}
+
+// VerifyReport is the result of verifying a backup
+type VerifyReport struct {
+	BackupID   string                  `json:"backup_id"`
+	BackupDir  string                  `json:"backup_dir"`
+	BackupZxid metadata.ZxidInfo       `json:"backup_zxid"`
+	Files      []*FileCheck            `json:"files"`
+	Validation metadata.ValidationInfo `json:"validation"`
+	Status     string                  `json:"status"`
+}
+
+// NewVerifyEngine creates a new verify engine
+func NewVerifyEngine(config *VerifyConfig) *VerifyEngine {
+	return &VerifyEngine{
+		config: config,
+		logger: utils.GetLogger(),
+	}
+}
+
+// Run executes the verify operation and prints the report
+func (e *VerifyEngine) Run() error {
+	report, err := e.Verify()
+	if err != nil {
+		return err
+	}
+
+	if err = e.printReport(report); err != nil {
+		return err
+	}
+
+	if report.Validation.UnrecoverableFiles > 0 {
+		return zkfile.NewCorruptionError("backup has unrecoverable files").
+			WithContext("backup_dir", e.config.BackupDir).
+			WithContext("unrecoverable_files", report.Validation.UnrecoverableFiles)
+	}
+
+	return nil
+}
+
+// Verify checks every backup file, optionally repairs txnlogs, and stores the
+// validation results in backup_info.json
+func (e *VerifyEngine) Verify() (*VerifyReport, error) {
+	// 1. Validate configuration
+	if err := e.config.Validate(); err != nil {
+		return nil, zkfile.NewConfigurationError("invalid configuration").WithError(err)
+	}
+
+	e.logger.Info("Starting verify", zap.String("backup_dir", e.config.BackupDir), zap.Bool("fix", e.config.Fix))
+	startTime := time.Now()
+
+	// 2. Load backup metadata
+	infoPath := metadata.InfoPath(e.config.BackupDir)
+	backupInfo, err := metadata.LoadBackupInfo(infoPath)
+	if err != nil {
+		return nil, zkfile.NewValidationError("failed to load backup info").WithError(err).WithContext("path", infoPath)
+	}
+
+	// 3. Validate file formats
+	snapshotDir := filepath.Join(e.config.BackupDir, "snapshots")
+	txnlogDir := filepath.Join(e.config.BackupDir, "txnlogs")
+	results, err := zkfile.ValidateBackupFiles(snapshotDir, txnlogDir)
+	if err != nil {
+		return nil, err
+	}
+	if err = e.checkTimeout(startTime); err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{
+		BackupID:   backupInfo.BackupID,
+		BackupDir:  e.config.BackupDir,
+		BackupZxid: backupInfo.BackupZxid,
+		Files:      make([]*FileCheck, 0, len(results)),
+	}
+
+	// 4. Check snapshots against metadata
+	for _, s := range backupInfo.Files.Snapshots {
+		path := filepath.Join(snapshotDir, s.Name)
+		check := &FileCheck{Name: s.Name, Type: zkfile.FileTypeSnapshot.String(), Size: s.Size}
+		e.checkSnapshot(path, s, results[path], check)
+		delete(results, path)
+		report.Files = append(report.Files, check)
+	}
+	if err = e.checkTimeout(startTime); err != nil {
+		return nil, err
+	}
+
+	// 5. Check txnlogs against metadata
+	for _, t := range backupInfo.Files.TxnLogs {
+		path := filepath.Join(txnlogDir, t.Name)
+		check := &FileCheck{Name: t.Name, Type: zkfile.FileTypeTxnLog.String(), Size: t.Size}
+		e.checkTxnLog(path, t, results[path], check)
+		delete(results, path)
+		report.Files = append(report.Files, check)
+	}
+
+	// Check the znode archive of a logical backup
+	if l := backupInfo.Logical; l != nil {
+		check := &FileCheck{Name: l.File, Type: logicalFileType, Size: l.Size}
+		e.checkLogicalArchive(filepath.Join(e.config.BackupDir, l.File), l, check)
+		report.Files = append(report.Files, check)
+	}
+
+	if err = e.checkTimeout(startTime); err != nil {
+		return nil, err
+	}
+
+	// 6. Files on disk that are not listed in the metadata
+	untracked := make([]string, 0, len(results))
+	for path := range results {
+		untracked = append(untracked, path)
+	}
+	sort.Strings(untracked)
+	for _, path := range untracked {
+		check := &FileCheck{Name: filepath.Base(path), Type: zkfile.DetermineFileType(path).String()}
+		e.checkUntracked(path, results[path], check)
+		report.Files = append(report.Files, check)
+	}
+
+	// 7. Store validation results
+	report.Validation = summarizeFileChecks(report.Files)
+	backupInfo.UpdateValidation(report.Validation.ValidFiles, report.Validation.CorruptedFiles, report.Validation.RepairedFiles)
+	backupInfo.Validation.Enabled = true
+	backupInfo.Validation.UnrecoverableFiles = report.Validation.UnrecoverableFiles
+	report.Status = backupInfo.Status()
+
+	if err = backupInfo.SaveToFile(infoPath); err != nil {
+		return nil, zkfile.NewIOError("failed to save backup info").WithError(err).WithContext("path", infoPath)
+	}
+	manifestPath := filepath.Join(e.config.BackupDir, "metadata", "MANIFEST.txt")
+	if err = os.WriteFile(manifestPath, []byte(backupInfo.GenerateManifest()), 0644); err != nil {
+		e.logger.Warn("Failed to write manifest", zap.Error(err))
+	}
+
+	e.logger.Info("Verification completed", zap.Int("total", report.Validation.TotalFiles),
+		zap.Int("valid", report.Validation.ValidFiles), zap.Int("corrupted", report.Validation.CorruptedFiles),
+		zap.Int("repaired", report.Validation.RepairedFiles), zap.Int("unrecoverable", report.Validation.UnrecoverableFiles))
+
+	return report, nil
+}
+
+// checkTimeout fails once the configured verification timeout has passed
+func (e *VerifyEngine) checkTimeout(startTime time.Time) error {
+	if e.config.Timeout > 0 && time.Since(startTime) > e.config.Timeout {
+		return zkfile.NewValidationError("verification timed out").
+			WithContext("backup_dir", e.config.BackupDir).WithContext("timeout", e.config.Timeout.String())
+	}
+	return nil
+}
+
+// checkSnapshot checks a snapshot listed in the metadata
+func (e *VerifyEngine) checkSnapshot(path string, info *zkfile.SnapshotInfo, result *zkfile.ValidationResult, check *FileCheck) {
+	if result == nil {
+		check.Status = FileStatusUnrecoverable
+		check.Detail = "file listed in metadata is missing"
+		return
+	}
+	if !result.IsValid {
+		check.Status = FileStatusUnrecoverable
+		check.Detail = result.CorruptionType
+		return
+	}
+
+	if info.Checksum != "" {
+		checksum, err := zkfile.CalculateFileChecksum(path)
+		if err != nil {
+			check.Status = FileStatusUnrecoverable
+			check.Detail = err.Error()
+			return
+		}
+		if checksum != info.Checksum {
+			check.Status = FileStatusUnrecoverable
+			check.Detail = fmt.Sprintf("checksum mismatch: expected %s, got %s", info.Checksum, checksum)
+			return
+		}
+	}
+
+	check.Status = FileStatusValid
+}
+
+// checkLogicalArchive checks the znode archive against its checksum
+func (e *VerifyEngine) checkLogicalArchive(path string, info *metadata.LogicalInfo, check *FileCheck) {
+	if !zkfile.FileExists(path) {
+		check.Status = FileStatusUnrecoverable
+		check.Detail = "file listed in metadata is missing"
+		return
+	}
+
+	checksum, err := zkfile.CalculateFileChecksum(path)
+	if err != nil {
+		check.Status = FileStatusUnrecoverable
+		check.Detail = err.Error()
+		return
+	}
+	if checksum != info.Checksum {
+		check.Status = FileStatusUnrecoverable
+		check.Detail = fmt.Sprintf("checksum mismatch: expected %s, got %s", info.Checksum, checksum)
+		return
+	}
+
+	check.Status = FileStatusValid
+}
+
+// checkTxnLog checks a txnlog listed in the metadata, repairing it if requested
+func (e *VerifyEngine) checkTxnLog(path string, info *zkfile.TxnLogInfo, result *zkfile.ValidationResult, check *FileCheck) {
+	if result == nil {
+		check.Status = FileStatusUnrecoverable
+		check.Detail = "file listed in metadata is missing"
+		return
+	}
+
+	check.TransactionCount = result.ValidTransactionCount
+	check.LastValidZxid = result.LastValidZxid
+
+	if result.IsValid {
+		check.Status = FileStatusValid
+		return
+	}
+
+	check.Detail = result.CorruptionType
+	if result.ValidTransactionCount == 0 {
+		check.Status = FileStatusUnrecoverable
+		return
+	}
+	if !e.config.Fix {
+		check.Status = FileStatusCorrupted
+		return
+	}
+
+	if err := e.repairTxnLog(path); err != nil {
+		check.Status = FileStatusUnrecoverable
+		check.Detail = fmt.Sprintf("repair failed: %v", err)
+		return
+	}
+
+	check.Status = FileStatusRepaired
+	check.Detail = fmt.Sprintf("truncated to %s after %d transactions", result.LastValidZxid, result.ValidTransactionCount)
+
+	// Refresh the metadata of the repaired file
+	if repaired, err := zkfile.GetTxnLogInfo(path); err == nil {
+		info.EndZxid = repaired.EndZxid
+		info.Size = repaired.Size
+		info.CompressedSize = repaired.CompressedSize
+		info.TransactionCount = repaired.TransactionCount
+		info.Status = "truncated"
+		info.Note = "Repaired by verify"
+		check.Size = repaired.Size
+	}
+}
+
+// checkUntracked checks a file found on disk but not listed in the metadata
+func (e *VerifyEngine) checkUntracked(path string, result *zkfile.ValidationResult, check *FileCheck) {
+	if fileInfo, err := zkfile.GetFileInfo(path); err == nil {
+		check.Size = fileInfo.Size
+	}
+
+	check.Status = FileStatusValid
+	check.Detail = "not listed in metadata"
+	if !result.IsValid {
+		check.Status = FileStatusCorrupted
+		check.Detail = "not listed in metadata: " + result.CorruptionType
+	}
+}
+
+// repairTxnLog truncates a txnlog to its last valid transaction in place
+func (e *VerifyEngine) repairTxnLog(path string) error {
+	e.logger.Info("Attempting to repair", zap.String("file", path))
+
+	repairedPath := path + ".repaired"
+	if _, err := zkfile.RepairTxnLog(path, repairedPath); err != nil {
+		_ = os.Remove(repairedPath)
+		return err
+	}
+	if err := os.Rename(repairedPath, path); err != nil {
+		_ = os.Remove(repairedPath)
+		return zkfile.NewIOError("failed to replace repaired file").WithError(err).WithContext("path", path)
+	}
+
+	e.logger.Info("File repaired successfully", zap.String("file", path))
+	return nil
+}
+
+// summarizeFileChecks counts file check results into validation info
+func summarizeFileChecks(checks []*FileCheck) metadata.ValidationInfo {
+	validation := metadata.ValidationInfo{Enabled: true, TotalFiles: len(checks)}
+
+	for _, check := range checks {
+		switch check.Status {
+		case FileStatusValid:
+			validation.ValidFiles++
+		case FileStatusRepaired:
+			validation.CorruptedFiles++
+			validation.RepairedFiles++
+		case FileStatusCorrupted:
+			validation.CorruptedFiles++
+		case FileStatusUnrecoverable:
+			validation.CorruptedFiles++
+			validation.UnrecoverableFiles++
+		}
+	}
+
+	return validation
+}
+
+// printReport prints the verify report in the configured format
+func (e *VerifyEngine) printReport(report *VerifyReport) error {
+	switch e.config.OutputFormat {
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "text":
+		fmt.Print(report.GenerateTextReport())
+	default:
+		return fmt.Errorf("unsupported output format: %s", e.config.OutputFormat)
+	}
+
+	return nil
+}
+
+// GenerateTextReport generates a human-readable verify report
+func (r *VerifyReport) GenerateTextReport() string {
+	var sb strings.Builder
+
+	sb.WriteString("╔════════════════════════════════════════════════════════════╗\n")
+	sb.WriteString("║           Verify Report                                    ║\n")
+	sb.WriteString("╚════════════════════════════════════════════════════════════╝\n\n")
+
+	sb.WriteString(fmt.Sprintf("Backup ID: %s\n", r.BackupID))
+	sb.WriteString(fmt.Sprintf("Backup Dir: %s\n", r.BackupDir))
+	sb.WriteString(fmt.Sprintf("Backup ZXID: 0x%s\n\n", r.BackupZxid.Hex))
+
+	sb.WriteString("Files:\n")
+	for _, check := range r.Files {
+		line := fmt.Sprintf("  %s %s: %s (%s", fileStatusIcon(check.Status), check.Name, check.Status, utils.FormatBytes(check.Size))
+		if check.Type == zkfile.FileTypeTxnLog.String() {
+			line += fmt.Sprintf(", %d txns", check.TransactionCount)
+		}
+		line += ")\n"
+		sb.WriteString(line)
+		if check.Detail != "" {
+			sb.WriteString(fmt.Sprintf("      └─ %s\n", check.Detail))
+		}
+	}
+
+	sb.WriteString("\nSummary:\n")
+	sb.WriteString(fmt.Sprintf("  Total Files: %d\n", r.Validation.TotalFiles))
+	sb.WriteString(fmt.Sprintf("  Valid Files: %d\n", r.Validation.ValidFiles))
+	sb.WriteString(fmt.Sprintf("  Corrupted Files: %d\n", r.Validation.CorruptedFiles))
+	sb.WriteString(fmt.Sprintf("  Repaired Files: %d\n", r.Validation.RepairedFiles))
+	sb.WriteString(fmt.Sprintf("  Unrecoverable Files: %d\n\n", r.Validation.UnrecoverableFiles))
+
+	switch {
+	case r.Validation.UnrecoverableFiles > 0:
+		sb.WriteString("Overall: ❌ backup has unrecoverable files\n")
+	case r.Validation.CorruptedFiles > r.Validation.RepairedFiles:
+		sb.WriteString("Overall: ⚠️  backup has corrupted txnlogs, run with --fix to repair them\n")
+	default:
+		sb.WriteString("Overall: ✅ backup is usable\n")
+	}
+
+	return sb.String()
+}
+
+// fileStatusIcon returns the icon for a file check status
+func fileStatusIcon(status string) string {
+	switch status {
+	case FileStatusValid:
+		return "✅"
+	case FileStatusRepaired, FileStatusCorrupted:
+		return "⚠️ "
+	default:
+		return "❌"
+	}
+}
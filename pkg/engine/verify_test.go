@@ -0,0 +1,219 @@
+package engine
+
+import (
+	"encoding/binary"
+	"hash/adler32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zookeeper-backup/pkg/metadata"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// writeTestTxnLog writes a txnlog holding one transaction per ZXID
+func writeTestTxnLog(t *testing.T, path string, zxids ...zkfile.ZXID) {
+	t.Helper()
+
+	writer, err := zkfile.CreateTxnLog(path, &zkfile.TxnLogHeader{Magic: zkfile.MagicNumber, Version: zkfile.LogVersion})
+	if err != nil {
+		t.Fatalf("CreateTxnLog() error = %v", err)
+	}
+	defer writer.Close()
+
+	for _, zxid := range zxids {
+		data := make([]byte, 32)
+		binary.BigEndian.PutUint64(data[0:8], 1)
+		binary.BigEndian.PutUint32(data[8:12], 1)
+		binary.BigEndian.PutUint64(data[12:20], uint64(zxid))
+		binary.BigEndian.PutUint64(data[20:28], uint64(zxid))
+		binary.BigEndian.PutUint32(data[28:32], 1)
+
+		txn := &zkfile.Transaction{Checksum: int64(adler32.Checksum(data)), Length: int32(len(data)), Data: data}
+		if err = writer.WriteTransaction(txn); err != nil {
+			t.Fatalf("WriteTransaction() error = %v", err)
+		}
+	}
+}
+
+// writeTestSnapshot writes a snapshot holding the root and a node with dataSize
+// bytes of data, and returns its content
+func writeTestSnapshot(t *testing.T, path string, dataSize int) []byte {
+	t.Helper()
+
+	header := &zkfile.SnapshotHeader{Magic: zkfile.SnapshotMagic, Version: zkfile.SnapshotVersion}
+	writer, err := zkfile.CreateSnapshot(path, header, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+	for _, node := range []*zkfile.SnapshotNode{
+		{Path: "/", ACLRef: zkfile.OpenACLUnsafeRef},
+		{Path: "/data", Data: make([]byte, dataSize), ACLRef: zkfile.OpenACLUnsafeRef},
+	} {
+		if err = writer.WriteNode(node); err != nil {
+			t.Fatalf("WriteNode() error = %v", err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+// createVerifyTestBackup creates a backup directory with one snapshot and one txnlog
+func createVerifyTestBackup(t *testing.T) (string, *metadata.BackupInfo) {
+	t.Helper()
+
+	backupDir := t.TempDir()
+	for _, dir := range []string{"metadata", "snapshots", "txnlogs"} {
+		os.MkdirAll(filepath.Join(backupDir, dir), 0755)
+	}
+
+	snapshotPath := filepath.Join(backupDir, "snapshots", "snapshot.100000002")
+	writeTestSnapshot(t, snapshotPath, 16)
+	txnlogPath := filepath.Join(backupDir, "txnlogs", "log.100000001")
+	writeTestTxnLog(t, txnlogPath, 0x100000001, 0x100000002, 0x100000003)
+
+	info := metadata.NewBackupInfo("backup-verify", zkfile.ZXID(0x100000003))
+	snapshotInfo, err := zkfile.GetSnapshotInfo(snapshotPath)
+	if err != nil {
+		t.Fatalf("GetSnapshotInfo() error = %v", err)
+	}
+	info.AddSnapshot(snapshotInfo)
+	txnlogInfo, err := zkfile.GetTxnLogInfo(txnlogPath)
+	if err != nil {
+		t.Fatalf("GetTxnLogInfo() error = %v", err)
+	}
+	info.AddTxnLog(txnlogInfo)
+
+	if err = info.SaveToFile(metadata.InfoPath(backupDir)); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	return backupDir, info
+}
+
+func TestVerifyEngine_Verify(t *testing.T) {
+	t.Run("valid backup", func(t *testing.T) {
+		backupDir, _ := createVerifyTestBackup(t)
+
+		report, err := NewVerifyEngine(&VerifyConfig{BackupDir: backupDir}).Verify()
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+		if report.Status != metadata.BackupStatusValid {
+			t.Errorf("Status = %v, want %v", report.Status, metadata.BackupStatusValid)
+		}
+		if report.Validation.ValidFiles != 2 {
+			t.Errorf("ValidFiles = %d, want 2", report.Validation.ValidFiles)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		backupDir, _ := createVerifyTestBackup(t)
+
+		_, err := NewVerifyEngine(&VerifyConfig{BackupDir: backupDir, Timeout: time.Nanosecond}).Verify()
+		if err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Errorf("Verify() error = %v, want timeout", err)
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		backupDir, _ := createVerifyTestBackup(t)
+		os.WriteFile(filepath.Join(backupDir, "snapshots", "snapshot.100000002"), []byte("tampered"), 0644)
+
+		report, err := NewVerifyEngine(&VerifyConfig{BackupDir: backupDir}).Verify()
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+		if report.Validation.UnrecoverableFiles != 1 {
+			t.Errorf("UnrecoverableFiles = %d, want 1", report.Validation.UnrecoverableFiles)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		backupDir, _ := createVerifyTestBackup(t)
+		os.Remove(filepath.Join(backupDir, "txnlogs", "log.100000001"))
+
+		engine := NewVerifyEngine(&VerifyConfig{BackupDir: backupDir, OutputFormat: "json"})
+		if err := engine.Run(); err == nil {
+			t.Error("Run() should return error for missing file")
+		}
+	})
+
+	t.Run("corrupted txnlog without fix", func(t *testing.T) {
+		backupDir, _ := createVerifyTestBackup(t)
+		appendGarbage(t, filepath.Join(backupDir, "txnlogs", "log.100000001"))
+
+		report, err := NewVerifyEngine(&VerifyConfig{BackupDir: backupDir}).Verify()
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+		if report.Status != metadata.BackupStatusCorrupted {
+			t.Errorf("Status = %v, want %v", report.Status, metadata.BackupStatusCorrupted)
+		}
+		if report.Validation.UnrecoverableFiles != 0 {
+			t.Errorf("UnrecoverableFiles = %d, want 0", report.Validation.UnrecoverableFiles)
+		}
+	})
+
+	t.Run("corrupted txnlog with fix", func(t *testing.T) {
+		backupDir, _ := createVerifyTestBackup(t)
+		txnlogPath := filepath.Join(backupDir, "txnlogs", "log.100000001")
+		appendGarbage(t, txnlogPath)
+
+		engine := NewVerifyEngine(&VerifyConfig{BackupDir: backupDir, Fix: true})
+		if err := engine.Run(); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+
+		result, err := zkfile.ValidateTxnLog(txnlogPath)
+		if err != nil {
+			t.Fatalf("ValidateTxnLog() error = %v", err)
+		}
+		if !result.IsValid || result.ValidTransactionCount != 3 {
+			t.Errorf("Repaired txnlog valid = %v, count = %d, want valid with 3", result.IsValid, result.ValidTransactionCount)
+		}
+
+		// Stored validation info is updated
+		stored, err := metadata.LoadBackupInfo(metadata.InfoPath(backupDir))
+		if err != nil {
+			t.Fatalf("LoadBackupInfo() error = %v", err)
+		}
+		if stored.Validation.RepairedFiles != 1 {
+			t.Errorf("Stored RepairedFiles = %d, want 1", stored.Validation.RepairedFiles)
+		}
+		if stored.Status() != metadata.BackupStatusPartial {
+			t.Errorf("Stored status = %v, want %v", stored.Status(), metadata.BackupStatusPartial)
+		}
+		if stored.Files.TxnLogs[0].Status != "truncated" {
+			t.Errorf("Stored txnlog status = %v, want truncated", stored.Files.TxnLogs[0].Status)
+		}
+	})
+
+	t.Run("missing metadata", func(t *testing.T) {
+		_, err := NewVerifyEngine(&VerifyConfig{BackupDir: t.TempDir()}).Verify()
+		if err == nil {
+			t.Error("Verify() should return error when metadata is missing")
+		}
+	})
+}
+
+// appendGarbage appends bytes that cannot be parsed as a transaction
+func appendGarbage(t *testing.T, path string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer f.Close()
+	f.Write([]byte("garbage data"))
+}
@@ -0,0 +1,142 @@
+package jute
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// InputArchive reads Jute encoded values from a stream, like BinaryInputArchive.
+// Reads are unbuffered; wrap files in a bufio.Reader.
+//
+// A read that hits the end of the input before its first byte returns io.EOF,
+// so callers can tell the end of a stream of records from a truncated one.
+// Every other failure is an *Error with the offset of the value.
+type InputArchive struct {
+	r         io.Reader
+	offset    int64
+	maxBuffer int
+	scratch   [8]byte
+}
+
+// NewInputArchive creates an InputArchive limited to MaxBuffer
+func NewInputArchive(r io.Reader) *InputArchive {
+	return &InputArchive{r: r, maxBuffer: MaxBuffer}
+}
+
+// SetMaxBuffer sets the jute.maxbuffer lengths are checked against
+func (a *InputArchive) SetMaxBuffer(n int) {
+	a.maxBuffer = n
+}
+
+// Offset returns the number of bytes read
+func (a *InputArchive) Offset() int64 {
+	return a.offset
+}
+
+// read fills p, returning io.EOF only when nothing was read
+func (a *InputArchive) read(p []byte) error {
+	start := a.offset
+	n, err := io.ReadFull(a.r, p)
+	a.offset += int64(n)
+	if err == io.EOF {
+		return io.EOF
+	}
+	if err == io.ErrUnexpectedEOF {
+		return &Error{Offset: start, Err: fmt.Errorf("need %d bytes, %w", len(p), io.ErrUnexpectedEOF)}
+	}
+	if err != nil {
+		return &Error{Offset: start, Err: err}
+	}
+	return nil
+}
+
+// ReadByte reads a byte
+func (a *InputArchive) ReadByte() (byte, error) {
+	if err := a.read(a.scratch[:1]); err != nil {
+		return 0, err
+	}
+	return a.scratch[0], nil
+}
+
+// ReadBool reads a bool, any non-zero byte is true
+func (a *InputArchive) ReadBool() (bool, error) {
+	b, err := a.ReadByte()
+	return b != 0, err
+}
+
+// ReadInt reads a 32-bit int
+func (a *InputArchive) ReadInt() (int32, error) {
+	if err := a.read(a.scratch[:4]); err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(a.scratch[:4])), nil
+}
+
+// ReadLong reads a 64-bit long
+func (a *InputArchive) ReadLong() (int64, error) {
+	if err := a.read(a.scratch[:8]); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(a.scratch[:8])), nil
+}
+
+// ReadBuffer reads a length-prefixed buffer, nil for a null buffer
+func (a *InputArchive) ReadBuffer() ([]byte, error) {
+	n, err := a.readLength("buffer")
+	if err != nil || n < 0 {
+		return nil, err
+	}
+
+	buf := make([]byte, n)
+	if err = a.read(buf); err == io.EOF {
+		err = &Error{Offset: a.offset, Err: fmt.Errorf("need %d bytes, %w", n, io.ErrUnexpectedEOF)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// ReadString reads a length-prefixed UTF-8 string, empty for a null string
+func (a *InputArchive) ReadString() (string, error) {
+	buf, err := a.ReadBuffer()
+	return string(buf), err
+}
+
+// StartVector reads the element count of a vector, -1 for a null vector.
+// The caller reads the elements.
+func (a *InputArchive) StartVector() (int, error) {
+	return a.readLength("vector")
+}
+
+// StartMap reads the entry count of a map, -1 for a null map.
+// The caller reads the keys and values.
+func (a *InputArchive) StartMap() (int, error) {
+	return a.readLength("map")
+}
+
+// ReadRecord reads a record. It returns io.EOF only when the input ends
+// before the record starts.
+func (a *InputArchive) ReadRecord(r Record) error {
+	start := a.offset
+	err := r.Deserialize(a)
+	if errors.Is(err, io.EOF) && a.offset != start {
+		return &Error{Offset: a.offset, Err: fmt.Errorf("record %w", io.ErrUnexpectedEOF)}
+	}
+	return err
+}
+
+// readLength reads a length or count and checks it against the max buffer
+func (a *InputArchive) readLength(kind string) (int, error) {
+	start := a.offset
+	n, err := a.ReadInt()
+	if err != nil {
+		return 0, err
+	}
+	if n < -1 || int64(n) > int64(a.maxBuffer)+ExtraMaxBuffer {
+		return 0, &Error{Offset: start, Err: fmt.Errorf("%w %d of %s, jute.maxbuffer is %d", ErrInvalidLength, n, kind, a.maxBuffer)}
+	}
+	return int(n), nil
+}
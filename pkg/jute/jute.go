@@ -0,0 +1,71 @@
+// Package jute implements the binary Jute serialization used by ZooKeeper for
+// its wire protocol, transaction logs and snapshots.
+//
+// Values are big endian. Buffers and strings are prefixed with an int length,
+// vectors and maps with an int element count; -1 encodes null.
+package jute
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+const (
+	// DefaultMaxBuffer is the default jute.maxbuffer of ZooKeeper (1MB - 1)
+	DefaultMaxBuffer = 0xfffff
+
+	// ExtraMaxBuffer is the slack ZooKeeper allows on top of jute.maxbuffer
+	// (zookeeper.jute.maxbuffer.extrasize)
+	ExtraMaxBuffer = 1024
+)
+
+// MaxBuffer is the jute.maxbuffer new archives start with. Servers running with
+// a larger -Djute.maxbuffer write buffers this package rejects unless it is raised.
+var MaxBuffer = DefaultMaxBuffer
+
+// ErrInvalidLength is returned for negative lengths and lengths above the max buffer
+var ErrInvalidLength = errors.New("invalid length")
+
+// Record is a type with a Jute encoding
+type Record interface {
+	Serialize(out *OutputArchive) error
+	Deserialize(in *InputArchive) error
+}
+
+// Error is a decoding failure at an offset of the input
+type Error struct {
+	Offset int64 // Offset where the failing value starts
+	Err    error
+}
+
+// Error returns the error message
+func (e *Error) Error() string {
+	return fmt.Sprintf("jute: %v at offset %d", e.Err, e.Offset)
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Marshal encodes a record
+func Marshal(r Record) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewOutputArchive(&buf).WriteRecord(r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes a record that takes up all of data
+func Unmarshal(data []byte, r Record) error {
+	in := NewInputArchive(bytes.NewReader(data))
+	if err := in.ReadRecord(r); err != nil {
+		return err
+	}
+	if in.Offset() != int64(len(data)) {
+		return &Error{Offset: in.Offset(), Err: fmt.Errorf("%d unexpected bytes after record", int64(len(data))-in.Offset())}
+	}
+	return nil
+}
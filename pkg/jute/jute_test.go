@@ -0,0 +1,304 @@
+package jute
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testRecord uses every type of the archives
+type testRecord struct {
+	B      byte
+	Flag   bool
+	I      int32
+	L      int64
+	Buf    []byte
+	S      string
+	Paths  []string
+	Counts map[string]int64
+}
+
+func (r *testRecord) Serialize(out *OutputArchive) error {
+	if err := out.WriteByte(r.B); err != nil {
+		return err
+	}
+	if err := out.WriteBool(r.Flag); err != nil {
+		return err
+	}
+	if err := out.WriteInt(r.I); err != nil {
+		return err
+	}
+	if err := out.WriteLong(r.L); err != nil {
+		return err
+	}
+	if err := out.WriteBuffer(r.Buf); err != nil {
+		return err
+	}
+	if err := out.WriteString(r.S); err != nil {
+		return err
+	}
+
+	if r.Paths == nil {
+		if err := out.StartVector(-1); err != nil {
+			return err
+		}
+	} else {
+		if err := out.StartVector(len(r.Paths)); err != nil {
+			return err
+		}
+		for _, path := range r.Paths {
+			if err := out.WriteString(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Sorted keys keep the encoding stable
+	keys := make([]string, 0, len(r.Counts))
+	for key := range r.Counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if err := out.StartMap(len(keys)); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := out.WriteString(key); err != nil {
+			return err
+		}
+		if err := out.WriteLong(r.Counts[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *testRecord) Deserialize(in *InputArchive) error {
+	var err error
+	if r.B, err = in.ReadByte(); err != nil {
+		return err
+	}
+	if r.Flag, err = in.ReadBool(); err != nil {
+		return err
+	}
+	if r.I, err = in.ReadInt(); err != nil {
+		return err
+	}
+	if r.L, err = in.ReadLong(); err != nil {
+		return err
+	}
+	if r.Buf, err = in.ReadBuffer(); err != nil {
+		return err
+	}
+	if r.S, err = in.ReadString(); err != nil {
+		return err
+	}
+
+	count, err := in.StartVector()
+	if err != nil {
+		return err
+	}
+	r.Paths = nil
+	if count >= 0 {
+		r.Paths = make([]string, count)
+		for i := range r.Paths {
+			if r.Paths[i], err = in.ReadString(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if count, err = in.StartMap(); err != nil {
+		return err
+	}
+	r.Counts = make(map[string]int64, count)
+	for i := 0; i < count; i++ {
+		key, err := in.ReadString()
+		if err != nil {
+			return err
+		}
+		if r.Counts[key], err = in.ReadLong(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		record *testRecord
+	}{
+		{
+			name: "values",
+			record: &testRecord{B: 0x42, Flag: true, I: -7, L: math.MaxInt64, Buf: []byte{0, 1, 2},
+				S: "/zookeeper/quota", Paths: []string{"/a", "/b/c"}, Counts: map[string]int64{"x": 1, "y": -1}},
+		},
+		{
+			name:   "zero values",
+			record: &testRecord{Buf: []byte{}, Paths: []string{}, Counts: map[string]int64{}},
+		},
+		{
+			name:   "null buffer and vector",
+			record: &testRecord{I: math.MinInt32, L: math.MinInt64, S: "ünïcode", Counts: map[string]int64{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.record)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			got := &testRecord{}
+			if err := Unmarshal(data, got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.record) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.record)
+			}
+		})
+	}
+}
+
+func TestOutputArchive_Encoding(t *testing.T) {
+	var buf bytes.Buffer
+	out := NewOutputArchive(&buf)
+	out.WriteInt(0x01020304)
+	out.WriteLong(-2)
+	out.WriteBool(true)
+	out.WriteBuffer(nil)
+	out.WriteString("ab")
+
+	want := []byte{
+		1, 2, 3, 4,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe,
+		1,
+		0xff, 0xff, 0xff, 0xff,
+		0, 0, 0, 2, 'a', 'b',
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("encoding = %v, want %v", buf.Bytes(), want)
+	}
+	if out.Offset() != int64(len(want)) {
+		t.Errorf("Offset() = %d, want %d", out.Offset(), len(want))
+	}
+}
+
+func TestInputArchive_Malformed(t *testing.T) {
+	valid, err := Marshal(&testRecord{S: "/path", Paths: []string{"/a"}, Counts: map[string]int64{"k": 1}})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	t.Run("every truncation", func(t *testing.T) {
+		for n := 1; n < len(valid); n++ {
+			err := Unmarshal(valid[:n], &testRecord{})
+			var juteErr *Error
+			if !errors.As(err, &juteErr) || !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Fatalf("Unmarshal(%d of %d bytes) error = %v, want unexpected EOF", n, len(valid), err)
+			}
+			if juteErr.Offset > int64(n) {
+				t.Errorf("Unmarshal(%d bytes) offset = %d, beyond the input", n, juteErr.Offset)
+			}
+		}
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		in := NewInputArchive(bytes.NewReader(nil))
+		if err := in.ReadRecord(&testRecord{}); err != io.EOF {
+			t.Errorf("ReadRecord() error = %v, want io.EOF", err)
+		}
+	})
+
+	t.Run("trailing bytes", func(t *testing.T) {
+		err := Unmarshal(append(valid, 0), &testRecord{})
+		if err == nil || !strings.Contains(err.Error(), "unexpected bytes") {
+			t.Errorf("Unmarshal() error = %v, want trailing bytes", err)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		data   []byte
+		read   func(*InputArchive) error
+		offset int64
+	}{
+		{
+			name:   "negative buffer length",
+			data:   []byte{0xff, 0xff, 0xff, 0xfe},
+			read:   func(in *InputArchive) error { _, err := in.ReadBuffer(); return err },
+			offset: 0,
+		},
+		{
+			name:   "buffer above max buffer",
+			data:   []byte{0, 0, 0, 1, 0x7f, 0xff, 0xff, 0xff},
+			read:   func(in *InputArchive) error { in.ReadInt(); _, err := in.ReadString(); return err },
+			offset: 4,
+		},
+		{
+			name:   "vector count above max buffer",
+			data:   []byte{0x10, 0, 0, 0},
+			read:   func(in *InputArchive) error { _, err := in.StartVector(); return err },
+			offset: 0,
+		},
+		{
+			name:   "negative map count",
+			data:   []byte{0x80, 0, 0, 0},
+			read:   func(in *InputArchive) error { _, err := in.StartMap(); return err },
+			offset: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.read(NewInputArchive(bytes.NewReader(tt.data)))
+			var juteErr *Error
+			if !errors.As(err, &juteErr) || !errors.Is(err, ErrInvalidLength) {
+				t.Fatalf("error = %v, want invalid length", err)
+			}
+			if juteErr.Offset != tt.offset {
+				t.Errorf("Offset = %d, want %d", juteErr.Offset, tt.offset)
+			}
+		})
+	}
+}
+
+func TestInputArchive_MaxBuffer(t *testing.T) {
+	data, err := Marshal(&testRecord{Buf: make([]byte, 4096), Counts: map[string]int64{}})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	t.Run("within extra size", func(t *testing.T) {
+		in := NewInputArchive(bytes.NewReader(data))
+		in.SetMaxBuffer(4096 - ExtraMaxBuffer)
+		if err := in.ReadRecord(&testRecord{}); err != nil {
+			t.Errorf("ReadRecord() error = %v", err)
+		}
+	})
+
+	t.Run("above", func(t *testing.T) {
+		in := NewInputArchive(bytes.NewReader(data))
+		in.SetMaxBuffer(1024)
+		if err := in.ReadRecord(&testRecord{}); !errors.Is(err, ErrInvalidLength) {
+			t.Errorf("ReadRecord() error = %v, want invalid length", err)
+		}
+	})
+
+	t.Run("package default", func(t *testing.T) {
+		old := MaxBuffer
+		defer func() { MaxBuffer = old }()
+
+		MaxBuffer = 1024
+		if err := Unmarshal(data, &testRecord{}); !errors.Is(err, ErrInvalidLength) {
+			t.Errorf("Unmarshal() error = %v, want invalid length", err)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package jute
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// OutputArchive writes Jute encoded values to a stream, like BinaryOutputArchive
+type OutputArchive struct {
+	w       io.Writer
+	offset  int64
+	scratch [8]byte
+}
+
+// NewOutputArchive creates an OutputArchive
+func NewOutputArchive(w io.Writer) *OutputArchive {
+	return &OutputArchive{w: w}
+}
+
+// Offset returns the number of bytes written
+func (a *OutputArchive) Offset() int64 {
+	return a.offset
+}
+
+// write writes p
+func (a *OutputArchive) write(p []byte) error {
+	n, err := a.w.Write(p)
+	a.offset += int64(n)
+	return err
+}
+
+// WriteByte writes a byte
+func (a *OutputArchive) WriteByte(b byte) error {
+	a.scratch[0] = b
+	return a.write(a.scratch[:1])
+}
+
+// WriteBool writes a bool as one byte
+func (a *OutputArchive) WriteBool(v bool) error {
+	if v {
+		return a.WriteByte(1)
+	}
+	return a.WriteByte(0)
+}
+
+// WriteInt writes a 32-bit int
+func (a *OutputArchive) WriteInt(v int32) error {
+	binary.BigEndian.PutUint32(a.scratch[:4], uint32(v))
+	return a.write(a.scratch[:4])
+}
+
+// WriteLong writes a 64-bit long
+func (a *OutputArchive) WriteLong(v int64) error {
+	binary.BigEndian.PutUint64(a.scratch[:8], uint64(v))
+	return a.write(a.scratch[:8])
+}
+
+// WriteBuffer writes a length-prefixed buffer, a nil buffer is written as null
+func (a *OutputArchive) WriteBuffer(v []byte) error {
+	if v == nil {
+		return a.WriteInt(-1)
+	}
+	if err := a.WriteInt(int32(len(v))); err != nil {
+		return err
+	}
+	return a.write(v)
+}
+
+// WriteString writes a length-prefixed string
+func (a *OutputArchive) WriteString(v string) error {
+	if err := a.WriteInt(int32(len(v))); err != nil {
+		return err
+	}
+	return a.write([]byte(v))
+}
+
+// StartVector writes the element count of a vector, -1 for a null vector.
+// The caller writes the elements.
+func (a *OutputArchive) StartVector(n int) error {
+	return a.WriteInt(int32(n))
+}
+
+// StartMap writes the entry count of a map, -1 for a null map.
+// The caller writes the keys and values.
+func (a *OutputArchive) StartMap(n int) error {
+	return a.WriteInt(int32(n))
+}
+
+// WriteRecord writes a record
+func (a *OutputArchive) WriteRecord(r Record) error {
+	return r.Serialize(a)
+}
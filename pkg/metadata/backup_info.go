@@ -14,8 +14,11 @@ type BackupInfo struct {
 	BackupID        string         `json:"backup_id"`
 	BackupTimestamp time.Time      `json:"backup_timestamp"`
 	BackupZxid      ZxidInfo       `json:"backup_zxid"`
+	Mode            string         `json:"mode,omitempty"`    // physical, stream or logical, empty for older backups
+	Cluster         string         `json:"cluster,omitempty"` // cluster profile the backup was taken with
 	ZooKeeper       ZooKeeperInfo  `json:"zookeeper"`
 	Files           FilesInfo      `json:"files"`
+	Logical         *LogicalInfo   `json:"logical,omitempty"` // znode archive of a logical backup
 	Validation      ValidationInfo `json:"validation"`
 	Statistics      StatisticsInfo `json:"statistics"`
 }
@@ -28,10 +31,11 @@ type ZxidInfo struct {
 
 // ZooKeeperInfo ZooKeeper server information
 type ZooKeeperInfo struct {
-	Version string `json:"version"`
-	Host    string `json:"host"`
-	DataDir string `json:"data_dir"`
-	LogDir  string `json:"log_dir"`
+	Version  string `json:"version"`
+	Host     string `json:"host"`
+	DataDir  string `json:"data_dir"`
+	LogDir   string `json:"log_dir"`
+	AdminURL string `json:"admin_url,omitempty"` // AdminServer a streamed snapshot was pulled from
 }
 
 // FilesInfo backup files information
@@ -40,6 +44,26 @@ type FilesInfo struct {
 	Snapshots []*zkfile.SnapshotInfo `json:"snapshots"`
 }
 
+// LogicalInfo describes the znode archive of a logical backup. The znodes were
+// read between StartZxid and EndZxid: every znode is at least as recent as
+// StartZxid, and none holds a change after EndZxid.
+type LogicalInfo struct {
+	File           string   `json:"file"`  // archive path relative to the backup directory
+	Paths          []string `json:"paths"` // subtrees walked
+	StartZxid      ZxidInfo `json:"start_zxid"`
+	EndZxid        ZxidInfo `json:"end_zxid"`
+	NodeCount      int      `json:"node_count"`
+	Size           int64    `json:"size"`
+	Compression    string   `json:"compression,omitempty"`
+	CompressedSize int64    `json:"compressed_size,omitempty"`
+	Checksum       string   `json:"checksum"`
+}
+
+// NewZxidInfo returns the hex and decimal forms of a ZXID
+func NewZxidInfo(zxid zkfile.ZXID) ZxidInfo {
+	return ZxidInfo{Hex: zxid.Hex(), Decimal: uint64(zxid)}
+}
+
 // ValidationInfo validation results
 type ValidationInfo struct {
 	Enabled            bool `json:"enabled"`
@@ -78,10 +102,7 @@ func NewBackupInfo(backupID string, zxid zkfile.ZXID) *BackupInfo {
 		Version:         "1.0",
 		BackupID:        backupID,
 		BackupTimestamp: time.Now(),
-		BackupZxid: ZxidInfo{
-			Hex:     zxid.Hex(),
-			Decimal: uint64(zxid),
-		},
+		BackupZxid:      NewZxidInfo(zxid),
 		Files: FilesInfo{
 			TxnLogs:   make([]*zkfile.TxnLogInfo, 0),
 			Snapshots: make([]*zkfile.SnapshotInfo, 0),
@@ -92,6 +113,11 @@ func NewBackupInfo(backupID string, zxid zkfile.ZXID) *BackupInfo {
 	}
 }
 
+// SetBackupZxid sets the ZXID the backup represents
+func (bi *BackupInfo) SetBackupZxid(zxid zkfile.ZXID) {
+	bi.BackupZxid = NewZxidInfo(zxid)
+}
+
 // SaveToFile saves BackupInfo to a JSON file
 func (bi *BackupInfo) SaveToFile(path string) error {
 	data, err := json.MarshalIndent(bi, "", "  ")
@@ -126,3 +152,30 @@ func (bi *BackupInfo) UpdateStatistics(totalSize, compressedSize int64, duration
 	bi.Statistics.CompressedSize = compressedSize
 	bi.Statistics.DurationSeconds = duration.Seconds()
 }
+
+// CompressionSavings returns the bytes saved by compressing the backup files
+func (bi *BackupInfo) CompressionSavings() int64 {
+	var savings int64
+	for _, s := range bi.Files.Snapshots {
+		if s.CompressedSize > 0 {
+			savings += s.Size - s.CompressedSize
+		}
+	}
+	for _, t := range bi.Files.TxnLogs {
+		if t.CompressedSize > 0 {
+			savings += t.Size - t.CompressedSize
+		}
+	}
+	if bi.Logical != nil && bi.Logical.CompressedSize > 0 {
+		savings += bi.Logical.Size - bi.Logical.CompressedSize
+	}
+	return savings
+}
+
+// StoredSize returns the on-disk size of the backup
+func (bi *BackupInfo) StoredSize() int64 {
+	if bi.Statistics.CompressedSize > 0 {
+		return bi.Statistics.CompressedSize
+	}
+	return bi.Statistics.TotalSize
+}
@@ -116,6 +116,27 @@ func TestBackupInfo_UpdateStatistics(t *testing.T) {
 	}
 }
 
+func TestBackupInfo_CompressionSavings(t *testing.T) {
+	info := NewBackupInfo("test", zkfile.ZXID(100))
+	info.AddSnapshot(&zkfile.SnapshotInfo{Name: "snapshot.100", Size: 1000, Compression: "gzip", CompressedSize: 300})
+	info.AddTxnLog(&zkfile.TxnLogInfo{Name: "log.1", Size: 4000, Compression: "gzip", CompressedSize: 100})
+	info.AddTxnLog(&zkfile.TxnLogInfo{Name: "log.50", Size: 500})
+
+	if got := info.CompressionSavings(); got != 4600 {
+		t.Errorf("CompressionSavings() = %d, want 4600", got)
+	}
+
+	info.UpdateStatistics(6000, 1400, time.Second)
+	if got := info.StoredSize(); got != 1400 {
+		t.Errorf("StoredSize() = %d, want 1400", got)
+	}
+
+	info.UpdateStatistics(6000, 0, time.Second)
+	if got := info.StoredSize(); got != 6000 {
+		t.Errorf("StoredSize() without compression = %d, want 6000", got)
+	}
+}
+
 func TestBackupInfo_SaveToFile_LoadBackupInfo(t *testing.T) {
 	// Create temp directory
 	tmpDir := t.TempDir()
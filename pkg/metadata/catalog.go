@@ -0,0 +1,203 @@
+package metadata
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// Backup status values reported by the catalog
+const (
+	BackupStatusValid      = "valid"
+	BackupStatusPartial    = "partial"
+	BackupStatusCorrupted  = "corrupted"
+	BackupStatusIncomplete = "incomplete"
+)
+
+// CatalogEntry describes a single backup found under a backup base directory
+type CatalogEntry struct {
+	BackupID      string      `json:"backup_id"`
+	Cluster       string      `json:"cluster,omitempty"`
+	Path          string      `json:"path"`
+	Timestamp     time.Time   `json:"timestamp"`
+	BackupZxid    ZxidInfo    `json:"backup_zxid"`
+	SnapshotCount int         `json:"snapshot_count"`
+	TxnLogCount   int         `json:"txnlog_count"`
+	TotalSize     int64       `json:"total_size"`
+	Status        string      `json:"status"`
+	Error         string      `json:"error,omitempty"`
+	Info          *BackupInfo `json:"-"`
+}
+
+// InfoPath returns the backup_info.json path of a backup directory
+func InfoPath(backupDir string) string {
+	return filepath.Join(backupDir, "metadata", "backup_info.json")
+}
+
+// Status derives the overall backup status from the validation results
+func (bi *BackupInfo) Status() string {
+	v := bi.Validation
+	if v.UnrecoverableFiles > 0 || v.CorruptedFiles > v.RepairedFiles {
+		return BackupStatusCorrupted
+	}
+	if v.RepairedFiles > 0 {
+		return BackupStatusPartial
+	}
+	return BackupStatusValid
+}
+
+// ScanCatalog loads every backup found directly under baseDir.
+// Directories whose metadata is missing or unreadable are reported as incomplete.
+func ScanCatalog(baseDir string) ([]*CatalogEntry, error) {
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		return nil, zkfile.NewIOError("failed to read backup base directory").WithError(err).WithContext("dir", baseDir)
+	}
+
+	catalog := make([]*CatalogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		catalog = append(catalog, loadCatalogEntry(filepath.Join(baseDir, entry.Name())))
+	}
+
+	return catalog, nil
+}
+
+// ScanClusterCatalog loads the backups of several clusters. namespaces maps each
+// cluster name to the directory holding its backups; a missing directory means
+// the cluster has no backups yet.
+func ScanClusterCatalog(namespaces map[string]string) ([]*CatalogEntry, error) {
+	var catalog []*CatalogEntry
+	for cluster, dir := range namespaces {
+		if !zkfile.DirExists(dir) {
+			continue
+		}
+
+		entries, err := ScanCatalog(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			entry.Cluster = cluster
+		}
+		catalog = append(catalog, entries...)
+	}
+
+	return catalog, nil
+}
+
+// loadCatalogEntry builds a catalog entry for a single backup directory
+func loadCatalogEntry(backupDir string) *CatalogEntry {
+	entry := &CatalogEntry{
+		BackupID: filepath.Base(backupDir),
+		Path:     backupDir,
+	}
+
+	info, err := LoadBackupInfo(InfoPath(backupDir))
+	if err != nil {
+		entry.Status = BackupStatusIncomplete
+		entry.Error = err.Error()
+		if stat, statErr := os.Stat(backupDir); statErr == nil {
+			entry.Timestamp = stat.ModTime()
+		}
+		entry.TotalSize, _ = zkfile.GetDirSize(backupDir)
+		return entry
+	}
+
+	entry.Info = info
+	entry.Timestamp = info.BackupTimestamp
+	entry.BackupZxid = info.BackupZxid
+	entry.SnapshotCount = len(info.Files.Snapshots)
+	entry.TxnLogCount = len(info.Files.TxnLogs)
+	entry.TotalSize = info.StoredSize()
+	entry.Status = info.Status()
+	entry.Cluster = info.Cluster
+	if info.BackupID != "" {
+		entry.BackupID = info.BackupID
+	}
+
+	return entry
+}
+
+// SortCatalog sorts catalog entries in descending order by time, size or zxid
+func SortCatalog(catalog []*CatalogEntry, sortBy string) error {
+	var less func(a, b *CatalogEntry) bool
+
+	switch sortBy {
+	case "", "time":
+		less = func(a, b *CatalogEntry) bool { return a.Timestamp.After(b.Timestamp) }
+	case "size":
+		less = func(a, b *CatalogEntry) bool { return a.TotalSize > b.TotalSize }
+	case "zxid":
+		less = func(a, b *CatalogEntry) bool { return a.BackupZxid.Decimal > b.BackupZxid.Decimal }
+	default:
+		return fmt.Errorf("unsupported sort key: %s", sortBy)
+	}
+
+	sort.SliceStable(catalog, func(i, j int) bool {
+		return less(catalog[i], catalog[j])
+	})
+
+	return nil
+}
+
+// FindBackup locates a backup by ID under baseDir
+func FindBackup(baseDir, backupID string) (*CatalogEntry, error) {
+	backupDir := filepath.Join(baseDir, backupID)
+	if filepath.Dir(backupDir) == filepath.Clean(baseDir) && zkfile.DirExists(backupDir) {
+		return loadCatalogEntry(backupDir), nil
+	}
+
+	// The directory name may differ from the ID recorded in the metadata
+	catalog, err := ScanCatalog(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range catalog {
+		if entry.BackupID == backupID {
+			return entry, nil
+		}
+	}
+
+	return nil, zkfile.NewUserError("backup not found").
+		WithContext("backup_id", backupID).WithContext("base_dir", baseDir)
+}
+
+// SelectBackup picks a backup by ID from a catalog spanning several cluster
+// namespaces. The ID must match a single backup, otherwise the cluster to look
+// in has to be selected.
+func SelectBackup(catalog []*CatalogEntry, backupID string) (*CatalogEntry, error) {
+	var matches []*CatalogEntry
+	for _, entry := range catalog {
+		if entry.BackupID == backupID || filepath.Base(entry.Path) == backupID {
+			matches = append(matches, entry)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, zkfile.NewUserError("backup not found").WithContext("backup_id", backupID)
+	case 1:
+		return matches[0], nil
+	}
+
+	clusters := make([]string, 0, len(matches))
+	for _, entry := range matches {
+		if entry.Cluster == "" {
+			clusters = append(clusters, "-")
+			continue
+		}
+		clusters = append(clusters, entry.Cluster)
+	}
+	sort.Strings(clusters)
+
+	return nil, zkfile.NewUserError("backup id is found in several clusters, select one with --cluster").
+		WithContext("backup_id", backupID).WithContext("clusters", clusters)
+}
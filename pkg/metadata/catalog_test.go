@@ -0,0 +1,284 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// createTestBackup writes a backup directory with metadata under baseDir
+func createTestBackup(t *testing.T, baseDir, id string, zxid zkfile.ZXID, ts time.Time, size int64) *BackupInfo {
+	t.Helper()
+
+	info := NewBackupInfo(id, zxid)
+	info.BackupTimestamp = ts
+	info.Statistics.TotalSize = size
+
+	backupDir := filepath.Join(baseDir, id)
+	if err := os.MkdirAll(filepath.Join(backupDir, "metadata"), 0755); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+	if err := info.SaveToFile(InfoPath(backupDir)); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	return info
+}
+
+func TestBackupInfo_Status(t *testing.T) {
+	tests := []struct {
+		name       string
+		validation ValidationInfo
+		want       string
+	}{
+		{
+			name:       "all files valid",
+			validation: ValidationInfo{TotalFiles: 3, ValidFiles: 3},
+			want:       BackupStatusValid,
+		},
+		{
+			name:       "corruption repaired",
+			validation: ValidationInfo{TotalFiles: 3, ValidFiles: 2, CorruptedFiles: 1, RepairedFiles: 1},
+			want:       BackupStatusPartial,
+		},
+		{
+			name:       "corruption not repaired",
+			validation: ValidationInfo{TotalFiles: 3, ValidFiles: 2, CorruptedFiles: 1},
+			want:       BackupStatusCorrupted,
+		},
+		{
+			name:       "unrecoverable files",
+			validation: ValidationInfo{TotalFiles: 3, ValidFiles: 2, UnrecoverableFiles: 1},
+			want:       BackupStatusCorrupted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := NewBackupInfo("test", zkfile.ZXID(100))
+			info.Validation = tt.validation
+
+			if got := info.Status(); got != tt.want {
+				t.Errorf("Status() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanCatalog(t *testing.T) {
+	baseDir := t.TempDir()
+	now := time.Now()
+
+	createTestBackup(t, baseDir, "backup-a", zkfile.ZXID(0x300), now.Add(-2*time.Hour), 100)
+	createTestBackup(t, baseDir, "backup-b", zkfile.ZXID(0x100), now.Add(-1*time.Hour), 300)
+	createTestBackup(t, baseDir, "backup-c", zkfile.ZXID(0x200), now, 200)
+
+	// Directory without metadata
+	os.MkdirAll(filepath.Join(baseDir, "backup-broken", "snapshots"), 0755)
+	// Directory with unreadable metadata
+	os.MkdirAll(filepath.Join(baseDir, "backup-garbage", "metadata"), 0755)
+	os.WriteFile(InfoPath(filepath.Join(baseDir, "backup-garbage")), []byte("{not json"), 0644)
+	// Plain files are ignored
+	os.WriteFile(filepath.Join(baseDir, "README"), []byte("x"), 0644)
+
+	catalog, err := ScanCatalog(baseDir)
+	if err != nil {
+		t.Fatalf("ScanCatalog() error = %v", err)
+	}
+
+	if len(catalog) != 5 {
+		t.Fatalf("Catalog size = %d, want 5", len(catalog))
+	}
+
+	byID := make(map[string]*CatalogEntry)
+	for _, entry := range catalog {
+		byID[entry.BackupID] = entry
+	}
+
+	for _, id := range []string{"backup-broken", "backup-garbage"} {
+		entry, ok := byID[id]
+		if !ok {
+			t.Fatalf("Entry %s missing from catalog", id)
+		}
+		if entry.Status != BackupStatusIncomplete {
+			t.Errorf("%s Status = %v, want %v", id, entry.Status, BackupStatusIncomplete)
+		}
+		if entry.Error == "" {
+			t.Errorf("%s Error should be set", id)
+		}
+		if entry.Info != nil {
+			t.Errorf("%s Info should be nil", id)
+		}
+	}
+
+	entry := byID["backup-a"]
+	if entry.Status != BackupStatusValid {
+		t.Errorf("Status = %v, want %v", entry.Status, BackupStatusValid)
+	}
+	if entry.BackupZxid.Decimal != 0x300 {
+		t.Errorf("BackupZxid.Decimal = %v, want %v", entry.BackupZxid.Decimal, 0x300)
+	}
+	if entry.TotalSize != 100 {
+		t.Errorf("TotalSize = %v, want 100", entry.TotalSize)
+	}
+
+	t.Run("nonexistent base dir", func(t *testing.T) {
+		_, err := ScanCatalog(filepath.Join(baseDir, "nonexistent"))
+		if err == nil {
+			t.Error("ScanCatalog() should return error for nonexistent directory")
+		}
+	})
+}
+
+func TestScanClusterCatalog(t *testing.T) {
+	baseDir := t.TempDir()
+	now := time.Now()
+
+	createTestBackup(t, filepath.Join(baseDir, "orders"), "backup-1", zkfile.ZXID(0x100), now, 100)
+	createTestBackup(t, filepath.Join(baseDir, "orders"), "backup-2", zkfile.ZXID(0x200), now, 100)
+	createTestBackup(t, filepath.Join(baseDir, "users"), "backup-1", zkfile.ZXID(0x300), now, 100)
+
+	catalog, err := ScanClusterCatalog(map[string]string{
+		"orders":   filepath.Join(baseDir, "orders"),
+		"users":    filepath.Join(baseDir, "users"),
+		"payments": filepath.Join(baseDir, "payments"),
+	})
+	if err != nil {
+		t.Fatalf("ScanClusterCatalog() error = %v", err)
+	}
+
+	counts := make(map[string]int)
+	for _, entry := range catalog {
+		counts[entry.Cluster]++
+	}
+	if len(catalog) != 3 || counts["orders"] != 2 || counts["users"] != 1 {
+		t.Errorf("Backups per cluster = %v, want orders: 2, users: 1", counts)
+	}
+}
+
+func TestSortCatalog(t *testing.T) {
+	baseDir := t.TempDir()
+	now := time.Now()
+
+	createTestBackup(t, baseDir, "backup-a", zkfile.ZXID(0x300), now.Add(-2*time.Hour), 100)
+	createTestBackup(t, baseDir, "backup-b", zkfile.ZXID(0x100), now.Add(-1*time.Hour), 300)
+	createTestBackup(t, baseDir, "backup-c", zkfile.ZXID(0x200), now, 200)
+
+	tests := []struct {
+		sortBy  string
+		want    []string
+		wantErr bool
+	}{
+		{sortBy: "time", want: []string{"backup-c", "backup-b", "backup-a"}},
+		{sortBy: "size", want: []string{"backup-b", "backup-c", "backup-a"}},
+		{sortBy: "zxid", want: []string{"backup-a", "backup-c", "backup-b"}},
+		{sortBy: "name", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			catalog, err := ScanCatalog(baseDir)
+			if err != nil {
+				t.Fatalf("ScanCatalog() error = %v", err)
+			}
+
+			err = SortCatalog(catalog, tt.sortBy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SortCatalog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			for i, id := range tt.want {
+				if catalog[i].BackupID != id {
+					t.Errorf("catalog[%d] = %v, want %v", i, catalog[i].BackupID, id)
+				}
+			}
+		})
+	}
+}
+
+func TestFindBackup(t *testing.T) {
+	baseDir := t.TempDir()
+	createTestBackup(t, baseDir, "backup-a", zkfile.ZXID(0x100), time.Now(), 100)
+
+	// Directory name differs from the recorded backup ID
+	info := createTestBackup(t, baseDir, "renamed", zkfile.ZXID(0x200), time.Now(), 100)
+	info.BackupID = "backup-b"
+	info.SaveToFile(InfoPath(filepath.Join(baseDir, "renamed")))
+
+	t.Run("by directory name", func(t *testing.T) {
+		entry, err := FindBackup(baseDir, "backup-a")
+		if err != nil {
+			t.Fatalf("FindBackup() error = %v", err)
+		}
+		if entry.Info == nil || entry.BackupZxid.Decimal != 0x100 {
+			t.Errorf("FindBackup() returned wrong entry: %+v", entry)
+		}
+	})
+
+	t.Run("by recorded backup id", func(t *testing.T) {
+		entry, err := FindBackup(baseDir, "backup-b")
+		if err != nil {
+			t.Fatalf("FindBackup() error = %v", err)
+		}
+		if entry.Path != filepath.Join(baseDir, "renamed") {
+			t.Errorf("Path = %v, want %v", entry.Path, filepath.Join(baseDir, "renamed"))
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := FindBackup(baseDir, "backup-missing")
+		if err == nil {
+			t.Error("FindBackup() should return error for unknown backup")
+		}
+	})
+}
+
+func TestSelectBackup(t *testing.T) {
+	baseDir := t.TempDir()
+	now := time.Now()
+
+	createTestBackup(t, filepath.Join(baseDir, "orders"), "backup-1", zkfile.ZXID(0x100), now, 100)
+	createTestBackup(t, filepath.Join(baseDir, "orders"), "backup-2", zkfile.ZXID(0x200), now, 100)
+	createTestBackup(t, filepath.Join(baseDir, "users"), "backup-1", zkfile.ZXID(0x300), now, 100)
+
+	catalog, err := ScanClusterCatalog(map[string]string{
+		"orders": filepath.Join(baseDir, "orders"),
+		"users":  filepath.Join(baseDir, "users"),
+	})
+	if err != nil {
+		t.Fatalf("ScanClusterCatalog() error = %v", err)
+	}
+
+	t.Run("unique id", func(t *testing.T) {
+		entry, err := SelectBackup(catalog, "backup-2")
+		if err != nil {
+			t.Fatalf("SelectBackup() error = %v", err)
+		}
+		if entry.Cluster != "orders" || entry.BackupZxid.Decimal != 0x200 {
+			t.Errorf("SelectBackup() returned wrong entry: %+v", entry)
+		}
+	})
+
+	t.Run("ambiguous id", func(t *testing.T) {
+		_, err := SelectBackup(catalog, "backup-1")
+		if err == nil {
+			t.Fatal("SelectBackup() should return error for an id found in several clusters")
+		}
+		if !strings.Contains(err.Error(), "--cluster") {
+			t.Errorf("SelectBackup() error = %v, want a hint to select the cluster", err)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if _, err := SelectBackup(catalog, "backup-missing"); err == nil {
+			t.Error("SelectBackup() should return error for unknown backup")
+		}
+	})
+}
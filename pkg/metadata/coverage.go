@@ -0,0 +1,121 @@
+package metadata
+
+import (
+	"sort"
+
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// ZxidRange is an inclusive range of ZXIDs
+type ZxidRange struct {
+	From zkfile.ZXID `json:"from"`
+	To   zkfile.ZXID `json:"to"`
+}
+
+// ZxidCoverage summarizes which ZXIDs a backup can reproduce
+type ZxidCoverage struct {
+	FirstZxid          zkfile.ZXID `json:"first_zxid"`
+	LastZxid           zkfile.ZXID `json:"last_zxid"`
+	OldestSnapshotZxid zkfile.ZXID `json:"oldest_snapshot_zxid"`
+	LatestSnapshotZxid zkfile.ZXID `json:"latest_snapshot_zxid"`
+	TxnLogStartZxid    zkfile.ZXID `json:"txnlog_start_zxid"`
+	TxnLogEndZxid      zkfile.ZXID `json:"txnlog_end_zxid"`
+	HasSnapshot        bool        `json:"has_snapshot"`
+	HasTxnLog          bool        `json:"has_txnlog"`
+	Gaps               []ZxidRange `json:"gaps,omitempty"`
+}
+
+// Coverage computes the ZXID coverage of the backed-up files
+func (bi *BackupInfo) Coverage() *ZxidCoverage {
+	coverage := &ZxidCoverage{Gaps: make([]ZxidRange, 0)}
+
+	for _, s := range bi.Files.Snapshots {
+		if !coverage.HasSnapshot || s.Zxid < coverage.OldestSnapshotZxid {
+			coverage.OldestSnapshotZxid = s.Zxid
+		}
+		if !coverage.HasSnapshot || s.Zxid > coverage.LatestSnapshotZxid {
+			coverage.LatestSnapshotZxid = s.Zxid
+		}
+		coverage.HasSnapshot = true
+	}
+
+	txnlogs := bi.sortedTxnLogs()
+	for i, t := range txnlogs {
+		if i == 0 {
+			coverage.TxnLogStartZxid = t.StartZxid
+			coverage.TxnLogEndZxid = t.EndZxid
+			coverage.HasTxnLog = true
+			continue
+		}
+		if !isContiguous(coverage.TxnLogEndZxid, t.StartZxid) {
+			coverage.Gaps = append(coverage.Gaps, ZxidRange{From: coverage.TxnLogEndZxid + 1, To: t.StartZxid - 1})
+		}
+		coverage.TxnLogEndZxid = zkfile.MaxZXID(coverage.TxnLogEndZxid, t.EndZxid)
+	}
+
+	switch {
+	case coverage.HasSnapshot && coverage.HasTxnLog:
+		coverage.FirstZxid = zkfile.MinZXID(coverage.OldestSnapshotZxid, coverage.TxnLogStartZxid)
+		coverage.LastZxid = zkfile.MaxZXID(coverage.LatestSnapshotZxid, coverage.TxnLogEndZxid)
+	case coverage.HasSnapshot:
+		coverage.FirstZxid = coverage.OldestSnapshotZxid
+		coverage.LastZxid = coverage.LatestSnapshotZxid
+	case coverage.HasTxnLog:
+		coverage.FirstZxid = coverage.TxnLogStartZxid
+		coverage.LastZxid = coverage.TxnLogEndZxid
+	}
+
+	return coverage
+}
+
+// CoversZxid reports whether the backup can reproduce the state at target:
+// a snapshot at or below target must exist, followed by gap-free txnlogs up to target
+func (bi *BackupInfo) CoversZxid(target zkfile.ZXID) bool {
+	var base *zkfile.SnapshotInfo
+	for _, s := range bi.Files.Snapshots {
+		if s.Zxid <= target && (base == nil || s.Zxid > base.Zxid) {
+			base = s
+		}
+	}
+	if base == nil {
+		return false
+	}
+	if base.Zxid == target {
+		return true
+	}
+
+	cursor := base.Zxid
+	for _, t := range bi.sortedTxnLogs() {
+		if t.EndZxid <= cursor {
+			continue
+		}
+		if !isContiguous(cursor, t.StartZxid) {
+			return false
+		}
+		cursor = t.EndZxid
+		if cursor >= target {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sortedTxnLogs returns the txnlogs ordered by their first ZXID
+func (bi *BackupInfo) sortedTxnLogs() []*zkfile.TxnLogInfo {
+	txnlogs := make([]*zkfile.TxnLogInfo, len(bi.Files.TxnLogs))
+	copy(txnlogs, bi.Files.TxnLogs)
+	sort.Slice(txnlogs, func(i, j int) bool {
+		return txnlogs[i].StartZxid < txnlogs[j].StartZxid
+	})
+	return txnlogs
+}
+
+// isContiguous reports whether next directly follows prev.
+// A new epoch restarts the counter, so its first ZXID follows any ZXID of an earlier epoch.
+func isContiguous(prev, next zkfile.ZXID) bool {
+	if next <= prev+1 {
+		return true
+	}
+	return next.Epoch() > prev.Epoch() && next.Counter() <= 1
+}
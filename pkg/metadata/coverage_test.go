@@ -0,0 +1,105 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// newCoverageTestInfo builds a BackupInfo with the given snapshots and txnlog ranges
+func newCoverageTestInfo(snapshots []zkfile.ZXID, txnlogs [][2]zkfile.ZXID) *BackupInfo {
+	info := NewBackupInfo("test", zkfile.ZXID(0))
+	for _, zxid := range snapshots {
+		info.AddSnapshot(&zkfile.SnapshotInfo{Name: zkfile.FormatZxidFileName(zkfile.FileTypeSnapshot, zxid), Zxid: zxid})
+	}
+	for _, r := range txnlogs {
+		info.AddTxnLog(&zkfile.TxnLogInfo{Name: zkfile.FormatZxidFileName(zkfile.FileTypeTxnLog, r[0]), StartZxid: r[0], EndZxid: r[1]})
+	}
+	return info
+}
+
+func TestBackupInfo_Coverage(t *testing.T) {
+	t.Run("contiguous txnlogs", func(t *testing.T) {
+		info := newCoverageTestInfo(
+			[]zkfile.ZXID{0x100000010, 0x100000050},
+			[][2]zkfile.ZXID{{0x100000040, 0x100000080}, {0x100000001, 0x10000003f}},
+		)
+
+		coverage := info.Coverage()
+		if coverage.FirstZxid != 0x100000001 {
+			t.Errorf("FirstZxid = %v, want 0x100000001", coverage.FirstZxid)
+		}
+		if coverage.LastZxid != 0x100000080 {
+			t.Errorf("LastZxid = %v, want 0x100000080", coverage.LastZxid)
+		}
+		if coverage.OldestSnapshotZxid != 0x100000010 || coverage.LatestSnapshotZxid != 0x100000050 {
+			t.Errorf("Snapshot range = %v ~ %v, want 0x100000010 ~ 0x100000050",
+				coverage.OldestSnapshotZxid, coverage.LatestSnapshotZxid)
+		}
+		if len(coverage.Gaps) != 0 {
+			t.Errorf("Gaps = %v, want none", coverage.Gaps)
+		}
+	})
+
+	t.Run("gap between txnlogs", func(t *testing.T) {
+		info := newCoverageTestInfo(
+			[]zkfile.ZXID{0x100000001},
+			[][2]zkfile.ZXID{{0x100000001, 0x100000010}, {0x100000020, 0x100000030}},
+		)
+
+		coverage := info.Coverage()
+		if len(coverage.Gaps) != 1 {
+			t.Fatalf("Gaps count = %d, want 1", len(coverage.Gaps))
+		}
+		if coverage.Gaps[0].From != 0x100000011 || coverage.Gaps[0].To != 0x10000001f {
+			t.Errorf("Gap = %v ~ %v, want 0x100000011 ~ 0x10000001f", coverage.Gaps[0].From, coverage.Gaps[0].To)
+		}
+	})
+
+	t.Run("new epoch is not a gap", func(t *testing.T) {
+		info := newCoverageTestInfo(
+			[]zkfile.ZXID{0x100000001},
+			[][2]zkfile.ZXID{{0x100000001, 0x100000010}, {0x200000001, 0x200000005}},
+		)
+
+		if gaps := info.Coverage().Gaps; len(gaps) != 0 {
+			t.Errorf("Gaps = %v, want none", gaps)
+		}
+	})
+
+	t.Run("empty backup", func(t *testing.T) {
+		coverage := newCoverageTestInfo(nil, nil).Coverage()
+		if coverage.HasSnapshot || coverage.HasTxnLog {
+			t.Error("Empty backup should have no snapshot or txnlog coverage")
+		}
+	})
+}
+
+func TestBackupInfo_CoversZxid(t *testing.T) {
+	info := newCoverageTestInfo(
+		[]zkfile.ZXID{0x100000010, 0x100000050},
+		[][2]zkfile.ZXID{{0x100000001, 0x100000060}, {0x100000070, 0x100000080}},
+	)
+
+	tests := []struct {
+		name   string
+		target zkfile.ZXID
+		want   bool
+	}{
+		{name: "before first snapshot", target: 0x100000005, want: false},
+		{name: "exact snapshot", target: 0x100000010, want: true},
+		{name: "between snapshots", target: 0x100000030, want: true},
+		{name: "end of first txnlog", target: 0x100000060, want: true},
+		{name: "inside gap", target: 0x100000065, want: false},
+		{name: "after gap", target: 0x100000075, want: false},
+		{name: "beyond last txnlog", target: 0x100000090, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := info.CoversZxid(tt.target); got != tt.want {
+				t.Errorf("CoversZxid(%v) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package metadata
 import (
 	"fmt"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/zookeeper-backup/pkg/utils"
@@ -18,11 +19,21 @@ func (bi *BackupInfo) GenerateTextReport() string {
 
 	sb.WriteString(fmt.Sprintf("Backup ID: %s\n", bi.BackupID))
 	sb.WriteString(fmt.Sprintf("Timestamp: %s\n", bi.BackupTimestamp.Format(time.RFC3339)))
-	sb.WriteString(fmt.Sprintf("Backup ZXID: 0x%s (%d)\n\n", bi.BackupZxid.Hex, bi.BackupZxid.Decimal))
+	sb.WriteString(fmt.Sprintf("Backup ZXID: 0x%s (%d)\n", bi.BackupZxid.Hex, bi.BackupZxid.Decimal))
+	if bi.Mode != "" {
+		sb.WriteString(fmt.Sprintf("Mode: %s\n", bi.Mode))
+	}
+	if bi.Cluster != "" {
+		sb.WriteString(fmt.Sprintf("Cluster: %s\n", bi.Cluster))
+	}
+	sb.WriteString("\n")
 
 	sb.WriteString("ZooKeeper Information:\n")
 	sb.WriteString(fmt.Sprintf("  Version: %s\n", bi.ZooKeeper.Version))
 	sb.WriteString(fmt.Sprintf("  Host: %s\n", bi.ZooKeeper.Host))
+	if bi.ZooKeeper.AdminURL != "" {
+		sb.WriteString(fmt.Sprintf("  Admin URL: %s\n", bi.ZooKeeper.AdminURL))
+	}
 	sb.WriteString(fmt.Sprintf("  Data Dir: %s\n", bi.ZooKeeper.DataDir))
 	sb.WriteString(fmt.Sprintf("  Log Dir: %s\n\n", bi.ZooKeeper.LogDir))
 
@@ -30,6 +41,15 @@ func (bi *BackupInfo) GenerateTextReport() string {
 	sb.WriteString(fmt.Sprintf("  Snapshots: %d\n", len(bi.Files.Snapshots)))
 	sb.WriteString(fmt.Sprintf("  TxnLogs: %d\n\n", len(bi.Files.TxnLogs)))
 
+	if l := bi.Logical; l != nil {
+		sb.WriteString("Logical Archive:\n")
+		sb.WriteString(fmt.Sprintf("  File: %s\n", l.File))
+		sb.WriteString(fmt.Sprintf("  Paths: %s\n", strings.Join(l.Paths, ", ")))
+		sb.WriteString(fmt.Sprintf("  ZXID Window: 0x%s ~ 0x%s\n", l.StartZxid.Hex, l.EndZxid.Hex))
+		sb.WriteString(fmt.Sprintf("  Nodes: %d\n", l.NodeCount))
+		sb.WriteString(fmt.Sprintf("  Size: %s\n\n", formatFileSize(l.Size, l.CompressedSize, l.Compression)))
+	}
+
 	if bi.Validation.Enabled {
 		sb.WriteString("Validation:\n")
 		sb.WriteString(fmt.Sprintf("  Total Files: %d\n", bi.Validation.TotalFiles))
@@ -40,14 +60,78 @@ func (bi *BackupInfo) GenerateTextReport() string {
 
 	sb.WriteString("Statistics:\n")
 	sb.WriteString(fmt.Sprintf("  Total Size: %s\n", utils.FormatBytes(bi.Statistics.TotalSize)))
-	if bi.Statistics.CompressedSize > 0 {
-		sb.WriteString(fmt.Sprintf("  Compressed Size: %s\n", utils.FormatBytes(bi.Statistics.CompressedSize)))
+	if bi.Statistics.CompressedSize > 0 && bi.Statistics.TotalSize > 0 {
+		sb.WriteString(fmt.Sprintf("  Compressed Size: %s (%.1f%%)\n", utils.FormatBytes(bi.Statistics.CompressedSize),
+			float64(bi.Statistics.CompressedSize)*100/float64(bi.Statistics.TotalSize)))
 	}
 	sb.WriteString(fmt.Sprintf("  Duration: %.2f seconds\n", bi.Statistics.DurationSeconds))
 
 	return sb.String()
 }
 
+// GenerateFileReport generates per-file details and the ZXID coverage summary
+func (bi *BackupInfo) GenerateFileReport() string {
+	var sb strings.Builder
+
+	sb.WriteString("Snapshot Files:\n")
+	if len(bi.Files.Snapshots) == 0 {
+		sb.WriteString("  (none)\n")
+	} else {
+		w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  NAME\tZXID\tNODES\tSIZE\tCHECKSUM")
+		for _, s := range bi.Files.Snapshots {
+			fmt.Fprintf(w, "  %s\t%s\t%d\t%s\t%s\n", s.Name, s.Zxid, s.NodeCount, formatFileSize(s.Size, s.CompressedSize, s.Compression), s.Checksum)
+		}
+		_ = w.Flush()
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("TxnLog Files:\n")
+	if len(bi.Files.TxnLogs) == 0 {
+		sb.WriteString("  (none)\n")
+	} else {
+		w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  NAME\tZXID RANGE\tTXNS\tSIZE\tSTATUS")
+		for _, t := range bi.Files.TxnLogs {
+			status := t.Status
+			if t.Note != "" {
+				status += " (" + t.Note + ")"
+			}
+			fmt.Fprintf(w, "  %s\t%s ~ %s\t%d\t%s\t%s\n",
+				t.Name, t.StartZxid, t.EndZxid, t.TransactionCount, formatFileSize(t.Size, t.CompressedSize, t.Compression), status)
+		}
+		_ = w.Flush()
+	}
+	sb.WriteString("\n")
+
+	coverage := bi.Coverage()
+	sb.WriteString("ZXID Coverage:\n")
+	if !coverage.HasSnapshot && !coverage.HasTxnLog {
+		sb.WriteString("  No data files in backup\n")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("  Range: %s ~ %s\n", coverage.FirstZxid, coverage.LastZxid))
+	if coverage.HasSnapshot {
+		sb.WriteString(fmt.Sprintf("  Snapshots: %s ~ %s\n", coverage.OldestSnapshotZxid, coverage.LatestSnapshotZxid))
+	} else {
+		sb.WriteString("  Snapshots: none (backup cannot be restored)\n")
+	}
+	if coverage.HasTxnLog {
+		sb.WriteString(fmt.Sprintf("  TxnLogs: %s ~ %s\n", coverage.TxnLogStartZxid, coverage.TxnLogEndZxid))
+	} else {
+		sb.WriteString("  TxnLogs: none\n")
+	}
+	if len(coverage.Gaps) == 0 {
+		sb.WriteString("  Gaps: none\n")
+	} else {
+		for _, gap := range coverage.Gaps {
+			sb.WriteString(fmt.Sprintf("  Gap: %s ~ %s\n", gap.From, gap.To))
+		}
+	}
+
+	return sb.String()
+}
+
 // GenerateManifest generates a simple manifest file content
 func (bi *BackupInfo) GenerateManifest() string {
 	var sb strings.Builder
@@ -59,13 +143,26 @@ func (bi *BackupInfo) GenerateManifest() string {
 
 	sb.WriteString("## Snapshot Files\n\n")
 	for _, s := range bi.Files.Snapshots {
-		sb.WriteString(fmt.Sprintf("- %s (ZXID: 0x%s, Size: %s)\n", s.Name, s.Zxid.Hex(), utils.FormatBytes(s.Size)))
+		sb.WriteString(fmt.Sprintf("- %s (ZXID: 0x%s, Size: %s)\n", s.Name, s.Zxid.Hex(), formatFileSize(s.Size, s.CompressedSize, s.Compression)))
 	}
 
 	sb.WriteString("\n## TxnLog Files\n\n")
 	for _, t := range bi.Files.TxnLogs {
-		sb.WriteString(fmt.Sprintf("- %s (ZXID: 0x%s - 0x%s, Txns: %d, Size: %s, Status: %s)\n", t.Name, t.StartZxid.Hex(), t.EndZxid.Hex(), t.TransactionCount, utils.FormatBytes(t.Size), t.Status))
+		sb.WriteString(fmt.Sprintf("- %s (ZXID: 0x%s - 0x%s, Txns: %d, Size: %s, Status: %s)\n", t.Name, t.StartZxid.Hex(), t.EndZxid.Hex(), t.TransactionCount, formatFileSize(t.Size, t.CompressedSize, t.Compression), t.Status))
+	}
+
+	if l := bi.Logical; l != nil {
+		sb.WriteString("\n## Logical Archive\n\n")
+		sb.WriteString(fmt.Sprintf("- %s (ZXID: 0x%s - 0x%s, Nodes: %d, Size: %s, Checksum: %s)\n", l.File, l.StartZxid.Hex, l.EndZxid.Hex, l.NodeCount, formatFileSize(l.Size, l.CompressedSize, l.Compression), l.Checksum))
 	}
 
 	return sb.String()
 }
+
+// formatFileSize formats a file size, adding the stored size of compressed files
+func formatFileSize(size, compressedSize int64, compression string) string {
+	if compressedSize <= 0 {
+		return utils.FormatBytes(size)
+	}
+	return fmt.Sprintf("%s (%s %s)", utils.FormatBytes(size), compression, utils.FormatBytes(compressedSize))
+}
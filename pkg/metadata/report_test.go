@@ -59,6 +59,73 @@ func TestBackupInfo_GenerateTextReport(t *testing.T) {
 	}
 }
 
+func TestBackupInfo_GenerateTextReport_Logical(t *testing.T) {
+	info := NewBackupInfo("logical-backup", zkfile.ZXID(0x100000007))
+	info.Mode = "logical"
+	info.Logical = &LogicalInfo{
+		File:      "logical/znodes.jsonl",
+		Paths:     []string{"/app", "/config"},
+		StartZxid: NewZxidInfo(0x100000005),
+		EndZxid:   NewZxidInfo(0x100000007),
+		NodeCount: 42,
+		Size:      4096,
+	}
+
+	report := info.GenerateTextReport()
+	for _, want := range []string{"Mode: logical", "Paths: /app, /config", "ZXID Window: 0x100000005 ~ 0x100000007", "Nodes: 42"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Report should contain %q", want)
+		}
+	}
+	if manifest := info.GenerateManifest(); !strings.Contains(manifest, "logical/znodes.jsonl") {
+		t.Error("Manifest should list the logical archive")
+	}
+}
+
+func TestBackupInfo_GenerateFileReport(t *testing.T) {
+	info := NewBackupInfo("test-backup-789", zkfile.ZXID(0x100000020))
+
+	info.AddSnapshot(&zkfile.SnapshotInfo{
+		Name:     "snapshot.100000000",
+		Zxid:     zkfile.ZXID(0x100000000),
+		Size:     1024,
+		Checksum: "sha256:abc123",
+	})
+
+	info.AddTxnLog(&zkfile.TxnLogInfo{
+		Name:             "log.100000001",
+		StartZxid:        zkfile.ZXID(0x100000001),
+		EndZxid:          zkfile.ZXID(0x100000010),
+		Size:             2048,
+		TransactionCount: 16,
+		Status:           "valid",
+	})
+	info.AddTxnLog(&zkfile.TxnLogInfo{
+		Name:             "log.100000018",
+		StartZxid:        zkfile.ZXID(0x100000018),
+		EndZxid:          zkfile.ZXID(0x100000020),
+		Size:             2048,
+		TransactionCount: 9,
+		Status:           "truncated",
+	})
+
+	report := info.GenerateFileReport()
+
+	for _, want := range []string{
+		"snapshot.100000000",
+		"sha256:abc123",
+		"log.100000001",
+		"0x100000001 ~ 0x100000010",
+		"truncated",
+		"Range: 0x100000000 ~ 0x100000020",
+		"Gap: 0x100000011 ~ 0x100000017",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Report should contain %q, got:\n%s", want, report)
+		}
+	}
+}
+
 func TestBackupInfo_GenerateManifest(t *testing.T) {
 	info := NewBackupInfo("test-backup-456", zkfile.ZXID(0x200000000))
 
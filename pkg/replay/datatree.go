@@ -0,0 +1,349 @@
+// Package replay materializes the ZooKeeper DataTree of a backup in memory:
+// it loads a snapshot and applies the decoded txnlog transactions after it,
+// the way a ZooKeeper server does when it starts from its data directories.
+package replay
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// Node is a znode of the DataTree
+type Node struct {
+	Path     string
+	Data     []byte
+	ACL      []zkfile.ACL
+	Stat     zkfile.StatPersisted
+	children map[string]struct{}
+}
+
+// Children returns the names of the node's children, sorted
+func (n *Node) Children() []string {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NumChildren returns the number of children
+func (n *Node) NumChildren() int {
+	return len(n.children)
+}
+
+// DataTree is an in-memory ZooKeeper DataTree, like the server's DataTree
+type DataTree struct {
+	nodes      map[string]*Node
+	sessions   map[int64]int32               // session id -> timeout
+	ephemerals map[int64]map[string]struct{} // session id -> ephemeral paths
+	zxid       zkfile.ZXID                   // last processed zxid
+}
+
+// NewDataTree creates a DataTree holding only the root and /zookeeper, like a
+// server without data
+func NewDataTree() *DataTree {
+	t := &DataTree{
+		nodes:      make(map[string]*Node),
+		sessions:   make(map[int64]int32),
+		ephemerals: make(map[int64]map[string]struct{}),
+	}
+	t.addNode(&Node{Path: "/", ACL: openACLUnsafe()})
+	t.addNode(&Node{Path: "/zookeeper", ACL: openACLUnsafe()})
+	return t
+}
+
+// LoadSnapshot builds a DataTree from a snapshot file. The last processed zxid
+// is the one in the snapshot file name.
+func LoadSnapshot(path string) (*DataTree, error) {
+	zxid, err := zkfile.ParseZxidFromFileName(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := zkfile.OpenSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	t := &DataTree{
+		nodes:      make(map[string]*Node),
+		sessions:   make(map[int64]int32, len(reader.Sessions())),
+		ephemerals: make(map[int64]map[string]struct{}),
+		zxid:       zxid,
+	}
+	for _, session := range reader.Sessions() {
+		t.sessions[session.ID] = session.Timeout
+	}
+
+	for {
+		sn, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// ZooKeeper fails the load as well, the node would lose its ACL
+		acl, ok := reader.ACL(sn.ACLRef)
+		if !ok {
+			return nil, zkfile.NewCorruptionError("invalid snapshot, acl of node not found").
+				WithContext("path", path).WithContext("node", sn.Path).WithContext("acl_ref", sn.ACLRef)
+		}
+		node := &Node{Path: sn.Path, Data: sn.Data, ACL: acl, Stat: sn.Stat}
+		if node.Path != "/" {
+			if _, ok := t.nodes[parentPath(node.Path)]; !ok {
+				return nil, zkfile.NewCorruptionError("invalid snapshot, parent of node not found").
+					WithContext("path", path).WithContext("node", node.Path)
+			}
+		}
+		t.addNode(node)
+	}
+	if _, ok := t.nodes["/"]; !ok {
+		return nil, zkfile.NewCorruptionError("invalid snapshot, root node not found").WithContext("path", path)
+	}
+
+	return t, nil
+}
+
+// Zxid returns the last processed zxid
+func (t *DataTree) Zxid() zkfile.ZXID {
+	return t.zxid
+}
+
+// Get returns the node at path
+func (t *DataTree) Get(path string) (*Node, bool) {
+	node, ok := t.nodes[path]
+	return node, ok
+}
+
+// NodeCount returns the number of nodes, including the root
+func (t *DataTree) NodeCount() int {
+	return len(t.nodes)
+}
+
+// Sessions returns the open sessions and their timeouts
+func (t *DataTree) Sessions() map[int64]int32 {
+	return t.sessions
+}
+
+// Ephemerals returns the paths of the ephemeral nodes owned by a session, sorted
+func (t *DataTree) Ephemerals(session int64) []string {
+	paths := make([]string, 0, len(t.ephemerals[session]))
+	for path := range t.ephemerals[session] {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Walk calls fn for the node at path and its descendants, parents before their
+// children and siblings sorted by name
+func (t *DataTree) Walk(path string, fn func(*Node) error) error {
+	node, ok := t.nodes[path]
+	if !ok {
+		return zkfile.NewUserError("node does not exist").WithContext("node", path)
+	}
+	return t.walk(node, fn)
+}
+
+// walk is Walk from a node
+func (t *DataTree) walk(node *Node, fn func(*Node) error) error {
+	if err := fn(node); err != nil {
+		return err
+	}
+	for _, name := range node.Children() {
+		if err := t.walk(t.nodes[childPath(node.Path, name)], fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply applies a transaction, like DataTree.processTxn. Transactions at or
+// below the last processed zxid are applied as well: a snapshot is fuzzy and
+// may already contain some of the transactions after its zxid, so errors a
+// replay can run into (the node exists, the node does not exist) are ignored
+// the way ZooKeeper ignores them.
+func (t *DataTree) Apply(txn *zkfile.Transaction) error {
+	body, err := txn.Body()
+	if err != nil {
+		return err
+	}
+
+	switch txn.Type {
+	case zkfile.TxnTypeCreateSession:
+		if b, ok := body.(*zkfile.CreateSessionTxn); ok {
+			t.sessions[txn.ClientId] = b.Timeout
+		}
+	case zkfile.TxnTypeCloseSession:
+		t.killSession(txn.ClientId, txn.Zxid)
+	default:
+		t.applyBody(body, txn)
+	}
+
+	if txn.Zxid > t.zxid {
+		t.zxid = txn.Zxid
+	}
+	return nil
+}
+
+// applyBody applies a DataTree change; multi ops are applied one by one
+func (t *DataTree) applyBody(body zkfile.TxnBody, txn *zkfile.Transaction) {
+	switch b := body.(type) {
+	case *zkfile.CreateTxn:
+		t.createNode(b.Path, b.Data, b.ACL, b.EphemeralOwner, b.ParentCVersion, txn)
+	case *zkfile.CreateContainerTxn:
+		t.createNode(b.Path, b.Data, b.ACL, b.EphemeralOwner, b.ParentCVersion, txn)
+	case *zkfile.CreateTTLTxn:
+		t.createNode(b.Path, b.Data, b.ACL, b.EphemeralOwner, b.ParentCVersion, txn)
+	case *zkfile.DeleteTxn:
+		t.deleteNode(b.Path, txn.Zxid)
+	case *zkfile.SetDataTxn:
+		if node, ok := t.nodes[b.Path]; ok {
+			node.Data = b.Data
+			node.Stat.Version = b.Version
+			node.Stat.Mzxid = txn.Zxid
+			node.Stat.Mtime = txn.Timestamp
+		}
+	case *zkfile.SetACLTxn:
+		if node, ok := t.nodes[b.Path]; ok {
+			node.ACL = b.ACL
+			node.Stat.Aversion = b.Version
+		}
+	case *zkfile.MultiTxn:
+		// A failed multi only holds error ops
+		for _, op := range b.Ops {
+			t.applyBody(op.Body, txn)
+		}
+	}
+}
+
+// createNode creates a node, like DataTree.createNode. When the node already
+// exists only the parent's cversion and pzxid are brought forward, like
+// FileTxnSnapLog does for a replayed create.
+func (t *DataTree) createNode(path string, data []byte, acl []zkfile.ACL, owner int64, parentCVersion int32, txn *zkfile.Transaction) {
+	parent, ok := t.nodes[parentPath(path)]
+	if !ok {
+		return
+	}
+
+	if parentCVersion == -1 {
+		parentCVersion = parent.Stat.Cversion + 1
+	}
+	if parentCVersion > parent.Stat.Cversion {
+		parent.Stat.Cversion = parentCVersion
+		parent.Stat.Pzxid = txn.Zxid
+	}
+
+	if _, exists := t.nodes[path]; exists {
+		return
+	}
+
+	t.addNode(&Node{Path: path, Data: data, ACL: acl, Stat: zkfile.StatPersisted{
+		Czxid:          txn.Zxid,
+		Mzxid:          txn.Zxid,
+		Ctime:          txn.Timestamp,
+		Mtime:          txn.Timestamp,
+		EphemeralOwner: owner,
+		Pzxid:          txn.Zxid,
+	}})
+}
+
+// deleteNode deletes a node, like DataTree.deleteNode
+func (t *DataTree) deleteNode(path string, zxid zkfile.ZXID) {
+	node, ok := t.nodes[path]
+	if !ok || path == "/" {
+		return
+	}
+
+	// ZooKeeper never deletes a node with children, but a fuzzy snapshot can
+	// leave some behind; they go with it rather than linger unreachable
+	for _, name := range node.Children() {
+		t.deleteNode(childPath(path, name), zxid)
+	}
+
+	delete(t.nodes, path)
+	if parent, ok := t.nodes[parentPath(path)]; ok {
+		delete(parent.children, baseName(path))
+		// A pzxid set by a later create must not go back
+		if zxid > parent.Stat.Pzxid {
+			parent.Stat.Pzxid = zxid
+		}
+	}
+
+	if paths, ok := t.ephemerals[node.Stat.EphemeralOwner]; ok {
+		delete(paths, path)
+		if len(paths) == 0 {
+			delete(t.ephemerals, node.Stat.EphemeralOwner)
+		}
+	}
+}
+
+// killSession closes a session and deletes its ephemeral nodes, children
+// before parents
+func (t *DataTree) killSession(session int64, zxid zkfile.ZXID) {
+	delete(t.sessions, session)
+
+	paths := t.Ephemerals(session)
+	for i := len(paths) - 1; i >= 0; i-- {
+		t.deleteNode(paths[i], zxid)
+	}
+}
+
+// addNode adds a node and links it to its parent
+func (t *DataTree) addNode(node *Node) {
+	if node.children == nil {
+		node.children = make(map[string]struct{})
+	}
+	if old, ok := t.nodes[node.Path]; ok {
+		node.children = old.children
+	}
+	t.nodes[node.Path] = node
+
+	if node.Path != "/" {
+		if parent, ok := t.nodes[parentPath(node.Path)]; ok {
+			parent.children[baseName(node.Path)] = struct{}{}
+		}
+	}
+
+	// Containers and TTL nodes have special owners, not sessions
+	if owner := node.Stat.EphemeralOwner; zkfile.IsSessionOwner(owner) {
+		if t.ephemerals[owner] == nil {
+			t.ephemerals[owner] = make(map[string]struct{})
+		}
+		t.ephemerals[owner][node.Path] = struct{}{}
+	}
+}
+
+// openACLUnsafe returns world:anyone with all permissions
+func openACLUnsafe() []zkfile.ACL {
+	return []zkfile.ACL{{Perms: 31, Scheme: "world", ID: "anyone"}}
+}
+
+// parentPath returns the parent of a path, "/" for top level nodes
+func parentPath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+// baseName returns the last element of a path
+func baseName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// childPath joins a parent path and a child name
+func childPath(parent, name string) string {
+	if parent == "/" {
+		return "/" + name
+	}
+	return parent + "/" + name
+}
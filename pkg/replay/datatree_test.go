@@ -0,0 +1,184 @@
+package replay
+
+import (
+	"bytes"
+	"hash/adler32"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zookeeper-backup/pkg/jute"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+const testSession int64 = 0x1234
+
+var testWorldACL = []zkfile.ACL{{Perms: 31, Scheme: "world", ID: "anyone"}}
+
+// newTestTxn encodes a transaction the way ZooKeeper logs it
+func newTestTxn(t *testing.T, zxid zkfile.ZXID, txnType int32, body jute.Record) *zkfile.Transaction {
+	t.Helper()
+
+	var buf bytes.Buffer
+	out := jute.NewOutputArchive(&buf)
+	out.WriteLong(testSession)
+	out.WriteInt(1)
+	out.WriteLong(int64(zxid))
+	out.WriteLong(int64(zxid) * 1000)
+	out.WriteInt(txnType)
+	if err := out.WriteRecord(body); err != nil {
+		t.Fatalf("WriteRecord() error = %v", err)
+	}
+
+	return &zkfile.Transaction{
+		Length:    int32(buf.Len()),
+		Data:      buf.Bytes(),
+		Checksum:  int64(adler32.Checksum(buf.Bytes())),
+		ClientId:  testSession,
+		Cxid:      1,
+		Zxid:      zxid,
+		Timestamp: int64(zxid) * 1000,
+		Type:      txnType,
+	}
+}
+
+func newTestCreate(t *testing.T, zxid zkfile.ZXID, path, data string, ephemeral bool, parentCVersion int32) *zkfile.Transaction {
+	return newTestTxn(t, zxid, zkfile.TxnTypeCreate, &zkfile.CreateTxn{
+		Path: path, Data: []byte(data), ACL: testWorldACL, Ephemeral: ephemeral, ParentCVersion: parentCVersion})
+}
+
+func applyTestTxns(t *testing.T, tree *DataTree, txns ...*zkfile.Transaction) {
+	t.Helper()
+	for _, txn := range txns {
+		if err := tree.Apply(txn); err != nil {
+			t.Fatalf("Apply(%s) error = %v", txn.Zxid, err)
+		}
+	}
+}
+
+func TestDataTree_Apply(t *testing.T) {
+	tree := NewDataTree()
+	applyTestTxns(t, tree,
+		newTestTxn(t, 0x100000001, zkfile.TxnTypeCreateSession, &zkfile.CreateSessionTxn{Timeout: 30000}),
+		newTestCreate(t, 0x100000002, "/app", "v0", false, 1),
+		newTestCreate(t, 0x100000003, "/app/lock", "", true, 1),
+		newTestTxn(t, 0x100000004, zkfile.TxnTypeSetData, &zkfile.SetDataTxn{Path: "/app", Data: []byte("v1"), Version: 1}),
+		newTestTxn(t, 0x100000005, zkfile.TxnTypeSetACL, &zkfile.SetACLTxn{
+			Path: "/app", ACL: []zkfile.ACL{{Perms: 1, Scheme: "digest", ID: "user:hash"}}, Version: 1}),
+	)
+
+	if tree.Zxid() != 0x100000005 || tree.NodeCount() != 4 {
+		t.Fatalf("Zxid() = %s, NodeCount() = %d, want 0x100000005, 4", tree.Zxid(), tree.NodeCount())
+	}
+	if tree.Sessions()[testSession] != 30000 {
+		t.Errorf("Sessions() = %v, want session 0x1234", tree.Sessions())
+	}
+
+	app, _ := tree.Get("/app")
+	wantStat := zkfile.StatPersisted{Czxid: 0x100000002, Mzxid: 0x100000004, Ctime: 0x100000002 * 1000, Mtime: 0x100000004 * 1000,
+		Version: 1, Cversion: 1, Aversion: 1, Pzxid: 0x100000003}
+	if string(app.Data) != "v1" || app.Stat != wantStat || app.ACL[0].Scheme != "digest" {
+		t.Errorf("/app = %+v, stat %+v", app, app.Stat)
+	}
+	if lock, _ := tree.Get("/app/lock"); lock.Stat.EphemeralOwner != testSession {
+		t.Errorf("/app/lock owner = %x, want %x", lock.Stat.EphemeralOwner, testSession)
+	}
+	if got := tree.Ephemerals(testSession); !reflect.DeepEqual(got, []string{"/app/lock"}) {
+		t.Errorf("Ephemerals() = %v", got)
+	}
+
+	t.Run("close session deletes ephemerals", func(t *testing.T) {
+		applyTestTxns(t, tree, newTestTxn(t, 0x100000006, zkfile.TxnTypeCloseSession, &zkfile.CloseSessionTxn{}))
+
+		if _, ok := tree.Get("/app/lock"); ok {
+			t.Error("/app/lock should be deleted with its session")
+		}
+		if _, ok := tree.Sessions()[testSession]; ok {
+			t.Error("session should be closed")
+		}
+		if app.Stat.Pzxid != 0x100000006 || app.NumChildren() != 0 {
+			t.Errorf("/app pzxid = %s, children = %v", app.Stat.Pzxid, app.Children())
+		}
+	})
+
+	t.Run("multi", func(t *testing.T) {
+		applyTestTxns(t, tree, newTestTxn(t, 0x100000007, zkfile.TxnTypeMulti, &zkfile.MultiTxn{Ops: []*zkfile.MultiOp{
+			{Type: zkfile.TxnTypeCreate, Body: &zkfile.CreateTxn{Path: "/app/a", ACL: testWorldACL, ParentCVersion: 3}},
+			{Type: zkfile.TxnTypeCreate, Body: &zkfile.CreateTxn{Path: "/app/b", ACL: testWorldACL, ParentCVersion: 4}},
+			{Type: zkfile.TxnTypeDelete, Body: &zkfile.DeleteTxn{Path: "/app/a"}},
+		}}))
+
+		if got := app.Children(); !reflect.DeepEqual(got, []string{"b"}) {
+			t.Errorf("/app children = %v, want [b]", got)
+		}
+		if app.Stat.Cversion != 4 {
+			t.Errorf("/app cversion = %d, want 4", app.Stat.Cversion)
+		}
+	})
+}
+
+func TestDataTree_ApplyIdempotent(t *testing.T) {
+	// A fuzzy snapshot already holds /app at its final state and /gone is
+	// deleted; replaying the transactions must not change either
+	tree := NewDataTree()
+	root, _ := tree.Get("/")
+	applyTestTxns(t, tree,
+		newTestCreate(t, 0x100000001, "/app", "v0", false, 1),
+		newTestTxn(t, 0x100000002, zkfile.TxnTypeSetData, &zkfile.SetDataTxn{Path: "/app", Data: []byte("v1"), Version: 1}),
+	)
+	snapshotStat, snapshotRoot := func() (zkfile.StatPersisted, zkfile.StatPersisted) {
+		app, _ := tree.Get("/app")
+		return app.Stat, root.Stat
+	}()
+
+	applyTestTxns(t, tree,
+		newTestCreate(t, 0x100000001, "/app", "v0", false, 1),
+		newTestCreate(t, 0x100000001, "/gone/child", "", false, 1),
+		newTestTxn(t, 0x100000002, zkfile.TxnTypeSetData, &zkfile.SetDataTxn{Path: "/app", Data: []byte("v1"), Version: 1}),
+		newTestTxn(t, 0x100000002, zkfile.TxnTypeDelete, &zkfile.DeleteTxn{Path: "/gone"}),
+	)
+
+	app, _ := tree.Get("/app")
+	if string(app.Data) != "v1" || app.Stat != snapshotStat || root.Stat != snapshotRoot {
+		t.Errorf("/app = %q %+v, root %+v, want unchanged", app.Data, app.Stat, root.Stat)
+	}
+	if tree.NodeCount() != 3 {
+		t.Errorf("NodeCount() = %d, want 3", tree.NodeCount())
+	}
+}
+
+func TestDataTree_Walk(t *testing.T) {
+	tree := NewDataTree()
+	applyTestTxns(t, tree,
+		newTestCreate(t, 0x100000001, "/b", "", false, 1),
+		newTestCreate(t, 0x100000002, "/a", "", false, 2),
+		newTestCreate(t, 0x100000003, "/a/c", "", false, 1),
+	)
+
+	var paths []string
+	if err := tree.Walk("/", func(n *Node) error { paths = append(paths, n.Path); return nil }); err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+	want := []string{"/", "/a", "/a/c", "/b", "/zookeeper"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("Walk() = %v, want %v", paths, want)
+	}
+
+	if err := tree.Walk("/missing", func(*Node) error { return nil }); err == nil {
+		t.Error("Walk() should fail for a missing node")
+	}
+}
+
+func TestLoadSnapshot_MissingACL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.100000001")
+	writeTestSnapshot(t, path,
+		&zkfile.SnapshotNode{Path: "/", ACLRef: zkfile.OpenACLUnsafeRef},
+		&zkfile.SnapshotNode{Path: "/app", ACLRef: 7},
+	)
+
+	_, err := LoadSnapshot(path)
+	if err == nil || !strings.Contains(err.Error(), "acl of node not found") || !strings.Contains(err.Error(), "/app") {
+		t.Errorf("LoadSnapshot() error = %v, want the missing ACL of /app reported", err)
+	}
+}
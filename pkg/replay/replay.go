@@ -0,0 +1,162 @@
+package replay
+
+import (
+	"io"
+	"math"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"github.com/zookeeper-backup/pkg/utils"
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// Latest is the target of a replay through the last transaction
+const Latest = zkfile.ZXID(math.MaxUint64)
+
+// LoadBackup materializes the DataTree of a backup directory at target, see Load
+func LoadBackup(backupDir string, target zkfile.ZXID) (*DataTree, error) {
+	return Load(filepath.Join(backupDir, "snapshots"), filepath.Join(backupDir, "txnlogs"), target)
+}
+
+// Load materializes the DataTree at target from the snapshots and txnlogs in
+// the given directories. It starts from the newest snapshot at or below target
+// that loads and does not already contain changes after target (snapshots are
+// fuzzy), then applies the transactions after the snapshot up to target.
+func Load(snapshotDir, txnlogDir string, target zkfile.ZXID) (*DataTree, error) {
+	logger := utils.GetLogger()
+
+	snapshots, err := zkfile.ListSnapshotFiles(snapshotDir)
+	if err != nil {
+		return nil, err
+	}
+	txnlogs, err := zkfile.ListTxnLogFiles(txnlogDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree *DataTree
+	for i := len(snapshots) - 1; i >= 0 && tree == nil; i-- {
+		zxid, err := zkfile.ParseZxidFromFileName(snapshots[i])
+		if err != nil {
+			return nil, err
+		}
+		if zxid > target {
+			continue
+		}
+
+		candidate, err := LoadSnapshot(snapshots[i])
+		if err != nil {
+			logger.Warn("Skipping snapshot that cannot be loaded", zap.String("file", snapshots[i]), zap.Error(err))
+			continue
+		}
+		if end := candidate.highestZxid(); end > target {
+			logger.Debug("Skipping snapshot with changes after the target",
+				zap.String("file", snapshots[i]), zap.String("highest_zxid", end.String()))
+			continue
+		}
+		tree = candidate
+		logger.Debug("Loaded snapshot", zap.String("file", snapshots[i]), zap.Int("nodes", tree.NodeCount()))
+	}
+	if tree == nil {
+		e := zkfile.NewUserError("no usable snapshot at or below target zxid").WithContext("snapshot_dir", snapshotDir)
+		if target != Latest {
+			e = e.WithContext("target_zxid", target.String())
+		}
+		return nil, e
+	}
+
+	if err = tree.replay(txnlogs, target); err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+// replay applies the transactions of txnlogs after the last processed zxid up
+// to target. ZooKeeper starts with the newest log whose first zxid is at or
+// below the snapshot zxid, because that log holds the transactions after it.
+func (t *DataTree) replay(txnlogs []string, target zkfile.ZXID) error {
+	start := t.zxid
+
+	first := 0
+	for i, path := range txnlogs {
+		zxid, err := zkfile.ParseZxidFromFileName(path)
+		if err != nil {
+			return err
+		}
+		if zxid <= start {
+			first = i
+		}
+	}
+
+	reached := target == Latest || target == start
+	last := start
+	for _, path := range txnlogs[first:] {
+		done, err := t.replayTxnLog(path, target, &last)
+		if err != nil {
+			return err
+		}
+		if done {
+			reached = true
+			break
+		}
+	}
+
+	if !reached && last != target {
+		return zkfile.NewUserError("transactions end before target zxid").
+			WithContext("target_zxid", target.String()).WithContext("last_zxid", last.String())
+	}
+
+	return nil
+}
+
+// replayTxnLog applies the transactions of one txnlog after last, returning
+// true once it read past target. last is updated to the zxid of the last
+// applied transaction and used to find transactions missing from the backup.
+func (t *DataTree) replayTxnLog(path string, target zkfile.ZXID, last *zkfile.ZXID) (bool, error) {
+	reader, err := zkfile.OpenTxnLog(path)
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	for {
+		txn, err := reader.ReadTransaction()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if txn.Zxid <= *last {
+			continue
+		}
+		if txn.Zxid > target {
+			return true, nil
+		}
+
+		// A new epoch starts a new counter
+		if txn.Zxid != *last+1 && txn.Zxid.Epoch() <= last.Epoch() {
+			return false, zkfile.NewCorruptionError("transactions missing from txnlogs").
+				WithContext("path", path).WithContext("after_zxid", last.String()).WithContext("next_zxid", txn.Zxid.String())
+		}
+
+		if err = t.Apply(txn); err != nil {
+			return false, zkfile.NewCorruptionError("failed to apply transaction").WithError(err).
+				WithContext("path", path).WithContext("zxid", txn.Zxid.String())
+		}
+		*last = txn.Zxid
+	}
+}
+
+// highestZxid returns the highest zxid recorded in the stats of the tree, the
+// last change a fuzzy snapshot is known to contain
+func (t *DataTree) highestZxid() zkfile.ZXID {
+	highest := t.zxid
+	for _, node := range t.nodes {
+		highest = max(highest, node.Stat.Czxid, node.Stat.Mzxid, node.Stat.Pzxid)
+	}
+	return highest
+}
@@ -0,0 +1,191 @@
+package replay
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+// writeTestSnapshot writes the nodes as a snapshot
+func writeTestSnapshot(t *testing.T, path string, nodes ...*zkfile.SnapshotNode) {
+	t.Helper()
+
+	header := &zkfile.SnapshotHeader{Magic: zkfile.SnapshotMagic, Version: zkfile.SnapshotVersion}
+	w, err := zkfile.CreateSnapshot(path, header, []zkfile.Session{{ID: testSession, Timeout: 30000}}, nil)
+	if err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+	for _, node := range nodes {
+		if err = w.WriteNode(node); err != nil {
+			t.Fatalf("WriteNode() error = %v", err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+// writeTestTxnLog writes the transactions as a txnlog
+func writeTestTxnLog(t *testing.T, path string, txns ...*zkfile.Transaction) {
+	t.Helper()
+
+	w, err := zkfile.CreateTxnLog(path, &zkfile.TxnLogHeader{Magic: zkfile.MagicNumber, Version: zkfile.LogVersion})
+	if err != nil {
+		t.Fatalf("CreateTxnLog() error = %v", err)
+	}
+	defer w.Close()
+	for _, txn := range txns {
+		if err = w.WriteTransaction(txn); err != nil {
+			t.Fatalf("WriteTransaction() error = %v", err)
+		}
+	}
+}
+
+// createTestBackup creates a backup whose snapshot.100000002 is fuzzy: it was
+// started after 0x100000002 but already holds /app at version 1 (0x100000003)
+func createTestBackup(t *testing.T) string {
+	t.Helper()
+
+	backupDir := t.TempDir()
+	snapshotDir := filepath.Join(backupDir, "snapshots")
+	txnlogDir := filepath.Join(backupDir, "txnlogs")
+	os.MkdirAll(snapshotDir, 0755)
+	os.MkdirAll(txnlogDir, 0755)
+
+	writeTestSnapshot(t, filepath.Join(snapshotDir, "snapshot.0"),
+		&zkfile.SnapshotNode{Path: "/", ACLRef: zkfile.OpenACLUnsafeRef},
+		&zkfile.SnapshotNode{Path: "/zookeeper", ACLRef: zkfile.OpenACLUnsafeRef},
+	)
+	writeTestSnapshot(t, filepath.Join(snapshotDir, "snapshot.100000002"),
+		&zkfile.SnapshotNode{Path: "/", ACLRef: zkfile.OpenACLUnsafeRef,
+			Stat: zkfile.StatPersisted{Cversion: 1, Pzxid: 0x100000001}},
+		&zkfile.SnapshotNode{Path: "/app", Data: []byte("v1"), ACLRef: zkfile.OpenACLUnsafeRef,
+			Stat: zkfile.StatPersisted{Czxid: 0x100000001, Mzxid: 0x100000003, Ctime: 0x100000001 * 1000,
+				Mtime: 0x100000003 * 1000, Version: 1, Cversion: 1, Pzxid: 0x100000002}},
+		&zkfile.SnapshotNode{Path: "/app/lock", ACLRef: zkfile.OpenACLUnsafeRef,
+			Stat: zkfile.StatPersisted{Czxid: 0x100000002, Mzxid: 0x100000002, Ctime: 0x100000002 * 1000,
+				Mtime: 0x100000002 * 1000, EphemeralOwner: testSession, Pzxid: 0x100000002}},
+		&zkfile.SnapshotNode{Path: "/zookeeper", ACLRef: zkfile.OpenACLUnsafeRef},
+	)
+
+	writeTestTxnLog(t, filepath.Join(txnlogDir, "log.100000001"),
+		newTestCreate(t, 0x100000001, "/app", "v0", false, 1),
+		newTestCreate(t, 0x100000002, "/app/lock", "", true, 1),
+		newTestTxn(t, 0x100000003, zkfile.TxnTypeSetData, &zkfile.SetDataTxn{Path: "/app", Data: []byte("v1"), Version: 1}),
+	)
+	writeTestTxnLog(t, filepath.Join(txnlogDir, "log.100000004"),
+		newTestTxn(t, 0x100000004, zkfile.TxnTypeCloseSession, &zkfile.CloseSessionTxn{}),
+		newTestCreate(t, 0x100000005, "/app/b", "b", false, 2),
+	)
+
+	return backupDir
+}
+
+func TestLoadBackup(t *testing.T) {
+	backupDir := createTestBackup(t)
+
+	tests := []struct {
+		name     string
+		target   zkfile.ZXID
+		wantZxid zkfile.ZXID
+		wantData string
+		wantPath []string
+		missing  []string
+	}{
+		{name: "latest", target: Latest, wantZxid: 0x100000005, wantData: "v1",
+			wantPath: []string{"/app", "/app/b"}, missing: []string{"/app/lock"}},
+		{name: "before the fuzzy snapshot ends", target: 0x100000002, wantZxid: 0x100000002, wantData: "v0",
+			wantPath: []string{"/app", "/app/lock"}},
+		{name: "inside the second txnlog", target: 0x100000004, wantZxid: 0x100000004, wantData: "v1",
+			wantPath: []string{"/app"}, missing: []string{"/app/lock", "/app/b"}},
+		{name: "snapshot zero", target: 0, wantZxid: 0, missing: []string{"/app"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := LoadBackup(backupDir, tt.target)
+			if err != nil {
+				t.Fatalf("LoadBackup() error = %v", err)
+			}
+			if tree.Zxid() != tt.wantZxid {
+				t.Errorf("Zxid() = %s, want %s", tree.Zxid(), tt.wantZxid)
+			}
+			for _, path := range tt.wantPath {
+				if _, ok := tree.Get(path); !ok {
+					t.Errorf("%s should exist", path)
+				}
+			}
+			for _, path := range tt.missing {
+				if _, ok := tree.Get(path); ok {
+					t.Errorf("%s should not exist", path)
+				}
+			}
+			if app, ok := tree.Get("/app"); ok && string(app.Data) != tt.wantData {
+				t.Errorf("/app data = %q, want %q", app.Data, tt.wantData)
+			}
+		})
+	}
+
+	t.Run("latest matches the stats of a server", func(t *testing.T) {
+		tree, err := LoadBackup(backupDir, Latest)
+		if err != nil {
+			t.Fatalf("LoadBackup() error = %v", err)
+		}
+		app, _ := tree.Get("/app")
+		want := zkfile.StatPersisted{Czxid: 0x100000001, Mzxid: 0x100000003, Ctime: 0x100000001 * 1000,
+			Mtime: 0x100000003 * 1000, Version: 1, Cversion: 2, Pzxid: 0x100000005}
+		if app.Stat != want {
+			t.Errorf("/app stat = %+v, want %+v", app.Stat, want)
+		}
+		if len(tree.Sessions()) != 0 {
+			t.Errorf("Sessions() = %v, want none", tree.Sessions())
+		}
+	})
+}
+
+func TestLoadBackup_Errors(t *testing.T) {
+	t.Run("target beyond the txnlogs", func(t *testing.T) {
+		_, err := LoadBackup(createTestBackup(t), 0x100000009)
+		if err == nil || !strings.Contains(err.Error(), "transactions end before target zxid") {
+			t.Errorf("LoadBackup() error = %v, want transactions end before target", err)
+		}
+	})
+
+	t.Run("missing transactions", func(t *testing.T) {
+		backupDir := createTestBackup(t)
+		os.Remove(filepath.Join(backupDir, "txnlogs", "log.100000001"))
+
+		_, err := LoadBackup(backupDir, Latest)
+		if err == nil || !strings.Contains(err.Error(), "transactions missing from txnlogs") {
+			t.Errorf("LoadBackup() error = %v, want missing transactions", err)
+		}
+	})
+
+	t.Run("corrupted snapshot falls back to an older one", func(t *testing.T) {
+		backupDir := createTestBackup(t)
+		path := filepath.Join(backupDir, "snapshots", "snapshot.100000002")
+		content, _ := os.ReadFile(path)
+		os.WriteFile(path, content[:len(content)-1], 0644)
+
+		tree, err := LoadBackup(backupDir, Latest)
+		if err != nil {
+			t.Fatalf("LoadBackup() error = %v", err)
+		}
+		if _, ok := tree.Get("/app/b"); !ok || tree.Zxid() != 0x100000005 {
+			t.Errorf("Zxid() = %s, want the full replay from snapshot.0", tree.Zxid())
+		}
+	})
+
+	t.Run("no snapshot", func(t *testing.T) {
+		backupDir := createTestBackup(t)
+		os.RemoveAll(filepath.Join(backupDir, "snapshots"))
+		os.MkdirAll(filepath.Join(backupDir, "snapshots"), 0755)
+
+		if _, err := LoadBackup(backupDir, Latest); err == nil {
+			t.Error("LoadBackup() should fail without a snapshot")
+		}
+	})
+}
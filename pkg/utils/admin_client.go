@@ -0,0 +1,315 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+const (
+	// DefaultAdminPort is the default port of the ZooKeeper AdminServer
+	DefaultAdminPort = "8080"
+
+	// SnapshotLastZxidHeader carries the last ZXID of a streamed snapshot
+	SnapshotLastZxidHeader = "last_zxid"
+
+	// maxAdminResponse bounds the response size of an AdminServer command
+	maxAdminResponse = 4 * 1024 * 1024
+)
+
+// AdminClient reads server state from the AdminServer HTTP API (/commands/<name>).
+// It implements StatsBackend and is available when four-letter words are disabled.
+type AdminClient struct {
+	baseURL string
+	auth    string // Authorization header value, e.g. "digest root:secret"
+	timeout time.Duration
+	client  *http.Client
+}
+
+// adminSrvrResponse is the JSON body of /commands/srvr
+type adminSrvrResponse struct {
+	Version     string `json:"version"`
+	NodeCount   int64  `json:"node_count"`
+	ServerStats struct {
+		LastProcessedZxid         int64   `json:"last_processed_zxid"`
+		ServerState               string  `json:"server_state"`
+		AvgLatency                float64 `json:"avg_latency"`
+		MaxLatency                float64 `json:"max_latency"`
+		MinLatency                float64 `json:"min_latency"`
+		PacketsReceived           int64   `json:"packets_received"`
+		PacketsSent               int64   `json:"packets_sent"`
+		NumAliveClientConnections int64   `json:"num_alive_client_connections"`
+		OutstandingRequests       int64   `json:"outstanding_requests"`
+	} `json:"server_stats"`
+}
+
+// NewAdminClient creates an AdminServer client for a base URL such as http://zk-0:8080.
+// A zero timeout selects DefaultFourLetterTimeout.
+func NewAdminClient(baseURL string, timeout time.Duration) *AdminClient {
+	if timeout <= 0 {
+		timeout = DefaultFourLetterTimeout
+	}
+	return &AdminClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		timeout: timeout,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// SetAuth sets the Authorization header sent with every command.
+// ZooKeeper 3.9 requires authentication for commands such as snapshot.
+func (c *AdminClient) SetAuth(auth string) {
+	c.auth = auth
+}
+
+// DefaultAdminURL returns the AdminServer URL on the default port of a ZooKeeper host
+func DefaultAdminURL(host string) string {
+	hostname, _, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname = host
+	}
+	return "http://" + net.JoinHostPort(hostname, DefaultAdminPort)
+}
+
+// Name returns the backend name
+func (c *AdminClient) Name() string {
+	return StatsBackendAdmin
+}
+
+// Run runs an AdminServer command and returns the raw JSON response
+func (c *AdminClient) Run(command string) ([]byte, error) {
+	url := c.baseURL + "/commands/" + command
+	resp, err := c.get(context.Background(), c.client, url)
+	if err != nil {
+		return nil, zkfile.NewZooKeeperError("failed to call admin server").WithError(err).WithContext("url", url)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAdminResponse))
+	if err != nil {
+		return nil, zkfile.NewZooKeeperError("failed to read admin server response").WithError(err).WithContext("url", url)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, zkfile.NewZooKeeperError("admin server command failed").
+			WithContext("url", url).WithContext("status", resp.Status)
+	}
+
+	// Every command reports failures in its "error" field
+	var result struct {
+		Error *string `json:"error"`
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		return nil, zkfile.NewZooKeeperError("invalid admin server response").WithError(err).WithContext("url", url)
+	}
+	if result.Error != nil {
+		return nil, zkfile.NewZooKeeperError("admin server command failed").
+			WithContext("url", url).WithContext("error", *result.Error)
+	}
+
+	return body, nil
+}
+
+// StreamSnapshot takes a fresh snapshot with the snapshot command (ZooKeeper 3.9+)
+// and streams it into w. It returns the last ZXID contained in the snapshot and
+// the number of bytes written.
+func (c *AdminClient) StreamSnapshot(w io.Writer) (zkfile.ZXID, int64, error) {
+	url := c.baseURL + "/commands/snapshot?streaming=true"
+
+	// The timeout bounds the wait for the response and for each read of the
+	// body, not the whole transfer
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: c.timeout}).DialContext,
+		ResponseHeaderTimeout: c.timeout,
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resp, err := c.get(ctx, client, url)
+	if err != nil {
+		return 0, 0, zkfile.NewZooKeeperError("failed to call admin server").WithError(err).WithContext("url", url)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, 0, zkfile.NewZooKeeperError("snapshot command failed").
+			WithContext("url", url).WithContext("status", resp.Status).
+			WithContext("response", strings.TrimSpace(string(body)))
+	}
+
+	lastZxid := resp.Header.Get(SnapshotLastZxidHeader)
+	if lastZxid == "" {
+		return 0, 0, zkfile.NewZooKeeperError("snapshot response has no last_zxid header, streaming requires ZooKeeper 3.9+").
+			WithContext("url", url)
+	}
+	zxid, err := zkfile.ParseZXID(lastZxid)
+	if err != nil {
+		return 0, 0, zkfile.NewZooKeeperError("invalid last_zxid header").WithError(err).WithContext("url", url)
+	}
+
+	// A server that stops sending cancels the request instead of hanging the backup
+	watchdog := time.AfterFunc(c.timeout, cancel)
+	n, err := io.Copy(w, &idleReader{r: resp.Body, watchdog: watchdog, timeout: c.timeout})
+	if !watchdog.Stop() && err != nil {
+		return 0, n, zkfile.NewZooKeeperError("snapshot stream stalled").WithError(err).
+			WithContext("url", url).WithContext("bytes", n).WithContext("idle_timeout", c.timeout.String())
+	}
+	if err != nil {
+		return 0, n, zkfile.NewZooKeeperError("failed to stream snapshot").WithError(err).
+			WithContext("url", url).WithContext("bytes", n)
+	}
+
+	return zxid, n, nil
+}
+
+// idleReader restarts the watchdog whenever data arrives
+type idleReader struct {
+	r        io.Reader
+	watchdog *time.Timer
+	timeout  time.Duration
+}
+
+// Read reads from the underlying reader and restarts the watchdog on progress
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.watchdog.Reset(r.timeout)
+	}
+	return n, err
+}
+
+// get sends a GET request with the configured Authorization header
+func (c *AdminClient) get(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.auth != "" {
+		req.Header.Set("Authorization", c.auth)
+	}
+	return client.Do(req)
+}
+
+// Srvr runs the srvr command and converts it to ServerStats
+func (c *AdminClient) Srvr() (*ServerStats, error) {
+	body, err := c.Run("srvr")
+	if err != nil {
+		return nil, err
+	}
+	return ParseAdminSrvr(body)
+}
+
+// Mntr runs the monitor command and converts it to MntrStats
+func (c *AdminClient) Mntr() (*MntrStats, error) {
+	body, err := c.Run("monitor")
+	if err != nil {
+		return nil, err
+	}
+	return ParseAdminMonitor(body)
+}
+
+// Conf runs the configuration command and converts it to ServerConf
+func (c *AdminClient) Conf() (*ServerConf, error) {
+	body, err := c.Run("configuration")
+	if err != nil {
+		return nil, err
+	}
+	return ParseAdminConfiguration(body)
+}
+
+// ParseAdminSrvr parses the JSON response of /commands/srvr
+func ParseAdminSrvr(body []byte) (*ServerStats, error) {
+	var resp adminSrvrResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, zkfile.NewZooKeeperError("invalid srvr response").WithError(err)
+	}
+	if resp.ServerStats.ServerState == "" {
+		return nil, zkfile.NewZooKeeperError("server stats not found in srvr response")
+	}
+
+	return &ServerStats{
+		Version:     trimBuildInfo(resp.Version),
+		MinLatency:  resp.ServerStats.MinLatency,
+		AvgLatency:  resp.ServerStats.AvgLatency,
+		MaxLatency:  resp.ServerStats.MaxLatency,
+		Received:    resp.ServerStats.PacketsReceived,
+		Sent:        resp.ServerStats.PacketsSent,
+		Connections: resp.ServerStats.NumAliveClientConnections,
+		Outstanding: resp.ServerStats.OutstandingRequests,
+		Zxid:        zkfile.ZXID(resp.ServerStats.LastProcessedZxid),
+		Mode:        resp.ServerStats.ServerState,
+		NodeCount:   resp.NodeCount,
+	}, nil
+}
+
+// ParseAdminMonitor parses the JSON response of /commands/monitor.
+// Keys get the zk_ prefix of mntr so both backends fill Values the same way.
+func ParseAdminMonitor(body []byte) (*MntrStats, error) {
+	values, err := parseAdminValues(body)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixed := make(map[string]string, len(values))
+	for key, value := range values {
+		prefixed["zk_"+key] = value
+	}
+
+	return newMntrStats(prefixed)
+}
+
+// ParseAdminConfiguration parses the JSON response of /commands/configuration
+func ParseAdminConfiguration(body []byte) (*ServerConf, error) {
+	values, err := parseAdminValues(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServerConf{
+		ClientPort: int(parseInt(values["client_port"])),
+		DataDir:    values["data_dir"],
+		DataLogDir: values["data_log_dir"],
+		Values:     values,
+	}, nil
+}
+
+// parseAdminValues flattens the top level fields of a command response into strings.
+// The command and error fields are dropped; nested objects are kept as JSON.
+func parseAdminValues(body []byte) (map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var raw map[string]interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, zkfile.NewZooKeeperError("invalid admin server response").WithError(err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if key == "command" || key == "error" {
+			continue
+		}
+		switch v := value.(type) {
+		case nil:
+			values[key] = ""
+		case string:
+			values[key] = v
+		case json.Number, bool:
+			values[key] = fmt.Sprint(v)
+		default:
+			data, _ := json.Marshal(v)
+			values[key] = string(data)
+		}
+	}
+
+	return values, nil
+}
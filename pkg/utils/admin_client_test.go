@@ -0,0 +1,333 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+const testAdminSrvrResponse = `{
+  "version" : "3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC",
+  "read_only" : false,
+  "server_stats" : {
+    "packets_sent" : 1205,
+    "packets_received" : 1204,
+    "max_latency" : 12,
+    "min_latency" : 0,
+    "avg_latency" : 0.4321,
+    "last_processed_zxid" : 21474836483,
+    "server_state" : "leader",
+    "num_alive_client_connections" : 3,
+    "outstanding_requests" : 0
+  },
+  "node_count" : 42,
+  "command" : "srvr",
+  "error" : null
+}`
+
+const testAdminMonitorResponse = `{
+  "version" : "3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC",
+  "avg_latency" : 0.4321,
+  "max_latency" : 12,
+  "min_latency" : 0,
+  "packets_received" : 1204,
+  "packets_sent" : 1205,
+  "num_alive_connections" : 3,
+  "outstanding_requests" : 0,
+  "server_state" : "leader",
+  "znode_count" : 42,
+  "watch_count" : 7,
+  "ephemerals_count" : 2,
+  "approximate_data_size" : 1048576,
+  "followers" : 2,
+  "synced_followers" : 2,
+  "command" : "monitor",
+  "error" : null
+}`
+
+const testAdminConfigurationResponse = `{
+  "client_port" : 2181,
+  "data_dir" : "/data/version-2",
+  "data_log_dir" : "/datalog/version-2",
+  "tick_time" : 2000,
+  "server_id" : 1,
+  "command" : "configuration",
+  "error" : null
+}`
+
+// startFakeAdminServer serves canned AdminServer responses under /commands/.
+// Unknown commands are answered with a command error like the real server.
+func startFakeAdminServer(t *testing.T, responses map[string]string) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		command := strings.TrimPrefix(r.URL.Path, "/commands/")
+		response, ok := responses[command]
+		if !ok {
+			response = `{"command":null,"error":"Unknown command: ` + command + `"}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+func TestAdminClient_Commands(t *testing.T) {
+	url := startFakeAdminServer(t, map[string]string{
+		"srvr":          testAdminSrvrResponse,
+		"monitor":       testAdminMonitorResponse,
+		"configuration": testAdminConfigurationResponse,
+	})
+	client := NewAdminClient(url+"/", time.Second)
+
+	t.Run("srvr", func(t *testing.T) {
+		stats, err := client.Srvr()
+		if err != nil {
+			t.Fatalf("Srvr() error = %v", err)
+		}
+		if stats.Zxid != zkfile.ZXID(0x500000003) {
+			t.Errorf("Zxid = %v, want 0x500000003", stats.Zxid)
+		}
+		if stats.Version != "3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7" {
+			t.Errorf("Version = %q", stats.Version)
+		}
+		if stats.Mode != "leader" || stats.NodeCount != 42 || stats.Connections != 3 {
+			t.Errorf("Mode = %q, NodeCount = %d, Connections = %d", stats.Mode, stats.NodeCount, stats.Connections)
+		}
+		if stats.AvgLatency != 0.4321 || stats.MaxLatency != 12 {
+			t.Errorf("latency = %v/%v/%v", stats.MinLatency, stats.AvgLatency, stats.MaxLatency)
+		}
+	})
+
+	t.Run("monitor", func(t *testing.T) {
+		stats, err := client.Mntr()
+		if err != nil {
+			t.Fatalf("Mntr() error = %v", err)
+		}
+		if stats.ServerState != "leader" || stats.ZnodeCount != 42 || stats.Followers != 2 {
+			t.Errorf("ServerState = %q, ZnodeCount = %d, Followers = %d", stats.ServerState, stats.ZnodeCount, stats.Followers)
+		}
+		if stats.ApproximateDataSize != 1048576 {
+			t.Errorf("ApproximateDataSize = %d, want 1048576", stats.ApproximateDataSize)
+		}
+		if stats.Values["zk_watch_count"] != "7" {
+			t.Errorf("Values[zk_watch_count] = %q, want 7", stats.Values["zk_watch_count"])
+		}
+		if _, ok := stats.Values["zk_command"]; ok {
+			t.Error("Values should not contain the command field")
+		}
+	})
+
+	t.Run("configuration", func(t *testing.T) {
+		conf, err := client.Conf()
+		if err != nil {
+			t.Fatalf("Conf() error = %v", err)
+		}
+		if conf.ClientPort != 2181 || conf.DataDir != "/data/version-2" || conf.DataLogDir != "/datalog/version-2" {
+			t.Errorf("Conf() = %+v", conf)
+		}
+		if conf.Values["tick_time"] != "2000" {
+			t.Errorf("Values[tick_time] = %q, want 2000", conf.Values["tick_time"])
+		}
+	})
+
+	t.Run("command error", func(t *testing.T) {
+		_, err := client.Run("snapshot")
+		if err == nil || !strings.Contains(err.Error(), "admin server command failed") {
+			t.Errorf("Run() error = %v, want command failure", err)
+		}
+	})
+}
+
+func TestAdminClient_Errors(t *testing.T) {
+	t.Run("http status", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+
+		_, err := NewAdminClient(server.URL, time.Second).Srvr()
+		if err == nil {
+			t.Error("Srvr() should fail on a non-200 response")
+		}
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		url := startFakeAdminServer(t, map[string]string{"srvr": "not json"})
+
+		_, err := NewAdminClient(url, time.Second).Srvr()
+		if err == nil {
+			t.Error("Srvr() should fail on invalid JSON")
+		}
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		_, err := NewAdminClient("http://127.0.0.1:1", time.Second).Srvr()
+		var backupErr *zkfile.BackupError
+		if !errors.As(err, &backupErr) || backupErr.Category != zkfile.ErrorCategoryZooKeeper {
+			t.Errorf("Srvr() error = %v, want ZooKeeper error", err)
+		}
+	})
+}
+
+func TestDefaultAdminURL(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"zk-0:2181", "http://zk-0:8080"},
+		{"zk-0", "http://zk-0:8080"},
+		{"[::1]:2181", "http://[::1]:8080"},
+	}
+
+	for _, tt := range tests {
+		if got := DefaultAdminURL(tt.host); got != tt.want {
+			t.Errorf("DefaultAdminURL(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestNewStatsBackend(t *testing.T) {
+	srvrHost := startFakeFourLetterServer(t, map[string]string{"srvr": testSrvrResponse})
+	disabledHost := startFakeFourLetterServer(t, map[string]string{})
+	adminURL := startFakeAdminServer(t, map[string]string{"srvr": testAdminSrvrResponse})
+
+	t.Run("unsupported backend", func(t *testing.T) {
+		if _, err := NewStatsBackend("jmx", srvrHost, "", time.Second); err == nil {
+			t.Error("NewStatsBackend() should reject unknown backends")
+		}
+	})
+
+	t.Run("4lw only", func(t *testing.T) {
+		backend, err := NewStatsBackend(StatsBackendFourLetter, disabledHost, adminURL, time.Second)
+		if err != nil {
+			t.Fatalf("NewStatsBackend() error = %v", err)
+		}
+		if _, err = backend.Srvr(); !errors.Is(err, ErrNotWhitelisted) {
+			t.Errorf("Srvr() error = %v, want ErrNotWhitelisted", err)
+		}
+	})
+
+	t.Run("admin only", func(t *testing.T) {
+		backend, err := NewStatsBackend(StatsBackendAdmin, "127.0.0.1:1", adminURL, time.Second)
+		if err != nil {
+			t.Fatalf("NewStatsBackend() error = %v", err)
+		}
+		stats, err := backend.Srvr()
+		if err != nil || stats.Zxid != zkfile.ZXID(0x500000003) {
+			t.Errorf("Srvr() = %v, %v", stats, err)
+		}
+	})
+
+	t.Run("auto falls back to admin", func(t *testing.T) {
+		backend, err := NewStatsBackend(StatsBackendAuto, disabledHost, adminURL, time.Second)
+		if err != nil {
+			t.Fatalf("NewStatsBackend() error = %v", err)
+		}
+		stats, err := backend.Srvr()
+		if err != nil {
+			t.Fatalf("Srvr() error = %v", err)
+		}
+		if stats.Mode != "leader" || stats.NodeCount != 42 {
+			t.Errorf("Srvr() = %+v, want admin stats", stats)
+		}
+	})
+
+	t.Run("auto reports every failure", func(t *testing.T) {
+		backend, _ := NewStatsBackend(StatsBackendAuto, disabledHost, "http://127.0.0.1:1", time.Second)
+		_, err := backend.Srvr()
+		if err == nil {
+			t.Fatal("Srvr() should fail when both backends fail")
+		}
+		if !errors.Is(err, ErrNotWhitelisted) || !strings.Contains(err.Error(), "admin server") {
+			t.Errorf("Srvr() error = %v, want both backend errors", err)
+		}
+	})
+}
+
+func TestAdminClient_StreamSnapshot(t *testing.T) {
+	snapshot := append([]byte("ZKSN"), make([]byte, 64*1024)...)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path != "/commands/snapshot" || r.URL.Query().Get("streaming") != "true":
+			http.NotFound(w, r)
+		case r.Header.Get("Authorization") != "digest root:secret":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"command":"snapshot","error":"Forbidden"}`))
+		default:
+			w.Header().Set(SnapshotLastZxidHeader, "0x500000003")
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(snapshot)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("streams snapshot", func(t *testing.T) {
+		client := NewAdminClient(server.URL, time.Second)
+		client.SetAuth("digest root:secret")
+
+		var buf bytes.Buffer
+		zxid, n, err := client.StreamSnapshot(&buf)
+		if err != nil {
+			t.Fatalf("StreamSnapshot() error = %v", err)
+		}
+		if zxid != zkfile.ZXID(0x500000003) {
+			t.Errorf("zxid = %v, want 0x500000003", zxid)
+		}
+		if n != int64(len(snapshot)) || !bytes.Equal(buf.Bytes(), snapshot) {
+			t.Errorf("streamed %d bytes, want %d", n, len(snapshot))
+		}
+	})
+
+	t.Run("auth failure", func(t *testing.T) {
+		_, _, err := NewAdminClient(server.URL, time.Second).StreamSnapshot(io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "Forbidden") {
+			t.Errorf("StreamSnapshot() error = %v, want forbidden", err)
+		}
+	})
+
+	t.Run("missing last_zxid header", func(t *testing.T) {
+		old := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(snapshot)
+		}))
+		defer old.Close()
+
+		_, _, err := NewAdminClient(old.URL, time.Second).StreamSnapshot(io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "last_zxid") {
+			t.Errorf("StreamSnapshot() error = %v, want missing header", err)
+		}
+	})
+
+	t.Run("server stalls after the headers", func(t *testing.T) {
+		stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(SnapshotLastZxidHeader, "0x500000003")
+			w.Write(snapshot[:1024])
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(10 * time.Second):
+			}
+		}))
+		defer stalled.Close()
+
+		start := time.Now()
+		_, n, err := NewAdminClient(stalled.URL, 100*time.Millisecond).StreamSnapshot(io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "snapshot stream stalled") {
+			t.Fatalf("StreamSnapshot() error = %v, want stalled", err)
+		}
+		if n != 1024 {
+			t.Errorf("streamed %d bytes, want the 1024 sent before the stall", n)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("StreamSnapshot() took %v, want it to give up after the idle timeout", elapsed)
+		}
+	})
+}
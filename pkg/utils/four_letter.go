@@ -0,0 +1,348 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+const (
+	// DefaultFourLetterTimeout is the timeout of a four-letter word command
+	DefaultFourLetterTimeout = 5 * time.Second
+
+	// maxFourLetterResponse bounds the response size of a four-letter word command
+	maxFourLetterResponse = 4 * 1024 * 1024
+)
+
+// ErrNotWhitelisted is returned when a command is not in 4lw.commands.whitelist
+var ErrNotWhitelisted = errors.New("command is not in 4lw.commands.whitelist")
+
+// FourLetterClient sends four-letter word commands over a raw TCP connection.
+// It implements StatsBackend.
+type FourLetterClient struct {
+	host    string
+	timeout time.Duration
+}
+
+// MntrStats is the parsed output of the mntr command
+type MntrStats struct {
+	Version                 string
+	ServerState             string
+	AvgLatency              float64
+	MaxLatency              float64
+	MinLatency              float64
+	PacketsReceived         int64
+	PacketsSent             int64
+	NumAliveConnections     int64
+	OutstandingRequests     int64
+	ZnodeCount              int64
+	WatchCount              int64
+	EphemeralsCount         int64
+	ApproximateDataSize     int64
+	OpenFileDescriptorCount int64
+	MaxFileDescriptorCount  int64
+	Followers               int64
+	SyncedFollowers         int64
+	PendingSyncs            int64
+	Zxid                    zkfile.ZXID // zk_zxid, only reported by some versions
+	Values                  map[string]string
+}
+
+// ServerStats is the parsed output of the srvr and stat commands
+type ServerStats struct {
+	Version     string
+	MinLatency  float64
+	AvgLatency  float64
+	MaxLatency  float64
+	Received    int64
+	Sent        int64
+	Connections int64
+	Outstanding int64
+	Zxid        zkfile.ZXID
+	Mode        string
+	NodeCount   int64
+	Clients     []string // stat only
+}
+
+// ServerConf is the parsed output of the conf command
+type ServerConf struct {
+	ClientPort int
+	DataDir    string
+	DataLogDir string
+	Values     map[string]string
+}
+
+// NewFourLetterClient creates a four-letter word client for host:port.
+// A zero timeout selects DefaultFourLetterTimeout.
+func NewFourLetterClient(host string, timeout time.Duration) *FourLetterClient {
+	if timeout <= 0 {
+		timeout = DefaultFourLetterTimeout
+	}
+	return &FourLetterClient{host: host, timeout: timeout}
+}
+
+// Run sends a four-letter word command and returns the raw response
+func (c *FourLetterClient) Run(command string) (string, error) {
+	if len(command) != 4 {
+		return "", zkfile.NewUserError("invalid four-letter word").WithContext("command", command)
+	}
+
+	conn, err := net.DialTimeout("tcp", c.host, c.timeout)
+	if err != nil {
+		return "", zkfile.NewZooKeeperError("failed to connect").WithError(err).
+			WithContext("host", c.host).WithContext("command", command)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err = conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return "", zkfile.NewZooKeeperError("failed to set deadline").WithError(err).WithContext("host", c.host)
+	}
+
+	if _, err = conn.Write([]byte(command)); err != nil {
+		return "", zkfile.NewZooKeeperError("failed to send command").WithError(err).
+			WithContext("host", c.host).WithContext("command", command)
+	}
+
+	// The server closes the connection after the response
+	data, err := io.ReadAll(io.LimitReader(conn, maxFourLetterResponse))
+	if err != nil {
+		return "", zkfile.NewZooKeeperError("failed to read response").WithError(err).
+			WithContext("host", c.host).WithContext("command", command)
+	}
+
+	response := string(data)
+	if strings.Contains(response, "not in the whitelist") {
+		return "", zkfile.NewZooKeeperError("four-letter word is disabled on server").WithError(ErrNotWhitelisted).
+			WithContext("host", c.host).WithContext("command", command)
+	}
+
+	return response, nil
+}
+
+// Name returns the backend name
+func (c *FourLetterClient) Name() string {
+	return StatsBackendFourLetter
+}
+
+// Ruok reports whether the server answers imok
+func (c *FourLetterClient) Ruok() (bool, error) {
+	response, err := c.Run("ruok")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(response) == "imok", nil
+}
+
+// Mntr runs mntr and parses its tab separated key/value lines
+func (c *FourLetterClient) Mntr() (*MntrStats, error) {
+	response, err := c.Run("mntr")
+	if err != nil {
+		return nil, err
+	}
+	return ParseMntr(response)
+}
+
+// Srvr runs srvr and parses the server details
+func (c *FourLetterClient) Srvr() (*ServerStats, error) {
+	response, err := c.Run("srvr")
+	if err != nil {
+		return nil, err
+	}
+	return ParseServerStats(response)
+}
+
+// Stat runs stat and parses the server details and client list
+func (c *FourLetterClient) Stat() (*ServerStats, error) {
+	response, err := c.Run("stat")
+	if err != nil {
+		return nil, err
+	}
+	return ParseServerStats(response)
+}
+
+// Conf runs conf and parses the server configuration
+func (c *FourLetterClient) Conf() (*ServerConf, error) {
+	response, err := c.Run("conf")
+	if err != nil {
+		return nil, err
+	}
+	return ParseConf(response), nil
+}
+
+// Envi runs envi and returns the server environment
+func (c *FourLetterClient) Envi() (map[string]string, error) {
+	response, err := c.Run("envi")
+	if err != nil {
+		return nil, err
+	}
+	return parseKeyValues(response, "="), nil
+}
+
+// ParseMntr parses the output of mntr
+func ParseMntr(response string) (*MntrStats, error) {
+	values := make(map[string]string)
+	for _, line := range strings.Split(response, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "\t", 2)
+		if len(parts) == 2 {
+			values[parts[0]] = strings.TrimSpace(parts[1])
+		}
+	}
+	if len(values) == 0 {
+		return nil, zkfile.NewZooKeeperError("empty mntr response")
+	}
+
+	return newMntrStats(values)
+}
+
+// newMntrStats builds MntrStats from zk_ prefixed monitor values
+func newMntrStats(values map[string]string) (*MntrStats, error) {
+	stats := &MntrStats{
+		Version:                 trimBuildInfo(values["zk_version"]),
+		ServerState:             values["zk_server_state"],
+		AvgLatency:              parseFloat(values["zk_avg_latency"]),
+		MaxLatency:              parseFloat(values["zk_max_latency"]),
+		MinLatency:              parseFloat(values["zk_min_latency"]),
+		PacketsReceived:         parseInt(values["zk_packets_received"]),
+		PacketsSent:             parseInt(values["zk_packets_sent"]),
+		NumAliveConnections:     parseInt(values["zk_num_alive_connections"]),
+		OutstandingRequests:     parseInt(values["zk_outstanding_requests"]),
+		ZnodeCount:              parseInt(values["zk_znode_count"]),
+		WatchCount:              parseInt(values["zk_watch_count"]),
+		EphemeralsCount:         parseInt(values["zk_ephemerals_count"]),
+		ApproximateDataSize:     parseInt(values["zk_approximate_data_size"]),
+		OpenFileDescriptorCount: parseInt(values["zk_open_file_descriptor_count"]),
+		MaxFileDescriptorCount:  parseInt(values["zk_max_file_descriptor_count"]),
+		Followers:               parseInt(values["zk_followers"]),
+		SyncedFollowers:         parseInt(values["zk_synced_followers"]),
+		PendingSyncs:            parseInt(values["zk_pending_syncs"]),
+		Values:                  values,
+	}
+
+	if zxid, ok := values["zk_zxid"]; ok {
+		parsed, err := zkfile.ParseZXID(zxid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse zxid: %w", err)
+		}
+		stats.Zxid = parsed
+	}
+
+	return stats, nil
+}
+
+// ParseServerStats parses the output of srvr or stat
+func ParseServerStats(response string) (*ServerStats, error) {
+	stats := &ServerStats{}
+	found := false
+
+	scanner := bufio.NewScanner(strings.NewReader(response))
+	inClients := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if inClients {
+			if line == "" {
+				inClients = false
+			} else {
+				stats.Clients = append(stats.Clients, line)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "Zookeeper version":
+			stats.Version = trimBuildInfo(value)
+		case "Clients":
+			inClients = true
+		case "Latency min/avg/max":
+			latency := strings.Split(value, "/")
+			if len(latency) == 3 {
+				stats.MinLatency = parseFloat(latency[0])
+				stats.AvgLatency = parseFloat(latency[1])
+				stats.MaxLatency = parseFloat(latency[2])
+			}
+		case "Received":
+			stats.Received = parseInt(value)
+		case "Sent":
+			stats.Sent = parseInt(value)
+		case "Connections":
+			stats.Connections = parseInt(value)
+		case "Outstanding":
+			stats.Outstanding = parseInt(value)
+		case "Zxid":
+			zxid, err := zkfile.ParseZXID(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse zxid: %w", err)
+			}
+			stats.Zxid = zxid
+			found = true
+		case "Mode":
+			stats.Mode = value
+		case "Node count":
+			stats.NodeCount = parseInt(value)
+		}
+	}
+
+	if !found {
+		return nil, zkfile.NewZooKeeperError("zxid not found in server stats")
+	}
+
+	return stats, nil
+}
+
+// ParseConf parses the output of conf
+func ParseConf(response string) *ServerConf {
+	values := parseKeyValues(response, "=")
+
+	port, _ := strconv.Atoi(values["clientPort"])
+	return &ServerConf{
+		ClientPort: port,
+		DataDir:    values["dataDir"],
+		DataLogDir: values["dataLogDir"],
+		Values:     values,
+	}
+}
+
+// parseKeyValues parses key<sep>value lines, ignoring lines without sep
+func parseKeyValues(response, sep string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(response, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), sep)
+		if ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// trimBuildInfo strips the ", built on ..." suffix of a version string
+func trimBuildInfo(version string) string {
+	if i := strings.Index(version, ", built on"); i >= 0 {
+		return version[:i]
+	}
+	return version
+}
+
+// parseInt parses an integer stat, returning 0 when absent or malformed
+func parseInt(value string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	return n
+}
+
+// parseFloat parses a float stat, returning 0 when absent or malformed
+func parseFloat(value string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return f
+}
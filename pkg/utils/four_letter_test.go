@@ -0,0 +1,271 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+const testSrvrResponse = `Zookeeper version: 3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC
+Latency min/avg/max: 0/0.4567/12
+Received: 1234
+Sent: 1233
+Connections: 2
+Outstanding: 0
+Zxid: 0x500000003
+Mode: leader
+Node count: 42
+Proposal sizes last/min/max: 48/32/120
+`
+
+const testStatResponse = `Zookeeper version: 3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC
+Clients:
+ /127.0.0.1:54321[1](queued=0,recved=10,sent=10)
+ /127.0.0.1:54322[0](queued=0,recved=1,sent=0)
+
+Latency min/avg/max: 0/1.5/3
+Received: 11
+Sent: 10
+Connections: 2
+Outstanding: 0
+Zxid: 0x10000002a
+Mode: follower
+Node count: 7
+`
+
+const testMntrResponse = "zk_version\t3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC\n" +
+	"zk_server_state\tleader\n" +
+	"zk_avg_latency\t0.4567\n" +
+	"zk_max_latency\t12\n" +
+	"zk_min_latency\t0\n" +
+	"zk_packets_received\t1234\n" +
+	"zk_packets_sent\t1233\n" +
+	"zk_num_alive_connections\t2\n" +
+	"zk_outstanding_requests\t0\n" +
+	"zk_znode_count\t42\n" +
+	"zk_watch_count\t3\n" +
+	"zk_ephemerals_count\t1\n" +
+	"zk_approximate_data_size\t2048\n" +
+	"zk_open_file_descriptor_count\t64\n" +
+	"zk_max_file_descriptor_count\t1048576\n" +
+	"zk_followers\t2\n" +
+	"zk_synced_followers\t2\n" +
+	"zk_pending_syncs\t0\n"
+
+const testConfResponse = `clientPort=2181
+secureClientPort=-1
+dataDir=/data/version-2
+dataDirSize=1024
+dataLogDir=/datalog/version-2
+dataLogSize=2048
+tickTime=2000
+server.1=zk-0:2888:3888:participant
+`
+
+const testEnviResponse = `Environment:
+zookeeper.version=3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC
+host.name=zk-0
+java.version=11.0.18
+`
+
+// startFakeFourLetterServer serves canned four-letter word responses on a local port.
+// Commands without a response are answered like a server that has not whitelisted them.
+func startFakeFourLetterServer(t *testing.T, responses map[string]string) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				command := make([]byte, 4)
+				if _, err := io.ReadFull(conn, command); err != nil {
+					return
+				}
+				response, ok := responses[string(command)]
+				if !ok {
+					response = fmt.Sprintf("%s is not executed because it is not in the whitelist.\n", command)
+				}
+				conn.Write([]byte(response))
+			}(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestFourLetterClient_Commands(t *testing.T) {
+	host := startFakeFourLetterServer(t, map[string]string{
+		"ruok": "imok",
+		"srvr": testSrvrResponse,
+		"stat": testStatResponse,
+		"mntr": testMntrResponse,
+		"conf": testConfResponse,
+		"envi": testEnviResponse,
+	})
+	client := NewFourLetterClient(host, time.Second)
+
+	t.Run("ruok", func(t *testing.T) {
+		ok, err := client.Ruok()
+		if err != nil || !ok {
+			t.Errorf("Ruok() = %v, %v, want true", ok, err)
+		}
+	})
+
+	t.Run("srvr", func(t *testing.T) {
+		stats, err := client.Srvr()
+		if err != nil {
+			t.Fatalf("Srvr() error = %v", err)
+		}
+		if stats.Zxid != zkfile.ZXID(0x500000003) {
+			t.Errorf("Zxid = %v, want 0x500000003", stats.Zxid)
+		}
+		if stats.Version != "3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7" {
+			t.Errorf("Version = %q", stats.Version)
+		}
+		if stats.Mode != "leader" || stats.NodeCount != 42 || stats.Received != 1234 || stats.Connections != 2 {
+			t.Errorf("Srvr() = %+v", stats)
+		}
+		if stats.AvgLatency != 0.4567 || stats.MaxLatency != 12 {
+			t.Errorf("Latency = %v/%v/%v", stats.MinLatency, stats.AvgLatency, stats.MaxLatency)
+		}
+	})
+
+	t.Run("stat", func(t *testing.T) {
+		stats, err := client.Stat()
+		if err != nil {
+			t.Fatalf("Stat() error = %v", err)
+		}
+		if len(stats.Clients) != 2 || !strings.HasPrefix(stats.Clients[0], "/127.0.0.1:54321") {
+			t.Errorf("Clients = %v", stats.Clients)
+		}
+		if stats.Zxid != zkfile.ZXID(0x10000002a) || stats.Mode != "follower" || stats.AvgLatency != 1.5 {
+			t.Errorf("Stat() = %+v", stats)
+		}
+	})
+
+	t.Run("mntr", func(t *testing.T) {
+		stats, err := client.Mntr()
+		if err != nil {
+			t.Fatalf("Mntr() error = %v", err)
+		}
+		if stats.ServerState != "leader" || stats.ZnodeCount != 42 || stats.Followers != 2 ||
+			stats.MaxFileDescriptorCount != 1048576 || stats.AvgLatency != 0.4567 {
+			t.Errorf("Mntr() = %+v", stats)
+		}
+		if stats.Version != "3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7" {
+			t.Errorf("Version = %q", stats.Version)
+		}
+		if stats.Zxid != 0 {
+			t.Errorf("Zxid = %v, want 0 when zk_zxid is not reported", stats.Zxid)
+		}
+	})
+
+	t.Run("conf", func(t *testing.T) {
+		conf, err := client.Conf()
+		if err != nil {
+			t.Fatalf("Conf() error = %v", err)
+		}
+		if conf.ClientPort != 2181 || conf.DataDir != "/data/version-2" || conf.DataLogDir != "/datalog/version-2" {
+			t.Errorf("Conf() = %+v", conf)
+		}
+		if conf.Values["server.1"] != "zk-0:2888:3888:participant" {
+			t.Errorf("server.1 = %q", conf.Values["server.1"])
+		}
+	})
+
+	t.Run("envi", func(t *testing.T) {
+		env, err := client.Envi()
+		if err != nil {
+			t.Fatalf("Envi() error = %v", err)
+		}
+		if env["host.name"] != "zk-0" || env["java.version"] != "11.0.18" {
+			t.Errorf("Envi() = %v", env)
+		}
+	})
+}
+
+func TestFourLetterClient_Errors(t *testing.T) {
+	t.Run("not whitelisted", func(t *testing.T) {
+		host := startFakeFourLetterServer(t, map[string]string{"srvr": testSrvrResponse})
+
+		_, err := NewFourLetterClient(host, time.Second).Mntr()
+		if !errors.Is(err, ErrNotWhitelisted) {
+			t.Errorf("Mntr() error = %v, want ErrNotWhitelisted", err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "mntr") {
+			t.Errorf("Error should name the command: %v", err)
+		}
+	})
+
+	t.Run("invalid command", func(t *testing.T) {
+		if _, err := NewFourLetterClient("127.0.0.1:1", time.Second).Run("status"); err == nil {
+			t.Error("Run() should reject commands that are not four letters")
+		}
+	})
+
+	t.Run("connection refused", func(t *testing.T) {
+		listener, _ := net.Listen("tcp", "127.0.0.1:0")
+		host := listener.Addr().String()
+		listener.Close()
+
+		if _, err := NewFourLetterClient(host, time.Second).Ruok(); err == nil {
+			t.Error("Ruok() should fail when the server is down")
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen() error = %v", err)
+		}
+		defer listener.Close()
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			<-done
+			conn.Close()
+		}()
+
+		start := time.Now()
+		_, err = NewFourLetterClient(listener.Addr().String(), 100*time.Millisecond).Run("srvr")
+		if err == nil {
+			t.Error("Run() should time out when the server does not answer")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Run() took %v, want about 100ms", elapsed)
+		}
+	})
+
+	t.Run("srvr without zxid", func(t *testing.T) {
+		if _, err := ParseServerStats("Mode: standalone\n"); err == nil {
+			t.Error("ParseServerStats() should fail without a Zxid line")
+		}
+	})
+
+	t.Run("empty mntr", func(t *testing.T) {
+		if _, err := ParseMntr(""); err == nil {
+			t.Error("ParseMntr() should fail for an empty response")
+		}
+	})
+}
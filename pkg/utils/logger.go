@@ -50,6 +50,12 @@ func Fatal(msg string, fields ...zap.Field) {
 
 // InitLogger initializes the global logger
 func InitLogger(level string, format string) error {
+	return InitLoggerWithOutput(level, format, "")
+}
+
+// InitLoggerWithOutput initializes the global logger writing to output:
+// stdout, stderr or a file path. An empty output keeps the zap default (stderr).
+func InitLoggerWithOutput(level, format, output string) error {
 	var config zap.Config
 
 	if format == "json" {
@@ -66,6 +72,9 @@ func InitLogger(level string, format string) error {
 		zapLevel = zapcore.InfoLevel
 	}
 	config.Level = zap.NewAtomicLevelAt(zapLevel)
+	if output != "" {
+		config.OutputPaths = []string{output}
+	}
 
 	logger, err := config.Build()
 	if err != nil {
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"github.com/zookeeper-backup/pkg/zkfile"
+)
+
+const (
+	// StatsBackendAuto tries four-letter words first and falls back to the AdminServer
+	StatsBackendAuto = "auto"
+
+	// StatsBackendFourLetter reads server state with four-letter words over TCP
+	StatsBackendFourLetter = "4lw"
+
+	// StatsBackendAdmin reads server state from the AdminServer HTTP API
+	StatsBackendAdmin = "admin"
+)
+
+// StatsBackend reads version, ZXID, state and configuration of a ZooKeeper server
+type StatsBackend interface {
+	// Name returns the backend name
+	Name() string
+
+	// Srvr returns the server details, including version, ZXID and mode
+	Srvr() (*ServerStats, error)
+
+	// Mntr returns the monitoring values of the server
+	Mntr() (*MntrStats, error)
+
+	// Conf returns the server configuration
+	Conf() (*ServerConf, error)
+}
+
+// ValidateStatsBackend checks a stats backend name. An empty name selects auto.
+func ValidateStatsBackend(name string) error {
+	switch name {
+	case "", StatsBackendAuto, StatsBackendFourLetter, StatsBackendAdmin:
+		return nil
+	default:
+		return zkfile.NewConfigurationError("unsupported stats backend").WithContext("backend", name)
+	}
+}
+
+// NewStatsBackend creates the stats backend for a server.
+// An empty adminURL is derived from host with DefaultAdminURL.
+func NewStatsBackend(name, host, adminURL string, timeout time.Duration) (StatsBackend, error) {
+	if err := ValidateStatsBackend(name); err != nil {
+		return nil, err
+	}
+	if adminURL == "" {
+		adminURL = DefaultAdminURL(host)
+	}
+
+	switch name {
+	case StatsBackendFourLetter:
+		return NewFourLetterClient(host, timeout), nil
+	case StatsBackendAdmin:
+		return NewAdminClient(adminURL, timeout), nil
+	default:
+		return &fallbackBackend{backends: []StatsBackend{
+			NewFourLetterClient(host, timeout),
+			NewAdminClient(adminURL, timeout),
+		}}, nil
+	}
+}
+
+// fallbackBackend tries each backend in order until one succeeds
+type fallbackBackend struct {
+	backends []StatsBackend
+}
+
+// Name returns the backend name
+func (f *fallbackBackend) Name() string {
+	return StatsBackendAuto
+}
+
+// Srvr returns the server details from the first working backend
+func (f *fallbackBackend) Srvr() (*ServerStats, error) {
+	var stats *ServerStats
+	err := f.try(func(b StatsBackend) (err error) {
+		stats, err = b.Srvr()
+		return err
+	})
+	return stats, err
+}
+
+// Mntr returns the monitoring values from the first working backend
+func (f *fallbackBackend) Mntr() (*MntrStats, error) {
+	var stats *MntrStats
+	err := f.try(func(b StatsBackend) (err error) {
+		stats, err = b.Mntr()
+		return err
+	})
+	return stats, err
+}
+
+// Conf returns the server configuration from the first working backend
+func (f *fallbackBackend) Conf() (*ServerConf, error) {
+	var conf *ServerConf
+	err := f.try(func(b StatsBackend) (err error) {
+		conf, err = b.Conf()
+		return err
+	})
+	return conf, err
+}
+
+// try calls fn with each backend until one succeeds
+func (f *fallbackBackend) try(fn func(StatsBackend) error) error {
+	var errs []error
+	for _, backend := range f.backends {
+		err := fn(backend)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+
+	return zkfile.NewZooKeeperError("all stats backends failed").WithError(errors.Join(errs...))
+}
@@ -1,9 +1,9 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
-	"strconv"
-	"strings"
+	"sort"
 	"time"
 
 	"github.com/go-zookeeper/zk"
@@ -13,8 +13,11 @@ import (
 
 // ZKClient wraps zookeeper client
 type ZKClient struct {
-	host string
-	conn *zk.Conn
+	host     string
+	conn     *zk.Conn
+	timeout  time.Duration
+	backend  string // stats backend: auto, 4lw or admin; empty selects auto
+	adminURL string // AdminServer URL, derived from host when empty
 }
 
 // NewZKClient creates a new ZooKeeper client
@@ -24,7 +27,7 @@ func NewZKClient(host string, timeout time.Duration) (*ZKClient, error) {
 		return nil, fmt.Errorf("failed to connect to zookeeper: %w", err)
 	}
 
-	return &ZKClient{conn: conn, host: host}, nil
+	return &ZKClient{conn: conn, host: host, timeout: timeout}, nil
 }
 
 // Close closes the client connection
@@ -34,69 +37,209 @@ func (c *ZKClient) Close() {
 	}
 }
 
+// SetStatsBackend selects how version, ZXID and server state are read.
+// An empty adminURL uses the default AdminServer port on the client host.
+func (c *ZKClient) SetStatsBackend(backend, adminURL string) error {
+	if err := ValidateStatsBackend(backend); err != nil {
+		return err
+	}
+	c.backend = backend
+	c.adminURL = adminURL
+	return nil
+}
+
 // IsAlive checks if ZooKeeper is alive
 func (c *ZKClient) IsAlive() bool {
 	_, _, err := c.conn.Get("/")
 	return err == nil
 }
 
+// Errors of znode operations
+var (
+	ErrNoNode     = zk.ErrNoNode
+	ErrNodeExists = zk.ErrNodeExists
+)
+
+// Znode is a znode read from a live ensemble
+type Znode struct {
+	Path     string
+	Data     []byte
+	Stat     zkfile.StatPersisted
+	ACL      []zkfile.ACL
+	Children []string // names, sorted
+}
+
+// GetZnode reads the data, stat, ACL and children of a znode. A znode deleted
+// while it is read returns ErrNoNode.
+func (c *ZKClient) GetZnode(path string) (*Znode, error) {
+	data, stat, err := c.conn.Get(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", path, err)
+	}
+	acl, _, err := c.conn.GetACL(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ACL of %s: %w", path, err)
+	}
+	children, _, err := c.conn.Children(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get children of %s: %w", path, err)
+	}
+	sort.Strings(children)
+
+	znode := &Znode{
+		Path: path,
+		Data: data,
+		Stat: zkfile.StatPersisted{
+			Czxid:          zkfile.ZXID(stat.Czxid),
+			Mzxid:          zkfile.ZXID(stat.Mzxid),
+			Ctime:          stat.Ctime,
+			Mtime:          stat.Mtime,
+			Version:        stat.Version,
+			Cversion:       stat.Cversion,
+			Aversion:       stat.Aversion,
+			EphemeralOwner: stat.EphemeralOwner,
+			Pzxid:          zkfile.ZXID(stat.Pzxid),
+		},
+		ACL:      make([]zkfile.ACL, len(acl)),
+		Children: children,
+	}
+	for i, a := range acl {
+		znode.ACL[i] = zkfile.ACL{Perms: a.Perms, Scheme: a.Scheme, ID: a.ID}
+	}
+
+	return znode, nil
+}
+
+// ZnodeOpType is the type of a ZnodeOp
+type ZnodeOpType int
+
+const (
+	// ZnodeOpCreate creates a persistent znode
+	ZnodeOpCreate ZnodeOpType = iota
+	// ZnodeOpSetData sets the data of any version of a znode
+	ZnodeOpSetData
+	// ZnodeOpDelete deletes any version of a znode without children
+	ZnodeOpDelete
+)
+
+// ZnodeOp is an operation of a Multi batch
+type ZnodeOp struct {
+	Type ZnodeOpType
+	Path string
+	Data []byte
+	ACL  []zkfile.ACL // ACL of a created znode
+}
+
+// Multi applies ops atomically: either all of them succeed or none does
+func (c *ZKClient) Multi(ops ...ZnodeOp) error {
+	requests := make([]interface{}, len(ops))
+	for i, op := range ops {
+		switch op.Type {
+		case ZnodeOpCreate:
+			requests[i] = &zk.CreateRequest{Path: op.Path, Data: op.Data, Acl: toZkACL(op.ACL)}
+		case ZnodeOpSetData:
+			requests[i] = &zk.SetDataRequest{Path: op.Path, Data: op.Data, Version: -1}
+		case ZnodeOpDelete:
+			requests[i] = &zk.DeleteRequest{Path: op.Path, Version: -1}
+		default:
+			return fmt.Errorf("unsupported znode operation: %d", op.Type)
+		}
+	}
+
+	responses, err := c.conn.Multi(requests...)
+	// The failed operation carries its error, the others a runtime inconsistency
+	for i, response := range responses {
+		if isOperationError(response.Error) {
+			return fmt.Errorf("failed to apply %s: %w", ops[i].Path, response.Error)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply multi: %w", err)
+	}
+	return nil
+}
+
+// SetACL sets the ACL of any version of a znode
+func (c *ZKClient) SetACL(path string, acl []zkfile.ACL) error {
+	if _, err := c.conn.SetACL(path, toZkACL(acl), -1); err != nil {
+		return fmt.Errorf("failed to set ACL of %s: %w", path, err)
+	}
+	return nil
+}
+
+// AddAuth adds authentication info, e.g. scheme "digest" and auth "user:password"
+func (c *ZKClient) AddAuth(scheme, auth string) error {
+	if err := c.conn.AddAuth(scheme, []byte(auth)); err != nil {
+		return fmt.Errorf("failed to add %s auth: %w", scheme, err)
+	}
+	return nil
+}
+
+// toZkACL converts ACLs to the client type
+func toZkACL(acl []zkfile.ACL) []zk.ACL {
+	converted := make([]zk.ACL, len(acl))
+	for i, a := range acl {
+		converted[i] = zk.ACL{Perms: a.Perms, Scheme: a.Scheme, ID: a.ID}
+	}
+	return converted
+}
+
+// isOperationError reports whether err is the error of the operation that made a multi fail
+func isOperationError(err error) bool {
+	for _, opErr := range []error{zk.ErrNoNode, zk.ErrNodeExists, zk.ErrNoAuth, zk.ErrBadVersion,
+		zk.ErrInvalidACL, zk.ErrNoChildrenForEphemerals, zk.ErrNotEmpty, zk.ErrBadArguments} {
+		if errors.Is(err, opErr) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetVersion retrieves ZooKeeper version
 func (c *ZKClient) GetVersion() (string, error) {
-	stats, err := c.getStats("mntr")
+	stats, err := c.GetServerStats()
 	if err != nil {
 		return "", err
 	}
 
-	for _, line := range strings.Split(stats, "\n") {
-		if strings.HasPrefix(line, "zk_version") {
-			parts := strings.SplitN(line, "\t", 2)
-			if len(parts) >= 2 {
-				return strings.TrimSpace(parts[1]), nil
-			}
-		}
+	if stats.Version == "" {
+		return "unknown", nil
 	}
-
-	return "unknown", nil
+	return stats.Version, nil
 }
 
 // GetCurrentZXID retrieves the current ZXID from ZooKeeper
 func (c *ZKClient) GetCurrentZXID() (zkfile.ZXID, error) {
-	// Use the 'mntr' four-letter word command
-	stats, err := c.getStats("mntr")
+	stats, err := c.GetServerStats()
 	if err != nil {
 		return 0, err
 	}
 
-	// Parse zk_zxid from stats
-	for _, line := range strings.Split(stats, "\n") {
-		if strings.HasPrefix(line, "zk_zxid") {
-			parts := strings.Fields(line)
-			if len(parts) >= 2 {
-				// ZXID is in hex format 0x...
-				zxidStr := strings.TrimPrefix(parts[1], "0x")
-				zxid, err := strconv.ParseUint(zxidStr, 16, 64)
-				if err != nil {
-					return 0, fmt.Errorf("failed to parse zxid: %w", err)
-				}
-				return zkfile.ZXID(zxid), nil
-			}
-		}
-	}
+	return stats.Zxid, nil
+}
 
-	return 0, fmt.Errorf("zk_zxid not found in mntr output")
+// GetServerStats retrieves version, ZXID and server state using the srvr command.
+// srvr is the only four-letter word whitelisted by default since ZooKeeper 3.5;
+// the auto backend falls back to the AdminServer when it is disabled.
+func (c *ZKClient) GetServerStats() (*ServerStats, error) {
+	backend, err := c.statsBackend()
+	if err != nil {
+		return nil, err
+	}
+	return backend.Srvr()
 }
 
-// GetStats retrieves ZooKeeper stats using four-letter word command
+// GetStats retrieves raw ZooKeeper stats using a four-letter word command
 func (c *ZKClient) GetStats(command string) (string, error) {
-	return c.getStats(command)
+	return c.fourLetter().Run(command)
 }
 
-// getStats internal method to get stats
-func (c *ZKClient) getStats(command string) (string, error) {
-	// Note: go-zookeeper library doesn't directly support four-letter words
-	// We need to use raw TCP connection for this
-	// For now, return a placeholder
-	// In production, you'd implement raw TCP connection to send four-letter words
+// statsBackend returns the configured stats backend for the server
+func (c *ZKClient) statsBackend() (StatsBackend, error) {
+	return NewStatsBackend(c.backend, c.host, c.adminURL, c.timeout)
+}
 
-	return "", fmt.Errorf("four-letter words not implemented in this client")
+// fourLetter returns a four-letter word client for the server
+func (c *ZKClient) fourLetter() *FourLetterClient {
+	return NewFourLetterClient(c.host, c.timeout)
 }
@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/zookeeper-backup/pkg/zkfile"
 )
@@ -23,38 +25,97 @@ func TestZKClient_IsAlive(t *testing.T) {
 }
 
 func TestZKClient_GetCurrentZXID(t *testing.T) {
-	t.Run("placeholder implementation", func(t *testing.T) {
-		// Current implementation returns error
-		// This test documents the current behavior
+	t.Run("from srvr", func(t *testing.T) {
+		host := startFakeFourLetterServer(t, map[string]string{"srvr": testSrvrResponse})
+		client := &ZKClient{host: host, timeout: time.Second}
+
+		zxid, err := client.GetCurrentZXID()
+		if err != nil {
+			t.Fatalf("GetCurrentZXID() error = %v", err)
+		}
+		if zxid != zkfile.ZXID(0x500000003) {
+			t.Errorf("GetCurrentZXID() = %v, want 0x500000003", zxid)
+		}
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
 		client := &ZKClient{
 			conn: nil,
 			host: "test:2181",
 		}
 
 		_, err := client.GetCurrentZXID()
-		// Current implementation returns error for four-letter words
 		if err == nil {
-			t.Error("GetCurrentZXID() should return error for unimplemented four-letter words")
+			t.Error("GetCurrentZXID() should return error when the server is unreachable")
 		}
 	})
 }
 
 func TestZKClient_GetVersion(t *testing.T) {
-	t.Run("placeholder implementation", func(t *testing.T) {
-		client := &ZKClient{
-			conn: nil,
-			host: "test:2181",
+	t.Run("from srvr", func(t *testing.T) {
+		host := startFakeFourLetterServer(t, map[string]string{"srvr": testSrvrResponse})
+		client := &ZKClient{host: host, timeout: time.Second}
+
+		version, err := client.GetVersion()
+		if err != nil {
+			t.Fatalf("GetVersion() error = %v", err)
 		}
+		if version != "3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7" {
+			t.Errorf("GetVersion() = %q", version)
+		}
+	})
+
+	t.Run("srvr not whitelisted", func(t *testing.T) {
+		host := startFakeFourLetterServer(t, map[string]string{})
+		client := &ZKClient{host: host, timeout: time.Second}
 
 		_, err := client.GetVersion()
-		if err == nil {
-			t.Error("GetVersion() should return error for unimplemented four-letter words")
+		if !errors.Is(err, ErrNotWhitelisted) {
+			t.Errorf("GetVersion() error = %v, want ErrNotWhitelisted", err)
+		}
+	})
+}
+
+func TestZKClient_SetStatsBackend(t *testing.T) {
+	t.Run("admin backend", func(t *testing.T) {
+		adminURL := startFakeAdminServer(t, map[string]string{"srvr": testAdminSrvrResponse})
+		client := &ZKClient{host: "127.0.0.1:1", timeout: time.Second}
+		if err := client.SetStatsBackend(StatsBackendAdmin, adminURL); err != nil {
+			t.Fatalf("SetStatsBackend() error = %v", err)
+		}
+
+		zxid, err := client.GetCurrentZXID()
+		if err != nil {
+			t.Fatalf("GetCurrentZXID() error = %v", err)
+		}
+		if zxid != zkfile.ZXID(0x500000003) {
+			t.Errorf("GetCurrentZXID() = %v, want 0x500000003", zxid)
+		}
+	})
+
+	t.Run("unsupported backend", func(t *testing.T) {
+		client := &ZKClient{host: "test:2181"}
+		if err := client.SetStatsBackend("jmx", ""); err == nil {
+			t.Error("SetStatsBackend() should reject unknown backends")
 		}
 	})
 }
 
 func TestZKClient_GetStats(t *testing.T) {
-	t.Run("placeholder implementation", func(t *testing.T) {
+	t.Run("raw command output", func(t *testing.T) {
+		host := startFakeFourLetterServer(t, map[string]string{"mntr": testMntrResponse})
+		client := &ZKClient{host: host, timeout: time.Second}
+
+		stats, err := client.GetStats("mntr")
+		if err != nil {
+			t.Fatalf("GetStats() error = %v", err)
+		}
+		if stats != testMntrResponse {
+			t.Errorf("GetStats() = %q, want %q", stats, testMntrResponse)
+		}
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
 		client := &ZKClient{
 			conn: nil,
 			host: "test:2181",
@@ -62,7 +123,7 @@ func TestZKClient_GetStats(t *testing.T) {
 
 		_, err := client.GetStats("mntr")
 		if err == nil {
-			t.Error("GetStats() should return error for unimplemented four-letter words")
+			t.Error("GetStats() should return error when the server is unreachable")
 		}
 	})
 }
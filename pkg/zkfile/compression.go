@@ -0,0 +1,314 @@
+package zkfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// CompressionNone stores files as they are
+	CompressionNone = "none"
+
+	// CompressionGzip compresses files with gzip
+	CompressionGzip = "gzip"
+
+	// CompressionZstd compresses files with zstd
+	CompressionZstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ValidateCompression checks a compression codec and level.
+// Level 0 selects the codec default; gzip accepts 1-9 and zstd 1-22.
+func ValidateCompression(codec string, level int) error {
+	maxLevel := 0
+	switch codec {
+	case CompressionNone, "":
+	case CompressionGzip:
+		maxLevel = gzip.BestCompression
+	case CompressionZstd:
+		maxLevel = 22
+	default:
+		return NewConfigurationError("unsupported compression").WithContext("compression", codec)
+	}
+
+	if level < 0 || level > maxLevel {
+		return NewConfigurationError("invalid compression level").
+			WithContext("compression", codec).WithContext("level", level).WithContext("max", maxLevel)
+	}
+
+	return nil
+}
+
+// DetectCompression returns the codec of a file from its leading magic bytes.
+// ZooKeeper snapshots and txnlogs start with their own magic, so a raw file is never
+// mistaken for a compressed one.
+func DetectCompression(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", NewIOError("failed to open file").WithError(err).WithContext("path", path)
+	}
+	defer func() { _ = f.Close() }()
+
+	magic := make([]byte, len(zstdMagic))
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", NewIOError("failed to read file").WithError(err).WithContext("path", path)
+	}
+
+	return detectMagic(magic[:n]), nil
+}
+
+// detectMagic returns the codec matching the leading bytes of a file
+func detectMagic(magic []byte) string {
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(magic, zstdMagic):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+// CompressFile compresses src into dst with the given codec and level and
+// returns the size of dst. CompressionNone copies the file as it is.
+func CompressFile(src, dst, codec string, level int) (int64, error) {
+	if err := ValidateCompression(codec, level); err != nil {
+		return 0, err
+	}
+	if codec == CompressionNone || codec == "" {
+		if err := CopyFile(src, dst); err != nil {
+			return 0, err
+		}
+		info, err := GetFileInfo(dst)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size, nil
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return 0, NewIOError("failed to open source file").WithError(err).WithContext("src", src)
+	}
+	defer func() { _ = srcFile.Close() }()
+
+	if err = EnsureDir(filepath.Dir(dst)); err != nil {
+		return 0, err
+	}
+
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return 0, NewIOError("failed to create destination file").WithError(err).WithContext("dst", dst)
+	}
+	defer func() { _ = dstFile.Close() }()
+
+	encoder, err := newEncoder(dstFile, codec, level)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = io.Copy(encoder, srcFile); err != nil {
+		_ = encoder.Close()
+		return 0, NewIOError("failed to compress file").WithError(err).WithContext("src", src).WithContext("dst", dst)
+	}
+	if err = encoder.Close(); err != nil {
+		return 0, NewIOError("failed to finish compression").WithError(err).WithContext("dst", dst)
+	}
+	if err = dstFile.Sync(); err != nil {
+		return 0, NewIOError("failed to sync file").WithError(err).WithContext("dst", dst)
+	}
+
+	info, err := dstFile.Stat()
+	if err != nil {
+		return 0, NewIOError("failed to stat file").WithError(err).WithContext("dst", dst)
+	}
+
+	return info.Size(), nil
+}
+
+// DecompressFile writes the decompressed content of src to dst.
+// Uncompressed files are copied as they are.
+func DecompressFile(src, dst string) error {
+	reader, err := OpenDecompressed(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = reader.Close() }()
+
+	if err = EnsureDir(filepath.Dir(dst)); err != nil {
+		return err
+	}
+
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return NewIOError("failed to create destination file").WithError(err).WithContext("dst", dst)
+	}
+	defer func() { _ = dstFile.Close() }()
+
+	if _, err = io.Copy(dstFile, reader); err != nil {
+		return NewCorruptionError("failed to decompress file").WithError(err).WithContext("src", src)
+	}
+	if err = dstFile.Sync(); err != nil {
+		return NewIOError("failed to sync file").WithError(err).WithContext("dst", dst)
+	}
+
+	return nil
+}
+
+// OpenDecompressed opens a file for reading its decompressed content
+func OpenDecompressed(path string) (io.ReadCloser, error) {
+	decompressed, err := openDecompressed(path)
+	if err != nil {
+		return nil, err
+	}
+	return decompressed, nil
+}
+
+// openDecompressed opens a file like OpenDecompressed and keeps its codec
+func openDecompressed(path string) (*decompressedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, NewIOError("failed to open file").WithError(err).WithContext("path", path)
+	}
+
+	reader, codec, err := newDecoder(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, NewCorruptionError("failed to open compressed file").WithError(err).WithContext("path", path)
+	}
+
+	decompressed := &decompressedFile{Reader: reader, file: f, codec: codec}
+	if closer, ok := reader.(io.Closer); ok {
+		decompressed.decoder = closer
+	}
+
+	return decompressed, nil
+}
+
+// recompressLike compresses the raw file at path in place with the codec of original
+func recompressLike(original, path string) error {
+	codec, err := DetectCompression(original)
+	if err != nil || codec == CompressionNone {
+		return err
+	}
+
+	compressed := path + ".tmp"
+	if _, err = CompressFile(path, compressed, codec, 0); err != nil {
+		_ = os.Remove(compressed)
+		return err
+	}
+	if err = os.Rename(compressed, path); err != nil {
+		_ = os.Remove(compressed)
+		return NewIOError("failed to replace file").WithError(err).WithContext("path", path)
+	}
+
+	return nil
+}
+
+// readContent writes the decompressed content of path to w and returns its size
+func readContent(path string, w io.Writer) (int64, error) {
+	reader, err := OpenDecompressed(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	n, err := io.Copy(w, reader)
+	if err != nil {
+		return n, NewCorruptionError("failed to read file content").WithError(err).WithContext("path", path)
+	}
+
+	return n, nil
+}
+
+// GetCompressionInfo returns the codec of a file and the size of its content.
+// For compressed files the content is decompressed to measure it.
+func GetCompressionInfo(path string) (codec string, contentSize int64, err error) {
+	if codec, err = DetectCompression(path); err != nil {
+		return "", 0, err
+	}
+
+	if codec == CompressionNone {
+		info, err := GetFileInfo(path)
+		if err != nil {
+			return "", 0, err
+		}
+		return codec, info.Size, nil
+	}
+
+	contentSize, err = readContent(path, io.Discard)
+	return codec, contentSize, err
+}
+
+// decompressedFile closes the decoder and the underlying file together
+type decompressedFile struct {
+	io.Reader
+	file    *os.File
+	decoder io.Closer
+	codec   string
+}
+
+// Close closes the decoder and the file
+func (d *decompressedFile) Close() error {
+	if d.decoder != nil {
+		_ = d.decoder.Close()
+	}
+	return d.file.Close()
+}
+
+// newDecoder wraps r with the decoder matching its magic bytes and returns the codec
+func newDecoder(r io.Reader) (io.Reader, string, error) {
+	buffered := bufio.NewReader(r)
+	magic, _ := buffered.Peek(len(zstdMagic))
+
+	switch codec := detectMagic(magic); codec {
+	case CompressionGzip:
+		decoder, err := gzip.NewReader(buffered)
+		return decoder, codec, err
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(buffered, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, codec, err
+		}
+		return decoder.IOReadCloser(), codec, nil
+	default:
+		return buffered, codec, nil
+	}
+}
+
+// newEncoder wraps w with the encoder of codec
+func newEncoder(w io.Writer, codec string, level int) (io.WriteCloser, error) {
+	switch codec {
+	case CompressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		encoder, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, NewConfigurationError("invalid gzip level").WithError(err).WithContext("level", level)
+		}
+		return encoder, nil
+	case CompressionZstd:
+		options := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if level != 0 {
+			options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		encoder, err := zstd.NewWriter(w, options...)
+		if err != nil {
+			return nil, NewConfigurationError("invalid zstd level").WithError(err).WithContext("level", level)
+		}
+		return encoder, nil
+	default:
+		return nil, NewConfigurationError("unsupported compression").WithContext("compression", codec)
+	}
+}
@@ -0,0 +1,171 @@
+package zkfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateCompression(t *testing.T) {
+	tests := []struct {
+		name    string
+		codec   string
+		level   int
+		wantErr bool
+	}{
+		{name: "none", codec: CompressionNone},
+		{name: "empty codec", codec: ""},
+		{name: "gzip default level", codec: CompressionGzip},
+		{name: "gzip best", codec: CompressionGzip, level: 9},
+		{name: "gzip level too high", codec: CompressionGzip, level: 10, wantErr: true},
+		{name: "zstd max level", codec: CompressionZstd, level: 22},
+		{name: "negative level", codec: CompressionZstd, level: -1, wantErr: true},
+		{name: "none with level", codec: CompressionNone, level: 3, wantErr: true},
+		{name: "unsupported codec", codec: "lz4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCompression(tt.codec, tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCompression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompressFile_RoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "snapshot.100")
+	content := append([]byte("ZKSN"), make([]byte, 64*1024)...)
+	os.WriteFile(src, content, 0644)
+
+	for _, codec := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		t.Run(codec, func(t *testing.T) {
+			compressed := filepath.Join(tmpDir, codec, "snapshot.100")
+			size, err := CompressFile(src, compressed, codec, 0)
+			if err != nil {
+				t.Fatalf("CompressFile() error = %v", err)
+			}
+			if codec != CompressionNone && size >= int64(len(content)) {
+				t.Errorf("Compressed size = %d, want less than %d", size, len(content))
+			}
+
+			detected, err := DetectCompression(compressed)
+			if err != nil {
+				t.Fatalf("DetectCompression() error = %v", err)
+			}
+			if detected != codec {
+				t.Errorf("DetectCompression() = %v, want %v", detected, codec)
+			}
+
+			gotCodec, contentSize, err := GetCompressionInfo(compressed)
+			if err != nil {
+				t.Fatalf("GetCompressionInfo() error = %v", err)
+			}
+			if gotCodec != codec || contentSize != int64(len(content)) {
+				t.Errorf("GetCompressionInfo() = %v, %d, want %v, %d", gotCodec, contentSize, codec, len(content))
+			}
+
+			restored := filepath.Join(tmpDir, codec, "restored")
+			if err = DecompressFile(compressed, restored); err != nil {
+				t.Fatalf("DecompressFile() error = %v", err)
+			}
+			got, _ := os.ReadFile(restored)
+			if !bytes.Equal(got, content) {
+				t.Error("Decompressed content does not match original")
+			}
+
+			original, _ := CalculateFileChecksum(src)
+			checksum, err := CalculateFileChecksum(compressed)
+			if err != nil {
+				t.Fatalf("CalculateFileChecksum() error = %v", err)
+			}
+			if checksum != original {
+				t.Errorf("Checksum of compressed file = %v, want content checksum %v", checksum, original)
+			}
+		})
+	}
+}
+
+func TestDecompressFile_Corrupted(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "snapshot.100")
+	os.WriteFile(src, bytes.Repeat([]byte("zookeeper"), 1024), 0644)
+
+	compressed := filepath.Join(tmpDir, "snapshot.100.gz")
+	if _, err := CompressFile(src, compressed, CompressionGzip, 0); err != nil {
+		t.Fatalf("CompressFile() error = %v", err)
+	}
+	data, _ := os.ReadFile(compressed)
+	os.WriteFile(compressed, data[:len(data)/2], 0644)
+
+	if err := DecompressFile(compressed, filepath.Join(tmpDir, "restored")); err == nil {
+		t.Error("DecompressFile() should fail for a truncated gzip file")
+	}
+	if err := ValidateSnapshot(compressed); err == nil {
+		t.Error("ValidateSnapshot() should fail for a truncated gzip file")
+	}
+}
+
+func TestTxnLog_Compressed(t *testing.T) {
+	tmpDir := t.TempDir()
+	raw := filepath.Join(tmpDir, "raw", "log.100")
+	os.MkdirAll(filepath.Dir(raw), 0755)
+	createTestTxnLog(t, raw, 12345, []testTransaction{
+		{ClientId: 1, Cxid: 1, Zxid: ZXID(0x100), Timestamp: 1000, Type: 1},
+		{ClientId: 1, Cxid: 2, Zxid: ZXID(0x101), Timestamp: 2000, Type: 1},
+		{ClientId: 1, Cxid: 3, Zxid: ZXID(0x102), Timestamp: 3000, Type: 1},
+	})
+	// Trailing garbage after the last transaction
+	f, _ := os.OpenFile(raw, os.O_APPEND|os.O_WRONLY, 0644)
+	f.Write([]byte{0x01, 0x02, 0x03})
+	f.Close()
+
+	for _, codec := range []string{CompressionGzip, CompressionZstd} {
+		t.Run(codec, func(t *testing.T) {
+			path := filepath.Join(tmpDir, codec, "log.100")
+			if _, err := CompressFile(raw, path, codec, 0); err != nil {
+				t.Fatalf("CompressFile() error = %v", err)
+			}
+
+			reader, err := OpenTxnLog(path)
+			if err != nil {
+				t.Fatalf("OpenTxnLog() error = %v", err)
+			}
+			if reader.Compression() != codec {
+				t.Errorf("Compression() = %v, want %v", reader.Compression(), codec)
+			}
+			if _, err = reader.Seek(0, 0); err == nil {
+				t.Error("Seek() should fail on a compressed txnlog")
+			}
+			reader.Close()
+
+			result, err := ValidateTxnLog(path)
+			if err != nil {
+				t.Fatalf("ValidateTxnLog() error = %v", err)
+			}
+			if result.IsValid || result.ValidTransactionCount != 3 || result.LastValidZxid != ZXID(0x102) {
+				t.Errorf("ValidateTxnLog() = %+v", result)
+			}
+
+			info, err := GetTxnLogInfo(path)
+			if err != nil {
+				t.Fatalf("GetTxnLogInfo() error = %v", err)
+			}
+			rawInfo, _ := GetFileInfo(raw)
+			if info.Compression != codec || info.Size != rawInfo.Size || info.CompressedSize == 0 {
+				t.Errorf("GetTxnLogInfo() = %+v", info)
+			}
+
+			repaired := path + ".repaired"
+			if _, err = RepairTxnLog(path, repaired); err != nil {
+				t.Fatalf("RepairTxnLog() error = %v", err)
+			}
+			if detected, _ := DetectCompression(repaired); detected != codec {
+				t.Errorf("Repaired file compression = %v, want %v", detected, codec)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package zkfile
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -14,6 +15,20 @@ const (
 	ErrorCategoryValidation
 	ErrorCategoryCorruption
 	ErrorCategoryConfiguration
+	ErrorCategoryCancelled
+	ErrorCategoryPartial
+)
+
+// Process exit codes of zkbackup
+const (
+	ExitOK               = 0
+	ExitFailure          = 1  // failure without a more specific code, e.g. invalid arguments
+	ExitPartialSuccess   = 2  // some items of a multi-item operation failed
+	ExitCancelled        = 3  // the user declined a confirmation
+	ExitValidationFailed = 10 // invalid or corrupted backup data
+	ExitBackupFailed     = 20
+	ExitRestoreFailed    = 30 // restore or rollback failed
+	ExitConfigError      = 40
 )
 
 func (ec ErrorCategory) String() string {
@@ -32,6 +47,10 @@ func (ec ErrorCategory) String() string {
 		return "Corruption"
 	case ErrorCategoryConfiguration:
 		return "Configuration"
+	case ErrorCategoryCancelled:
+		return "Cancelled"
+	case ErrorCategoryPartial:
+		return "Partial"
 	}
 }
 
@@ -97,6 +116,24 @@ func NewConfigurationError(message string) *BackupError {
 	}
 }
 
+// NewCancelledError creates an error for an operation the user cancelled
+func NewCancelledError(message string) *BackupError {
+	return &BackupError{
+		Message:  message,
+		Category: ErrorCategoryCancelled,
+		Context:  make(map[string]interface{}),
+	}
+}
+
+// NewPartialError creates an error for an operation that only partially succeeded
+func NewPartialError(message string) *BackupError {
+	return &BackupError{
+		Message:  message,
+		Category: ErrorCategoryPartial,
+		Context:  make(map[string]interface{}),
+	}
+}
+
 func (e *BackupError) Unwrap() error {
 	return e.Cause
 }
@@ -137,3 +174,73 @@ func (e *BackupError) WithContext(key string, value interface{}) *BackupError {
 	e.Context[key] = value
 	return e
 }
+
+// ErrorReport is the machine readable form of an error, printed by --error-format json
+type ErrorReport struct {
+	ExitCode int                    `json:"exit_code"`
+	Category string                 `json:"category"`
+	Message  string                 `json:"message"`
+	Cause    string                 `json:"cause,omitempty"`
+	Context  map[string]interface{} `json:"context,omitempty"`
+	Error    string                 `json:"error"` // full error text including wrapping messages
+}
+
+// ExitCode maps an error to a process exit code. The outermost BackupError in
+// the chain decides configuration, cancellation, partial success and validation
+// failures; any other error exits with failure, the code of the failed operation.
+func ExitCode(err error, failure int) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var backupErr *BackupError
+	if !errors.As(err, &backupErr) {
+		return failure
+	}
+
+	switch backupErr.Category {
+	case ErrorCategoryConfiguration:
+		return ExitConfigError
+	case ErrorCategoryCancelled:
+		return ExitCancelled
+	case ErrorCategoryPartial:
+		return ExitPartialSuccess
+	case ErrorCategoryValidation, ErrorCategoryCorruption:
+		return ExitValidationFailed
+	default:
+		return failure
+	}
+}
+
+// NewErrorReport describes err using its outermost BackupError
+func NewErrorReport(err error, exitCode int) *ErrorReport {
+	report := &ErrorReport{
+		ExitCode: exitCode,
+		Category: "Unknown",
+		Message:  err.Error(),
+		Error:    err.Error(),
+	}
+
+	var backupErr *BackupError
+	if !errors.As(err, &backupErr) {
+		return report
+	}
+
+	report.Category = backupErr.Category.String()
+	report.Message = backupErr.Message
+	if backupErr.Cause != nil {
+		report.Cause = backupErr.Cause.Error()
+	}
+	if len(backupErr.Context) > 0 {
+		report.Context = make(map[string]interface{}, len(backupErr.Context))
+		for key, value := range backupErr.Context {
+			// Errors have no exported fields and would encode as {}
+			if err, ok := value.(error); ok {
+				value = err.Error()
+			}
+			report.Context[key] = value
+		}
+	}
+
+	return report
+}
@@ -1,7 +1,10 @@
 package zkfile
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -204,3 +207,62 @@ func TestErrorCategory_String(t *testing.T) {
 		})
 	}
 }
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, ExitOK},
+		{"plain error", errors.New("boom"), ExitBackupFailed},
+		{"io error", NewIOError("failed to copy"), ExitBackupFailed},
+		{"zookeeper error", NewZooKeeperError("unreachable"), ExitBackupFailed},
+		{"configuration error", NewConfigurationError("invalid configuration"), ExitConfigError},
+		{"cancelled", NewCancelledError("restore cancelled by user"), ExitCancelled},
+		{"partial", NewPartialError("some cluster backups failed"), ExitPartialSuccess},
+		{"validation error", NewValidationError("invalid magic"), ExitValidationFailed},
+		{"corruption error", NewCorruptionError("checksum mismatch"), ExitValidationFailed},
+		{"wrapped error", fmt.Errorf("pre-check failed: %w", NewConfigurationError("missing dir")), ExitConfigError},
+		{"outermost category wins", NewIOError("failed").WithError(NewCorruptionError("bad")), ExitBackupFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err, ExitBackupFailed); got != tt.want {
+				t.Errorf("ExitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewErrorReport(t *testing.T) {
+	t.Run("backup error", func(t *testing.T) {
+		err := fmt.Errorf("backup verification failed: %w",
+			NewCorruptionError("checksum mismatch").
+				WithError(errors.New("expected 1234")).
+				WithContext("file", "log.100000001").
+				WithContext("reason", errors.New("truncated")))
+
+		report := NewErrorReport(err, ExitValidationFailed)
+		if report.Category != "Corruption" || report.Message != "checksum mismatch" || report.Cause != "expected 1234" {
+			t.Errorf("report = %+v", report)
+		}
+		if report.Context["file"] != "log.100000001" || report.Context["reason"] != "truncated" {
+			t.Errorf("Context = %v", report.Context)
+		}
+		if report.ExitCode != ExitValidationFailed || !strings.HasPrefix(report.Error, "backup verification failed: ") {
+			t.Errorf("ExitCode = %d, Error = %q", report.ExitCode, report.Error)
+		}
+		if _, err := json.Marshal(report); err != nil {
+			t.Errorf("json.Marshal() error = %v", err)
+		}
+	})
+
+	t.Run("plain error", func(t *testing.T) {
+		report := NewErrorReport(errors.New("unknown command"), ExitFailure)
+		if report.Category != "Unknown" || report.Message != "unknown command" || report.Context != nil {
+			t.Errorf("report = %+v", report)
+		}
+	})
+}
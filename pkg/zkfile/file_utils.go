@@ -126,16 +126,28 @@ func CopyFile(src, dst string) error {
 	return nil
 }
 
-// calculateFileChecksum calculates SHA256 checksum of a file
-func calculateFileChecksum(path string) (string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return "", NewIOError("failed to open file for checksum").WithError(err).WithContext("path", path)
+// MoveFile moves a file from src to dst.
+// Falls back to copy and remove when src and dst are on different filesystems.
+func MoveFile(src, dst string) error {
+	if err := EnsureDir(filepath.Dir(dst)); err != nil {
+		return err
+	}
+
+	if err := os.Rename(src, dst); err == nil {
+		return nil
 	}
-	defer func() { _ = f.Close() }()
 
+	if err := CopyFile(src, dst); err != nil {
+		return err
+	}
+	return RemoveFile(src)
+}
+
+// CalculateFileChecksum calculates SHA256 checksum of a file.
+// Compressed files are checksummed over their decompressed content.
+func CalculateFileChecksum(path string) (string, error) {
 	h := sha256.New()
-	if _, err = io.Copy(h, f); err != nil {
+	if _, err := readContent(path, h); err != nil {
 		return "", NewIOError("failed to calculate checksum").WithError(err).WithContext("path", path)
 	}
 
@@ -90,6 +90,38 @@ func TestCopyFile(t *testing.T) {
 	})
 }
 
+func TestMoveFile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("move file to subdirectory", func(t *testing.T) {
+		src := filepath.Join(tmpDir, "move_source.txt")
+		dst := filepath.Join(tmpDir, "moved", "move_dest.txt")
+		content := []byte("test content for move")
+
+		os.WriteFile(src, content, 0644)
+
+		if err := MoveFile(src, dst); err != nil {
+			t.Fatalf("MoveFile() error = %v", err)
+		}
+
+		if FileExists(src) {
+			t.Error("Source file should not exist after move")
+		}
+
+		dstContent, _ := os.ReadFile(dst)
+		if string(dstContent) != string(content) {
+			t.Errorf("Content mismatch: got %v, want %v", string(dstContent), string(content))
+		}
+	})
+
+	t.Run("source file not found", func(t *testing.T) {
+		err := MoveFile(filepath.Join(tmpDir, "nonexistent.txt"), filepath.Join(tmpDir, "dest.txt"))
+		if err == nil {
+			t.Error("MoveFile() should return error for nonexistent source")
+		}
+	})
+}
+
 func TestEnsureDir(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -1,6 +1,9 @@
 package zkfile
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -9,10 +12,18 @@ import (
 
 // SnapshotInfo contains Snapshot file information
 type SnapshotInfo struct {
-	Name     string `json:"name"`
-	Zxid     ZXID   `json:"zxid"`
-	Size     int64  `json:"size"`
-	Checksum string `json:"checksum"` // SHA256
+	Name           string `json:"name"`
+	Zxid           ZXID   `json:"zxid"`
+	Size           int64  `json:"size"`                      // Uncompressed size
+	Checksum       string `json:"checksum"`                  // SHA256 of the uncompressed content
+	Compression    string `json:"compression,omitempty"`     // none, gzip or zstd
+	CompressedSize int64  `json:"compressed_size,omitempty"` // Stored size when compressed
+	Version        uint32 `json:"version,omitempty"`         // Snapshot format version
+	NodeCount      int64  `json:"node_count"`
+	SessionCount   int    `json:"session_count"`
+	DataSize       int64  `json:"data_size"`        // Total bytes of node data
+	Status         string `json:"status,omitempty"` // valid, corrupted
+	Note           string `json:"note,omitempty"`
 }
 
 // GetSnapshotInfo extracts information from a snapshot file
@@ -27,12 +38,58 @@ func GetSnapshotInfo(path string) (*SnapshotInfo, error) {
 		return nil, err
 	}
 
-	checksum, err := calculateFileChecksum(path)
+	input, err := openDecompressed(path)
 	if err != nil {
 		return nil, err
 	}
+	defer input.Close()
 
-	return &SnapshotInfo{Name: filepath.Base(path), Zxid: zxid, Size: info.Size(), Checksum: checksum}, nil
+	// The checksum and size are taken from the same decompressed stream the
+	// nodes are parsed from, so a large snapshot is only read once
+	hash := sha256.New()
+	content := &countingReader{r: io.TeeReader(input, hash)}
+
+	snapshotInfo := &SnapshotInfo{Name: filepath.Base(path), Zxid: zxid, Compression: input.codec}
+	if input.codec != CompressionNone {
+		snapshotInfo.CompressedSize = info.Size()
+	}
+
+	// Content that does not parse is reported rather than failing, like txnlogs
+	snapshotInfo.Status = "valid"
+	reader, err := newSnapshotReader(path, io.NopCloser(content))
+	if err == nil {
+		err = scanSnapshot(reader, snapshotInfo)
+	}
+	if err != nil {
+		snapshotInfo.Status = "corrupted"
+		snapshotInfo.Note = err.Error()
+	}
+
+	// Whatever the parse left behind still counts towards the checksum and size
+	if _, err = io.Copy(io.Discard, content); err != nil {
+		return nil, NewIOError("failed to calculate checksum").WithError(err).WithContext("path", path)
+	}
+	snapshotInfo.Size = content.n
+	snapshotInfo.Checksum = fmt.Sprintf("sha256:%x", hash.Sum(nil))
+
+	return snapshotInfo, nil
+}
+
+// scanSnapshot streams through the nodes of reader and fills the content counts of info
+func scanSnapshot(reader *SnapshotReader, info *SnapshotInfo) error {
+	info.Version = reader.Header().Version
+	info.SessionCount = len(reader.Sessions())
+	for {
+		_, err := reader.Next()
+		info.NodeCount = reader.NodeCount()
+		info.DataSize = reader.DataSize()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
 }
 
 // ListSnapshotFiles lists all snapshot files in the given directory
@@ -85,20 +142,20 @@ func GetLatestSnapshot(dir string) (string, ZXID, error) {
 	return latest, zxid, nil
 }
 
-// ValidateSnapshot validates the integrity of a Snapshot file
+// CopySnapshot copies a snapshot file from src to dst, decompressing it if needed
+func CopySnapshot(src, dst string) error {
+	return DecompressFile(src, dst)
+}
+
+// ValidateSnapshot validates the integrity of a Snapshot file: the header, every
+// node and the Adler32 checksum and terminator ZooKeeper writes at the end. A
+// snapshot whose write was interrupted fails with the offset where it ends.
 func ValidateSnapshot(path string) error {
-	// Simple validation: check if file exists and size > 0
-	info, err := GetFileInfo(path)
+	reader, err := OpenSnapshot(path)
 	if err != nil {
 		return err
 	}
+	defer reader.Close()
 
-	if info.Size == 0 {
-		return NewCorruptionError("empty snapshot file").WithContext("path", path)
-	}
-
-	// TODO: Can add more detailed snapshot format validation
-	// ZooKeeper snapshot files also have a specific format, can validate file header
-
-	return nil
+	return scanSnapshot(reader, &SnapshotInfo{})
 }
@@ -0,0 +1,639 @@
+package zkfile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/zookeeper-backup/pkg/jute"
+)
+
+const (
+	// SnapshotMagic is the magic number for Snapshot files "ZKSN"
+	SnapshotMagic = 0x5a4b534e
+
+	// SnapshotVersion is the supported snapshot version
+	SnapshotVersion = 2
+
+	// snapshotTerminator is the path that ends the node list and every seal
+	snapshotTerminator = "/"
+
+	// OpenACLUnsafeRef is the ACL cache reference of world:anyone with all permissions,
+	// which is implicit and never written to the cache
+	OpenACLUnsafeRef = -1
+)
+
+// Sizes of the optional sections after the first seal (section + 8 byte checksum + "/")
+const (
+	sealSize              = 8 + 4 + 1
+	digestSectionSize     = 8 + 4 + 8 + sealSize // ZooKeeper 3.6+ zxid digest
+	lastZxidSectionSize   = 8 + sealSize         // ZooKeeper 3.7+ last processed zxid
+	maxSnapshotTailLength = digestSectionSize + lastZxidSectionSize
+)
+
+// SnapshotHeader is the header of a Snapshot file, laid out like the txnlog FileHeader
+type SnapshotHeader = TxnLogHeader
+
+// Session is an entry of the snapshot session table
+type Session struct {
+	ID      int64 `json:"id"`
+	Timeout int32 `json:"timeout"` // milliseconds
+}
+
+// StatPersisted is the stat of a znode stored in snapshots
+type StatPersisted struct {
+	Czxid          ZXID  `json:"czxid"`
+	Mzxid          ZXID  `json:"mzxid"`
+	Ctime          int64 `json:"ctime"`
+	Mtime          int64 `json:"mtime"`
+	Version        int32 `json:"version"`
+	Cversion       int32 `json:"cversion"`
+	Aversion       int32 `json:"aversion"`
+	EphemeralOwner int64 `json:"ephemeral_owner"`
+	Pzxid          ZXID  `json:"pzxid"`
+}
+
+// SnapshotNode is a znode of the serialized DataTree
+type SnapshotNode struct {
+	Path   string        `json:"path"` // "/" for the root, which ZooKeeper writes as ""
+	Data   []byte        `json:"data"`
+	ACLRef int64         `json:"acl_ref"` // key of the ACL cache
+	Stat   StatPersisted `json:"stat"`
+}
+
+// SnapshotDigest is the DataTree digest ZooKeeper 3.6+ writes after the first seal
+type SnapshotDigest struct {
+	Zxid    ZXID  `json:"zxid"`
+	Version int32 `json:"version"`
+	Digest  int64 `json:"digest"`
+}
+
+// Serialize writes the stat
+func (s *StatPersisted) Serialize(out *jute.OutputArchive) error {
+	for _, v := range []int64{int64(s.Czxid), int64(s.Mzxid), s.Ctime, s.Mtime} {
+		if err := out.WriteLong(v); err != nil {
+			return err
+		}
+	}
+	for _, v := range []int32{s.Version, s.Cversion, s.Aversion} {
+		if err := out.WriteInt(v); err != nil {
+			return err
+		}
+	}
+	if err := out.WriteLong(s.EphemeralOwner); err != nil {
+		return err
+	}
+	return out.WriteLong(int64(s.Pzxid))
+}
+
+// Deserialize reads the stat
+func (s *StatPersisted) Deserialize(in *jute.InputArchive) error {
+	longs := make([]int64, 4)
+	for i := range longs {
+		v, err := in.ReadLong()
+		if err != nil {
+			return err
+		}
+		longs[i] = v
+	}
+	s.Czxid, s.Mzxid, s.Ctime, s.Mtime = ZXID(longs[0]), ZXID(longs[1]), longs[2], longs[3]
+
+	var err error
+	if s.Version, err = in.ReadInt(); err != nil {
+		return err
+	}
+	if s.Cversion, err = in.ReadInt(); err != nil {
+		return err
+	}
+	if s.Aversion, err = in.ReadInt(); err != nil {
+		return err
+	}
+	if s.EphemeralOwner, err = in.ReadLong(); err != nil {
+		return err
+	}
+	pzxid, err := in.ReadLong()
+	s.Pzxid = ZXID(pzxid)
+	return err
+}
+
+// Serialize writes the node record (DataNode) without its path
+func (n *SnapshotNode) Serialize(out *jute.OutputArchive) error {
+	if err := out.WriteBuffer(n.Data); err != nil {
+		return err
+	}
+	if err := out.WriteLong(n.ACLRef); err != nil {
+		return err
+	}
+	return out.WriteRecord(&n.Stat)
+}
+
+// Deserialize reads the node record (DataNode) without its path
+func (n *SnapshotNode) Deserialize(in *jute.InputArchive) error {
+	var err error
+	if n.Data, err = in.ReadBuffer(); err != nil {
+		return err
+	}
+	if n.ACLRef, err = in.ReadLong(); err != nil {
+		return err
+	}
+	return in.ReadRecord(&n.Stat)
+}
+
+// Serialize writes the digest
+func (d *SnapshotDigest) Serialize(out *jute.OutputArchive) error {
+	if err := out.WriteLong(int64(d.Zxid)); err != nil {
+		return err
+	}
+	if err := out.WriteInt(d.Version); err != nil {
+		return err
+	}
+	return out.WriteLong(d.Digest)
+}
+
+// Deserialize reads the digest
+func (d *SnapshotDigest) Deserialize(in *jute.InputArchive) error {
+	zxid, err := in.ReadLong()
+	if err != nil {
+		return err
+	}
+	d.Zxid = ZXID(zxid)
+	if d.Version, err = in.ReadInt(); err != nil {
+		return err
+	}
+	d.Digest, err = in.ReadLong()
+	return err
+}
+
+// SnapshotReader reads a Snapshot file as a stream, like FileSnap.deserialize.
+// The header, session table and ACL cache are read when it is opened; the
+// DataTree nodes are returned one at a time by Next, so the whole tree never
+// has to fit in memory. Compressed files are decompressed transparently and
+// offsets are positions in the decompressed content.
+type SnapshotReader struct {
+	path     string
+	input    io.ReadCloser
+	buffered *bufio.Reader
+	checksum hash.Hash32
+	archive  *jute.InputArchive // reads buffered through checksum
+	base     int64              // offset where archive started
+
+	header   *SnapshotHeader
+	sessions []Session
+	acls     map[int64][]ACL
+
+	done              bool
+	digest            *SnapshotDigest
+	lastProcessedZxid ZXID
+	nodeCount         int64
+	dataSize          int64
+}
+
+// OpenSnapshot opens a Snapshot file and reads everything before the nodes
+func OpenSnapshot(path string) (*SnapshotReader, error) {
+	input, err := OpenDecompressed(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := newSnapshotReader(path, input)
+	if err != nil {
+		_ = input.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+// newSnapshotReader reads everything before the nodes from the decompressed
+// content in input, which the caller closes if it fails
+func newSnapshotReader(path string, input io.ReadCloser) (*SnapshotReader, error) {
+	reader := &SnapshotReader{
+		path:     path,
+		input:    input,
+		buffered: bufio.NewReaderSize(input, 64*1024),
+		checksum: adler32.New(),
+	}
+	reader.archive = jute.NewInputArchive(io.TeeReader(reader.buffered, reader.checksum))
+
+	err := reader.readHeader()
+	if err == nil {
+		err = reader.readSessions()
+	}
+	if err == nil {
+		err = reader.readACLCache()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+// Path returns the file path
+func (r *SnapshotReader) Path() string {
+	return r.path
+}
+
+// Header returns the file header
+func (r *SnapshotReader) Header() *SnapshotHeader {
+	return r.header
+}
+
+// Sessions returns the session table
+func (r *SnapshotReader) Sessions() []Session {
+	return r.sessions
+}
+
+// ACL returns the ACL list a node references
+func (r *SnapshotReader) ACL(ref int64) ([]ACL, bool) {
+	if ref == OpenACLUnsafeRef {
+		return []ACL{{Perms: 31, Scheme: "world", ID: "anyone"}}, true
+	}
+	acls, ok := r.acls[ref]
+	return acls, ok
+}
+
+// ACLCache returns the ACL cache by reference
+func (r *SnapshotReader) ACLCache() map[int64][]ACL {
+	return r.acls
+}
+
+// Offset returns the number of bytes read
+func (r *SnapshotReader) Offset() int64 {
+	return r.base + r.archive.Offset()
+}
+
+// NodeCount returns the number of nodes read so far
+func (r *SnapshotReader) NodeCount() int64 {
+	return r.nodeCount
+}
+
+// DataSize returns the total data bytes of the nodes read so far
+func (r *SnapshotReader) DataSize() int64 {
+	return r.dataSize
+}
+
+// Digest returns the DataTree digest, nil until Next returned io.EOF or when
+// the server did not write one
+func (r *SnapshotReader) Digest() *SnapshotDigest {
+	return r.digest
+}
+
+// LastProcessedZxid returns the last processed zxid ZooKeeper 3.7+ may write
+// at the end, 0 when it is absent
+func (r *SnapshotReader) LastProcessedZxid() ZXID {
+	return r.lastProcessedZxid
+}
+
+// Close closes the file
+func (r *SnapshotReader) Close() error {
+	if r.input != nil {
+		return r.input.Close()
+	}
+	return nil
+}
+
+// corruption returns a corruption error at offset, calling it truncated when the
+// file ended in the middle of a value
+func (r *SnapshotReader) corruption(message string, offset int64, err error) *BackupError {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		message = "truncated snapshot, " + message
+	}
+	e := NewCorruptionError(message).WithContext("path", r.path).WithContext("offset", offset)
+	if err != nil {
+		e = e.WithError(err)
+	}
+	return e
+}
+
+// readHeader reads the file header
+func (r *SnapshotReader) readHeader() error {
+	r.header = &SnapshotHeader{}
+
+	err := r.archive.ReadRecord(r.header)
+	if err == io.EOF {
+		return NewCorruptionError("empty snapshot file").WithContext("path", r.path)
+	}
+	if err != nil {
+		return r.corruption("failed to read snapshot header", 0, err)
+	}
+	if r.header.Magic != SnapshotMagic {
+		return NewCorruptionError("invalid snapshot magic number").
+			WithContext("path", r.path).WithContext("magic", r.header.Magic).WithContext("expected", SnapshotMagic)
+	}
+	if r.header.Version != SnapshotVersion {
+		return NewCorruptionError("unsupported snapshot version").
+			WithContext("path", r.path).WithContext("version", r.header.Version).WithContext("expected", SnapshotVersion)
+	}
+
+	return nil
+}
+
+// readSessions reads the session table (id, timeout pairs)
+func (r *SnapshotReader) readSessions() error {
+	offset := r.Offset()
+	count, err := r.archive.ReadInt()
+	if err == nil && count < 0 {
+		err = fmt.Errorf("negative session count %d", count)
+	}
+	if err != nil {
+		return r.corruption("failed to read session count", offset, unexpectedEOF(err))
+	}
+
+	r.sessions = make([]Session, 0, min(int(count), 1024))
+	for i := int32(0); i < count; i++ {
+		offset = r.Offset()
+		var session Session
+		session.ID, err = r.archive.ReadLong()
+		if err == nil {
+			session.Timeout, err = r.archive.ReadInt()
+		}
+		if err != nil {
+			return r.corruption("failed to read session", offset, unexpectedEOF(err))
+		}
+		r.sessions = append(r.sessions, session)
+	}
+
+	return nil
+}
+
+// readACLCache reads the ACL cache (ReferenceCountedACLCache): a count of
+// reference, ACL vector pairs
+func (r *SnapshotReader) readACLCache() error {
+	offset := r.Offset()
+	count, err := r.archive.ReadInt()
+	if err == nil && count < 0 {
+		err = fmt.Errorf("negative ACL cache size %d", count)
+	}
+	if err != nil {
+		return r.corruption("failed to read ACL cache size", offset, unexpectedEOF(err))
+	}
+
+	r.acls = make(map[int64][]ACL, min(int(count), 1024))
+	for i := int32(0); i < count; i++ {
+		offset = r.Offset()
+		ref, err := r.archive.ReadLong()
+		var acls []ACL
+		if err == nil {
+			acls, err = readACLs(r.archive)
+		}
+		if err == nil && acls == nil {
+			err = fmt.Errorf("missing ACL list of reference %d", ref)
+		}
+		if err != nil {
+			return r.corruption("failed to read ACL cache entry", offset, unexpectedEOF(err))
+		}
+		r.acls[ref] = acls
+	}
+
+	return nil
+}
+
+// Next returns the next node in the order ZooKeeper wrote them, parents before
+// their children. After the last node it verifies the checksum and terminator
+// and returns io.EOF.
+func (r *SnapshotReader) Next() (*SnapshotNode, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+
+	offset := r.Offset()
+	path, err := r.archive.ReadString()
+	if err != nil {
+		return nil, r.corruption("failed to read node path", offset, unexpectedEOF(err))
+	}
+
+	if path == snapshotTerminator {
+		if err = r.readTrailer(); err != nil {
+			return nil, err
+		}
+		r.done = true
+		return nil, io.EOF
+	}
+
+	node := &SnapshotNode{Path: path}
+	if err = r.archive.ReadRecord(node); err != nil {
+		return nil, r.corruption("failed to read node", offset, unexpectedEOF(err)).WithContext("node", path)
+	}
+	if node.Path == "" {
+		node.Path = "/"
+	}
+
+	r.nodeCount++
+	r.dataSize += int64(len(node.Data))
+	return node, nil
+}
+
+// readTrailer verifies the seal after the nodes and reads the optional digest
+// and last processed zxid sections, each followed by its own seal
+func (r *SnapshotReader) readTrailer() error {
+	if err := r.readSeal(); err != nil {
+		return err
+	}
+
+	// The optional sections are only told apart by their size
+	base := r.Offset()
+	tail, err := io.ReadAll(io.LimitReader(r.buffered, maxSnapshotTailLength+1))
+	if err != nil {
+		return NewIOError("failed to read snapshot").WithError(err).WithContext("path", r.path)
+	}
+	r.archive = jute.NewInputArchive(io.TeeReader(bytes.NewReader(tail), r.checksum))
+	r.base = base
+
+	switch len(tail) {
+	case 0:
+		return nil
+	case digestSectionSize, maxSnapshotTailLength:
+		r.digest = &SnapshotDigest{}
+		if err = r.archive.ReadRecord(r.digest); err != nil {
+			return r.corruption("failed to read snapshot digest", base, err)
+		}
+		if err = r.readSeal(); err != nil {
+			return err
+		}
+		if len(tail) == digestSectionSize {
+			return nil
+		}
+	case lastZxidSectionSize:
+	default:
+		return r.corruption(fmt.Sprintf("unexpected %d bytes after snapshot", len(tail)), base, nil)
+	}
+
+	offset := r.Offset()
+	zxid, err := r.archive.ReadLong()
+	if err != nil {
+		return r.corruption("failed to read last processed zxid", offset, err)
+	}
+	r.lastProcessedZxid = ZXID(zxid)
+	return r.readSeal()
+}
+
+// readSeal verifies an Adler32 checksum of everything before it and the "/" after it
+func (r *SnapshotReader) readSeal() error {
+	expected := int64(r.checksum.Sum32())
+
+	start := r.Offset()
+	val, err := r.archive.ReadLong()
+	if err != nil {
+		return r.corruption("missing snapshot checksum", start, unexpectedEOF(err))
+	}
+	if val != expected {
+		return r.corruption("snapshot checksum mismatch", start, nil).
+			WithContext("expected", val).WithContext("calculated", expected)
+	}
+
+	start = r.Offset()
+	path, err := r.archive.ReadString()
+	if err != nil {
+		return r.corruption("missing snapshot terminator", start, unexpectedEOF(err))
+	}
+	if path != snapshotTerminator {
+		return r.corruption("invalid snapshot terminator", start, nil).WithContext("terminator", path)
+	}
+
+	return nil
+}
+
+// unexpectedEOF turns the end of the input into io.ErrUnexpectedEOF, where a
+// value was required
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// SnapshotWriter writes a Snapshot file, like FileSnap.serialize
+type SnapshotWriter struct {
+	path     string
+	file     *os.File
+	buffered *bufio.Writer
+	checksum hash.Hash32
+	output   *jute.OutputArchive // writes buffered through checksum
+	digest   *SnapshotDigest
+}
+
+// CreateSnapshot creates a Snapshot file and writes everything before the nodes.
+// Write the nodes parents first with WriteNode, then finish the file with Close.
+func CreateSnapshot(path string, header *SnapshotHeader, sessions []Session, acls map[int64][]ACL) (*SnapshotWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, NewIOError("failed to create snapshot").WithError(err).WithContext("path", path)
+	}
+
+	writer := &SnapshotWriter{
+		path:     path,
+		file:     f,
+		buffered: bufio.NewWriterSize(f, 64*1024),
+		checksum: adler32.New(),
+	}
+	writer.output = jute.NewOutputArchive(io.MultiWriter(writer.buffered, writer.checksum))
+
+	if err = writer.writeHead(header, sessions, acls); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return nil, NewIOError("failed to write snapshot").WithError(err).WithContext("path", path)
+	}
+
+	return writer, nil
+}
+
+// writeHead writes the header, session table and ACL cache
+func (w *SnapshotWriter) writeHead(header *SnapshotHeader, sessions []Session, acls map[int64][]ACL) error {
+	if err := w.output.WriteRecord(header); err != nil {
+		return err
+	}
+
+	if err := w.output.WriteInt(int32(len(sessions))); err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if err := w.output.WriteLong(session.ID); err != nil {
+			return err
+		}
+		if err := w.output.WriteInt(session.Timeout); err != nil {
+			return err
+		}
+	}
+
+	refs := make([]int64, 0, len(acls))
+	for ref := range acls {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i] < refs[j] })
+
+	if err := w.output.WriteInt(int32(len(refs))); err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if err := w.output.WriteLong(ref); err != nil {
+			return err
+		}
+		if err := writeACLs(w.output, acls[ref]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteNode writes a node; the root "/" is written as "" like ZooKeeper does
+func (w *SnapshotWriter) WriteNode(node *SnapshotNode) error {
+	path := node.Path
+	if path == "/" {
+		path = ""
+	}
+
+	err := w.output.WriteString(path)
+	if err == nil {
+		err = w.output.WriteRecord(node)
+	}
+	if err != nil {
+		return NewIOError("failed to write node").WithError(err).WithContext("path", w.path).WithContext("node", node.Path)
+	}
+	return nil
+}
+
+// SetDigest makes Close write a digest section like ZooKeeper 3.6+
+func (w *SnapshotWriter) SetDigest(digest *SnapshotDigest) {
+	w.digest = digest
+}
+
+// Close writes the terminator and seals, then syncs and closes the file
+func (w *SnapshotWriter) Close() error {
+	err := w.output.WriteString(snapshotTerminator)
+	if err == nil {
+		err = w.writeSeal()
+	}
+	if err == nil && w.digest != nil {
+		if err = w.output.WriteRecord(w.digest); err == nil {
+			err = w.writeSeal()
+		}
+	}
+	if err == nil {
+		err = w.buffered.Flush()
+	}
+	if err == nil {
+		err = w.file.Sync()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return NewIOError("failed to write snapshot").WithError(err).WithContext("path", w.path)
+	}
+	return nil
+}
+
+// writeSeal writes the Adler32 checksum of everything written so far and "/"
+func (w *SnapshotWriter) writeSeal() error {
+	if err := w.output.WriteLong(int64(w.checksum.Sum32())); err != nil {
+		return err
+	}
+	return w.output.WriteString(snapshotTerminator)
+}
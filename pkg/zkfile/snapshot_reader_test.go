@@ -0,0 +1,270 @@
+package zkfile
+
+import (
+	"errors"
+	"hash/adler32"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testSnapshotNodes = []*SnapshotNode{
+	{Path: "/", ACLRef: OpenACLUnsafeRef, Stat: StatPersisted{Pzxid: 0x100000003}},
+	{Path: "/app", Data: []byte("config"), ACLRef: 1,
+		Stat: StatPersisted{Czxid: 0x100000001, Mzxid: 0x100000002, Ctime: 1000, Mtime: 2000, Version: 1, Pzxid: 0x100000003}},
+	{Path: "/app/lock", Data: []byte{}, ACLRef: OpenACLUnsafeRef,
+		Stat: StatPersisted{Czxid: 0x100000003, Mzxid: 0x100000003, Ctime: 3000, Mtime: 3000, EphemeralOwner: 0x1234, Pzxid: 0x100000003}},
+	{Path: "/zookeeper", ACLRef: OpenACLUnsafeRef},
+}
+
+var testSnapshotACLs = map[int64][]ACL{1: {{Perms: 1, Scheme: "digest", ID: "user:hash"}, testWorldACL}}
+
+// createTestSnapshot writes a snapshot of testSnapshotNodes
+func createTestSnapshot(t *testing.T, path string, digest *SnapshotDigest) {
+	t.Helper()
+
+	header := &SnapshotHeader{Magic: SnapshotMagic, Version: SnapshotVersion, DbId: 1}
+	w, err := CreateSnapshot(path, header, []Session{{ID: 0x1234, Timeout: 30000}}, testSnapshotACLs)
+	if err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+	for _, node := range testSnapshotNodes {
+		if err := w.WriteNode(node); err != nil {
+			t.Fatalf("WriteNode() error = %v", err)
+		}
+	}
+	if digest != nil {
+		w.SetDigest(digest)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+// readTestSnapshot reads every node of a snapshot
+func readTestSnapshot(path string) (*SnapshotReader, []*SnapshotNode, error) {
+	r, err := OpenSnapshot(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	var nodes []*SnapshotNode
+	for {
+		node, err := r.Next()
+		if err == io.EOF {
+			return r, nodes, nil
+		}
+		if err != nil {
+			return r, nodes, err
+		}
+		nodes = append(nodes, node)
+	}
+}
+
+// testSeal appends an Adler32 seal of everything in j
+func testSeal(j *testJute) *testJute {
+	return j.long(int64(adler32.Checksum(j.Bytes()))).string("/")
+}
+
+func TestSnapshotReader_ZooKeeperLayout(t *testing.T) {
+	// Laid out like FileSnap.serialize of ZooKeeper 3.7
+	j := &testJute{}
+	j.int(SnapshotMagic).int(SnapshotVersion).long(1)
+	j.int(2).long(0x1234).int(30000).long(0x5678).int(4000)
+	j.int(1).long(1).acls(testWorldACL)
+	j.string("").buffer(nil).long(-1).long(0).long(0).long(0).long(0).int(0).int(1).int(0).long(0).long(0x100000001)
+	j.string("/a").buffer([]byte("v")).long(1).long(0x100000001).long(0x100000001).long(5).long(5).int(0).int(0).int(0).long(0x5678).long(0x100000001)
+	j.string("/")
+	testSeal(j)
+	j.long(0x100000001).int(2).long(42)
+	testSeal(j)
+	j.long(0x100000002)
+	testSeal(j)
+
+	path := filepath.Join(t.TempDir(), "snapshot.100000001")
+	if err := os.WriteFile(path, j.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, nodes, err := readTestSnapshot(path)
+	if err != nil {
+		t.Fatalf("read error = %v", err)
+	}
+
+	if r.Header().DbId != 1 {
+		t.Errorf("DbId = %d, want 1", r.Header().DbId)
+	}
+	wantSessions := []Session{{ID: 0x1234, Timeout: 30000}, {ID: 0x5678, Timeout: 4000}}
+	if !reflect.DeepEqual(r.Sessions(), wantSessions) {
+		t.Errorf("Sessions() = %+v, want %+v", r.Sessions(), wantSessions)
+	}
+	if acls, ok := r.ACL(1); !ok || !reflect.DeepEqual(acls, []ACL{testWorldACL}) {
+		t.Errorf("ACL(1) = %+v, %v", acls, ok)
+	}
+	if acls, ok := r.ACL(OpenACLUnsafeRef); !ok || !reflect.DeepEqual(acls, []ACL{testWorldACL}) {
+		t.Errorf("ACL(-1) = %+v, %v", acls, ok)
+	}
+
+	if len(nodes) != 2 || nodes[0].Path != "/" || nodes[1].Path != "/a" {
+		t.Fatalf("nodes = %+v, want / and /a", nodes)
+	}
+	if string(nodes[1].Data) != "v" || nodes[1].Stat.EphemeralOwner != 0x5678 || nodes[0].Stat.Cversion != 1 {
+		t.Errorf("nodes = %+v, %+v", nodes[0], nodes[1])
+	}
+	if r.NodeCount() != 2 || r.DataSize() != 1 {
+		t.Errorf("NodeCount() = %d, DataSize() = %d, want 2, 1", r.NodeCount(), r.DataSize())
+	}
+
+	wantDigest := &SnapshotDigest{Zxid: 0x100000001, Version: 2, Digest: 42}
+	if !reflect.DeepEqual(r.Digest(), wantDigest) {
+		t.Errorf("Digest() = %+v, want %+v", r.Digest(), wantDigest)
+	}
+	if r.LastProcessedZxid() != 0x100000002 {
+		t.Errorf("LastProcessedZxid() = %v, want 0x100000002", r.LastProcessedZxid())
+	}
+	if r.Offset() != int64(j.Len()) {
+		t.Errorf("Offset() = %d, want %d", r.Offset(), j.Len())
+	}
+}
+
+func TestSnapshotWriter(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := []struct {
+		name   string
+		digest *SnapshotDigest
+		codec  string
+	}{
+		{name: "plain"},
+		{name: "with digest", digest: &SnapshotDigest{Zxid: 0x100000003, Version: 2, Digest: -7}},
+		{name: "gzip", codec: CompressionGzip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, strings.ReplaceAll(tt.name, " ", "_"))
+			createTestSnapshot(t, path, tt.digest)
+			if tt.codec != "" {
+				compressed := path + ".compressed"
+				if _, err := CompressFile(path, compressed, tt.codec, 0); err != nil {
+					t.Fatalf("CompressFile() error = %v", err)
+				}
+				path = compressed
+			}
+
+			r, nodes, err := readTestSnapshot(path)
+			if err != nil {
+				t.Fatalf("read error = %v", err)
+			}
+			if !reflect.DeepEqual(nodes, testSnapshotNodes) {
+				t.Errorf("nodes = %+v, want %+v", nodes, testSnapshotNodes)
+			}
+			if !reflect.DeepEqual(r.ACLCache(), testSnapshotACLs) {
+				t.Errorf("ACLCache() = %+v, want %+v", r.ACLCache(), testSnapshotACLs)
+			}
+			if !reflect.DeepEqual(r.Digest(), tt.digest) {
+				t.Errorf("Digest() = %+v, want %+v", r.Digest(), tt.digest)
+			}
+		})
+	}
+}
+
+func TestSnapshotReader_Corrupted(t *testing.T) {
+	tmpDir := t.TempDir()
+	valid := filepath.Join(tmpDir, "snapshot.100000003")
+	createTestSnapshot(t, valid, nil)
+	content, err := os.ReadFile(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(t *testing.T, data []byte, want string) *BackupError {
+		t.Helper()
+		path := filepath.Join(tmpDir, "corrupted")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err := readTestSnapshot(path)
+		var backupErr *BackupError
+		if !errors.As(err, &backupErr) || backupErr.Category != ErrorCategoryCorruption {
+			t.Fatalf("read error = %v, want a corruption error", err)
+		}
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("read error = %v, want %q", err, want)
+		}
+		return backupErr
+	}
+
+	t.Run("every truncation", func(t *testing.T) {
+		for n := 1; n < len(content); n++ {
+			backupErr := check(t, content[:n], "")
+			offset, ok := backupErr.Context["offset"].(int64)
+			if n >= 16 && (!ok || offset > int64(n)) {
+				t.Errorf("truncated to %d bytes: offset = %v, want at most %d", n, backupErr.Context["offset"], n)
+			}
+		}
+	})
+
+	t.Run("bad magic", func(t *testing.T) {
+		data := append([]byte("ZKLG"), content[4:]...)
+		check(t, data, "invalid snapshot magic number")
+	})
+
+	t.Run("bad version", func(t *testing.T) {
+		data := append([]byte{}, content...)
+		data[7] = 9
+		check(t, data, "unsupported snapshot version")
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		data := append([]byte{}, content...)
+		i := strings.Index(string(data), "config")
+		data[i] = 'C'
+		backupErr := check(t, data, "snapshot checksum mismatch")
+		if backupErr.Context["offset"] != int64(len(content)-13) {
+			t.Errorf("offset = %v, want %d", backupErr.Context["offset"], len(content)-13)
+		}
+	})
+
+	t.Run("trailing bytes", func(t *testing.T) {
+		check(t, append(append([]byte{}, content...), 1, 2, 3), "unexpected 3 bytes after snapshot")
+	})
+}
+
+func TestGetSnapshotInfo_Content(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.100000003")
+	createTestSnapshot(t, path, nil)
+
+	info, err := GetSnapshotInfo(path)
+	if err != nil {
+		t.Fatalf("GetSnapshotInfo() error = %v", err)
+	}
+	if info.Status != "valid" || info.Version != SnapshotVersion {
+		t.Errorf("Status = %q, Version = %d, note %q", info.Status, info.Version, info.Note)
+	}
+	if info.NodeCount != 4 || info.SessionCount != 1 || info.DataSize != 6 {
+		t.Errorf("NodeCount = %d, SessionCount = %d, DataSize = %d, want 4, 1, 6", info.NodeCount, info.SessionCount, info.DataSize)
+	}
+
+	// The compressed copy describes the same content
+	compressed := filepath.Join(t.TempDir(), filepath.Base(path))
+	if _, err = CompressFile(path, compressed, CompressionZstd, 0); err != nil {
+		t.Fatalf("CompressFile() error = %v", err)
+	}
+	got, err := GetSnapshotInfo(compressed)
+	if err != nil {
+		t.Fatalf("GetSnapshotInfo() error = %v", err)
+	}
+	stat, _ := os.Stat(compressed)
+	if got.Compression != CompressionZstd || got.CompressedSize != stat.Size() {
+		t.Errorf("Compression = %q, CompressedSize = %d, want zstd, %d", got.Compression, got.CompressedSize, stat.Size())
+	}
+	if got.Size != info.Size || got.Checksum != info.Checksum || got.Status != "valid" || got.NodeCount != info.NodeCount {
+		t.Errorf("compressed info = %+v, want the content of %+v", got, info)
+	}
+}
@@ -1,8 +1,10 @@
 package zkfile
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -121,6 +123,9 @@ func TestGetSnapshotInfo(t *testing.T) {
 		if len(info.Checksum) < 10 {
 			t.Errorf("Checksum seems too short: %v", info.Checksum)
 		}
+		if info.Status != "corrupted" || info.Note == "" {
+			t.Errorf("Status = %q, Note = %q, want an unparseable snapshot reported as corrupted", info.Status, info.Note)
+		}
 	})
 
 	t.Run("nonexistent file", func(t *testing.T) {
@@ -179,7 +184,7 @@ func TestValidateSnapshot(t *testing.T) {
 
 	t.Run("valid snapshot", func(t *testing.T) {
 		path := filepath.Join(tmpDir, "snapshot.100")
-		os.WriteFile(path, []byte("snapshot content"), 0644)
+		createTestSnapshot(t, path, &SnapshotDigest{Zxid: 0x100, Version: 2, Digest: 1})
 
 		err := ValidateSnapshot(path)
 		if err != nil {
@@ -187,6 +192,59 @@ func TestValidateSnapshot(t *testing.T) {
 		}
 	})
 
+	t.Run("not a snapshot", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "snapshot.150")
+		os.WriteFile(path, []byte("snapshot content"), 0644)
+
+		err := ValidateSnapshot(path)
+		if err == nil || !strings.Contains(err.Error(), "invalid snapshot magic number") {
+			t.Errorf("ValidateSnapshot() error = %v, want invalid magic", err)
+		}
+	})
+
+	t.Run("partial snapshot", func(t *testing.T) {
+		// ZooKeeper crashed while writing the nodes
+		path := filepath.Join(tmpDir, "snapshot.200")
+		createTestSnapshot(t, path, nil)
+		content, _ := os.ReadFile(path)
+		cut := strings.Index(string(content), "/app/lock") + 2
+		os.WriteFile(path, content[:cut], 0644)
+
+		err := ValidateSnapshot(path)
+		var backupErr *BackupError
+		if !errors.As(err, &backupErr) || !strings.Contains(err.Error(), "truncated snapshot") {
+			t.Fatalf("ValidateSnapshot() error = %v, want truncated snapshot", err)
+		}
+		if backupErr.Context["offset"] != int64(cut-6) {
+			t.Errorf("offset = %v, want the path of the cut node at %d", backupErr.Context["offset"], cut-6)
+		}
+	})
+
+	t.Run("missing seal", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "snapshot.300")
+		createTestSnapshot(t, path, nil)
+		content, _ := os.ReadFile(path)
+		os.WriteFile(path, content[:len(content)-sealSize], 0644)
+
+		err := ValidateSnapshot(path)
+		if err == nil || !strings.Contains(err.Error(), "missing snapshot checksum") {
+			t.Errorf("ValidateSnapshot() error = %v, want missing checksum", err)
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "snapshot.400")
+		createTestSnapshot(t, path, nil)
+		content, _ := os.ReadFile(path)
+		content[len(content)-6]++
+		os.WriteFile(path, content, 0644)
+
+		err := ValidateSnapshot(path)
+		if err == nil || !strings.Contains(err.Error(), "snapshot checksum mismatch") {
+			t.Errorf("ValidateSnapshot() error = %v, want checksum mismatch", err)
+		}
+	})
+
 	t.Run("empty snapshot", func(t *testing.T) {
 		path := filepath.Join(tmpDir, "empty_snapshot.100")
 		os.WriteFile(path, []byte{}, 0644)
@@ -0,0 +1,717 @@
+package zkfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/zookeeper-backup/pkg/jute"
+)
+
+// Transaction types (ZooDefs.OpCode) found in txnlogs
+const (
+	TxnTypeError           int32 = -1
+	TxnTypeCreate          int32 = 1
+	TxnTypeDelete          int32 = 2
+	TxnTypeSetData         int32 = 5
+	TxnTypeSetACL          int32 = 7
+	TxnTypeCheck           int32 = 13
+	TxnTypeMulti           int32 = 14
+	TxnTypeCreate2         int32 = 15
+	TxnTypeReconfig        int32 = 16
+	TxnTypeCreateContainer int32 = 19
+	TxnTypeDeleteContainer int32 = 20
+	TxnTypeCreateTTL       int32 = 21
+	TxnTypeCreateSession   int32 = -10
+	TxnTypeCloseSession    int32 = -11
+)
+
+// TxnHeaderSize is the size of the header that starts every record body
+const TxnHeaderSize = 32 // 8(ClientId) + 4(Cxid) + 8(Zxid) + 8(Time) + 4(Type)
+
+// Ephemeral owners of nodes that are not owned by a session (EphemeralType)
+const (
+	ContainerEphemeralOwner int64 = math.MinInt64
+	ttlEphemeralOwnerMask   int64 = -0x100000000000000 // 0xFF00000000000000
+)
+
+// IsSessionOwner reports whether an ephemeral owner is a session, the node being
+// ephemeral, rather than no owner or the marker of a container or TTL node
+func IsSessionOwner(owner int64) bool {
+	return owner != 0 && owner != ContainerEphemeralOwner && owner&ttlEphemeralOwnerMask != ttlEphemeralOwnerMask
+}
+
+// TxnTypeName returns the name of a transaction type
+func TxnTypeName(txnType int32) string {
+	switch txnType {
+	case TxnTypeError:
+		return "error"
+	case TxnTypeCreate:
+		return "create"
+	case TxnTypeDelete:
+		return "delete"
+	case TxnTypeSetData:
+		return "setData"
+	case TxnTypeSetACL:
+		return "setACL"
+	case TxnTypeCheck:
+		return "check"
+	case TxnTypeMulti:
+		return "multi"
+	case TxnTypeCreate2:
+		return "create2"
+	case TxnTypeReconfig:
+		return "reconfig"
+	case TxnTypeCreateContainer:
+		return "createContainer"
+	case TxnTypeDeleteContainer:
+		return "deleteContainer"
+	case TxnTypeCreateTTL:
+		return "createTTL"
+	case TxnTypeCreateSession:
+		return "createSession"
+	case TxnTypeCloseSession:
+		return "closeSession"
+	default:
+		return fmt.Sprintf("unknown(%d)", txnType)
+	}
+}
+
+// ACL is a permission entry of a znode
+type ACL struct {
+	Perms  int32  `json:"perms"`
+	Scheme string `json:"scheme"`
+	ID     string `json:"id"`
+}
+
+// TxnBody is the decoded body of a transaction, one of the *Txn types below
+type TxnBody interface {
+	// TxnPath returns the znode the transaction changes, empty for session and error transactions
+	TxnPath() string
+}
+
+// CreateTxn creates a znode (create and create2)
+type CreateTxn struct {
+	Path           string `json:"path"`
+	Data           []byte `json:"data"`
+	ACL            []ACL  `json:"acl"`
+	Ephemeral      bool   `json:"ephemeral"`
+	ParentCVersion int32  `json:"parent_cversion"` // -1 in the pre 3.3 format without it
+	EphemeralOwner int64  `json:"ephemeral_owner"` // session of an ephemeral node, taken from the header
+}
+
+// CreateContainerTxn creates a container znode
+type CreateContainerTxn struct {
+	Path           string `json:"path"`
+	Data           []byte `json:"data"`
+	ACL            []ACL  `json:"acl"`
+	ParentCVersion int32  `json:"parent_cversion"`
+	EphemeralOwner int64  `json:"ephemeral_owner"` // always ContainerEphemeralOwner
+}
+
+// CreateTTLTxn creates a znode that expires after TTL milliseconds without children
+type CreateTTLTxn struct {
+	Path           string `json:"path"`
+	Data           []byte `json:"data"`
+	ACL            []ACL  `json:"acl"`
+	ParentCVersion int32  `json:"parent_cversion"`
+	TTL            int64  `json:"ttl"`
+	EphemeralOwner int64  `json:"ephemeral_owner"` // TTL encoded as an ephemeral owner
+}
+
+// DeleteTxn deletes a znode (delete and deleteContainer)
+type DeleteTxn struct {
+	Path string `json:"path"`
+}
+
+// SetDataTxn sets the data of a znode. Reconfig uses it to update /zookeeper/config.
+type SetDataTxn struct {
+	Path    string `json:"path"`
+	Data    []byte `json:"data"`
+	Version int32  `json:"version"`
+}
+
+// SetACLTxn sets the ACL of a znode
+type SetACLTxn struct {
+	Path    string `json:"path"`
+	ACL     []ACL  `json:"acl"`
+	Version int32  `json:"version"`
+}
+
+// CheckVersionTxn checks the version of a znode inside a multi
+type CheckVersionTxn struct {
+	Path    string `json:"path"`
+	Version int32  `json:"version"`
+}
+
+// MultiTxn applies several operations atomically
+type MultiTxn struct {
+	Ops []*MultiOp `json:"ops"`
+}
+
+// MultiOp is a single operation of a multi transaction
+type MultiOp struct {
+	Type int32   `json:"type"`
+	Body TxnBody `json:"body"`
+}
+
+// CreateSessionTxn creates a session
+type CreateSessionTxn struct {
+	Timeout int32 `json:"timeout"`
+}
+
+// CloseSessionTxn closes a session and deletes its ephemeral nodes.
+// Paths is only recorded by ZooKeeper 3.6+.
+type CloseSessionTxn struct {
+	Paths []string `json:"paths,omitempty"`
+}
+
+// ErrorTxn records a failed operation, e.g. inside a multi
+type ErrorTxn struct {
+	Err int32 `json:"err"`
+}
+
+// UnknownTxn holds the raw body of a transaction type this package does not decode
+type UnknownTxn struct {
+	Type int32  `json:"type"`
+	Data []byte `json:"data"`
+}
+
+// TxnDigest is the data tree digest ZooKeeper 3.6+ appends after the body
+type TxnDigest struct {
+	Version    int32 `json:"version"`
+	TreeDigest int64 `json:"tree_digest"`
+}
+
+// TxnPath returns the znode path
+func (t *CreateTxn) TxnPath() string { return t.Path }
+
+// TxnPath returns the znode path
+func (t *CreateContainerTxn) TxnPath() string { return t.Path }
+
+// TxnPath returns the znode path
+func (t *CreateTTLTxn) TxnPath() string { return t.Path }
+
+// TxnPath returns the znode path
+func (t *DeleteTxn) TxnPath() string { return t.Path }
+
+// TxnPath returns the znode path
+func (t *SetDataTxn) TxnPath() string { return t.Path }
+
+// TxnPath returns the znode path
+func (t *SetACLTxn) TxnPath() string { return t.Path }
+
+// TxnPath returns the znode path
+func (t *CheckVersionTxn) TxnPath() string { return t.Path }
+
+// TxnPath returns an empty path, the ops carry their own
+func (t *MultiTxn) TxnPath() string { return "" }
+
+// TxnPath returns an empty path
+func (t *CreateSessionTxn) TxnPath() string { return "" }
+
+// TxnPath returns an empty path
+func (t *CloseSessionTxn) TxnPath() string { return "" }
+
+// TxnPath returns an empty path
+func (t *ErrorTxn) TxnPath() string { return "" }
+
+// TxnPath returns an empty path
+func (t *UnknownTxn) TxnPath() string { return "" }
+
+// Body decodes the transaction body that follows the header. Types this package
+// does not know are returned as *UnknownTxn. The result is cached.
+func (t *Transaction) Body() (TxnBody, error) {
+	if err := t.decodeBody(); err != nil {
+		return nil, err
+	}
+	return t.body, nil
+}
+
+// Digest returns the data tree digest recorded after the body, nil when the
+// server did not write one
+func (t *Transaction) Digest() (*TxnDigest, error) {
+	if err := t.decodeBody(); err != nil {
+		return nil, err
+	}
+	return t.digest, nil
+}
+
+// decodeBody decodes the body and digest once, like SerializeUtils.deserializeTxn
+func (t *Transaction) decodeBody() error {
+	if t.body != nil || t.bodyErr != nil {
+		return t.bodyErr
+	}
+	if len(t.Data) < TxnHeaderSize {
+		t.bodyErr = NewCorruptionError("invalid data length").WithContext("length", len(t.Data))
+		return t.bodyErr
+	}
+
+	data := t.Data[TxnHeaderSize:]
+	in := jute.NewInputArchive(bytes.NewReader(data))
+	remaining := func() int { return len(data) - int(in.Offset()) }
+
+	var err error
+	record := newTxnRecord(t.Type)
+	switch {
+	case record == nil:
+		t.body = &UnknownTxn{Type: t.Type, Data: append([]byte(nil), data...)}
+		return nil
+	case t.Type == TxnTypeCloseSession && remaining() == 12:
+		// Before 3.6 the body is empty. A digest alone is 12 bytes and a path
+		// list at least 4, so treat 12 bytes as a digest.
+	default:
+		err = in.ReadRecord(record)
+	}
+	if err == nil && remaining() > 0 {
+		t.digest = &TxnDigest{}
+		err = in.ReadRecord(t.digest)
+	}
+	if err == nil && remaining() > 0 {
+		err = fmt.Errorf("%d unexpected bytes after body", remaining())
+	}
+	if err != nil {
+		t.digest = nil
+		t.bodyErr = NewCorruptionError("failed to decode transaction body").WithError(err).
+			WithContext("zxid", t.Zxid).WithContext("type", TxnTypeName(t.Type))
+		return t.bodyErr
+	}
+
+	setEphemeralOwner(record, t.ClientId)
+	t.body = record
+	return nil
+}
+
+// txnRecord is a TxnBody with a Jute encoding
+type txnRecord interface {
+	TxnBody
+	jute.Record
+}
+
+// newTxnRecord returns an empty record for a transaction type, nil for unknown types
+func newTxnRecord(txnType int32) txnRecord {
+	switch txnType {
+	case TxnTypeCreate, TxnTypeCreate2:
+		return &CreateTxn{}
+	case TxnTypeCreateContainer:
+		return &CreateContainerTxn{}
+	case TxnTypeCreateTTL:
+		return &CreateTTLTxn{}
+	case TxnTypeDelete, TxnTypeDeleteContainer:
+		return &DeleteTxn{}
+	case TxnTypeSetData, TxnTypeReconfig:
+		return &SetDataTxn{}
+	case TxnTypeSetACL:
+		return &SetACLTxn{}
+	case TxnTypeCheck:
+		return &CheckVersionTxn{}
+	case TxnTypeMulti:
+		return &MultiTxn{}
+	case TxnTypeCreateSession:
+		return &CreateSessionTxn{}
+	case TxnTypeCloseSession:
+		return &CloseSessionTxn{}
+	case TxnTypeError:
+		return &ErrorTxn{}
+	default:
+		return nil
+	}
+}
+
+// setEphemeralOwner sets the owner of ephemeral nodes to the session of the transaction
+func setEphemeralOwner(body TxnBody, clientID int64) {
+	switch txn := body.(type) {
+	case *CreateTxn:
+		if txn.Ephemeral {
+			txn.EphemeralOwner = clientID
+		}
+	case *MultiTxn:
+		for _, op := range txn.Ops {
+			setEphemeralOwner(op.Body, clientID)
+		}
+	}
+}
+
+// Serialize writes the ACL
+func (a *ACL) Serialize(out *jute.OutputArchive) error {
+	if err := out.WriteInt(a.Perms); err != nil {
+		return err
+	}
+	if err := out.WriteString(a.Scheme); err != nil {
+		return err
+	}
+	return out.WriteString(a.ID)
+}
+
+// Deserialize reads the ACL
+func (a *ACL) Deserialize(in *jute.InputArchive) error {
+	var err error
+	if a.Perms, err = in.ReadInt(); err != nil {
+		return err
+	}
+	if a.Scheme, err = in.ReadString(); err != nil {
+		return err
+	}
+	a.ID, err = in.ReadString()
+	return err
+}
+
+// writeACLs writes a vector of ACLs
+func writeACLs(out *jute.OutputArchive, acls []ACL) error {
+	if acls == nil {
+		return out.StartVector(-1)
+	}
+	if err := out.StartVector(len(acls)); err != nil {
+		return err
+	}
+	for i := range acls {
+		if err := out.WriteRecord(&acls[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readACLs reads a vector of ACLs, nil for a null vector
+func readACLs(in *jute.InputArchive) ([]ACL, error) {
+	count, err := in.StartVector()
+	if err != nil || count < 0 {
+		return nil, err
+	}
+	acls := make([]ACL, count)
+	for i := range acls {
+		if err := in.ReadRecord(&acls[i]); err != nil {
+			return nil, err
+		}
+	}
+	return acls, nil
+}
+
+// Serialize writes the transaction
+func (t *CreateTxn) Serialize(out *jute.OutputArchive) error {
+	if err := out.WriteString(t.Path); err != nil {
+		return err
+	}
+	if err := out.WriteBuffer(t.Data); err != nil {
+		return err
+	}
+	if err := writeACLs(out, t.ACL); err != nil {
+		return err
+	}
+	if err := out.WriteBool(t.Ephemeral); err != nil {
+		return err
+	}
+	return out.WriteInt(t.ParentCVersion)
+}
+
+// Deserialize reads the transaction, accepting the CreateTxnV0 format
+func (t *CreateTxn) Deserialize(in *jute.InputArchive) error {
+	var err error
+	if t.Path, err = in.ReadString(); err != nil {
+		return err
+	}
+	if t.Data, err = in.ReadBuffer(); err != nil {
+		return err
+	}
+	if t.ACL, err = readACLs(in); err != nil {
+		return err
+	}
+	if t.Ephemeral, err = in.ReadBool(); err != nil {
+		return err
+	}
+	// CreateTxnV0 of ZooKeeper before 3.3 ends here
+	t.ParentCVersion, err = in.ReadInt()
+	if err == io.EOF {
+		t.ParentCVersion, err = -1, nil
+	}
+	return err
+}
+
+// Serialize writes the transaction
+func (t *CreateContainerTxn) Serialize(out *jute.OutputArchive) error {
+	if err := out.WriteString(t.Path); err != nil {
+		return err
+	}
+	if err := out.WriteBuffer(t.Data); err != nil {
+		return err
+	}
+	if err := writeACLs(out, t.ACL); err != nil {
+		return err
+	}
+	return out.WriteInt(t.ParentCVersion)
+}
+
+// Deserialize reads the transaction
+func (t *CreateContainerTxn) Deserialize(in *jute.InputArchive) error {
+	var err error
+	if t.Path, err = in.ReadString(); err != nil {
+		return err
+	}
+	if t.Data, err = in.ReadBuffer(); err != nil {
+		return err
+	}
+	if t.ACL, err = readACLs(in); err != nil {
+		return err
+	}
+	if t.ParentCVersion, err = in.ReadInt(); err != nil {
+		return err
+	}
+	t.EphemeralOwner = ContainerEphemeralOwner
+	return nil
+}
+
+// Serialize writes the transaction
+func (t *CreateTTLTxn) Serialize(out *jute.OutputArchive) error {
+	if err := out.WriteString(t.Path); err != nil {
+		return err
+	}
+	if err := out.WriteBuffer(t.Data); err != nil {
+		return err
+	}
+	if err := writeACLs(out, t.ACL); err != nil {
+		return err
+	}
+	if err := out.WriteInt(t.ParentCVersion); err != nil {
+		return err
+	}
+	return out.WriteLong(t.TTL)
+}
+
+// Deserialize reads the transaction
+func (t *CreateTTLTxn) Deserialize(in *jute.InputArchive) error {
+	var err error
+	if t.Path, err = in.ReadString(); err != nil {
+		return err
+	}
+	if t.Data, err = in.ReadBuffer(); err != nil {
+		return err
+	}
+	if t.ACL, err = readACLs(in); err != nil {
+		return err
+	}
+	if t.ParentCVersion, err = in.ReadInt(); err != nil {
+		return err
+	}
+	if t.TTL, err = in.ReadLong(); err != nil {
+		return err
+	}
+	t.EphemeralOwner = ttlEphemeralOwnerMask | t.TTL
+	return nil
+}
+
+// Serialize writes the transaction
+func (t *DeleteTxn) Serialize(out *jute.OutputArchive) error {
+	return out.WriteString(t.Path)
+}
+
+// Deserialize reads the transaction
+func (t *DeleteTxn) Deserialize(in *jute.InputArchive) error {
+	var err error
+	t.Path, err = in.ReadString()
+	return err
+}
+
+// Serialize writes the transaction
+func (t *SetDataTxn) Serialize(out *jute.OutputArchive) error {
+	if err := out.WriteString(t.Path); err != nil {
+		return err
+	}
+	if err := out.WriteBuffer(t.Data); err != nil {
+		return err
+	}
+	return out.WriteInt(t.Version)
+}
+
+// Deserialize reads the transaction
+func (t *SetDataTxn) Deserialize(in *jute.InputArchive) error {
+	var err error
+	if t.Path, err = in.ReadString(); err != nil {
+		return err
+	}
+	if t.Data, err = in.ReadBuffer(); err != nil {
+		return err
+	}
+	t.Version, err = in.ReadInt()
+	return err
+}
+
+// Serialize writes the transaction
+func (t *SetACLTxn) Serialize(out *jute.OutputArchive) error {
+	if err := out.WriteString(t.Path); err != nil {
+		return err
+	}
+	if err := writeACLs(out, t.ACL); err != nil {
+		return err
+	}
+	return out.WriteInt(t.Version)
+}
+
+// Deserialize reads the transaction
+func (t *SetACLTxn) Deserialize(in *jute.InputArchive) error {
+	var err error
+	if t.Path, err = in.ReadString(); err != nil {
+		return err
+	}
+	if t.ACL, err = readACLs(in); err != nil {
+		return err
+	}
+	t.Version, err = in.ReadInt()
+	return err
+}
+
+// Serialize writes the transaction
+func (t *CheckVersionTxn) Serialize(out *jute.OutputArchive) error {
+	if err := out.WriteString(t.Path); err != nil {
+		return err
+	}
+	return out.WriteInt(t.Version)
+}
+
+// Deserialize reads the transaction
+func (t *CheckVersionTxn) Deserialize(in *jute.InputArchive) error {
+	var err error
+	if t.Path, err = in.ReadString(); err != nil {
+		return err
+	}
+	t.Version, err = in.ReadInt()
+	return err
+}
+
+// Serialize writes the ops as (type, buffer) pairs
+func (t *MultiTxn) Serialize(out *jute.OutputArchive) error {
+	if err := out.StartVector(len(t.Ops)); err != nil {
+		return err
+	}
+	for _, op := range t.Ops {
+		var data []byte
+		switch body := op.Body.(type) {
+		case *UnknownTxn:
+			data = body.Data
+		case jute.Record:
+			var err error
+			if data, err = jute.Marshal(body); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("cannot serialize multi op of type %T", op.Body)
+		}
+
+		if err := out.WriteInt(op.Type); err != nil {
+			return err
+		}
+		if err := out.WriteBuffer(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deserialize reads the ops and decodes their bodies
+func (t *MultiTxn) Deserialize(in *jute.InputArchive) error {
+	count, err := in.StartVector()
+	if err != nil {
+		return err
+	}
+
+	t.Ops = make([]*MultiOp, 0, max(count, 0))
+	for i := 0; i < count; i++ {
+		op := &MultiOp{}
+		if op.Type, err = in.ReadInt(); err != nil {
+			return err
+		}
+		data, err := in.ReadBuffer()
+		if err != nil {
+			return err
+		}
+
+		if record := newTxnRecord(op.Type); record != nil {
+			if err = jute.Unmarshal(data, record); err != nil {
+				return fmt.Errorf("multi op %d (%s): %w", i, TxnTypeName(op.Type), err)
+			}
+			op.Body = record
+		} else {
+			op.Body = &UnknownTxn{Type: op.Type, Data: data}
+		}
+		t.Ops = append(t.Ops, op)
+	}
+
+	return nil
+}
+
+// Serialize writes the transaction
+func (t *CreateSessionTxn) Serialize(out *jute.OutputArchive) error {
+	return out.WriteInt(t.Timeout)
+}
+
+// Deserialize reads the transaction
+func (t *CreateSessionTxn) Deserialize(in *jute.InputArchive) error {
+	var err error
+	t.Timeout, err = in.ReadInt()
+	return err
+}
+
+// Serialize writes the paths, nothing when there are none like ZooKeeper before 3.6
+func (t *CloseSessionTxn) Serialize(out *jute.OutputArchive) error {
+	if t.Paths == nil {
+		return nil
+	}
+	if err := out.StartVector(len(t.Paths)); err != nil {
+		return err
+	}
+	for _, path := range t.Paths {
+		if err := out.WriteString(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deserialize reads the paths, an empty body is the format before 3.6
+func (t *CloseSessionTxn) Deserialize(in *jute.InputArchive) error {
+	count, err := in.StartVector()
+	if err == io.EOF || count < 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	t.Paths = make([]string, count)
+	for i := range t.Paths {
+		if t.Paths[i], err = in.ReadString(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Serialize writes the transaction
+func (t *ErrorTxn) Serialize(out *jute.OutputArchive) error {
+	return out.WriteInt(t.Err)
+}
+
+// Deserialize reads the transaction
+func (t *ErrorTxn) Deserialize(in *jute.InputArchive) error {
+	var err error
+	t.Err, err = in.ReadInt()
+	return err
+}
+
+// Serialize writes the digest
+func (d *TxnDigest) Serialize(out *jute.OutputArchive) error {
+	if err := out.WriteInt(d.Version); err != nil {
+		return err
+	}
+	return out.WriteLong(d.TreeDigest)
+}
+
+// Deserialize reads the digest
+func (d *TxnDigest) Deserialize(in *jute.InputArchive) error {
+	var err error
+	if d.Version, err = in.ReadInt(); err != nil {
+		return err
+	}
+	d.TreeDigest, err = in.ReadLong()
+	return err
+}
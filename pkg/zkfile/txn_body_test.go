@@ -0,0 +1,313 @@
+package zkfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testJute builds Jute encoded records for tests
+type testJute struct {
+	bytes.Buffer
+}
+
+func (j *testJute) int(v int32) *testJute {
+	binary.Write(j, binary.BigEndian, v)
+	return j
+}
+
+func (j *testJute) long(v int64) *testJute {
+	binary.Write(j, binary.BigEndian, v)
+	return j
+}
+
+func (j *testJute) bool(v bool) *testJute {
+	if v {
+		j.WriteByte(1)
+	} else {
+		j.WriteByte(0)
+	}
+	return j
+}
+
+func (j *testJute) buffer(v []byte) *testJute {
+	if v == nil {
+		return j.int(-1)
+	}
+	j.int(int32(len(v)))
+	j.Write(v)
+	return j
+}
+
+func (j *testJute) string(v string) *testJute {
+	return j.buffer([]byte(v))
+}
+
+func (j *testJute) acls(acls ...ACL) *testJute {
+	j.int(int32(len(acls)))
+	for _, acl := range acls {
+		j.int(acl.Perms).string(acl.Scheme).string(acl.ID)
+	}
+	return j
+}
+
+var testWorldACL = ACL{Perms: 31, Scheme: "world", ID: "anyone"}
+
+func newTestBodyTransaction(txnType int32, body []byte) *Transaction {
+	header := &testJute{}
+	header.long(0x1234).int(7).long(0x100000001).long(1000).int(txnType)
+	data := append(header.Bytes(), body...)
+	return &Transaction{Length: int32(len(data)), Data: data, ClientId: 0x1234, Zxid: ZXID(0x100000001), Type: txnType}
+}
+
+func TestTransaction_Body(t *testing.T) {
+	createBody := func() *testJute {
+		return (&testJute{}).string("/app/node").buffer([]byte("hello")).acls(testWorldACL)
+	}
+
+	tests := []struct {
+		name    string
+		txnType int32
+		body    []byte
+		want    TxnBody
+	}{
+		{
+			name:    "create",
+			txnType: TxnTypeCreate,
+			body:    createBody().bool(false).int(3).Bytes(),
+			want:    &CreateTxn{Path: "/app/node", Data: []byte("hello"), ACL: []ACL{testWorldACL}, ParentCVersion: 3},
+		},
+		{
+			name:    "create2 ephemeral",
+			txnType: TxnTypeCreate2,
+			body:    createBody().bool(true).int(4).Bytes(),
+			want: &CreateTxn{Path: "/app/node", Data: []byte("hello"), ACL: []ACL{testWorldACL},
+				Ephemeral: true, ParentCVersion: 4, EphemeralOwner: 0x1234},
+		},
+		{
+			name:    "create v0",
+			txnType: TxnTypeCreate,
+			body:    createBody().bool(false).Bytes(),
+			want:    &CreateTxn{Path: "/app/node", Data: []byte("hello"), ACL: []ACL{testWorldACL}, ParentCVersion: -1},
+		},
+		{
+			name:    "create container",
+			txnType: TxnTypeCreateContainer,
+			body:    createBody().int(5).Bytes(),
+			want: &CreateContainerTxn{Path: "/app/node", Data: []byte("hello"), ACL: []ACL{testWorldACL},
+				ParentCVersion: 5, EphemeralOwner: ContainerEphemeralOwner},
+		},
+		{
+			name:    "create ttl",
+			txnType: TxnTypeCreateTTL,
+			body:    createBody().int(6).long(60000).Bytes(),
+			want: &CreateTTLTxn{Path: "/app/node", Data: []byte("hello"), ACL: []ACL{testWorldACL},
+				ParentCVersion: 6, TTL: 60000, EphemeralOwner: -0x100000000000000 | 60000},
+		},
+		{
+			name:    "delete",
+			txnType: TxnTypeDelete,
+			body:    (&testJute{}).string("/app/node").Bytes(),
+			want:    &DeleteTxn{Path: "/app/node"},
+		},
+		{
+			name:    "set data with null data",
+			txnType: TxnTypeSetData,
+			body:    (&testJute{}).string("/app/node").buffer(nil).int(2).Bytes(),
+			want:    &SetDataTxn{Path: "/app/node", Version: 2},
+		},
+		{
+			name:    "reconfig",
+			txnType: TxnTypeReconfig,
+			body:    (&testJute{}).string("/zookeeper/config").buffer([]byte("server.1=zk-0:2888:3888")).int(-1).Bytes(),
+			want:    &SetDataTxn{Path: "/zookeeper/config", Data: []byte("server.1=zk-0:2888:3888"), Version: -1},
+		},
+		{
+			name:    "set acl",
+			txnType: TxnTypeSetACL,
+			body:    (&testJute{}).string("/app/node").acls(ACL{Perms: 1, Scheme: "digest", ID: "user:hash"}).int(1).Bytes(),
+			want:    &SetACLTxn{Path: "/app/node", ACL: []ACL{{Perms: 1, Scheme: "digest", ID: "user:hash"}}, Version: 1},
+		},
+		{
+			name:    "check version",
+			txnType: TxnTypeCheck,
+			body:    (&testJute{}).string("/app/node").int(8).Bytes(),
+			want:    &CheckVersionTxn{Path: "/app/node", Version: 8},
+		},
+		{
+			name:    "create session",
+			txnType: TxnTypeCreateSession,
+			body:    (&testJute{}).int(30000).Bytes(),
+			want:    &CreateSessionTxn{Timeout: 30000},
+		},
+		{
+			name:    "close session",
+			txnType: TxnTypeCloseSession,
+			want:    &CloseSessionTxn{},
+		},
+		{
+			name:    "close session with paths",
+			txnType: TxnTypeCloseSession,
+			body:    (&testJute{}).int(2).string("/app/lock-1").string("/app/lock-2").Bytes(),
+			want:    &CloseSessionTxn{Paths: []string{"/app/lock-1", "/app/lock-2"}},
+		},
+		{
+			name:    "error",
+			txnType: TxnTypeError,
+			body:    (&testJute{}).int(-101).Bytes(),
+			want:    &ErrorTxn{Err: -101},
+		},
+		{
+			name:    "unknown type",
+			txnType: 99,
+			body:    []byte{1, 2, 3},
+			want:    &UnknownTxn{Type: 99, Data: []byte{1, 2, 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txn := newTestBodyTransaction(tt.txnType, tt.body)
+
+			body, err := txn.Body()
+			if err != nil {
+				t.Fatalf("Body() error = %v", err)
+			}
+			if !reflect.DeepEqual(body, tt.want) {
+				t.Errorf("Body() = %+v, want %+v", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransaction_BodyMulti(t *testing.T) {
+	create := (&testJute{}).string("/app/a").buffer([]byte("a")).acls(testWorldACL).bool(true).int(0).Bytes()
+	setData := (&testJute{}).string("/app/b").buffer([]byte("b")).int(3).Bytes()
+	errTxn := (&testJute{}).int(0).Bytes()
+
+	body := (&testJute{}).int(3).
+		int(TxnTypeCreate).buffer(create).
+		int(TxnTypeSetData).buffer(setData).
+		int(TxnTypeError).buffer(errTxn)
+	txn := newTestBodyTransaction(TxnTypeMulti, body.Bytes())
+
+	decoded, err := txn.Body()
+	if err != nil {
+		t.Fatalf("Body() error = %v", err)
+	}
+	multi, ok := decoded.(*MultiTxn)
+	if !ok || len(multi.Ops) != 3 {
+		t.Fatalf("Body() = %+v, want multi with 3 ops", decoded)
+	}
+
+	if c, ok := multi.Ops[0].Body.(*CreateTxn); !ok || c.Path != "/app/a" || c.EphemeralOwner != 0x1234 {
+		t.Errorf("op 0 = %+v, want ephemeral create of /app/a", multi.Ops[0].Body)
+	}
+	if s, ok := multi.Ops[1].Body.(*SetDataTxn); !ok || s.TxnPath() != "/app/b" || s.Version != 3 {
+		t.Errorf("op 1 = %+v, want setData of /app/b", multi.Ops[1].Body)
+	}
+	if multi.Ops[2].Type != TxnTypeError {
+		t.Errorf("op 2 type = %d, want error", multi.Ops[2].Type)
+	}
+
+	t.Run("malformed op", func(t *testing.T) {
+		body := (&testJute{}).int(1).int(TxnTypeDelete).buffer([]byte{0, 0, 0, 9})
+		_, err := newTestBodyTransaction(TxnTypeMulti, body.Bytes()).Body()
+		if err == nil || !strings.Contains(err.Error(), "multi op 0 (delete)") {
+			t.Errorf("Body() error = %v, want multi op failure", err)
+		}
+	})
+}
+
+func TestTransaction_Digest(t *testing.T) {
+	body := (&testJute{}).string("/app/node").int(2).long(0x7766554433221100)
+	txn := newTestBodyTransaction(TxnTypeDelete, body.Bytes())
+
+	digest, err := txn.Digest()
+	if err != nil {
+		t.Fatalf("Digest() error = %v", err)
+	}
+	if digest == nil || digest.Version != 2 || digest.TreeDigest != 0x7766554433221100 {
+		t.Errorf("Digest() = %+v", digest)
+	}
+
+	t.Run("without digest", func(t *testing.T) {
+		txn := newTestBodyTransaction(TxnTypeDelete, (&testJute{}).string("/app/node").Bytes())
+		if digest, err := txn.Digest(); err != nil || digest != nil {
+			t.Errorf("Digest() = %+v, %v, want nil", digest, err)
+		}
+	})
+
+	t.Run("close session with digest only", func(t *testing.T) {
+		body := (&testJute{}).int(2).long(42)
+		txn := newTestBodyTransaction(TxnTypeCloseSession, body.Bytes())
+		digest, err := txn.Digest()
+		if err != nil || digest == nil || digest.TreeDigest != 42 {
+			t.Errorf("Digest() = %+v, %v, want tree digest 42", digest, err)
+		}
+	})
+}
+
+func TestTransaction_BodyMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		txnType int32
+		body    []byte
+	}{
+		{"truncated path", TxnTypeDelete, []byte{0, 0, 0, 10, '/'}},
+		{"negative length", TxnTypeDelete, (&testJute{}).int(-2).Bytes()},
+		{"oversized acl vector", TxnTypeSetACL, (&testJute{}).string("/a").int(1 << 30).Bytes()},
+		{"missing version", TxnTypeSetData, (&testJute{}).string("/a").buffer([]byte("x")).Bytes()},
+		{"trailing bytes", TxnTypeCreateSession, (&testJute{}).int(30000).int(1).Bytes()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txn := newTestBodyTransaction(tt.txnType, tt.body)
+			_, err := txn.Body()
+			var backupErr *BackupError
+			if !errors.As(err, &backupErr) || backupErr.Category != ErrorCategoryCorruption {
+				t.Fatalf("Body() error = %v, want corruption", err)
+			}
+			if _, again := txn.Body(); again != err {
+				t.Errorf("Body() should return the cached error")
+			}
+		})
+	}
+}
+
+func TestTxnLogReader_Body(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.100000001")
+	createTestTxnLog(t, path, 1, []testTransaction{
+		{ClientId: 5, Zxid: ZXID(0x100000001), Type: TxnTypeCreateSession, Body: (&testJute{}).int(4000).Bytes()},
+		{ClientId: 5, Zxid: ZXID(0x100000002), Type: TxnTypeCreate,
+			Body: (&testJute{}).string("/lock").buffer(nil).acls(testWorldACL).bool(true).int(1).Bytes()},
+	})
+
+	reader, err := OpenTxnLog(path)
+	if err != nil {
+		t.Fatalf("OpenTxnLog() error = %v", err)
+	}
+	defer reader.Close()
+
+	var paths []string
+	for {
+		txn, err := reader.ReadTransaction()
+		if err != nil {
+			break
+		}
+		body, err := txn.Body()
+		if err != nil {
+			t.Fatalf("Body() error = %v", err)
+		}
+		paths = append(paths, TxnTypeName(txn.Type)+":"+body.TxnPath())
+	}
+
+	if strings.Join(paths, ",") != "createSession:,create:/lock" {
+		t.Errorf("transactions = %v", paths)
+	}
+}
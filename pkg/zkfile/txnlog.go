@@ -1,13 +1,16 @@
 package zkfile
 
 import (
-	"encoding/binary"
+	"bytes"
 	"hash/adler32"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
+
+	"github.com/zookeeper-backup/pkg/jute"
 )
 
 const (
@@ -17,22 +20,32 @@ const (
 	// MagicNumber is the magic number for TxnLog files "ZKLG"
 	MagicNumber = 0x5a4b4c47
 
-	// MaxRecordSize is the maximum size of a single record (10MB)
-	MaxRecordSize = 10 * 1024 * 1024
+	// DefaultMaxRecordSize is the default maximum size of a single record (10MB)
+	DefaultMaxRecordSize = 10 * 1024 * 1024
 
 	// HeaderSize is the size of the file header (bytes)
 	HeaderSize = 16 // 4(MagicNumber) + 4(LogVersion) + 8(DbID)
+
+	// EndOfRecord is the byte ('B') that follows every record body
+	EndOfRecord = 0x42
 )
 
+// MaxRecordSize is the maximum size of a single record. Records above it are
+// treated as corruption; raise it for servers with a larger jute.maxbuffer.
+var MaxRecordSize int32 = DefaultMaxRecordSize
+
 // TxnLogInfo contains TxnLog file information
 type TxnLogInfo struct {
 	Name             string `json:"name"`
 	StartZxid        ZXID   `json:"start_zxid"`
 	EndZxid          ZXID   `json:"end_zxid"`
-	Size             int64  `json:"size"`
-	Status           string `json:"status"` // valid, truncated, corrupted
+	Size             int64  `json:"size"`        // Uncompressed size, including the preallocated tail
+	LogicalEnd       int64  `json:"logical_end"` // Offset after the last valid record
+	Status           string `json:"status"`      // valid, truncated, corrupted
 	TransactionCount int    `json:"transaction_count"`
 	Note             string `json:"note,omitempty"`
+	Compression      string `json:"compression,omitempty"`     // none, gzip or zstd
+	CompressedSize   int64  `json:"compressed_size,omitempty"` // Stored size when compressed
 }
 
 // GetTxnLogInfo extracts information from a txnlog file
@@ -65,23 +78,66 @@ func GetTxnLogInfo(path string) (*TxnLogInfo, error) {
 		endZxid = startZxid
 	}
 
-	return &TxnLogInfo{
+	codec, size, err := GetCompressionInfo(path)
+	if err != nil {
+		return nil, err
+	}
+
+	txnlogInfo := &TxnLogInfo{
 		Name:             filepath.Base(path),
 		StartZxid:        startZxid,
 		EndZxid:          endZxid,
-		Size:             info.Size(),
+		Size:             size,
+		LogicalEnd:       result.LogicalEnd,
 		Status:           status,
 		TransactionCount: result.ValidTransactionCount,
-	}, nil
+		Compression:      codec,
+	}
+	if codec != CompressionNone {
+		txnlogInfo.CompressedSize = info.Size()
+	}
+
+	return txnlogInfo, nil
 }
 
-// TxnLogHeader is the header of a TxnLog file
+// TxnLogHeader is the header of a TxnLog file (FileHeader)
 type TxnLogHeader struct {
 	Magic   uint32 // 0x5a4b4c47 ("ZKLG")
 	Version uint32 // Version number, usually 2
 	DbId    uint64 // Cluster Database ID
 }
 
+// Serialize writes the header
+func (h *TxnLogHeader) Serialize(out *jute.OutputArchive) error {
+	if err := out.WriteInt(int32(h.Magic)); err != nil {
+		return err
+	}
+	if err := out.WriteInt(int32(h.Version)); err != nil {
+		return err
+	}
+	return out.WriteLong(int64(h.DbId))
+}
+
+// Deserialize reads the header
+func (h *TxnLogHeader) Deserialize(in *jute.InputArchive) error {
+	magic, err := in.ReadInt()
+	if err != nil {
+		return err
+	}
+	h.Magic = uint32(magic)
+	version, err := in.ReadInt()
+	if err != nil {
+		return err
+	}
+	h.Version = uint32(version)
+	dbID, err := in.ReadLong()
+	if err != nil {
+		return err
+	}
+	h.DbId = uint64(dbID)
+	return nil
+}
+
 // Transaction represents a transaction record
 type Transaction struct {
 	Length   int32  // Record body length
@@ -92,37 +148,107 @@ type Transaction struct {
 	Cxid      int32 // Client transaction ID
 	Zxid      ZXID  // ZooKeeper's transaction ID
 	Timestamp int64 // Timestamp
-	Type      int32 // Transaction type
+	Type      int32 // Transaction type, see TxnType*
 
+	body    TxnBody    // Decoded body, see Body()
+	digest  *TxnDigest // Decoded digest, see Digest()
+	bodyErr error      // Error of decoding the body
 }
 
-// parse parses transaction data
+// parse parses the TxnHeader at the start of the transaction data
 func (t *Transaction) parse() error {
-	if len(t.Data) < 32 {
+	if len(t.Data) < TxnHeaderSize {
 		return NewCorruptionError("invalid data length").WithContext("length", len(t.Data))
 	}
 
-	// Parse fields (all fields are BigEndian)
-	t.ClientId = int64(binary.BigEndian.Uint64(t.Data[0:8]))
-	t.Cxid = int32(binary.BigEndian.Uint32(t.Data[8:12]))
-	t.Zxid = ZXID(binary.BigEndian.Uint64(t.Data[12:20]))
-	t.Timestamp = int64(binary.BigEndian.Uint64(t.Data[20:28]))
-	t.Type = int32(binary.BigEndian.Uint32(t.Data[28:32]))
+	in := jute.NewInputArchive(bytes.NewReader(t.Data[:TxnHeaderSize]))
+	if err := in.ReadRecord(txnHeader{t}); err != nil {
+		return NewCorruptionError("invalid transaction header").WithError(err)
+	}
 
 	return nil
 }
 
+// txnHeader is the Jute record of the TxnHeader fields of a transaction
+type txnHeader struct {
+	*Transaction
+}
+
+// Serialize writes the header fields
+func (h txnHeader) Serialize(out *jute.OutputArchive) error {
+	if err := out.WriteLong(h.ClientId); err != nil {
+		return err
+	}
+	if err := out.WriteInt(h.Cxid); err != nil {
+		return err
+	}
+	if err := out.WriteLong(int64(h.Zxid)); err != nil {
+		return err
+	}
+	if err := out.WriteLong(h.Timestamp); err != nil {
+		return err
+	}
+	return out.WriteInt(h.Type)
+}
+
+// Deserialize reads the header fields
+func (h txnHeader) Deserialize(in *jute.InputArchive) error {
+	var err error
+	if h.ClientId, err = in.ReadLong(); err != nil {
+		return err
+	}
+	if h.Cxid, err = in.ReadInt(); err != nil {
+		return err
+	}
+	zxid, err := in.ReadLong()
+	if err != nil {
+		return err
+	}
+	h.Zxid = ZXID(zxid)
+	if h.Timestamp, err = in.ReadLong(); err != nil {
+		return err
+	}
+	h.Type, err = in.ReadInt()
+	return err
+}
+
+// Time returns the transaction timestamp (milliseconds since the Unix epoch)
+func (t *Transaction) Time() time.Time {
+	return time.UnixMilli(t.Timestamp)
+}
+
 // TxnLogWriter is a writer for TxnLog files
 type TxnLogWriter struct {
-	path string
-	file *os.File
+	path   string
+	file   *os.File
+	output *jute.OutputArchive
 }
 
-// TxnLogReader is a reader for TxnLog files
+// TxnLogReader is a reader for TxnLog files.
+// Compressed files are decompressed transparently; positions are offsets in the
+// decompressed content.
 type TxnLogReader struct {
-	path   string
-	file   *os.File
-	header *TxnLogHeader
+	path        string
+	file        *os.File
+	input       *countingReader
+	archive     *jute.InputArchive // reads from input
+	decoder     io.Closer
+	compression string
+	header      *TxnLogHeader
+	logicalEnd  int64 // offset after the last record read successfully
+}
+
+// countingReader tracks the number of bytes read
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read reads from the underlying reader and counts the bytes read
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
 }
 
 // CreateTxnLog creates a new TxnLog file
@@ -133,8 +259,9 @@ func CreateTxnLog(path string, header *TxnLogHeader) (*TxnLogWriter, error) {
 	}
 
 	writer := &TxnLogWriter{
-		file: f,
-		path: path,
+		file:   f,
+		path:   path,
+		output: jute.NewOutputArchive(f),
 	}
 
 	err = writer.writeHeader(header)
@@ -165,19 +292,9 @@ func (w *TxnLogWriter) Close() error {
 
 // writeHeader writes the file header
 func (w *TxnLogWriter) writeHeader(header *TxnLogHeader) error {
-	err := binary.Write(w.file, binary.BigEndian, header.Magic)
-	if err != nil {
-		return NewIOError("failed to write magic").WithError(err).WithContext("path", w.path)
-	}
-
-	err = binary.Write(w.file, binary.BigEndian, header.Version)
-	if err != nil {
-		return NewIOError("failed to write version").WithError(err).WithContext("path", w.path)
-	}
-
-	err = binary.Write(w.file, binary.BigEndian, header.DbId)
+	err := w.output.WriteRecord(header)
 	if err != nil {
-		return NewIOError("failed to write dbid").WithError(err).WithContext("path", w.path)
+		return NewIOError("failed to write header").WithError(err).WithContext("path", w.path)
 	}
 
 	return nil
@@ -186,21 +303,25 @@ func (w *TxnLogWriter) writeHeader(header *TxnLogHeader) error {
 // WriteTransaction writes a transaction
 func (w *TxnLogWriter) WriteTransaction(txn *Transaction) error {
 	// Write checksum
-	err := binary.Write(w.file, binary.BigEndian, txn.Checksum)
+	err := w.output.WriteLong(txn.Checksum)
 	if err != nil {
 		return NewIOError("failed to write checksum").WithError(err).WithContext("path", w.path)
 	}
 
-	// Write length
-	err = binary.Write(w.file, binary.BigEndian, txn.Length)
+	// Write length and data, like BinaryOutputArchive.writeBuffer
+	if txn.Length != int32(len(txn.Data)) {
+		return NewValidationError("record length does not match data").
+			WithContext("path", w.path).WithContext("length", txn.Length).WithContext("data", len(txn.Data))
+	}
+	err = w.output.WriteBuffer(txn.Data)
 	if err != nil {
-		return NewIOError("failed to write length").WithError(err).WithContext("path", w.path)
+		return NewIOError("failed to write data").WithError(err).WithContext("path", w.path)
 	}
 
-	// Write data
-	_, err = w.file.Write(txn.Data)
+	// Write end of record marker
+	err = w.output.WriteByte(EndOfRecord)
 	if err != nil {
-		return NewIOError("failed to write data").WithError(err).WithContext("path", w.path)
+		return NewIOError("failed to write end of record").WithError(err).WithContext("path", w.path)
 	}
 
 	return nil
@@ -214,13 +335,30 @@ func OpenTxnLog(path string) (*TxnLogReader, error) {
 	}
 
 	reader := &TxnLogReader{
-		file: f,
-		path: path,
+		file:        f,
+		path:        path,
+		input:       &countingReader{r: f},
+		compression: CompressionNone,
 	}
 
+	if reader.compression, err = DetectCompression(path); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if reader.compression != CompressionNone {
+		decoder, _, err := newDecoder(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, NewCorruptionError("failed to open compressed txnlog").WithError(err).WithContext("path", path)
+		}
+		reader.input.r = decoder
+		reader.decoder, _ = decoder.(io.Closer)
+	}
+	reader.archive = jute.NewInputArchive(reader.input)
+
 	err = reader.readHeader()
 	if err != nil {
-		_ = f.Close()
+		_ = reader.Close()
 		return nil, err
 	}
 
@@ -237,84 +375,108 @@ func (r *TxnLogReader) Header() *TxnLogHeader {
 	return r.header
 }
 
-// Seek seeks to the specified position
+// Compression returns the compression codec of the file
+func (r *TxnLogReader) Compression() string {
+	return r.compression
+}
+
+// Seek seeks to the specified position, compressed files cannot seek
 func (r *TxnLogReader) Seek(offset int64, whence int) (int64, error) {
-	return r.file.Seek(offset, whence)
+	if r.compression != CompressionNone {
+		return 0, NewUserError("cannot seek in compressed txnlog").
+			WithContext("path", r.path).WithContext("compression", r.compression)
+	}
+
+	pos, err := r.file.Seek(offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	r.input.n = pos
+	return pos, nil
 }
 
 // Close closes the file
 func (r *TxnLogReader) Close() error {
+	if r.decoder != nil {
+		_ = r.decoder.Close()
+	}
 	if r.file != nil {
 		return r.file.Close()
 	}
 	return nil
 }
 
-// CurrentPosition returns the current file position
+// CurrentPosition returns the current position in the (decompressed) content
 func (r *TxnLogReader) CurrentPosition() (int64, error) {
-	return r.file.Seek(0, io.SeekCurrent)
+	return r.input.n, nil
+}
+
+// LogicalEnd returns the offset after the last record read successfully.
+// ZooKeeper preallocates txnlogs with zeros, so the file is usually larger.
+func (r *TxnLogReader) LogicalEnd() int64 {
+	return r.logicalEnd
 }
 
 // readHeader reads the file header
 func (r *TxnLogReader) readHeader() error {
 	r.header = &TxnLogHeader{}
 
-	// Read Magic Number (4 bytes)
-	err := binary.Read(r.file, binary.BigEndian, &r.header.Magic)
+	// Read Magic Number (4 bytes), Log Version (4 bytes) and DbId (8 bytes)
+	err := r.archive.ReadRecord(r.header)
+	if err == io.EOF {
+		return NewCorruptionError("empty file").WithContext("path", r.path)
+	}
 	if err != nil {
-		if err == io.EOF {
-			return NewCorruptionError("empty file").WithContext("path", r.path)
-		}
-		return NewIOError("failed to read magic").WithError(err).WithContext("path", r.path)
+		return NewCorruptionError("failed to read header").WithError(err).WithContext("path", r.path)
 	}
 	if r.header.Magic != MagicNumber {
 		return NewCorruptionError("invalid magic number").
 			WithContext("path", r.path).WithContext("magic", r.header.Magic).WithContext("expected", MagicNumber)
 	}
-
-	// Read Log Version (4 bytes)
-	err = binary.Read(r.file, binary.BigEndian, &r.header.Version)
-	if err != nil {
-		return NewIOError("failed to read version").WithError(err).WithContext("path", r.path)
-	}
 	if r.header.Version != LogVersion {
 		return NewCorruptionError("unsupported version").
 			WithContext("path", r.path).WithContext("version", r.header.Version).WithContext("expected", LogVersion)
 	}
-
-	// Read DbId (8 bytes)
-	err = binary.Read(r.file, binary.BigEndian, &r.header.DbId)
-	if err != nil {
-		return NewIOError("failed to read dbid").WithError(err).WithContext("path", r.path)
-	}
+	r.logicalEnd = r.input.n
 
 	return nil
 }
 
-// ReadTransaction reads the next transaction
+// ReadTransaction reads the next transaction. It returns io.EOF at the end of
+// the file and at a zero length record header, which marks the start of the
+// zero-filled tail ZooKeeper preallocates.
 func (r *TxnLogReader) ReadTransaction() (*Transaction, error) {
 	txn := &Transaction{}
 
 	// Read checksum (8 bytes)
-	err := binary.Read(r.file, binary.BigEndian, &txn.Checksum)
-	if err != nil {
+	var err error
+	txn.Checksum, err = r.archive.ReadLong()
+	if err == io.EOF {
 		return nil, err
 	}
+	if err != nil {
+		return nil, NewCorruptionError("failed to read checksum").WithError(err).WithContext("path", r.path)
+	}
 
 	// Read length (4 bytes)
-	err = binary.Read(r.file, binary.BigEndian, &txn.Length)
+	txn.Length, err = r.archive.ReadInt()
 	if err != nil {
 		return nil, NewCorruptionError("failed to read length").WithContext("path", r.path)
 	}
 
-	if txn.Length <= 0 || txn.Length > MaxRecordSize {
+	// ZooKeeper stops reading at the first empty record, like FileTxnIterator
+	if txn.Length == 0 {
+		return nil, io.EOF
+	}
+
+	if txn.Length < 0 || txn.Length > MaxRecordSize {
 		return nil, NewCorruptionError("invalid record length").
 			WithContext("path", r.path).WithContext("length", txn.Length).WithContext("max", MaxRecordSize)
 	}
 
 	// Read record body
 	txn.Data = make([]byte, txn.Length)
-	_, err = io.ReadFull(r.file, txn.Data)
+	_, err = io.ReadFull(r.input, txn.Data)
 	if err != nil {
 		return nil, NewCorruptionError("failed to read body").WithContext("path", r.path).WithContext("length", txn.Length)
 	}
@@ -331,6 +493,17 @@ func (r *TxnLogReader) ReadTransaction() (*Transaction, error) {
 		return nil, NewCorruptionError("failed to parse transaction").WithError(err).WithContext("path", r.path)
 	}
 
+	// Read end of record marker (1 byte)
+	eor, err := r.archive.ReadByte()
+	if err != nil {
+		return nil, NewCorruptionError("missing end of record marker").WithContext("path", r.path).WithContext("zxid", txn.Zxid)
+	}
+	if eor != EndOfRecord {
+		return nil, NewCorruptionError("invalid end of record marker").
+			WithContext("path", r.path).WithContext("zxid", txn.Zxid).WithContext("marker", eor)
+	}
+
+	r.logicalEnd = r.input.n
 	return txn, nil
 }
 
@@ -363,6 +536,47 @@ func ListTxnLogFiles(dir string) ([]string, error) {
 	return txnlogs, nil
 }
 
+// FindLastTxnAtTime scans the given TxnLog files in order and returns the last
+// transaction committed at or before ts. Returns nil if no transaction was
+// committed at or before ts.
+//
+// Transaction times are the wall clock of the leader that proposed them, which
+// is assumed to only go forward along the ZXIDs: scanning stops at the first
+// transaction after ts. Should a clock step back, the target is the
+// transaction before that step, never one after a later-stamped transaction.
+// A corrupted record before the target is an error, as the transactions after
+// it could not be replayed.
+func FindLastTxnAtTime(paths []string, ts time.Time) (*Transaction, error) {
+	var last *Transaction
+
+	for _, path := range paths {
+		reader, err := OpenTxnLog(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			txn, err := reader.ReadTransaction()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				_ = reader.Close()
+				return nil, err
+			}
+			if txn.Time().After(ts) {
+				_ = reader.Close()
+				return last, nil
+			}
+			last = txn
+		}
+
+		_ = reader.Close()
+	}
+
+	return last, nil
+}
+
 // ValidateTxnLog validates the integrity of a TxnLog file
 func ValidateTxnLog(path string) (*ValidationResult, error) {
 	reader, err := OpenTxnLog(path)
@@ -411,6 +625,8 @@ func ValidateTxnLog(path string) (*ValidationResult, error) {
 		result.Transactions = append(result.Transactions, txn.Zxid)
 	}
 
+	result.LogicalEnd = reader.LogicalEnd()
+
 	return result, nil
 }
 
@@ -440,6 +656,11 @@ func RepairTxnLog(inputPath, outputPath string) (*ValidationResult, error) {
 		return result, err
 	}
 
+	// Keep the compression of the input file
+	if err = recompressLike(inputPath, outputPath); err != nil {
+		return result, err
+	}
+
 	// Validate repaired file
 	repairedResult, err := ValidateTxnLog(outputPath)
 	if err != nil {
@@ -453,10 +674,14 @@ func RepairTxnLog(inputPath, outputPath string) (*ValidationResult, error) {
 	return repairedResult, nil
 }
 
-// CopyTxnLogUntilZxid copies a TxnLog file until the specified ZXID
+// CopyTxnLogUntilZxid copies a TxnLog file until the specified ZXID. A record
+// that cannot be read before maxZxid was copied is an error; records after it
+// are never read.
 func CopyTxnLogUntilZxid(inputPath, outputPath string, maxZxid ZXID) (int, error) {
 	return copyTxnLogWithFilter(inputPath, outputPath, func(zxid ZXID) bool {
 		return zxid <= maxZxid
+	}, func(zxid ZXID) bool {
+		return zxid >= maxZxid
 	})
 }
 
@@ -464,11 +689,13 @@ func CopyTxnLogUntilZxid(inputPath, outputPath string, maxZxid ZXID) (int, error
 func CopyTxnLogFromZxid(inputPath, outputPath string, minZxid ZXID) (int, error) {
 	return copyTxnLogWithFilter(inputPath, outputPath, func(zxid ZXID) bool {
 		return zxid >= minZxid
-	})
+	}, nil)
 }
 
-// copyTxnLogWithFilter copies transactions based on a filter
-func copyTxnLogWithFilter(inputPath, outputPath string, filter func(ZXID) bool) (int, error) {
+// copyTxnLogWithFilter copies transactions based on a filter. The copy stops
+// at the end of the log, or once done reports the last ZXID read completes it;
+// an unreadable record before that is an error.
+func copyTxnLogWithFilter(inputPath, outputPath string, filter, done func(ZXID) bool) (int, error) {
 	reader, err := OpenTxnLog(inputPath)
 	if err != nil {
 		return 0, err
@@ -482,13 +709,19 @@ func copyTxnLogWithFilter(inputPath, outputPath string, filter func(ZXID) bool)
 	defer func() { _ = writer.Close() }()
 
 	copiedCount := 0
+	var lastZxid ZXID // last ZXID read
 
 	// Process transactions one by one
-	for {
+	for done == nil || !done(lastZxid) {
 		txn, err := reader.ReadTransaction()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			break // Encountered corrupted transaction, stop
+			return 0, NewCorruptionError("failed to read transaction").WithError(err).
+				WithContext("input_path", inputPath).WithContext("last_zxid", lastZxid.String())
 		}
+		lastZxid = txn.Zxid
 
 		// Apply filter
 		if !filter(txn.Zxid) {
@@ -7,7 +7,9 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 // Helper function to create a valid TxnLog file for testing
@@ -37,6 +39,7 @@ type testTransaction struct {
 	Zxid      ZXID
 	Timestamp int64
 	Type      int32
+	Body      []byte // Encoded record after the header
 }
 
 func writeTestTransaction(t *testing.T, w io.Writer, txn testTransaction) {
@@ -49,6 +52,7 @@ func writeTestTransaction(t *testing.T, w io.Writer, txn testTransaction) {
 	binary.Write(&body, binary.BigEndian, uint64(txn.Zxid))
 	binary.Write(&body, binary.BigEndian, txn.Timestamp)
 	binary.Write(&body, binary.BigEndian, txn.Type)
+	body.Write(txn.Body)
 
 	bodyBytes := body.Bytes()
 
@@ -59,6 +63,7 @@ func writeTestTransaction(t *testing.T, w io.Writer, txn testTransaction) {
 	binary.Write(w, binary.BigEndian, checksum)
 	binary.Write(w, binary.BigEndian, int32(len(bodyBytes)))
 	w.Write(bodyBytes)
+	w.Write([]byte{EndOfRecord})
 }
 
 func TestOpenTxnLog(t *testing.T) {
@@ -595,6 +600,31 @@ func TestCopyTxnLogUntilZxid(t *testing.T) {
 			t.Errorf("ValidTransactionCount = %d, want 2", result.ValidTransactionCount)
 		}
 	})
+
+	t.Run("corrupted record", func(t *testing.T) {
+		input := filepath.Join(tmpDir, "copy_corrupted.log")
+		output := filepath.Join(tmpDir, "copy_corrupted_output.log")
+
+		createTestTxnLog(t, input, 12345, []testTransaction{
+			{ClientId: 1, Cxid: 1, Zxid: ZXID(0x100), Timestamp: 1000, Type: 1},
+			{ClientId: 2, Cxid: 2, Zxid: ZXID(0x101), Timestamp: 2000, Type: 2},
+			{ClientId: 3, Cxid: 3, Zxid: ZXID(0x102), Timestamp: 3000, Type: 3},
+		})
+		// Cut the record of 0x102
+		info, _ := os.Stat(input)
+		os.Truncate(input, info.Size()-3)
+
+		// Records after the target are never read
+		count, err := CopyTxnLogUntilZxid(input, output, ZXID(0x101))
+		if err != nil || count != 2 {
+			t.Errorf("CopyTxnLogUntilZxid() = %d, %v, want 2 copied before the corrupted record", count, err)
+		}
+
+		_, err = CopyTxnLogUntilZxid(input, output, ZXID(0x102))
+		if err == nil || !strings.Contains(err.Error(), "failed to read transaction") {
+			t.Errorf("CopyTxnLogUntilZxid() error = %v, want the corrupted record before the target reported", err)
+		}
+	})
 }
 
 func TestCopyTxnLogFromZxid(t *testing.T) {
@@ -638,3 +668,172 @@ func TestCopyTxnLogFromZxid(t *testing.T) {
 		}
 	})
 }
+
+func TestFindLastTxnAtTime(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	first := filepath.Join(tmpDir, "log.100")
+	second := filepath.Join(tmpDir, "log.103")
+	createTestTxnLog(t, first, 12345, []testTransaction{
+		{ClientId: 1, Cxid: 1, Zxid: ZXID(0x100), Timestamp: 1000, Type: 1},
+		{ClientId: 1, Cxid: 2, Zxid: ZXID(0x101), Timestamp: 2000, Type: 1},
+		{ClientId: 1, Cxid: 3, Zxid: ZXID(0x102), Timestamp: 3000, Type: 1},
+	})
+	createTestTxnLog(t, second, 12345, []testTransaction{
+		{ClientId: 1, Cxid: 4, Zxid: ZXID(0x103), Timestamp: 4000, Type: 1},
+		{ClientId: 1, Cxid: 5, Zxid: ZXID(0x104), Timestamp: 5000, Type: 1},
+	})
+	paths := []string{first, second}
+
+	tests := []struct {
+		name     string
+		ts       int64
+		wantZxid ZXID
+		wantNil  bool
+	}{
+		{name: "before first transaction", ts: 999, wantNil: true},
+		{name: "exact timestamp", ts: 2000, wantZxid: ZXID(0x101)},
+		{name: "between transactions", ts: 2500, wantZxid: ZXID(0x101)},
+		{name: "across files", ts: 4500, wantZxid: ZXID(0x103)},
+		{name: "after last transaction", ts: 9000, wantZxid: ZXID(0x104)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txn, err := FindLastTxnAtTime(paths, time.UnixMilli(tt.ts))
+			if err != nil {
+				t.Fatalf("FindLastTxnAtTime() error = %v", err)
+			}
+			if tt.wantNil {
+				if txn != nil {
+					t.Errorf("FindLastTxnAtTime() = %v, want nil", txn.Zxid)
+				}
+				return
+			}
+			if txn == nil || txn.Zxid != tt.wantZxid {
+				t.Fatalf("FindLastTxnAtTime() = %v, want %v", txn, tt.wantZxid)
+			}
+			if !txn.Time().Equal(time.UnixMilli(txn.Timestamp)) {
+				t.Errorf("Time() = %v, want %v", txn.Time(), time.UnixMilli(txn.Timestamp))
+			}
+		})
+	}
+
+	t.Run("clock stepping back", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "log.200")
+		createTestTxnLog(t, path, 12345, []testTransaction{
+			{ClientId: 1, Cxid: 1, Zxid: ZXID(0x200), Timestamp: 1000, Type: 1},
+			{ClientId: 1, Cxid: 2, Zxid: ZXID(0x201), Timestamp: 3000, Type: 1},
+			{ClientId: 1, Cxid: 3, Zxid: ZXID(0x202), Timestamp: 2000, Type: 1},
+		})
+
+		txn, err := FindLastTxnAtTime([]string{path}, time.UnixMilli(2500))
+		if err != nil || txn == nil || txn.Zxid != ZXID(0x200) {
+			t.Errorf("FindLastTxnAtTime() = %v, %v, want 0x200 before the later-stamped 0x201", txn, err)
+		}
+	})
+
+	t.Run("corrupted record before the target", func(t *testing.T) {
+		corrupted := filepath.Join(tmpDir, "log.300")
+		createTestTxnLog(t, corrupted, 12345, []testTransaction{
+			{ClientId: 1, Cxid: 1, Zxid: ZXID(0x300), Timestamp: 1000, Type: 1},
+		})
+		f, _ := os.OpenFile(corrupted, os.O_APPEND|os.O_WRONLY, 0644)
+		f.Write([]byte("corrupted data"))
+		f.Close()
+		next := filepath.Join(tmpDir, "log.302")
+		createTestTxnLog(t, next, 12345, []testTransaction{
+			{ClientId: 1, Cxid: 3, Zxid: ZXID(0x302), Timestamp: 3000, Type: 1},
+		})
+
+		if txn, err := FindLastTxnAtTime([]string{corrupted, next}, time.UnixMilli(5000)); err == nil {
+			t.Errorf("FindLastTxnAtTime() = %v, want a corruption error", txn)
+		}
+	})
+}
+
+func TestTxnLogReader_RecordTrailer(t *testing.T) {
+	tmpDir := t.TempDir()
+	txns := []testTransaction{
+		{ClientId: 1, Cxid: 1, Zxid: ZXID(0x100), Timestamp: 1000, Type: 1},
+		{ClientId: 2, Cxid: 2, Zxid: ZXID(0x101), Timestamp: 2000, Type: 2},
+	}
+	// checksum + length + 32 byte body + EOR
+	recordSize := int64(8 + 4 + 32 + 1)
+	logicalEnd := HeaderSize + 2*recordSize
+
+	t.Run("preallocated tail", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "log.100")
+		createTestTxnLog(t, path, 12345, txns)
+		f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		f.Write(make([]byte, 64*1024))
+		f.Close()
+
+		result, err := ValidateTxnLog(path)
+		if err != nil {
+			t.Fatalf("ValidateTxnLog() error = %v", err)
+		}
+		if !result.IsValid || result.ValidTransactionCount != 2 {
+			t.Errorf("result = %+v, want 2 valid transactions", result)
+		}
+		if result.LogicalEnd != logicalEnd {
+			t.Errorf("LogicalEnd = %d, want %d", result.LogicalEnd, logicalEnd)
+		}
+
+		info, err := GetTxnLogInfo(path)
+		if err != nil {
+			t.Fatalf("GetTxnLogInfo() error = %v", err)
+		}
+		if info.Size != logicalEnd+64*1024 || info.LogicalEnd != logicalEnd || info.EndZxid != ZXID(0x101) {
+			t.Errorf("Size = %d, LogicalEnd = %d, EndZxid = %v", info.Size, info.LogicalEnd, info.EndZxid)
+		}
+	})
+
+	t.Run("missing end of record marker", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "log.200")
+		createTestTxnLog(t, path, 12345, txns)
+		os.Truncate(path, logicalEnd-1)
+
+		result, err := ValidateTxnLog(path)
+		if err != nil {
+			t.Fatalf("ValidateTxnLog() error = %v", err)
+		}
+		if result.IsValid || result.ValidTransactionCount != 1 || result.LogicalEnd != HeaderSize+recordSize {
+			t.Errorf("result = %+v, want 1 valid transaction", result)
+		}
+	})
+
+	t.Run("invalid end of record marker", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "log.300")
+		createTestTxnLog(t, path, 12345, txns)
+		data, _ := os.ReadFile(path)
+		data[HeaderSize+recordSize-1] = 0
+		os.WriteFile(path, data, 0644)
+
+		reader, err := OpenTxnLog(path)
+		if err != nil {
+			t.Fatalf("OpenTxnLog() error = %v", err)
+		}
+		defer reader.Close()
+
+		_, err = reader.ReadTransaction()
+		if err == nil || !strings.Contains(err.Error(), "invalid end of record marker") {
+			t.Errorf("ReadTransaction() error = %v, want invalid marker", err)
+		}
+	})
+
+	t.Run("writer appends marker", func(t *testing.T) {
+		input := filepath.Join(tmpDir, "log.400")
+		output := filepath.Join(tmpDir, "log.400.copy")
+		createTestTxnLog(t, input, 12345, txns)
+
+		if _, err := CopyTxnLogUntilZxid(input, output, ZXID(0x101)); err != nil {
+			t.Fatalf("CopyTxnLogUntilZxid() error = %v", err)
+		}
+		want, _ := os.ReadFile(input)
+		got, _ := os.ReadFile(output)
+		if !bytes.Equal(got, want) {
+			t.Errorf("copied txnlog differs from the original:\n got %x\nwant %x", got, want)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package zkfile
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -9,8 +10,10 @@ type ValidationResult struct {
 	IsValid               bool   // Whether it's completely valid
 	ValidTransactionCount int    // Number of valid transactions
 	LastValidPos          int64  // Position of last valid transaction
+	LogicalEnd            int64  // Offset after the last valid record, before any preallocated tail
 	LastValidZxid         ZXID   // ZXID of last valid transaction
 	CorruptionType        string // Type of corruption
+	FailedOffset          int64  // Offset where parsing a snapshot failed, -1 when unknown
 	Transactions          []ZXID // List of all transaction ZXIDs
 }
 
@@ -31,6 +34,17 @@ func GetValidationSummary(results map[string]*ValidationResult) string {
 	return fmt.Sprintf("Total: %d, Valid: %d, Corrupted: %d", totalFiles, validFiles, corruptedFiles)
 }
 
+// errorOffset returns the offset context of an error, -1 when it has none
+func errorOffset(err error) int64 {
+	var backupErr *BackupError
+	if errors.As(err, &backupErr) {
+		if offset, ok := backupErr.Context["offset"].(int64); ok {
+			return offset
+		}
+	}
+	return -1
+}
+
 // ValidateBackupFiles validates all files in the backup directories
 func ValidateBackupFiles(snapshotDir, txnlogDir string) (map[string]*ValidationResult, error) {
 	results := make(map[string]*ValidationResult)
@@ -60,7 +74,7 @@ func ValidateBackupFiles(snapshotDir, txnlogDir string) (map[string]*ValidationR
 		if err == nil {
 			results[snapshot] = &ValidationResult{IsValid: true}
 		} else {
-			results[snapshot] = &ValidationResult{IsValid: false, CorruptionType: err.Error()}
+			results[snapshot] = &ValidationResult{IsValid: false, CorruptionType: err.Error(), FailedOffset: errorOffset(err)}
 		}
 	}
 
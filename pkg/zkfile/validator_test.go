@@ -15,9 +15,12 @@ func TestValidateBackupFiles(t *testing.T) {
 	os.MkdirAll(txnlogDir, 0755)
 
 	t.Run("validate mixed files", func(t *testing.T) {
-		// Create snapshots
-		os.WriteFile(filepath.Join(snapshotDir, "snapshot.100"), []byte("data1"), 0644)
-		os.WriteFile(filepath.Join(snapshotDir, "snapshot.200"), []byte("data2"), 0644)
+		// Create a complete and a partial snapshot
+		createTestSnapshot(t, filepath.Join(snapshotDir, "snapshot.100"), nil)
+		partial := filepath.Join(snapshotDir, "snapshot.200")
+		createTestSnapshot(t, partial, nil)
+		content, _ := os.ReadFile(partial)
+		os.WriteFile(partial, content[:len(content)-9], 0644)
 
 		// Create txnlogs
 		createTestTxnLog(t, filepath.Join(txnlogDir, "log.100"), 12345, []testTransaction{
@@ -34,12 +37,15 @@ func TestValidateBackupFiles(t *testing.T) {
 		}
 
 		// Check snapshot results
-		for path, result := range results {
-			if DetermineFileType(path) == FileTypeSnapshot {
-				if !result.IsValid {
-					t.Errorf("Snapshot %v should be valid", path)
-				}
-			}
+		if result := results[filepath.Join(snapshotDir, "snapshot.100")]; result == nil || !result.IsValid {
+			t.Errorf("snapshot.100 result = %+v, want valid", result)
+		}
+		result := results[partial]
+		if result == nil || result.IsValid {
+			t.Fatalf("snapshot.200 result = %+v, want invalid", result)
+		}
+		if result.FailedOffset != int64(len(content)-sealSize) {
+			t.Errorf("FailedOffset = %d, want %d", result.FailedOffset, len(content)-sealSize)
 		}
 	})
 }
@@ -40,6 +40,16 @@ func (z ZXID) Hex() string {
 	return fmt.Sprintf("%x", uint64(z))
 }
 
+// Epoch returns the leader epoch (high 32 bits) of the ZXID
+func (z ZXID) Epoch() uint32 {
+	return uint32(uint64(z) >> 32)
+}
+
+// Counter returns the transaction counter (low 32 bits) of the ZXID
+func (z ZXID) Counter() uint32 {
+	return uint32(uint64(z) & 0xffffffff)
+}
+
 // Compare compares two ZXIDs
 // Returns: -1 (z < other), 0 (z == other), 1 (z > other)
 func (z ZXID) Compare(other ZXID) int {
@@ -94,6 +104,18 @@ func FormatZxidFileName(fileType FileType, zxid ZXID) string {
 	}
 }
 
+// ParseZXID parses a hexadecimal ZXID, with or without the 0x prefix
+func ParseZXID(s string) (ZXID, error) {
+	str := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "0x"), "0X")
+
+	zxid, err := strconv.ParseUint(str, 16, 64)
+	if err != nil {
+		return 0, NewUserError("failed to parse zxid").WithError(err).WithContext("zxid_str", s)
+	}
+
+	return ZXID(zxid), nil
+}
+
 // ParseZxidFromFileName parses ZXID from filename
 // Supported formats:
 // - log.100000000
@@ -323,3 +323,90 @@ func TestFormatZxidFileName(t *testing.T) {
 		})
 	}
 }
+
+func TestZXID_EpochCounter(t *testing.T) {
+	tests := []struct {
+		name        string
+		zxid        ZXID
+		wantEpoch   uint32
+		wantCounter uint32
+	}{
+		{
+			name:        "zero",
+			zxid:        ZXID(0),
+			wantEpoch:   0,
+			wantCounter: 0,
+		},
+		{
+			name:        "counter only",
+			zxid:        ZXID(0x100),
+			wantEpoch:   0,
+			wantCounter: 0x100,
+		},
+		{
+			name:        "epoch and counter",
+			zxid:        ZXID(0x500000001),
+			wantEpoch:   5,
+			wantCounter: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.zxid.Epoch(); got != tt.wantEpoch {
+				t.Errorf("ZXID.Epoch() = %v, want %v", got, tt.wantEpoch)
+			}
+			if got := tt.zxid.Counter(); got != tt.wantCounter {
+				t.Errorf("ZXID.Counter() = %v, want %v", got, tt.wantCounter)
+			}
+		})
+	}
+}
+
+func TestParseZXID(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    ZXID
+		wantErr bool
+	}{
+		{
+			name:  "with prefix",
+			input: "0x500000001",
+			want:  ZXID(0x500000001),
+		},
+		{
+			name:  "without prefix",
+			input: "500000001",
+			want:  ZXID(0x500000001),
+		},
+		{
+			name:  "upper case prefix",
+			input: "0X1A",
+			want:  ZXID(0x1a),
+		},
+		{
+			name:    "empty",
+			input:   "",
+			wantErr: true,
+		},
+		{
+			name:    "not hex",
+			input:   "0xzz",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseZXID(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseZXID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseZXID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}